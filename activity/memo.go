@@ -0,0 +1,13 @@
+package activity
+
+import (
+	"context"
+
+	"github.com/paveliak/go-workflows/internal/activity"
+)
+
+// Memo returns the memo of the workflow instance this activity is executed for. Changes to the returned map are not
+// persisted.
+func Memo(ctx context.Context) map[string]string {
+	return activity.GetActivityState(ctx).Memo.Clone()
+}
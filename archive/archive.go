@@ -0,0 +1,25 @@
+package archive
+
+import (
+	"context"
+	"errors"
+
+	"github.com/paveliak/go-workflows/internal/core"
+	"github.com/paveliak/go-workflows/internal/history"
+)
+
+var ErrInstanceNotFound = errors.New("archived workflow instance not found")
+
+// Archiver stores the history of finished workflow instances outside of the backend.
+type Archiver interface {
+	// ArchiveWorkflowInstance stores the full history of the given workflow instance. It is called when an
+	// instance finishes and again before it is removed from the backend, so implementations need to be idempotent.
+	ArchiveWorkflowInstance(ctx context.Context, instance *core.WorkflowInstance, h []history.Event) error
+}
+
+// Reader reads back histories of archived workflow instances.
+type Reader interface {
+	// GetWorkflowInstanceHistory returns the archived history for the given instance, or ErrInstanceNotFound
+	// if the instance has not been archived.
+	GetWorkflowInstanceHistory(ctx context.Context, instanceID string) ([]history.Event, error)
+}
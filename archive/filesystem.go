@@ -0,0 +1,124 @@
+package archive
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/paveliak/go-workflows/internal/core"
+	"github.com/paveliak/go-workflows/internal/history"
+)
+
+const fileExtension = ".jsonl.gz"
+
+type filesystemArchiver struct {
+	dir string
+}
+
+var _ Archiver = (*filesystemArchiver)(nil)
+var _ Reader = (*filesystemArchiver)(nil)
+
+// NewFilesystemArchiver returns an archiver that writes one gzip compressed file per workflow instance into dir.
+// Every line in the file is a JSON serialized history event.
+func NewFilesystemArchiver(dir string) (*filesystemArchiver, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating archive directory: %w", err)
+	}
+
+	return &filesystemArchiver{
+		dir: dir,
+	}, nil
+}
+
+func (fa *filesystemArchiver) ArchiveWorkflowInstance(ctx context.Context, instance *core.WorkflowInstance, h []history.Event) error {
+	// Write to a temporary file first, so that readers never see a partially written archive
+	f, err := os.CreateTemp(fa.dir, ".archive-*")
+	if err != nil {
+		return fmt.Errorf("creating archive file: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	if err := writeEvents(f, h); err != nil {
+		f.Close()
+		return fmt.Errorf("writing archive: %w", err)
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("closing archive file: %w", err)
+	}
+
+	if err := os.Rename(f.Name(), fa.path(instance.InstanceID)); err != nil {
+		return fmt.Errorf("moving archive file: %w", err)
+	}
+
+	return nil
+}
+
+func (fa *filesystemArchiver) GetWorkflowInstanceHistory(ctx context.Context, instanceID string) ([]history.Event, error) {
+	f, err := os.Open(fa.path(instanceID))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrInstanceNotFound
+		}
+
+		return nil, fmt.Errorf("opening archive file: %w", err)
+	}
+	defer f.Close()
+
+	h, err := readEvents(f)
+	if err != nil {
+		return nil, fmt.Errorf("reading archive: %w", err)
+	}
+
+	return h, nil
+}
+
+func (fa *filesystemArchiver) path(instanceID string) string {
+	// Instance ids are user provided, make sure they can't escape the archive directory
+	return filepath.Join(fa.dir, url.PathEscape(instanceID)+fileExtension)
+}
+
+func writeEvents(w io.Writer, h []history.Event) error {
+	gw := gzip.NewWriter(w)
+
+	enc := json.NewEncoder(gw)
+	for _, event := range h {
+		if err := enc.Encode(event); err != nil {
+			return fmt.Errorf("encoding event: %w", err)
+		}
+	}
+
+	return gw.Close()
+}
+
+func readEvents(r io.Reader) ([]history.Event, error) {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gr.Close()
+
+	h := make([]history.Event, 0)
+
+	dec := json.NewDecoder(gr)
+	for {
+		var event history.Event
+		if err := dec.Decode(&event); err != nil {
+			if err == io.EOF {
+				break
+			}
+
+			return nil, fmt.Errorf("decoding event: %w", err)
+		}
+
+		h = append(h, event)
+	}
+
+	return h, nil
+}
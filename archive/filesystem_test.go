@@ -0,0 +1,54 @@
+package archive
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/paveliak/go-workflows/internal/core"
+	"github.com/paveliak/go-workflows/internal/history"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_FilesystemArchiver_Roundtrip(t *testing.T) {
+	ctx := context.Background()
+
+	a, err := NewFilesystemArchiver(t.TempDir())
+	require.NoError(t, err)
+
+	instance := core.NewWorkflowInstance("some/instance", uuid.NewString())
+	h := []history.Event{
+		history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowTaskStarted, &history.WorkflowTaskStartedAttributes{}),
+		history.NewHistoryEvent(2, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{
+			Name: "my-workflow",
+		}),
+		history.NewHistoryEvent(3, time.Now(), history.EventType_WorkflowExecutionFinished, &history.ExecutionCompletedAttributes{
+			Error: "some error",
+		}),
+	}
+
+	require.NoError(t, a.ArchiveWorkflowInstance(ctx, instance, h))
+
+	// Archiving again overwrites the previous archive
+	require.NoError(t, a.ArchiveWorkflowInstance(ctx, instance, h))
+
+	ah, err := a.GetWorkflowInstanceHistory(ctx, instance.InstanceID)
+	require.NoError(t, err)
+	require.Len(t, ah, len(h))
+
+	for i, event := range h {
+		require.Equal(t, event.ID, ah[i].ID)
+		require.Equal(t, event.SequenceID, ah[i].SequenceID)
+		require.Equal(t, event.Type, ah[i].Type)
+		require.Equal(t, event.Attributes, ah[i].Attributes)
+	}
+}
+
+func Test_FilesystemArchiver_NotFound(t *testing.T) {
+	a, err := NewFilesystemArchiver(t.TempDir())
+	require.NoError(t, err)
+
+	_, err = a.GetWorkflowInstanceHistory(context.Background(), "does-not-exist")
+	require.ErrorIs(t, err, ErrInstanceNotFound)
+}
@@ -3,6 +3,7 @@ package backend
 import (
 	"context"
 	"errors"
+	"time"
 
 	core "github.com/paveliak/go-workflows/internal/core"
 	"github.com/paveliak/go-workflows/internal/history"
@@ -15,6 +16,14 @@ import (
 
 var ErrInstanceNotFound = errors.New("workflow instance not found")
 var ErrInstanceAlreadyExists = errors.New("workflow instance already exists")
+var ErrInstanceNotFinished = errors.New("workflow instance is not finished")
+var ErrInstanceNotSuspended = errors.New("workflow instance is not suspended")
+var ErrInstanceNotActive = errors.New("workflow instance is not active")
+var ErrInstanceFinished = errors.New("workflow instance is already finished")
+
+// ErrWorkflowTaskDiscarded is returned by CompleteWorkflowTask when the workflow instance has been reset while the
+// task was executed. The result of the task is dropped, the new execution picks up the pending events.
+var ErrWorkflowTaskDiscarded = errors.New("workflow instance has been reset, discarding workflow task")
 
 const TracerName = "go-workflow"
 
@@ -26,13 +35,33 @@ type Backend interface {
 	// CancelWorkflowInstance cancels a running workflow instance
 	CancelWorkflowInstance(ctx context.Context, instance *workflow.Instance, cancelEvent *history.Event) error
 
+	// TerminateWorkflowInstance adds the given termination event to a workflow instance. Suspended instances are
+	// made active again so the termination is processed. Returns ErrInstanceFinished if the instance is already
+	// finished.
+	TerminateWorkflowInstance(ctx context.Context, instance *workflow.Instance, terminateEvent *history.Event) error
+
 	// GetWorkflowInstanceState returns the state of the given workflow instance
 	GetWorkflowInstanceState(ctx context.Context, instance *workflow.Instance) (core.WorkflowInstanceState, error)
 
+	// ListWorkflowInstances returns workflow instances matching the given filter, newest first
+	ListWorkflowInstances(ctx context.Context, filter *WorkflowInstanceFilter) ([]*WorkflowInstanceRef, error)
+
 	// GetWorkflowInstanceHistory returns the workflow history for the given instance. When lastSequenceID
 	// is given, only events after that event are returned. Otherwise the full history is returned.
 	GetWorkflowInstanceHistory(ctx context.Context, instance *workflow.Instance, lastSequenceID *int64) ([]history.Event, error)
 
+	// RemoveWorkflowInstance removes a finished workflow instance and its history from the backend. If an
+	// archiver is configured, the history is archived before it is removed.
+	RemoveWorkflowInstance(ctx context.Context, instance *workflow.Instance) error
+
+	// ResetWorkflowInstance starts a new execution with the given executionID for an existing workflow instance. History
+	// events with a sequence id greater than or equal to toSequenceID are removed, as are all pending events, activities,
+	// and timers of the previous execution. activityEvents and timerEvents are scheduled for the new execution and
+	// pendingEvents are added as new events. Suspended instances stay suspended, all others become active.
+	ResetWorkflowInstance(
+		ctx context.Context, instance *workflow.Instance, executionID string, toSequenceID int64,
+		activityEvents, timerEvents, pendingEvents []history.Event) error
+
 	// SignalWorkflow signals a running workflow instance
 	SignalWorkflow(ctx context.Context, instanceID string, event history.Event) error
 
@@ -51,6 +80,33 @@ type Backend interface {
 		ctx context.Context, task *task.Workflow, instance *workflow.Instance, state core.WorkflowInstanceState,
 		executedEvents, activityEvents, timerEvents []history.Event, workflowEvents []history.WorkflowEvent) error
 
+	// AbandonWorkflowTask records lastError for a workflow task retrieved using GetWorkflowTask without checkpointing
+	// it. The history of the workflow instance is left untouched and the task is retried once its lock expires.
+	AbandonWorkflowTask(ctx context.Context, task *task.Workflow, lastError string) error
+
+	// ReleaseWorkflowTask releases the lock of a workflow task without completing it, so that any worker can pick it up
+	// again immediately
+	ReleaseWorkflowTask(ctx context.Context, taskID string, instance *core.WorkflowInstance) error
+
+	// SuspendWorkflowTask suspends the workflow instance of a locked workflow task instead of completing the task, for
+	// example after too many failed attempts. The task's events are kept as pending events, and no workflow tasks are
+	// returned for the instance until it is resumed. reason is recorded as the instance's last error.
+	SuspendWorkflowTask(ctx context.Context, task *task.Workflow, reason string) error
+
+	// SuspendWorkflowInstance suspends an active workflow instance. New events for the instance, including fired
+	// timers, are kept as pending events and no workflow tasks are returned for it until it is resumed. Returns
+	// ErrInstanceNotActive if the instance is not active.
+	SuspendWorkflowInstance(ctx context.Context, instance *workflow.Instance) error
+
+	// ResumeWorkflowInstance resumes a suspended workflow instance. Workflow tasks for its pending events are handed out
+	// again, with a reset attempt counter. Returns ErrInstanceNotSuspended if the instance is not suspended.
+	ResumeWorkflowInstance(ctx context.Context, instance *workflow.Instance) error
+
+	// ReleaseStickiness releases the affinity of a workflow instance to this worker, so that its next workflow task
+	// can be picked up by any worker without waiting for the sticky timeout. If instance is nil, the affinity of all
+	// workflow instances to this worker is released. Locks of workflow tasks are not affected.
+	ReleaseStickiness(ctx context.Context, instance *core.WorkflowInstance) error
+
 	// GetActivityTask returns a pending activity task or nil if there are no pending activities
 	GetActivityTask(ctx context.Context) (*task.Activity, error)
 
@@ -60,6 +116,10 @@ type Backend interface {
 	// ExtendActivityTask extends the lock of an activity task
 	ExtendActivityTask(ctx context.Context, activityID string) error
 
+	// ReleaseActivityTask releases the lock of an activity task without completing it, so that any worker can pick it
+	// up again after delay. A zero delay makes the task available immediately.
+	ReleaseActivityTask(ctx context.Context, activityID string, delay time.Duration) error
+
 	// Logger returns the configured logger for the backend
 	Logger() log.Logger
 
@@ -69,3 +129,23 @@ type Backend interface {
 	// Metrics returns the configured metrics client for the backend
 	Metrics() metrics.Client
 }
+
+// RateLimiter is implemented by backends that can share rate limit budgets between all workers using the backend
+type RateLimiter interface {
+	// TakeRateLimitToken takes a token from the token bucket identified by key, which is refilled with rate tokens
+	// per second up to burst tokens. If the bucket is empty, no token is taken and the time to wait before trying
+	// again is returned.
+	TakeRateLimitToken(ctx context.Context, key string, rate float64, burst int) (time.Duration, error)
+}
+
+// Importer is implemented by backends that can create workflow instances with an existing history, for example one
+// exported from another backend
+type Importer interface {
+	// ImportWorkflowInstance creates a workflow instance in the given state with the history h, which has to contain
+	// the WorkflowExecutionStarted event. Instances imported in a finished state are completed at the time of their
+	// last event. activityEvents and timerEvents are scheduled for the instance. Returns ErrInstanceAlreadyExists if
+	// an instance with the same id exists.
+	ImportWorkflowInstance(
+		ctx context.Context, instance *workflow.Instance, state core.WorkflowInstanceState,
+		h, activityEvents, timerEvents []history.Event) error
+}
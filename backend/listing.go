@@ -0,0 +1,97 @@
+package backend
+
+import (
+	"time"
+
+	"github.com/paveliak/go-workflows/internal/core"
+)
+
+// DefaultListCount is the number of instances returned by ListWorkflowInstances if the filter does not set Count
+const DefaultListCount = 25
+
+// WorkflowInstanceFilter selects workflow instances in ListWorkflowInstances. Instances have to match all set
+// fields, zero values are ignored.
+type WorkflowInstanceFilter struct {
+	// WorkflowName matches instances of the workflow with the given name
+	WorkflowName string
+
+	// States matches instances in any of the given states
+	States []core.WorkflowInstanceState
+
+	// CreatedAfter and CreatedBefore match instances created in the given range, including CreatedAfter
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+
+	// CompletedAfter and CompletedBefore match instances completed in the given range, including CompletedAfter.
+	// Setting either excludes instances that have not completed.
+	CompletedAfter  time.Time
+	CompletedBefore time.Time
+
+	// SearchAttributes matches instances with all the given search attribute values
+	SearchAttributes map[string]interface{}
+
+	// AfterInstanceID continues a previous listing after the instance with the given id
+	AfterInstanceID string
+
+	// Count is the maximum number of instances to return. Defaults to DefaultListCount.
+	Count int
+}
+
+// Limit returns the maximum number of instances to return
+func (f *WorkflowInstanceFilter) Limit() int {
+	if f.Count <= 0 {
+		return DefaultListCount
+	}
+
+	return f.Count
+}
+
+// Matches returns true if an instance with the given properties matches the filter. Search attributes are not
+// checked.
+func (f *WorkflowInstanceFilter) Matches(workflowName string, state core.WorkflowInstanceState, createdAt time.Time, completedAt *time.Time) bool {
+	if f.WorkflowName != "" && f.WorkflowName != workflowName {
+		return false
+	}
+
+	if len(f.States) > 0 {
+		found := false
+		for _, s := range f.States {
+			if s == state {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	if (!f.CreatedAfter.IsZero() && createdAt.Before(f.CreatedAfter)) ||
+		(!f.CreatedBefore.IsZero() && !createdAt.Before(f.CreatedBefore)) {
+		return false
+	}
+
+	if !f.CompletedAfter.IsZero() || !f.CompletedBefore.IsZero() {
+		if completedAt == nil ||
+			(!f.CompletedAfter.IsZero() && completedAt.Before(f.CompletedAfter)) ||
+			(!f.CompletedBefore.IsZero() && !completedAt.Before(f.CompletedBefore)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// WorkflowInstanceRef describes a workflow instance returned by ListWorkflowInstances
+type WorkflowInstanceRef struct {
+	Instance     *core.WorkflowInstance     `json:"instance,omitempty"`
+	WorkflowName string                     `json:"workflow_name,omitempty"`
+	State        core.WorkflowInstanceState `json:"state"`
+	CreatedAt    time.Time                  `json:"created_at,omitempty"`
+	CompletedAt  *time.Time                 `json:"completed_at,omitempty"`
+
+	SearchAttributes map[string]interface{} `json:"search_attributes,omitempty"`
+
+	Memo core.Memo `json:"memo,omitempty"`
+}
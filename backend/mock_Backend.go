@@ -16,6 +16,8 @@ import (
 
 	task "github.com/paveliak/go-workflows/internal/task"
 
+	time "time"
+
 	trace "go.opentelemetry.io/otel/trace"
 )
 
@@ -94,6 +96,62 @@ func (_m *MockBackend) ExtendActivityTask(ctx context.Context, activityID string
 	return r0
 }
 
+// AbandonWorkflowTask provides a mock function with given fields: ctx, _a1, lastError
+func (_m *MockBackend) AbandonWorkflowTask(ctx context.Context, _a1 *task.Workflow, lastError string) error {
+	ret := _m.Called(ctx, _a1, lastError)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *task.Workflow, string) error); ok {
+		r0 = rf(ctx, _a1, lastError)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SuspendWorkflowTask provides a mock function with given fields: ctx, _a1, reason
+func (_m *MockBackend) SuspendWorkflowTask(ctx context.Context, _a1 *task.Workflow, reason string) error {
+	ret := _m.Called(ctx, _a1, reason)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *task.Workflow, string) error); ok {
+		r0 = rf(ctx, _a1, reason)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SuspendWorkflowInstance provides a mock function with given fields: ctx, instance
+func (_m *MockBackend) SuspendWorkflowInstance(ctx context.Context, instance *core.WorkflowInstance) error {
+	ret := _m.Called(ctx, instance)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *core.WorkflowInstance) error); ok {
+		r0 = rf(ctx, instance)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ResumeWorkflowInstance provides a mock function with given fields: ctx, instance
+func (_m *MockBackend) ResumeWorkflowInstance(ctx context.Context, instance *core.WorkflowInstance) error {
+	ret := _m.Called(ctx, instance)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *core.WorkflowInstance) error); ok {
+		r0 = rf(ctx, instance)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // ExtendWorkflowTask provides a mock function with given fields: ctx, taskID, instance
 func (_m *MockBackend) ExtendWorkflowTask(ctx context.Context, taskID string, instance *core.WorkflowInstance) error {
 	ret := _m.Called(ctx, taskID, instance)
@@ -154,6 +212,29 @@ func (_m *MockBackend) GetWorkflowInstanceHistory(ctx context.Context, instance
 	return r0, r1
 }
 
+// ListWorkflowInstances provides a mock function with given fields: ctx, filter
+func (_m *MockBackend) ListWorkflowInstances(ctx context.Context, filter *WorkflowInstanceFilter) ([]*WorkflowInstanceRef, error) {
+	ret := _m.Called(ctx, filter)
+
+	var r0 []*WorkflowInstanceRef
+	if rf, ok := ret.Get(0).(func(context.Context, *WorkflowInstanceFilter) []*WorkflowInstanceRef); ok {
+		r0 = rf(ctx, filter)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*WorkflowInstanceRef)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *WorkflowInstanceFilter) error); ok {
+		r1 = rf(ctx, filter)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetWorkflowInstanceState provides a mock function with given fields: ctx, instance
 func (_m *MockBackend) GetWorkflowInstanceState(ctx context.Context, instance *core.WorkflowInstance) (core.WorkflowInstanceState, error) {
 	ret := _m.Called(ctx, instance)
@@ -230,6 +311,76 @@ func (_m *MockBackend) Metrics() metrics.Client {
 	return r0
 }
 
+// ReleaseActivityTask provides a mock function with given fields: ctx, activityID, delay
+func (_m *MockBackend) ReleaseActivityTask(ctx context.Context, activityID string, delay time.Duration) error {
+	ret := _m.Called(ctx, activityID, delay)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Duration) error); ok {
+		r0 = rf(ctx, activityID, delay)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ReleaseStickiness provides a mock function with given fields: ctx, instance
+func (_m *MockBackend) ReleaseStickiness(ctx context.Context, instance *core.WorkflowInstance) error {
+	ret := _m.Called(ctx, instance)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *core.WorkflowInstance) error); ok {
+		r0 = rf(ctx, instance)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ReleaseWorkflowTask provides a mock function with given fields: ctx, taskID, instance
+func (_m *MockBackend) ReleaseWorkflowTask(ctx context.Context, taskID string, instance *core.WorkflowInstance) error {
+	ret := _m.Called(ctx, taskID, instance)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, *core.WorkflowInstance) error); ok {
+		r0 = rf(ctx, taskID, instance)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RemoveWorkflowInstance provides a mock function with given fields: ctx, instance
+func (_m *MockBackend) RemoveWorkflowInstance(ctx context.Context, instance *core.WorkflowInstance) error {
+	ret := _m.Called(ctx, instance)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *core.WorkflowInstance) error); ok {
+		r0 = rf(ctx, instance)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ResetWorkflowInstance provides a mock function with given fields: ctx, instance, executionID, toSequenceID, activityEvents, timerEvents, pendingEvents
+func (_m *MockBackend) ResetWorkflowInstance(ctx context.Context, instance *core.WorkflowInstance, executionID string, toSequenceID int64, activityEvents []history.Event, timerEvents []history.Event, pendingEvents []history.Event) error {
+	ret := _m.Called(ctx, instance, executionID, toSequenceID, activityEvents, timerEvents, pendingEvents)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *core.WorkflowInstance, string, int64, []history.Event, []history.Event, []history.Event) error); ok {
+		r0 = rf(ctx, instance, executionID, toSequenceID, activityEvents, timerEvents, pendingEvents)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // SignalWorkflow provides a mock function with given fields: ctx, instanceID, event
 func (_m *MockBackend) SignalWorkflow(ctx context.Context, instanceID string, event history.Event) error {
 	ret := _m.Called(ctx, instanceID, event)
@@ -244,6 +395,20 @@ func (_m *MockBackend) SignalWorkflow(ctx context.Context, instanceID string, ev
 	return r0
 }
 
+// TerminateWorkflowInstance provides a mock function with given fields: ctx, instance, terminateEvent
+func (_m *MockBackend) TerminateWorkflowInstance(ctx context.Context, instance *core.WorkflowInstance, terminateEvent *history.Event) error {
+	ret := _m.Called(ctx, instance, terminateEvent)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *core.WorkflowInstance, *history.Event) error); ok {
+		r0 = rf(ctx, instance, terminateEvent)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // Tracer provides a mock function with given fields:
 func (_m *MockBackend) Tracer() trace.Tracer {
 	ret := _m.Called()
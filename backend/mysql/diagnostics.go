@@ -3,6 +3,8 @@ package mysql
 import (
 	"context"
 	"database/sql"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/paveliak/go-workflows/diag"
@@ -10,67 +12,83 @@ import (
 )
 
 var _ diag.Backend = (*mysqlBackend)(nil)
+var _ diag.StatsBackend = (*mysqlBackend)(nil)
 
-func (mb *mysqlBackend) GetWorkflowInstances(ctx context.Context, afterInstanceID string, count int) ([]*diag.WorkflowInstanceRef, error) {
-	var err error
+// instanceRefColumns are the columns of the instances table read by scanInstanceRef
+const instanceRefColumns = `i.instance_id, i.execution_id, i.parent_instance_id, i.parent_schedule_event_id, i.workflow_name,
+	i.created_at, i.completed_at, i.state, i.last_error, i.memo`
+
+func (mb *mysqlBackend) GetWorkflowInstances(ctx context.Context, afterInstanceID string, count int, states ...core.WorkflowInstanceState) ([]*diag.WorkflowInstanceRef, error) {
+	return mb.QueryWorkflowInstances(ctx, &diag.WorkflowInstanceQuery{
+		States:          states,
+		AfterInstanceID: afterInstanceID,
+		Count:           count,
+	})
+}
+
+func (mb *mysqlBackend) QueryWorkflowInstances(ctx context.Context, query *diag.WorkflowInstanceQuery) ([]*diag.WorkflowInstanceRef, error) {
 	tx, err := mb.db.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
 	defer tx.Rollback()
 
-	var rows *sql.Rows
-	if afterInstanceID != "" {
-		rows, err = tx.QueryContext(
-			ctx,
-			`SELECT i.instance_id, i.execution_id, i.created_at, i.completed_at
-			FROM instances i
-			INNER JOIN (SELECT instance_id, created_at FROM instances WHERE id = ?) ii
-				ON i.created_at < ii.created_at OR (i.created_at = ii.created_at AND i.instance_id < ii.instance_id)
-			ORDER BY i.created_at DESC, i.instance_id DESC
-			LIMIT ?`,
-			afterInstanceID,
-			count,
-		)
-	} else {
-		rows, err = tx.QueryContext(
-			ctx,
-			`SELECT i.instance_id, i.execution_id, i.created_at, i.completed_at
-			FROM instances i
-			ORDER BY i.created_at DESC, i.instance_id DESC
-			LIMIT ?`,
-			count,
-		)
-	}
-	if err != nil {
-		return nil, err
+	q := `SELECT ` + instanceRefColumns + `
+		FROM instances i`
+	args := []interface{}{}
+
+	if query.AfterInstanceID != "" {
+		q += `
+		INNER JOIN (SELECT instance_id, created_at FROM instances WHERE instance_id = ?) ii
+			ON i.created_at < ii.created_at OR (i.created_at = ii.created_at AND i.instance_id < ii.instance_id)`
+		args = append(args, query.AfterInstanceID)
 	}
 
-	var instances []*diag.WorkflowInstanceRef
+	where := []string{}
 
-	for rows.Next() {
-		var id, executionID string
-		var createdAt time.Time
-		var completedAt *time.Time
-		err = rows.Scan(&id, &executionID, &createdAt, &completedAt)
-		if err != nil {
-			return nil, err
+	if len(query.States) > 0 {
+		where = append(where, "i.state IN (?"+strings.Repeat(", ?", len(query.States)-1)+")")
+		for _, state := range query.States {
+			args = append(args, state)
 		}
+	}
 
-		var state core.WorkflowInstanceState
-		if completedAt != nil {
-			state = core.WorkflowInstanceStateFinished
+	if query.WorkflowName != "" {
+		where = append(where, "i.workflow_name = ?")
+		args = append(args, query.WorkflowName)
+	}
+
+	if query.InstanceIDPrefix != "" {
+		where = append(where, "i.instance_id LIKE ?")
+		args = append(args, likePrefix(query.InstanceIDPrefix))
+	}
+
+	for _, r := range []struct {
+		condition string
+		t         time.Time
+	}{
+		{"i.created_at >= ?", query.CreatedAfter},
+		{"i.created_at < ?", query.CreatedBefore},
+		{"i.completed_at >= ?", query.CompletedAfter},
+		{"i.completed_at < ?", query.CompletedBefore},
+	} {
+		if !r.t.IsZero() {
+			where = append(where, r.condition)
+			args = append(args, r.t)
 		}
+	}
 
-		instances = append(instances, &diag.WorkflowInstanceRef{
-			Instance:    core.NewWorkflowInstance(id, executionID),
-			CreatedAt:   createdAt,
-			CompletedAt: completedAt,
-			State:       state,
-		})
+	if len(where) > 0 {
+		q += `
+		WHERE ` + strings.Join(where, " AND ")
 	}
 
-	return instances, nil
+	q += `
+		ORDER BY i.created_at DESC, i.instance_id DESC
+		LIMIT ?`
+	args = append(args, query.Limit())
+
+	return queryInstanceRefs(ctx, tx, q, args...)
 }
 
 func (mb *mysqlBackend) GetWorkflowInstance(ctx context.Context, instanceID string) (*diag.WorkflowInstanceRef, error) {
@@ -80,13 +98,9 @@ func (mb *mysqlBackend) GetWorkflowInstance(ctx context.Context, instanceID stri
 	}
 	defer tx.Rollback()
 
-	res := tx.QueryRowContext(ctx, "SELECT instance_id, execution_id, created_at, completed_at FROM instances WHERE instance_id = ?", instanceID)
+	res := tx.QueryRowContext(ctx, "SELECT "+instanceRefColumns+" FROM instances i WHERE i.instance_id = ?", instanceID)
 
-	var id, executionID string
-	var createdAt time.Time
-	var completedAt *time.Time
-
-	err = res.Scan(&id, &executionID, &createdAt, &completedAt)
+	ref, err := scanInstanceRef(res)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -95,15 +109,169 @@ func (mb *mysqlBackend) GetWorkflowInstance(ctx context.Context, instanceID stri
 		return nil, err
 	}
 
+	return ref, nil
+}
+
+func (mb *mysqlBackend) GetWorkflowInstanceChildren(ctx context.Context, instanceID string) ([]*diag.WorkflowInstanceRef, error) {
+	tx, err := mb.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	return queryInstanceRefs(
+		ctx,
+		tx,
+		"SELECT "+instanceRefColumns+" FROM instances i WHERE i.parent_instance_id = ? ORDER BY i.created_at, i.instance_id",
+		instanceID,
+	)
+}
+
+func (mb *mysqlBackend) GetStats(ctx context.Context, since time.Time) (*diag.Stats, error) {
+	tx, err := mb.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	stats := &diag.Stats{}
+
+	rows, err := tx.QueryContext(ctx, "SELECT COALESCE(workflow_name, ''), state, COUNT(*) FROM instances GROUP BY workflow_name, state")
+	if err != nil {
+		return nil, fmt.Errorf("counting instances: %w", err)
+	}
+
+	for rows.Next() {
+		var workflowName string
+		var state core.WorkflowInstanceState
+		var count int64
+		if err := rows.Scan(&workflowName, &state, &count); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scanning instance count: %w", err)
+		}
+
+		stats.AddInstances(workflowName, state, count)
+	}
+
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("counting instances: %w", err)
+	}
+
+	rows, err = tx.QueryContext(ctx, "SELECT state, COUNT(*) FROM instances WHERE completed_at >= ? GROUP BY state", since)
+	if err != nil {
+		return nil, fmt.Errorf("counting finished instances: %w", err)
+	}
+
+	for rows.Next() {
+		var state core.WorkflowInstanceState
+		var count int64
+		if err := rows.Scan(&state, &count); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scanning finished instance count: %w", err)
+		}
+
+		stats.AddRecentlyFinished(state, count)
+	}
+
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("counting finished instances: %w", err)
+	}
+
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM activities").Scan(&stats.ActivityQueueDepth); err != nil {
+		return nil, fmt.Errorf("counting activities: %w", err)
+	}
+
+	// Pending events of active instances are workflow tasks once they are visible
+	for _, q := range []struct {
+		query string
+		args  []interface{}
+	}{
+		{
+			`SELECT pe.timestamp FROM pending_events pe INNER JOIN instances i ON i.instance_id = pe.instance_id
+				WHERE i.state = ? AND (pe.visible_at IS NULL OR pe.visible_at <= ?)
+				ORDER BY pe.timestamp LIMIT 1`,
+			[]interface{}{core.WorkflowInstanceStateActive, time.Now()},
+		},
+		{
+			"SELECT timestamp FROM activities ORDER BY timestamp LIMIT 1",
+			nil,
+		},
+	} {
+		var queuedAt time.Time
+		if err := tx.QueryRowContext(ctx, q.query, q.args...).Scan(&queuedAt); err != nil {
+			if err == sql.ErrNoRows {
+				continue
+			}
+
+			return nil, fmt.Errorf("getting oldest pending task: %w", err)
+		}
+
+		stats.AddPendingTask(queuedAt)
+	}
+
+	return stats, nil
+}
+
+func queryInstanceRefs(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) ([]*diag.WorkflowInstanceRef, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var instances []*diag.WorkflowInstanceRef
+
+	for rows.Next() {
+		ref, err := scanInstanceRef(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		instances = append(instances, ref)
+	}
+
+	return instances, rows.Err()
+}
+
+func scanInstanceRef(row interface{ Scan(dest ...any) error }) (*diag.WorkflowInstanceRef, error) {
+	var id, executionID string
+	var parentInstanceID *string
+	var parentEventID *int64
+	var workflowName sql.NullString
+	var createdAt time.Time
+	var completedAt *time.Time
 	var state core.WorkflowInstanceState
-	if completedAt != nil {
-		state = core.WorkflowInstanceStateFinished
+	var lastError, memoJson sql.NullString
+
+	if err := row.Scan(&id, &executionID, &parentInstanceID, &parentEventID, &workflowName, &createdAt, &completedAt, &state, &lastError, &memoJson); err != nil {
+		return nil, err
+	}
+
+	instance := core.NewWorkflowInstance(id, executionID)
+	if parentInstanceID != nil {
+		instance = core.NewSubWorkflowInstance(id, executionID, *parentInstanceID, *parentEventID)
+	}
+
+	memo, err := unmarshalMemo(memoJson)
+	if err != nil {
+		return nil, err
 	}
 
 	return &diag.WorkflowInstanceRef{
-		Instance:    core.NewWorkflowInstance(id, executionID),
-		CreatedAt:   createdAt,
-		CompletedAt: completedAt,
-		State:       state,
+		Instance:     instance,
+		WorkflowName: workflowName.String,
+		CreatedAt:    createdAt,
+		CompletedAt:  completedAt,
+		State:        state,
+		Memo:         memo,
+		LastError:    lastError.String,
 	}, nil
 }
+
+// likePrefix returns a LIKE pattern matching strings starting with prefix
+func likePrefix(prefix string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return r.Replace(prefix) + "%"
+}
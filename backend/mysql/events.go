@@ -3,6 +3,7 @@ package mysql
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"strings"
 
 	"github.com/paveliak/go-workflows/internal/history"
@@ -62,3 +63,59 @@ func removeFutureEvent(ctx context.Context, tx *sql.Tx, instanceID string, sched
 
 	return err
 }
+
+func getHistory(ctx context.Context, tx *sql.Tx, instanceID string, lastSequenceID *int64) ([]history.Event, error) {
+	var historyEvents *sql.Rows
+	var err error
+	if lastSequenceID != nil {
+		historyEvents, err = tx.QueryContext(
+			ctx,
+			"SELECT event_id, sequence_id, instance_id, event_type, timestamp, schedule_event_id, attributes, visible_at FROM `history` WHERE instance_id = ? AND sequence_id > ? ORDER BY sequence_id",
+			instanceID,
+			*lastSequenceID,
+		)
+	} else {
+		historyEvents, err = tx.QueryContext(
+			ctx,
+			"SELECT event_id, sequence_id, instance_id, event_type, timestamp, schedule_event_id, attributes, visible_at FROM `history` WHERE instance_id = ? ORDER BY sequence_id",
+			instanceID,
+		)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting history: %w", err)
+	}
+	defer historyEvents.Close()
+
+	h := make([]history.Event, 0)
+
+	for historyEvents.Next() {
+		var instanceID string
+		var attributes []byte
+
+		historyEvent := history.Event{}
+
+		if err := historyEvents.Scan(
+			&historyEvent.ID,
+			&historyEvent.SequenceID,
+			&instanceID,
+			&historyEvent.Type,
+			&historyEvent.Timestamp,
+			&historyEvent.ScheduleEventID,
+			&attributes,
+			&historyEvent.VisibleAt,
+		); err != nil {
+			return nil, fmt.Errorf("scanning event: %w", err)
+		}
+
+		a, err := history.DeserializeAttributes(historyEvent.Type, attributes)
+		if err != nil {
+			return nil, fmt.Errorf("deserializing attributes: %w", err)
+		}
+
+		historyEvent.Attributes = a
+
+		h = append(h, historyEvent)
+	}
+
+	return h, nil
+}
@@ -0,0 +1,72 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/paveliak/go-workflows/backend"
+	"github.com/paveliak/go-workflows/internal/core"
+	"github.com/paveliak/go-workflows/internal/history"
+	"github.com/paveliak/go-workflows/workflow"
+)
+
+var _ backend.Importer = (*mysqlBackend)(nil)
+
+func (b *mysqlBackend) ImportWorkflowInstance(
+	ctx context.Context,
+	instance *workflow.Instance,
+	state core.WorkflowInstanceState,
+	h, activityEvents, timerEvents []history.Event,
+) error {
+	a := history.StartedAttributes(h)
+	if a == nil {
+		return errors.New("history does not contain a WorkflowExecutionStarted event")
+	}
+
+	tx, err := b.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := createInstance(ctx, tx, instance, a, false); err != nil {
+		return err
+	}
+
+	var completedAt *time.Time
+	if state.Finished() {
+		completedAt = &h[len(h)-1].Timestamp
+	}
+
+	if _, err := tx.ExecContext(
+		ctx,
+		"UPDATE `instances` SET state = ?, completed_at = ? WHERE instance_id = ?",
+		state,
+		completedAt,
+		instance.InstanceID,
+	); err != nil {
+		return fmt.Errorf("updating workflow instance: %w", err)
+	}
+
+	if err := insertHistoryEvents(ctx, tx, instance.InstanceID, h); err != nil {
+		return fmt.Errorf("inserting history events: %w", err)
+	}
+
+	for _, event := range activityEvents {
+		if err := scheduleActivity(ctx, tx, instance, event); err != nil {
+			return fmt.Errorf("scheduling activity: %w", err)
+		}
+	}
+
+	if err := insertPendingEvents(ctx, tx, instance.InstanceID, timerEvents); err != nil {
+		return fmt.Errorf("scheduling timers: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("importing workflow instance: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,115 @@
+package mysql
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/paveliak/go-workflows/internal/core"
+	"github.com/paveliak/go-workflows/internal/history"
+)
+
+// migration adds a column or an index to a table of a database created with an older schema. New databases get
+// them from the schema, migrations only run for tables that miss them.
+type migration struct {
+	table string
+
+	// column and its definition, or index and its columns
+	column string
+	index  string
+
+	definition string
+
+	// backfill statements run after a column has been added, to set its value for existing rows
+	backfill []string
+}
+
+// migrations in the order the columns and indexes were added to the schema
+var migrations = []migration{
+	{table: "instances", column: "last_error", definition: "TEXT NULL"},
+	{table: "instances", column: "priority", definition: "INT NOT NULL DEFAULT 0"},
+	{table: "instances", index: "idx_instances_priority", definition: "`priority`"},
+	{table: "activities", column: "priority", definition: "INT NOT NULL DEFAULT 0"},
+	{table: "activities", index: "idx_activities_priority", definition: "`priority`"},
+	{table: "instances", column: "state", definition: "INT NOT NULL DEFAULT 0", backfill: []string{
+		// The outcome of instances finished before their state was recorded is unknown
+		fmt.Sprintf("UPDATE `instances` SET state = %d WHERE completed_at IS NOT NULL", core.WorkflowInstanceStateFinished),
+	}},
+	{table: "instances", column: "attempts", definition: "INT NOT NULL DEFAULT 0"},
+	{table: "instances", column: "workflow_name", definition: "NVARCHAR(255) NULL", backfill: []string{
+		// Take the name from the started event, which is still pending for instances that haven't run yet
+		fmt.Sprintf(`UPDATE instances i SET workflow_name = (
+			SELECT JSON_UNQUOTE(JSON_EXTRACT(CONVERT(e.attributes USING utf8mb4), '$.name')) FROM (
+				SELECT instance_id, event_type, attributes FROM history
+				UNION ALL
+				SELECT instance_id, event_type, attributes FROM pending_events
+			) e WHERE e.instance_id = i.instance_id AND e.event_type = %d LIMIT 1)`, history.EventType_WorkflowExecutionStarted),
+	}},
+	{table: "instances", index: "idx_instances_created_at", definition: "`created_at`"},
+	{table: "instances", column: "memo", definition: "TEXT NULL"},
+}
+
+// migrate adds missing columns and indexes to the tables of an existing database. It runs after the schema has
+// created missing tables.
+func migrate(db *sql.DB) error {
+	for _, m := range migrations {
+		if m.column != "" {
+			exists, err := hasColumn(db, m.table, m.column)
+			if err != nil {
+				return err
+			}
+
+			if exists {
+				continue
+			}
+
+			if _, err := db.Exec(fmt.Sprintf("ALTER TABLE `%s` ADD COLUMN `%s` %s", m.table, m.column, m.definition)); err != nil {
+				return fmt.Errorf("adding column %s.%s: %w", m.table, m.column, err)
+			}
+
+			for _, stmt := range m.backfill {
+				if _, err := db.Exec(stmt); err != nil {
+					return fmt.Errorf("backfilling column %s.%s: %w", m.table, m.column, err)
+				}
+			}
+
+			continue
+		}
+
+		exists, err := hasIndex(db, m.table, m.index)
+		if err != nil {
+			return err
+		}
+
+		if !exists {
+			if _, err := db.Exec(fmt.Sprintf("ALTER TABLE `%s` ADD INDEX `%s` (%s)", m.table, m.index, m.definition)); err != nil {
+				return fmt.Errorf("adding index %s.%s: %w", m.table, m.index, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func hasColumn(db *sql.DB, table, column string) (bool, error) {
+	var n int
+	if err := db.QueryRow(
+		"SELECT COUNT(*) FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND COLUMN_NAME = ?",
+		table, column,
+	).Scan(&n); err != nil {
+		return false, fmt.Errorf("reading columns of %s: %w", table, err)
+	}
+
+	return n > 0, nil
+}
+
+func hasIndex(db *sql.DB, table, index string) (bool, error) {
+	var n int
+	if err := db.QueryRow(
+		"SELECT COUNT(*) FROM information_schema.STATISTICS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND INDEX_NAME = ?",
+		table, index,
+	).Scan(&n); err != nil {
+		return false, fmt.Errorf("reading indexes of %s: %w", table, err)
+	}
+
+	return n > 0, nil
+}
@@ -10,6 +10,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/go-sql-driver/mysql"
+	"github.com/google/uuid"
 	"github.com/paveliak/go-workflows/backend"
 	"github.com/paveliak/go-workflows/internal/core"
 	"github.com/paveliak/go-workflows/internal/history"
@@ -18,8 +20,6 @@ import (
 	"github.com/paveliak/go-workflows/log"
 	"github.com/paveliak/go-workflows/metrics"
 	"github.com/paveliak/go-workflows/workflow"
-	_ "github.com/go-sql-driver/mysql"
-	"github.com/google/uuid"
 	"go.opentelemetry.io/otel/trace"
 )
 
@@ -27,10 +27,27 @@ import (
 var schema string
 
 func NewMysqlBackend(host string, port int, user, password, database string, opts ...backend.BackendOption) *mysqlBackend {
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?parseTime=true&interpolateParams=true", user, password, host, port, database)
+	cfg := mysql.NewConfig()
+	cfg.Net = "tcp"
+	cfg.Addr = fmt.Sprintf("%s:%d", host, port)
+	cfg.User = user
+	cfg.Passwd = password
+	cfg.DBName = database
+
+	return NewMysqlBackendWithConfig(cfg, opts...)
+}
 
-	schemaDsn := dsn + "&multiStatements=true"
-	db, err := sql.Open("mysql", schemaDsn)
+// NewMysqlBackendWithConfig creates a backend for the database of the given driver configuration, e.g. one returned
+// by mysql.ParseDSN. Time parsing and parameter interpolation are always enabled, all other settings are kept.
+func NewMysqlBackendWithConfig(cfg *mysql.Config, opts ...backend.BackendOption) *mysqlBackend {
+	cfg = cfg.Clone()
+	cfg.ParseTime = true
+	cfg.InterpolateParams = true
+	dsn := cfg.FormatDSN()
+
+	schemaCfg := cfg.Clone()
+	schemaCfg.MultiStatements = true
+	db, err := sql.Open("mysql", schemaCfg.FormatDSN())
 	if err != nil {
 		panic(err)
 	}
@@ -39,6 +56,10 @@ func NewMysqlBackend(host string, port int, user, password, database string, opt
 		panic(fmt.Errorf("initializing database: %w", err))
 	}
 
+	if err := migrate(db); err != nil {
+		panic(fmt.Errorf("migrating database: %w", err))
+	}
+
 	if err := db.Close(); err != nil {
 		panic(err)
 	}
@@ -48,10 +69,15 @@ func NewMysqlBackend(host string, port int, user, password, database string, opt
 		panic(err)
 	}
 
+	options := backend.ApplyOptions(opts...)
+
 	return &mysqlBackend{
 		db:         db,
 		workerName: fmt.Sprintf("worker-%v", uuid.NewString()),
-		options:    backend.ApplyOptions(opts...),
+		options:    options,
+
+		workflowPriorities: core.NewPriorityScheduler(options.PriorityFairnessInterval),
+		activityPriorities: core.NewPriorityScheduler(options.PriorityFairnessInterval),
 	}
 }
 
@@ -59,6 +85,9 @@ type mysqlBackend struct {
 	db         *sql.DB
 	workerName string
 	options    backend.Options
+
+	workflowPriorities *core.PriorityScheduler
+	activityPriorities *core.PriorityScheduler
 }
 
 // CreateWorkflowInstance creates a new workflow instance
@@ -72,7 +101,8 @@ func (b *mysqlBackend) CreateWorkflowInstance(ctx context.Context, instance *wor
 	defer tx.Rollback()
 
 	// Create workflow instance
-	if err := createInstance(ctx, tx, instance, event.Attributes.(*history.ExecutionStartedAttributes).Metadata, false); err != nil {
+	a := event.Attributes.(*history.ExecutionStartedAttributes)
+	if err := createInstance(ctx, tx, instance, a, false); err != nil {
 		return err
 	}
 
@@ -129,61 +159,133 @@ func (b *mysqlBackend) CancelWorkflowInstance(ctx context.Context, instance *wor
 	return tx.Commit()
 }
 
-func (b *mysqlBackend) GetWorkflowInstanceHistory(ctx context.Context, instance *workflow.Instance, lastSequenceID *int64) ([]history.Event, error) {
+func (b *mysqlBackend) TerminateWorkflowInstance(ctx context.Context, instance *workflow.Instance, event *history.Event) error {
 	tx, err := b.db.BeginTx(ctx, nil)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer tx.Rollback()
 
-	var historyEvents *sql.Rows
-	if lastSequenceID != nil {
-		historyEvents, err = tx.QueryContext(
-			ctx,
-			"SELECT event_id, sequence_id, instance_id, event_type, timestamp, schedule_event_id, attributes, visible_at FROM `history` WHERE instance_id = ? AND sequence_id > ? ORDER BY sequence_id",
-			instance.InstanceID,
-			*lastSequenceID,
-		)
-	} else {
-		historyEvents, err = tx.QueryContext(
+	var state core.WorkflowInstanceState
+	if err := tx.QueryRowContext(
+		ctx,
+		"SELECT state FROM instances WHERE instance_id = ? AND execution_id = ? FOR UPDATE",
+		instance.InstanceID,
+		instance.ExecutionID,
+	).Scan(&state); err != nil {
+		if err == sql.ErrNoRows {
+			return backend.ErrInstanceNotFound
+		}
+
+		return fmt.Errorf("getting workflow instance state: %w", err)
+	}
+
+	if state.Finished() {
+		return backend.ErrInstanceFinished
+	}
+
+	// Suspended instances don't get workflow tasks, make them active again so the termination is processed
+	if state == core.WorkflowInstanceStateSuspended {
+		if _, err := tx.ExecContext(
 			ctx,
-			"SELECT event_id, sequence_id, instance_id, event_type, timestamp, schedule_event_id, attributes, visible_at FROM `history` WHERE instance_id = ? ORDER BY sequence_id",
+			"UPDATE instances SET state = ?, attempts = 0 WHERE instance_id = ? AND execution_id = ?",
+			core.WorkflowInstanceStateActive,
 			instance.InstanceID,
-		)
+			instance.ExecutionID,
+		); err != nil {
+			return fmt.Errorf("resuming workflow instance: %w", err)
+		}
+	}
+
+	if err := insertPendingEvents(ctx, tx, instance.InstanceID, []history.Event{*event}); err != nil {
+		return fmt.Errorf("inserting termination event: %w", err)
 	}
+
+	return tx.Commit()
+}
+
+func (b *mysqlBackend) ResetWorkflowInstance(
+	ctx context.Context,
+	instance *workflow.Instance,
+	executionID string,
+	toSequenceID int64,
+	activityEvents, timerEvents, pendingEvents []history.Event,
+) error {
+	tx, err := b.db.BeginTx(ctx, nil)
 	if err != nil {
-		return nil, fmt.Errorf("getting history: %w", err)
+		return err
 	}
+	defer tx.Rollback()
 
-	h := make([]history.Event, 0)
+	// Start new execution. Release any lock and stickiness, tasks for the previous execution cannot be completed anymore.
+	res, err := tx.ExecContext(
+		ctx,
+		`UPDATE instances SET execution_id = ?, completed_at = NULL, locked_until = NULL, sticky_until = NULL, worker = NULL, last_error = NULL,
+			state = CASE WHEN state = ? THEN state ELSE ? END, attempts = 0
+			WHERE instance_id = ? AND execution_id = ?`,
+		executionID,
+		core.WorkflowInstanceStateSuspended, // Suspended instances stay suspended until they are resumed
+		core.WorkflowInstanceStateActive,
+		instance.InstanceID,
+		instance.ExecutionID,
+	)
+	if err != nil {
+		return fmt.Errorf("updating workflow instance: %w", err)
+	}
 
-	for historyEvents.Next() {
-		var instanceID string
-		var attributes []byte
+	if changedRows, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("checking for updated workflow instance: %w", err)
+	} else if changedRows != 1 {
+		return backend.ErrInstanceNotFound
+	}
 
-		historyEvent := history.Event{}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM `history` WHERE instance_id = ? AND sequence_id >= ?", instance.InstanceID, toSequenceID); err != nil {
+		return fmt.Errorf("truncating history: %w", err)
+	}
 
-		if err := historyEvents.Scan(
-			&historyEvent.ID,
-			&historyEvent.SequenceID,
-			&instanceID,
-			&historyEvent.Type,
-			&historyEvent.Timestamp,
-			&historyEvent.ScheduleEventID,
-			&attributes,
-			&historyEvent.VisibleAt,
-		); err != nil {
-			return nil, fmt.Errorf("scanning event: %w", err)
-		}
+	// Pending events include timers of the previous execution
+	if _, err := tx.ExecContext(ctx, "DELETE FROM `pending_events` WHERE instance_id = ?", instance.InstanceID); err != nil {
+		return fmt.Errorf("removing pending events: %w", err)
+	}
 
-		a, err := history.DeserializeAttributes(historyEvent.Type, attributes)
-		if err != nil {
-			return nil, fmt.Errorf("deserializing attributes: %w", err)
+	if _, err := tx.ExecContext(ctx, "DELETE FROM `activities` WHERE instance_id = ?", instance.InstanceID); err != nil {
+		return fmt.Errorf("removing activities: %w", err)
+	}
+
+	newInstance := *instance
+	newInstance.ExecutionID = executionID
+
+	for _, e := range activityEvents {
+		if err := scheduleActivity(ctx, tx, &newInstance, e); err != nil {
+			return fmt.Errorf("scheduling activity: %w", err)
 		}
+	}
 
-		historyEvent.Attributes = a
+	if err := insertPendingEvents(ctx, tx, instance.InstanceID, timerEvents); err != nil {
+		return fmt.Errorf("scheduling timers: %w", err)
+	}
 
-		h = append(h, historyEvent)
+	if err := insertPendingEvents(ctx, tx, instance.InstanceID, pendingEvents); err != nil {
+		return fmt.Errorf("inserting pending events: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing reset workflow instance transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (b *mysqlBackend) GetWorkflowInstanceHistory(ctx context.Context, instance *workflow.Instance, lastSequenceID *int64) ([]history.Event, error) {
+	tx, err := b.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	h, err := getHistory(ctx, tx, instance.InstanceID, lastSequenceID)
+	if err != nil {
+		return nil, err
 	}
 
 	return h, nil
@@ -192,26 +294,24 @@ func (b *mysqlBackend) GetWorkflowInstanceHistory(ctx context.Context, instance
 func (b *mysqlBackend) GetWorkflowInstanceState(ctx context.Context, instance *workflow.Instance) (core.WorkflowInstanceState, error) {
 	row := b.db.QueryRowContext(
 		ctx,
-		"SELECT completed_at FROM instances WHERE instance_id = ? AND execution_id = ?",
+		"SELECT state FROM instances WHERE instance_id = ? AND execution_id = ?",
 		instance.InstanceID,
 		instance.ExecutionID,
 	)
 
-	var completedAt sql.NullTime
-	if err := row.Scan(&completedAt); err != nil {
+	var state core.WorkflowInstanceState
+	if err := row.Scan(&state); err != nil {
 		if err == sql.ErrNoRows {
 			return core.WorkflowInstanceStateActive, backend.ErrInstanceNotFound
 		}
-	}
 
-	if completedAt.Valid {
-		return core.WorkflowInstanceStateFinished, nil
+		return core.WorkflowInstanceStateActive, fmt.Errorf("getting workflow instance state: %w", err)
 	}
 
-	return core.WorkflowInstanceStateActive, nil
+	return state, nil
 }
 
-func createInstance(ctx context.Context, tx *sql.Tx, wfi *workflow.Instance, metadata *workflow.Metadata, ignoreDuplicate bool) error {
+func createInstance(ctx context.Context, tx *sql.Tx, wfi *workflow.Instance, a *history.ExecutionStartedAttributes, ignoreDuplicate bool) error {
 	var parentInstanceID *string
 	var parentEventID *int64
 	if wfi.SubWorkflow() {
@@ -222,38 +322,79 @@ func createInstance(ctx context.Context, tx *sql.Tx, wfi *workflow.Instance, met
 		parentEventID = &n
 	}
 
-	metadataJson, err := json.Marshal(metadata)
+	metadataJson, err := json.Marshal(a.Metadata)
 	if err != nil {
 		return fmt.Errorf("marshaling metadata: %w", err)
 	}
 
+	memoJson, err := marshalMemo(a.Memo)
+	if err != nil {
+		return fmt.Errorf("marshaling memo: %w", err)
+	}
+
 	res, err := tx.ExecContext(
 		ctx,
-		"INSERT IGNORE INTO `instances` (instance_id, execution_id, parent_instance_id, parent_schedule_event_id, metadata) VALUES (?, ?, ?, ?, ?)",
+		"INSERT IGNORE INTO `instances` (instance_id, execution_id, parent_instance_id, parent_schedule_event_id, metadata, priority, workflow_name, memo) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
 		wfi.InstanceID,
 		wfi.ExecutionID,
 		parentInstanceID,
 		parentEventID,
 		string(metadataJson),
+		a.Priority.Clamp(),
+		a.Name,
+		memoJson,
 	)
 	if err != nil {
 		return fmt.Errorf("inserting workflow instance: %w", err)
 	}
 
-	if !ignoreDuplicate {
-		rows, err := res.RowsAffected()
-		if err != nil {
-			return err
-		}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
 
-		if rows != 1 {
+	if rows != 1 {
+		if !ignoreDuplicate {
 			return backend.ErrInstanceAlreadyExists
 		}
+
+		return nil
+	}
+
+	if err := upsertSearchAttributes(ctx, tx, wfi.InstanceID, a.SearchAttributes); err != nil {
+		return fmt.Errorf("indexing search attributes: %w", err)
 	}
 
 	return nil
 }
 
+func marshalMemo(memo core.Memo) (*string, error) {
+	if len(memo) == 0 {
+		return nil, nil
+	}
+
+	b, err := json.Marshal(memo)
+	if err != nil {
+		return nil, err
+	}
+
+	s := string(b)
+	return &s, nil
+}
+
+func unmarshalMemo(memoJson sql.NullString) (core.Memo, error) {
+	if !memoJson.Valid {
+		return nil, nil
+	}
+
+	var memo core.Memo
+	if err := json.Unmarshal([]byte(memoJson.String), &memo); err != nil {
+		return nil, fmt.Errorf("unmarshaling memo: %w", err)
+	}
+
+	return memo, nil
+}
+
 // SignalWorkflow signals a running workflow instance
 func (b *mysqlBackend) SignalWorkflow(ctx context.Context, instanceID string, event history.Event) error {
 	tx, err := b.db.BeginTx(ctx, &sql.TxOptions{
@@ -291,20 +432,24 @@ func (b *mysqlBackend) GetWorkflowTask(ctx context.Context) (*task.Workflow, err
 	now := time.Now()
 	row := tx.QueryRowContext(
 		ctx,
-		`SELECT i.id, i.instance_id, i.execution_id, i.parent_instance_id, i.parent_schedule_event_id, i.metadata, i.sticky_until
+		`SELECT i.id, i.instance_id, i.execution_id, i.parent_instance_id, i.parent_schedule_event_id, i.metadata, i.sticky_until, i.attempts
 			FROM instances i
 			INNER JOIN pending_events pe ON i.instance_id = pe.instance_id
 			WHERE
 				i.completed_at IS NULL
+				AND i.state = ?
 				AND (pe.visible_at IS NULL OR pe.visible_at <= ?)
 				AND (i.locked_until IS NULL OR i.locked_until < ?)
 				AND (i.sticky_until IS NULL OR i.sticky_until < ? OR i.worker = ?)
+			ORDER BY i.priority = ? DESC, i.priority DESC
 			LIMIT 1
 			FOR UPDATE OF i SKIP LOCKED`,
-		now,          // event.visible_at
-		now,          // locked_until
-		now,          // sticky_until
-		b.workerName, // worker
+		core.WorkflowInstanceStateActive, // state
+		now,                              // event.visible_at
+		now,                              // locked_until
+		now,                              // sticky_until
+		b.workerName,                     // worker
+		b.workflowPriorities.Next()[0],   // preferred priority
 	)
 
 	var id int
@@ -313,7 +458,8 @@ func (b *mysqlBackend) GetWorkflowTask(ctx context.Context) (*task.Workflow, err
 	var parentEventID *int64
 	var metadataJson sql.NullString
 	var stickyUntil *time.Time
-	if err := row.Scan(&id, &instanceID, &executionID, &parentInstanceID, &parentEventID, &metadataJson, &stickyUntil); err != nil {
+	var attempts int
+	if err := row.Scan(&id, &instanceID, &executionID, &parentInstanceID, &parentEventID, &metadataJson, &stickyUntil, &attempts); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
@@ -324,7 +470,7 @@ func (b *mysqlBackend) GetWorkflowTask(ctx context.Context) (*task.Workflow, err
 	res, err := tx.ExecContext(
 		ctx,
 		`UPDATE instances i
-			SET locked_until = ?, worker = ?
+			SET locked_until = ?, worker = ?, attempts = attempts + 1
 			WHERE id = ?`,
 		now.Add(b.options.WorkflowLockTimeout),
 		b.workerName,
@@ -361,6 +507,7 @@ func (b *mysqlBackend) GetWorkflowTask(ctx context.Context) (*task.Workflow, err
 		WorkflowInstanceState: core.WorkflowInstanceStateActive,
 		Metadata:              metadata,
 		NewEvents:             []history.Event{},
+		Attempt:               attempts + 1,
 	}
 
 	// Get new events
@@ -447,17 +594,22 @@ func (b *mysqlBackend) CompleteWorkflowTask(
 	defer tx.Rollback()
 
 	// Unlock instance, but keep it sticky to the current worker
+	// Keep the state of instances that have been suspended while this task was executed, unless they finished
 	var completedAt *time.Time
-	if state == core.WorkflowInstanceStateFinished {
+	var finishedState *core.WorkflowInstanceState
+	if state.Finished() {
 		t := time.Now()
 		completedAt = &t
+		finishedState = &state
 	}
 
 	res, err := tx.ExecContext(
 		ctx,
-		`UPDATE instances SET locked_until = NULL, sticky_until = ?, completed_at = ? WHERE instance_id = ? AND execution_id = ? AND worker = ?`,
+		`UPDATE instances SET locked_until = NULL, sticky_until = ?, completed_at = ?, state = COALESCE(?, state), last_error = NULL, attempts = 0
+			WHERE instance_id = ? AND execution_id = ? AND worker = ?`,
 		time.Now().Add(b.options.StickyTimeout),
 		completedAt,
+		finishedState,
 		instance.InstanceID,
 		instance.ExecutionID,
 		b.workerName,
@@ -470,7 +622,7 @@ func (b *mysqlBackend) CompleteWorkflowTask(
 	if err != nil {
 		return fmt.Errorf("checking for unlocked workflow instances: %w", err)
 	} else if changedRows != 1 {
-		return errors.New("could not find workflow instance to unlock")
+		return unlockError(ctx, tx, instance)
 	}
 
 	// Remove handled events from task
@@ -513,6 +665,12 @@ func (b *mysqlBackend) CompleteWorkflowTask(
 			if err := removeFutureEvent(ctx, tx, instance.InstanceID, event.ScheduleEventID); err != nil {
 				return fmt.Errorf("removing future event: %w", err)
 			}
+
+		case history.EventType_SearchAttributesUpserted:
+			a := event.Attributes.(*history.SearchAttributesUpsertedAttributes)
+			if err := upsertSearchAttributes(ctx, tx, instance.InstanceID, a.SearchAttributes); err != nil {
+				return fmt.Errorf("indexing search attributes: %w", err)
+			}
 		}
 	}
 
@@ -524,7 +682,7 @@ func (b *mysqlBackend) CompleteWorkflowTask(
 			if m.HistoryEvent.Type == history.EventType_WorkflowExecutionStarted {
 				a := m.HistoryEvent.Attributes.(*history.ExecutionStartedAttributes)
 				// Create new instance
-				if err := createInstance(ctx, tx, m.WorkflowInstance, a.Metadata, true); err != nil {
+				if err := createInstance(ctx, tx, m.WorkflowInstance, a, true); err != nil {
 					return err
 				}
 
@@ -546,6 +704,89 @@ func (b *mysqlBackend) CompleteWorkflowTask(
 		return fmt.Errorf("committing complete workflow transaction: %w", err)
 	}
 
+	if state.Finished() && b.options.Archiver != nil {
+		// The instance is already finished at this point, failing to archive it must not fail the task. The
+		// history is archived again when the instance is removed.
+		if err := b.archiveWorkflowInstance(ctx, instance); err != nil {
+			b.Logger().Error("archiving workflow instance", "instance_id", instance.InstanceID, "error", err.Error())
+		}
+	}
+
+	return nil
+}
+
+func (b *mysqlBackend) archiveWorkflowInstance(ctx context.Context, instance *workflow.Instance) error {
+	h, err := b.GetWorkflowInstanceHistory(ctx, instance, nil)
+	if err != nil {
+		return err
+	}
+
+	return b.options.Archiver.ArchiveWorkflowInstance(ctx, instance, h)
+}
+
+func (b *mysqlBackend) RemoveWorkflowInstance(ctx context.Context, instance *workflow.Instance) error {
+	// Archive before opening the transaction that deletes the instance, the archiver might be slow
+	if b.options.Archiver != nil {
+		state, err := b.GetWorkflowInstanceState(ctx, instance)
+		if err != nil {
+			return err
+		}
+
+		if !state.Finished() {
+			return backend.ErrInstanceNotFinished
+		}
+
+		if err := b.archiveWorkflowInstance(ctx, instance); err != nil {
+			return fmt.Errorf("archiving workflow instance: %w", err)
+		}
+	}
+
+	tx, err := b.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// The instance might have been reset since it was archived
+	if err := checkInstanceFinished(ctx, tx, instance); err != nil {
+		return err
+	}
+
+	for _, table := range []string{"instances", "history", "pending_events", "activities", "search_attributes"} {
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM `%v` WHERE instance_id = ?", table), instance.InstanceID); err != nil {
+			return fmt.Errorf("deleting %v: %w", table, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing remove workflow instance transaction: %w", err)
+	}
+
+	return nil
+}
+
+// checkInstanceFinished returns an error if the given execution of the instance does not exist or has not finished
+func checkInstanceFinished(ctx context.Context, tx *sql.Tx, instance *workflow.Instance) error {
+	row := tx.QueryRowContext(
+		ctx,
+		"SELECT completed_at FROM instances WHERE instance_id = ? AND execution_id = ? FOR UPDATE",
+		instance.InstanceID,
+		instance.ExecutionID,
+	)
+
+	var completedAt sql.NullTime
+	if err := row.Scan(&completedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return backend.ErrInstanceNotFound
+		}
+
+		return fmt.Errorf("getting workflow instance: %w", err)
+	}
+
+	if !completedAt.Valid {
+		return backend.ErrInstanceNotFinished
+	}
+
 	return nil
 }
 
@@ -578,6 +819,173 @@ func (b *mysqlBackend) ExtendWorkflowTask(ctx context.Context, taskID string, in
 	return tx.Commit()
 }
 
+func (b *mysqlBackend) AbandonWorkflowTask(ctx context.Context, t *task.Workflow, lastError string) error {
+	// Keep the lock, the task is retried once the lock expires
+	res, err := b.db.ExecContext(
+		ctx,
+		`UPDATE instances SET last_error = ? WHERE instance_id = ? AND execution_id = ? AND worker = ?`,
+		lastError,
+		t.WorkflowInstance.InstanceID,
+		t.WorkflowInstance.ExecutionID,
+		b.workerName,
+	)
+	if err != nil {
+		return fmt.Errorf("abandoning workflow task: %w", err)
+	}
+
+	if rowsAffected, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("determining if workflow task was abandoned: %w", err)
+	} else if rowsAffected == 0 {
+		return errors.New("could not abandon workflow task")
+	}
+
+	return nil
+}
+
+func (b *mysqlBackend) SuspendWorkflowTask(ctx context.Context, t *task.Workflow, reason string) error {
+	// Unlock the instance and keep the pending events, they are processed once the instance is resumed
+	res, err := b.db.ExecContext(
+		ctx,
+		`UPDATE instances SET state = ?, last_error = ?, locked_until = NULL, sticky_until = NULL, worker = NULL
+			WHERE instance_id = ? AND execution_id = ? AND worker = ?`,
+		core.WorkflowInstanceStateSuspended,
+		reason,
+		t.WorkflowInstance.InstanceID,
+		t.WorkflowInstance.ExecutionID,
+		b.workerName,
+	)
+	if err != nil {
+		return fmt.Errorf("suspending workflow instance: %w", err)
+	}
+
+	if rowsAffected, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("determining if workflow instance was suspended: %w", err)
+	} else if rowsAffected == 0 {
+		return errors.New("could not suspend workflow instance")
+	}
+
+	return nil
+}
+
+func (b *mysqlBackend) SuspendWorkflowInstance(ctx context.Context, instance *workflow.Instance) error {
+	tx, err := b.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var state core.WorkflowInstanceState
+	if err := tx.QueryRowContext(
+		ctx,
+		"SELECT state FROM instances WHERE instance_id = ? AND execution_id = ? FOR UPDATE",
+		instance.InstanceID,
+		instance.ExecutionID,
+	).Scan(&state); err != nil {
+		if err == sql.ErrNoRows {
+			return backend.ErrInstanceNotFound
+		}
+
+		return fmt.Errorf("getting workflow instance state: %w", err)
+	}
+
+	if state != core.WorkflowInstanceStateActive {
+		return backend.ErrInstanceNotActive
+	}
+
+	// A task that is currently being executed can still be completed, but no new tasks are handed out
+	if _, err := tx.ExecContext(
+		ctx,
+		"UPDATE instances SET state = ? WHERE instance_id = ? AND execution_id = ?",
+		core.WorkflowInstanceStateSuspended,
+		instance.InstanceID,
+		instance.ExecutionID,
+	); err != nil {
+		return fmt.Errorf("suspending workflow instance: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+func (b *mysqlBackend) ResumeWorkflowInstance(ctx context.Context, instance *workflow.Instance) error {
+	tx, err := b.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var state core.WorkflowInstanceState
+	if err := tx.QueryRowContext(
+		ctx,
+		"SELECT state FROM instances WHERE instance_id = ? AND execution_id = ? FOR UPDATE",
+		instance.InstanceID,
+		instance.ExecutionID,
+	).Scan(&state); err != nil {
+		if err == sql.ErrNoRows {
+			return backend.ErrInstanceNotFound
+		}
+
+		return fmt.Errorf("getting workflow instance state: %w", err)
+	}
+
+	if state != core.WorkflowInstanceStateSuspended {
+		return backend.ErrInstanceNotSuspended
+	}
+
+	if _, err := tx.ExecContext(
+		ctx,
+		"UPDATE instances SET state = ?, attempts = 0, last_error = NULL WHERE instance_id = ? AND execution_id = ?",
+		core.WorkflowInstanceStateActive,
+		instance.InstanceID,
+		instance.ExecutionID,
+	); err != nil {
+		return fmt.Errorf("resuming workflow instance: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+func (b *mysqlBackend) ReleaseStickiness(ctx context.Context, instance *core.WorkflowInstance) error {
+	// Only clear sticky_until, the worker column also tracks the owner of the lock
+	var err error
+	if instance == nil {
+		_, err = b.db.ExecContext(
+			ctx,
+			`UPDATE instances SET sticky_until = NULL WHERE worker = ? AND sticky_until IS NOT NULL`,
+			b.workerName,
+		)
+	} else {
+		_, err = b.db.ExecContext(
+			ctx,
+			`UPDATE instances SET sticky_until = NULL WHERE instance_id = ? AND execution_id = ? AND worker = ?`,
+			instance.InstanceID,
+			instance.ExecutionID,
+			b.workerName,
+		)
+	}
+	if err != nil {
+		return fmt.Errorf("releasing workflow instance stickiness: %w", err)
+	}
+
+	return nil
+}
+
+func (b *mysqlBackend) ReleaseWorkflowTask(ctx context.Context, taskID string, instance *core.WorkflowInstance) error {
+	// Release the lock and stickiness, if the task has been completed in the meantime this is a no-op. The task was
+	// not attempted, so don't count it.
+	if _, err := b.db.ExecContext(
+		ctx,
+		`UPDATE instances SET locked_until = NULL, sticky_until = NULL, worker = NULL, attempts = GREATEST(attempts - 1, 0)
+			WHERE instance_id = ? AND execution_id = ? AND worker = ?`,
+		instance.InstanceID,
+		instance.ExecutionID,
+		b.workerName,
+	); err != nil {
+		return fmt.Errorf("releasing workflow task: %w", err)
+	}
+
+	return nil
+}
+
 // GetActivityTask returns a pending activity task or nil if there are no pending activities
 func (b *mysqlBackend) GetActivityTask(ctx context.Context) (*task.Activity, error) {
 	tx, err := b.db.BeginTx(ctx, &sql.TxOptions{
@@ -593,23 +1001,25 @@ func (b *mysqlBackend) GetActivityTask(ctx context.Context) (*task.Activity, err
 	res := tx.QueryRowContext(
 		ctx,
 		`SELECT activities.id, activity_id, activities.instance_id, activities.execution_id,
-			instances.metadata, event_type, timestamp, schedule_event_id, attributes, visible_at
+			instances.metadata, instances.memo, event_type, timestamp, schedule_event_id, attributes, visible_at
 			FROM activities
 				INNER JOIN instances ON activities.instance_id = instances.instance_id
 			WHERE activities.locked_until IS NULL OR activities.locked_until < ?
+			ORDER BY activities.priority = ? DESC, activities.priority DESC, activities.id
 			LIMIT 1
 			FOR UPDATE SKIP LOCKED`,
 		now,
+		b.activityPriorities.Next()[0],
 	)
 
 	var id int64
 	var instanceID, executionID string
 	var attributes []byte
-	var metadataJson sql.NullString
+	var metadataJson, memoJson sql.NullString
 	event := history.Event{}
 
 	if err := res.Scan(
-		&id, &event.ID, &instanceID, &executionID, &metadataJson, &event.Type,
+		&id, &event.ID, &instanceID, &executionID, &metadataJson, &memoJson, &event.Type,
 		&event.Timestamp, &event.ScheduleEventID, &attributes, &event.VisibleAt); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -623,6 +1033,11 @@ func (b *mysqlBackend) GetActivityTask(ctx context.Context) (*task.Activity, err
 		return nil, fmt.Errorf("unmarshaling metadata: %w", err)
 	}
 
+	memo, err := unmarshalMemo(memoJson)
+	if err != nil {
+		return nil, err
+	}
+
 	a, err := history.DeserializeAttributes(event.Type, attributes)
 	if err != nil {
 		return nil, fmt.Errorf("deserializing attributes: %w", err)
@@ -644,6 +1059,7 @@ func (b *mysqlBackend) GetActivityTask(ctx context.Context) (*task.Activity, err
 		ID:               event.ID,
 		WorkflowInstance: core.NewWorkflowInstance(instanceID, executionID),
 		Metadata:         metadata,
+		Memo:             memo,
 		Event:            event,
 	}
 
@@ -664,6 +1080,21 @@ func (b *mysqlBackend) CompleteActivityTask(ctx context.Context, instance *workf
 	}
 	defer tx.Rollback()
 
+	// Drop results of activities scheduled by a previous execution, the instance has been reset in the meantime
+	var executionID string
+	if err := tx.QueryRowContext(ctx, "SELECT execution_id FROM instances WHERE instance_id = ?", instance.InstanceID).Scan(&executionID); err != nil {
+		if err == sql.ErrNoRows {
+			return backend.ErrInstanceNotFound
+		}
+
+		return fmt.Errorf("getting workflow instance: %w", err)
+	}
+
+	if executionID != instance.ExecutionID {
+		b.Logger().Debug("Discarding result of activity for previous execution", "instance_id", instance.InstanceID, "activity_id", id)
+		return nil
+	}
+
 	// Remove activity
 	if res, err := tx.ExecContext(
 		ctx,
@@ -725,6 +1156,27 @@ func (b *mysqlBackend) ExtendActivityTask(ctx context.Context, activityID string
 	return tx.Commit()
 }
 
+func (b *mysqlBackend) ReleaseActivityTask(ctx context.Context, activityID string, delay time.Duration) error {
+	// Tasks are locked until they can be picked up again
+	var lockedUntil *time.Time
+	if delay > 0 {
+		t := time.Now().Add(delay)
+		lockedUntil = &t
+	}
+
+	if _, err := b.db.ExecContext(
+		ctx,
+		`UPDATE activities SET locked_until = ?, worker = NULL WHERE activity_id = ? AND worker = ?`,
+		lockedUntil,
+		activityID,
+		b.workerName,
+	); err != nil {
+		return fmt.Errorf("releasing activity task: %w", err)
+	}
+
+	return nil
+}
+
 func scheduleActivity(ctx context.Context, tx *sql.Tx, instance *core.WorkflowInstance, event history.Event) error {
 	a, err := history.SerializeAttributes(event.Attributes)
 	if err != nil {
@@ -734,7 +1186,7 @@ func scheduleActivity(ctx context.Context, tx *sql.Tx, instance *core.WorkflowIn
 	_, err = tx.ExecContext(
 		ctx,
 		`INSERT INTO activities
-			(activity_id, instance_id, execution_id, event_type, timestamp, schedule_event_id, attributes, visible_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			(activity_id, instance_id, execution_id, event_type, timestamp, schedule_event_id, attributes, visible_at, priority) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		event.ID,
 		instance.InstanceID,
 		instance.ExecutionID,
@@ -743,7 +1195,27 @@ func scheduleActivity(ctx context.Context, tx *sql.Tx, instance *core.WorkflowIn
 		event.ScheduleEventID,
 		a,
 		event.VisibleAt,
+		event.Attributes.(*history.ActivityScheduledAttributes).Priority.Clamp(),
 	)
 
 	return err
 }
+
+// unlockError returns the error for a workflow task whose instance could not be unlocked. This happens when the
+// instance has been reset while the task was executed, or when the task's lock has expired.
+func unlockError(ctx context.Context, tx *sql.Tx, instance *workflow.Instance) error {
+	var executionID string
+	if err := tx.QueryRowContext(ctx, "SELECT execution_id FROM instances WHERE instance_id = ?", instance.InstanceID).Scan(&executionID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return backend.ErrInstanceNotFound
+		}
+
+		return fmt.Errorf("getting workflow instance: %w", err)
+	}
+
+	if executionID != instance.ExecutionID {
+		return backend.ErrWorkflowTaskDiscarded
+	}
+
+	return errors.New("could not find workflow instance to unlock")
+}
@@ -6,11 +6,14 @@ import (
 	"fmt"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/paveliak/go-workflows/backend"
 	"github.com/paveliak/go-workflows/backend/test"
+	"github.com/paveliak/go-workflows/internal/core"
 	"github.com/paveliak/go-workflows/internal/history"
 	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
 )
 
 const testUser = "root"
@@ -97,6 +100,120 @@ func TestMySqlBackendE2E(t *testing.T) {
 	})
 }
 
+// baselineSchema is the initial schema of the tables that got columns or indexes since, and of the tables they are
+// backfilled from
+const baselineSchema = `
+CREATE TABLE instances (
+  id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+  instance_id NVARCHAR(128) NOT NULL,
+  execution_id NVARCHAR(128) NOT NULL,
+  parent_instance_id NVARCHAR(128) NULL,
+  parent_schedule_event_id BIGINT NULL,
+  metadata BLOB NULL,
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  completed_at DATETIME NULL,
+  locked_until DATETIME NULL,
+  sticky_until DATETIME NULL,
+  worker NVARCHAR(64) NULL,
+
+  UNIQUE INDEX idx_instances_instance_id (instance_id),
+  INDEX idx_instances_locked_until_completed_at (completed_at, locked_until, sticky_until, worker),
+  INDEX idx_instances_parent_instance_id (parent_instance_id)
+);
+
+CREATE TABLE activities (
+  id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+  activity_id NVARCHAR(64) NOT NULL,
+  instance_id NVARCHAR(128) NOT NULL,
+  execution_id NVARCHAR(128) NOT NULL,
+  event_type INT NOT NULL,
+  timestamp DATETIME NOT NULL,
+  schedule_event_id BIGINT NOT NULL,
+  attributes BLOB NOT NULL,
+  visible_at DATETIME NULL,
+  locked_until DATETIME NULL,
+  worker NVARCHAR(64) NULL,
+
+  UNIQUE INDEX idx_activities_instance_id (instance_id, activity_id, execution_id, worker),
+  INDEX idx_activities_locked_until (locked_until)
+);
+
+CREATE TABLE history (
+  id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+  event_id NVARCHAR(64) NOT NULL,
+  sequence_id BIGINT NOT NULL,
+  instance_id NVARCHAR(128) NOT NULL,
+  event_type INT NOT NULL,
+  timestamp DATETIME NOT NULL,
+  schedule_event_id BIGINT NOT NULL,
+  attributes BLOB NOT NULL,
+  visible_at DATETIME NULL,
+
+  INDEX idx_history_instance_id (instance_id),
+  INDEX idx_history_instance_id_sequence_id (instance_id, sequence_id)
+);
+`
+
+func Test_MysqlBackend_MigratesBaselineSchema(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	db, err := sql.Open("mysql", fmt.Sprintf("%s:%s@/?parseTime=true&interpolateParams=true&multiStatements=true", testUser, testPassword))
+	require.NoError(t, err)
+	defer db.Close()
+
+	dbName := "test_" + strings.Replace(uuid.NewString(), "-", "", -1)
+	_, err = db.Exec("CREATE DATABASE " + dbName)
+	require.NoError(t, err)
+	defer db.Exec("DROP DATABASE IF EXISTS " + dbName)
+
+	_, err = db.Exec("USE " + dbName + ";" + baselineSchema)
+	require.NoError(t, err)
+
+	active := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+	finished := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+	_, err = db.Exec(
+		"INSERT INTO "+dbName+".instances (instance_id, execution_id, completed_at) VALUES (?, ?, NULL), (?, ?, ?)",
+		active.InstanceID, active.ExecutionID, finished.InstanceID, finished.ExecutionID, time.Now())
+	require.NoError(t, err)
+
+	_, err = db.Exec(
+		"INSERT INTO "+dbName+".history (event_id, sequence_id, instance_id, event_type, timestamp, schedule_event_id, attributes) VALUES (?, 1, ?, ?, ?, -1, ?)",
+		uuid.NewString(), active.InstanceID, history.EventType_WorkflowExecutionStarted, time.Now(), []byte(`{"name":"Greet"}`))
+	require.NoError(t, err)
+
+	b := NewMysqlBackend("localhost", 3306, testUser, testPassword, dbName)
+	defer b.db.Close()
+
+	state, err := b.GetWorkflowInstanceState(context.Background(), active)
+	require.NoError(t, err)
+	require.Equal(t, core.WorkflowInstanceStateActive, state)
+
+	state, err = b.GetWorkflowInstanceState(context.Background(), finished)
+	require.NoError(t, err)
+	require.Equal(t, core.WorkflowInstanceStateFinished, state)
+
+	ref, err := b.GetWorkflowInstance(context.Background(), active.InstanceID)
+	require.NoError(t, err)
+	require.Equal(t, "Greet", ref.WorkflowName)
+
+	// Migrations only add missing columns and indexes
+	require.NoError(t, migrate(b.db))
+
+	for _, m := range migrations {
+		if m.column != "" {
+			exists, err := hasColumn(b.db, m.table, m.column)
+			require.NoError(t, err)
+			require.True(t, exists, "missing column %s.%s", m.table, m.column)
+		} else {
+			exists, err := hasIndex(b.db, m.table, m.index)
+			require.NoError(t, err)
+			require.True(t, exists, "missing index %s.%s", m.table, m.index)
+		}
+	}
+}
+
 var _ test.TestBackend = (*mysqlBackend)(nil)
 
 func (mb *mysqlBackend) GetFutureEvents(ctx context.Context) ([]history.Event, error) {
@@ -0,0 +1,60 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/paveliak/go-workflows/internal/ratelimit"
+)
+
+func (b *mysqlBackend) TakeRateLimitToken(ctx context.Context, key string, rate float64, burst int) (time.Duration, error) {
+	tx, err := b.db.BeginTx(ctx, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+
+	// Create a full bucket if there is none yet, so that the row can be locked below
+	bucket := ratelimit.NewBucket(now, burst)
+	if _, err := tx.ExecContext(
+		ctx,
+		"INSERT IGNORE INTO rate_limits (id, tokens, updated_at) VALUES (?, ?, ?)",
+		key,
+		bucket.Tokens,
+		bucket.UpdatedAt,
+	); err != nil {
+		return 0, fmt.Errorf("creating rate limit: %w", err)
+	}
+
+	if err := tx.QueryRowContext(
+		ctx,
+		"SELECT tokens, updated_at FROM rate_limits WHERE id = ? FOR UPDATE",
+		key,
+	).Scan(&bucket.Tokens, &bucket.UpdatedAt); err != nil {
+		return 0, fmt.Errorf("reading rate limit: %w", err)
+	}
+
+	wait := bucket.Take(now, rate, burst)
+
+	if _, err := tx.ExecContext(
+		ctx,
+		"UPDATE rate_limits SET tokens = ?, updated_at = ? WHERE id = ?",
+		bucket.Tokens,
+		bucket.UpdatedAt,
+		key,
+	); err != nil {
+		return 0, fmt.Errorf("updating rate limit: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return wait, nil
+}
@@ -10,10 +10,18 @@ CREATE TABLE IF NOT EXISTS `instances` (
   `locked_until` DATETIME NULL,
   `sticky_until` DATETIME NULL,
   `worker` NVARCHAR(64) NULL,
+  `last_error` TEXT NULL,
+  `priority` INT NOT NULL DEFAULT 0,
+  `state` INT NOT NULL DEFAULT 0,
+  `attempts` INT NOT NULL DEFAULT 0,
+  `workflow_name` NVARCHAR(255) NULL,
+  `memo` TEXT NULL,
 
   UNIQUE INDEX `idx_instances_instance_id` (`instance_id`),
   INDEX `idx_instances_locked_until_completed_at` (`completed_at`, `locked_until`, `sticky_until`, `worker`),
-  INDEX `idx_instances_parent_instance_id` (`parent_instance_id`)
+  INDEX `idx_instances_parent_instance_id` (`parent_instance_id`),
+  INDEX `idx_instances_priority` (`priority`),
+  INDEX `idx_instances_created_at` (`created_at`)
 );
 
 
@@ -61,7 +69,24 @@ CREATE TABLE IF NOT EXISTS `activities` (
   `visible_at` DATETIME NULL,
   `locked_until` DATETIME NULL,
   `worker` NVARCHAR(64) NULL,
+  `priority` INT NOT NULL DEFAULT 0,
 
   UNIQUE INDEX `idx_activities_instance_id` (`instance_id`, `activity_id`, `execution_id`, `worker`),
-  INDEX `idx_activities_locked_until` (`locked_until`)
-);
\ No newline at end of file
+  INDEX `idx_activities_locked_until` (`locked_until`),
+  INDEX `idx_activities_priority` (`priority`)
+);
+
+CREATE TABLE IF NOT EXISTS `rate_limits` (
+  `id` NVARCHAR(255) NOT NULL PRIMARY KEY,
+  `tokens` DOUBLE NOT NULL,
+  `updated_at` DATETIME(6) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS `search_attributes` (
+  `instance_id` NVARCHAR(128) NOT NULL,
+  `name` NVARCHAR(255) NOT NULL,
+  `value` TEXT NOT NULL,
+
+  PRIMARY KEY (`instance_id`, `name`),
+  INDEX `idx_search_attributes_name_value` (`name`, `value`(255))
+);
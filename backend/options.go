@@ -3,6 +3,7 @@ package backend
 import (
 	"time"
 
+	"github.com/paveliak/go-workflows/archive"
 	"github.com/paveliak/go-workflows/internal/logger"
 	mi "github.com/paveliak/go-workflows/internal/metrics"
 	"github.com/paveliak/go-workflows/log"
@@ -22,6 +23,15 @@ type Options struct {
 	WorkflowLockTimeout time.Duration
 
 	ActivityLockTimeout time.Duration
+
+	// PriorityFairnessInterval protects tasks with a low priority from starvation. Every n-th dequeue prefers a
+	// lower priority level, in rotation, over the highest level with pending tasks. Set to 0 to always dequeue
+	// tasks strictly by priority.
+	PriorityFairnessInterval int
+
+	// Archiver, if set, receives the history of workflow instances when they finish and before they
+	// are removed from the backend.
+	Archiver archive.Archiver
 }
 
 var DefaultOptions Options = Options{
@@ -29,6 +39,8 @@ var DefaultOptions Options = Options{
 	WorkflowLockTimeout: time.Minute,
 	ActivityLockTimeout: time.Minute * 2,
 
+	PriorityFairnessInterval: 10,
+
 	Logger:         logger.NewDefaultLogger(),
 	Metrics:        mi.NewNoopMetricsClient(),
 	TracerProvider: trace.NewNoopTracerProvider(),
@@ -42,6 +54,12 @@ func WithStickyTimeout(timeout time.Duration) BackendOption {
 	}
 }
 
+func WithPriorityFairnessInterval(interval int) BackendOption {
+	return func(o *Options) {
+		o.PriorityFairnessInterval = interval
+	}
+}
+
 func WithLogger(logger log.Logger) BackendOption {
 	return func(o *Options) {
 		o.Logger = logger
@@ -60,6 +78,12 @@ func WithTracerProvider(tp trace.TracerProvider) BackendOption {
 	}
 }
 
+func WithArchiver(a archive.Archiver) BackendOption {
+	return func(o *Options) {
+		o.Archiver = a
+	}
+}
+
 func ApplyOptions(opts ...BackendOption) Options {
 	options := DefaultOptions
 
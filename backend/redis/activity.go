@@ -3,10 +3,12 @@ package redis
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/paveliak/go-workflows/internal/core"
 	"github.com/paveliak/go-workflows/internal/history"
 	"github.com/paveliak/go-workflows/internal/task"
+	"github.com/go-redis/redis/v8"
 )
 
 func (rb *redisBackend) GetActivityTask(ctx context.Context) (*task.Activity, error) {
@@ -27,6 +29,7 @@ func (rb *redisBackend) GetActivityTask(ctx context.Context) (*task.Activity, er
 	return &task.Activity{
 		WorkflowInstance: activityTask.Data.Instance,
 		Metadata:         instanceState.Metadata,
+		Memo:             instanceState.Memo,
 		ID:               activityTask.TaskID, // Use the queue generated ID here
 		Event:            activityTask.Data.Event,
 	}, nil
@@ -43,18 +46,45 @@ func (rb *redisBackend) ExtendActivityTask(ctx context.Context, activityID strin
 	return err
 }
 
+func (rb *redisBackend) ReleaseActivityTask(ctx context.Context, activityID string, delay time.Duration) error {
+	if delay > 0 {
+		return rb.activityQueue.Delay(ctx, rb.rdb, activityID, rb.options.ActivityLockTimeout, delay)
+	}
+
+	return rb.activityQueue.Release(ctx, rb.rdb, activityID)
+}
+
 func (rb *redisBackend) CompleteActivityTask(ctx context.Context, instance *core.WorkflowInstance, activityID string, event history.Event) error {
-	p := rb.rdb.TxPipeline()
+	return rb.watchInstance(ctx, instance.InstanceID, func(tx *redis.Tx) error {
+		instanceState, err := readInstanceTx(ctx, tx, instance.InstanceID)
+		if err != nil {
+			return err
+		}
 
-	if err := rb.addWorkflowInstanceEventP(ctx, p, instance, &event); err != nil {
-		return err
-	}
+		_, err = tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			// Drop results of activities scheduled by a previous execution, the instance has been reset in the meantime
+			if instanceState.Instance.ExecutionID == instance.ExecutionID {
+				if err := rb.addWorkflowInstanceEventP(ctx, p, instance, instanceState.Priority, &event); err != nil {
+					return err
+				}
+			} else {
+				rb.Logger().Debug("Discarding result of activity for previous execution", "instance_id", instance.InstanceID, "activity_id", activityID)
+			}
+
+			// Unlock activity
+			_, err := rb.activityQueue.Complete(ctx, p, activityID)
+			return err
+		})
 
-	// Unlock activity
-	if _, err := rb.activityQueue.Complete(ctx, p, activityID); err != nil {
 		return err
+	})
+}
+
+// activityPriority returns the priority an activity was scheduled with
+func activityPriority(event history.Event) core.Priority {
+	if a, ok := event.Attributes.(*history.ActivityScheduledAttributes); ok {
+		return a.Priority
 	}
 
-	_, err := p.Exec(ctx)
-	return err
+	return core.PriorityNormal
 }
@@ -4,81 +4,320 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/paveliak/go-workflows/diag"
+	"github.com/paveliak/go-workflows/internal/core"
 	"github.com/go-redis/redis/v8"
 )
 
 var _ diag.Backend = (*redisBackend)(nil)
+var _ diag.StatsBackend = (*redisBackend)(nil)
 
-func (rb *redisBackend) GetWorkflowInstances(ctx context.Context, afterInstanceID string, count int) ([]*diag.WorkflowInstanceRef, error) {
+func (rb *redisBackend) GetWorkflowInstances(ctx context.Context, afterInstanceID string, count int, states ...core.WorkflowInstanceState) ([]*diag.WorkflowInstanceRef, error) {
+	return rb.QueryWorkflowInstances(ctx, &diag.WorkflowInstanceQuery{
+		States:          states,
+		AfterInstanceID: afterInstanceID,
+		Count:           count,
+	})
+}
+
+func (rb *redisBackend) QueryWorkflowInstances(ctx context.Context, query *diag.WorkflowInstanceQuery) ([]*diag.WorkflowInstanceRef, error) {
 	max := "+inf"
+	if !query.CreatedBefore.IsZero() {
+		max = fmt.Sprintf("(%v", query.CreatedBefore.UnixMilli())
+	}
 
-	if afterInstanceID != "" {
-		scores, err := rb.rdb.ZMScore(ctx, instancesByCreation(), afterInstanceID).Result()
+	if query.AfterInstanceID != "" {
+		scores, err := rb.rdb.ZMScore(ctx, instancesByCreation(), query.AfterInstanceID).Result()
 		if err != nil {
-			return nil, fmt.Errorf("getting instance score for %v: %w", afterInstanceID, err)
+			return nil, fmt.Errorf("getting instance score for %v: %w", query.AfterInstanceID, err)
 		}
 
 		if len(scores) == 0 {
-			rb.Logger().Error("could not find instance %v", "afterInstanceID", afterInstanceID)
+			rb.Logger().Error("could not find instance %v", "afterInstanceID", query.AfterInstanceID)
 			return nil, nil
 		}
 
-		max = fmt.Sprintf("(%v", int64(scores[0]))
+		if query.CreatedBefore.IsZero() || int64(scores[0]) < query.CreatedBefore.UnixMilli() {
+			max = fmt.Sprintf("(%v", int64(scores[0]))
+		}
+	}
+
+	min := "-inf"
+	if !query.CreatedAfter.IsZero() {
+		min = fmt.Sprintf("%v", query.CreatedAfter.UnixMilli())
+	}
+
+	count := query.Limit()
+
+	var instanceRefs []*diag.WorkflowInstanceRef
+
+	// Instances are only indexed by creation time, when filtering keep reading pages until enough instances match
+	for offset := int64(0); len(instanceRefs) < count; offset += int64(count) {
+		result, err := rb.rdb.ZRangeArgs(ctx, redis.ZRangeArgs{
+			Key:     instancesByCreation(),
+			Stop:    max,
+			Start:   min,
+			ByScore: true,
+			Rev:     true,
+			Offset:  offset,
+			Count:   int64(count),
+		}).Result()
+		if err != nil {
+			return nil, fmt.Errorf("getting instances after %v: %w", max, err)
+		}
+
+		if len(result) == 0 {
+			break
+		}
+
+		instanceIDs := make([]string, 0)
+		for _, r := range result {
+			instanceID := r
+			instanceIDs = append(instanceIDs, instanceKey(instanceID))
+		}
+
+		instances, err := rb.rdb.MGet(ctx, instanceIDs...).Result()
+		if err != nil {
+			return nil, fmt.Errorf("getting instances: %w", err)
+		}
+
+		for _, instance := range instances {
+			if instance == nil {
+				continue
+			}
+
+			var state instanceState
+			if err := json.Unmarshal([]byte(instance.(string)), &state); err != nil {
+				return nil, fmt.Errorf("unmarshaling instance state: %w", err)
+			}
+
+			ref := instanceRef(&state)
+			if !query.Matches(ref) {
+				continue
+			}
+
+			instanceRefs = append(instanceRefs, ref)
+
+			if len(instanceRefs) == count {
+				break
+			}
+		}
+
+		if len(result) < count {
+			break
+		}
+	}
+
+	return instanceRefs, nil
+}
+
+func (rb *redisBackend) GetWorkflowInstance(ctx context.Context, instanceID string) (*diag.WorkflowInstanceRef, error) {
+	instance, err := readInstance(ctx, rb.rdb, instanceID)
+	if err != nil {
+		return nil, err
 	}
 
-	result, err := rb.rdb.ZRangeArgs(ctx, redis.ZRangeArgs{
-		Key:     instancesByCreation(),
-		Stop:    max,
-		Start:   "-inf",
-		ByScore: true,
-		Rev:     true,
-		Count:   int64(count),
-	}).Result()
+	return instanceRef(instance), nil
+}
+
+func (rb *redisBackend) GetWorkflowInstanceChildren(ctx context.Context, instanceID string) ([]*diag.WorkflowInstanceRef, error) {
+	childIDs, err := rb.rdb.ZRange(ctx, instanceChildrenKey(instanceID), 0, -1).Result()
 	if err != nil {
-		return nil, fmt.Errorf("getting instances after %v: %w", max, err)
+		return nil, fmt.Errorf("getting child instances: %w", err)
 	}
 
-	instanceIDs := make([]string, 0)
-	for _, r := range result {
-		instanceID := r
-		instanceIDs = append(instanceIDs, instanceKey(instanceID))
+	if len(childIDs) == 0 {
+		return nil, nil
 	}
 
-	instances, err := rb.rdb.MGet(ctx, instanceIDs...).Result()
+	keys := make([]string, 0, len(childIDs))
+	for _, childID := range childIDs {
+		keys = append(keys, instanceKey(childID))
+	}
+
+	instances, err := rb.rdb.MGet(ctx, keys...).Result()
 	if err != nil {
 		return nil, fmt.Errorf("getting instances: %w", err)
 	}
 
-	var instanceRefs []*diag.WorkflowInstanceRef
+	refs := make([]*diag.WorkflowInstanceRef, 0, len(instances))
 	for _, instance := range instances {
+		if instance == nil {
+			// Child instance has been removed
+			continue
+		}
+
 		var state instanceState
 		if err := json.Unmarshal([]byte(instance.(string)), &state); err != nil {
 			return nil, fmt.Errorf("unmarshaling instance state: %w", err)
 		}
 
-		instanceRefs = append(instanceRefs, &diag.WorkflowInstanceRef{
-			Instance:    state.Instance,
-			CreatedAt:   state.CreatedAt,
-			CompletedAt: state.CompletedAt,
-			State:       state.State,
-		})
+		refs = append(refs, instanceRef(&state))
 	}
 
-	return instanceRefs, nil
+	return refs, nil
 }
 
-func (rb *redisBackend) GetWorkflowInstance(ctx context.Context, instanceID string) (*diag.WorkflowInstanceRef, error) {
-	instance, err := readInstance(ctx, rb.rdb, instanceID)
+// GetStats reads the instance counters that are kept up to date whenever instances are created, change their
+// state, or are removed. Instances created by versions without the counters are counted when the backend starts,
+// see backfillInstanceStats.
+func (rb *redisBackend) GetStats(ctx context.Context, since time.Time) (*diag.Stats, error) {
+	stats := &diag.Stats{}
+
+	counts, err := rb.rdb.HGetAll(ctx, instanceStatsKey()).Result()
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("getting instance counts: %w", err)
 	}
 
+	for field, value := range counts {
+		s, workflowName, ok := strings.Cut(field, ":")
+		if !ok {
+			continue
+		}
+
+		state, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, fmt.Errorf("parsing instance count %v: %w", field, err)
+		}
+
+		count, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parsing instance count %v: %w", field, err)
+		}
+
+		if count > 0 {
+			stats.AddInstances(workflowName, core.WorkflowInstanceState(state), count)
+		}
+	}
+
+	min := strconv.FormatInt(since.UnixMilli(), 10)
+
+	if stats.RecentlyFinished, err = rb.rdb.ZCount(ctx, instancesByCompletion(), min, "+inf").Result(); err != nil {
+		return nil, fmt.Errorf("counting finished instances: %w", err)
+	}
+
+	if stats.RecentlyFailed, err = rb.rdb.ZCount(ctx, failedInstancesByCompletion(), min, "+inf").Result(); err != nil {
+		return nil, fmt.Errorf("counting failed instances: %w", err)
+	}
+
+	depth, err := rb.activityQueue.Size(ctx, rb.rdb)
+	if err != nil {
+		return nil, fmt.Errorf("getting activity queue size: %w", err)
+	}
+	stats.ActivityQueueDepth = depth
+
+	for _, oldest := range []func(context.Context, redis.UniversalClient) (*time.Time, error){
+		rb.workflowQueue.Oldest,
+		rb.activityQueue.Oldest,
+	} {
+		queuedAt, err := oldest(ctx, rb.rdb)
+		if err != nil {
+			return nil, fmt.Errorf("getting oldest pending task: %w", err)
+		}
+
+		if queuedAt != nil {
+			stats.AddPendingTask(*queuedAt)
+		}
+	}
+
+	return stats, nil
+}
+
+// backfillBatchSize is the number of instances read at once when backfilling the instance counters
+const backfillBatchSize = 1000
+
+// backfillInstanceStats counts the instances created by versions without the instance counters. Otherwise their
+// counters would go negative once they change their state or are removed. It only runs when the counters don't
+// exist yet, the first backend to claim the backfill counts the instances created before the claim.
+func (rb *redisBackend) backfillInstanceStats(ctx context.Context) error {
+	exists, err := rb.rdb.Exists(ctx, instanceStatsKey()).Result()
+	if err != nil {
+		return fmt.Errorf("checking instance counters: %w", err)
+	}
+
+	if exists > 0 {
+		return nil
+	}
+
+	claimedAt := time.Now()
+
+	claimed, err := rb.rdb.HSetNX(ctx, instanceStatsKey(), instanceStatsBackfillField, claimedAt.UnixMilli()).Result()
+	if err != nil {
+		return fmt.Errorf("claiming instance counters backfill: %w", err)
+	}
+
+	if !claimed {
+		return nil
+	}
+
+	// Instances created after the claim are counted when they are created
+	counts := make(map[string]int64)
+
+	for offset := int64(0); ; offset += backfillBatchSize {
+		instanceIDs, err := rb.rdb.ZRangeByScore(ctx, instancesByCreation(), &redis.ZRangeBy{
+			Min:    "-inf",
+			Max:    fmt.Sprintf("(%v", claimedAt.UnixMilli()),
+			Offset: offset,
+			Count:  backfillBatchSize,
+		}).Result()
+		if err != nil {
+			return fmt.Errorf("listing instances: %w", err)
+		}
+
+		if len(instanceIDs) == 0 {
+			break
+		}
+
+		keys := make([]string, 0, len(instanceIDs))
+		for _, instanceID := range instanceIDs {
+			keys = append(keys, instanceKey(instanceID))
+		}
+
+		instances, err := rb.rdb.MGet(ctx, keys...).Result()
+		if err != nil {
+			return fmt.Errorf("reading instances: %w", err)
+		}
+
+		for _, instance := range instances {
+			if instance == nil {
+				// Instance has been removed
+				continue
+			}
+
+			var state instanceState
+			if err := json.Unmarshal([]byte(instance.(string)), &state); err != nil {
+				return fmt.Errorf("unmarshaling instance state: %w", err)
+			}
+
+			counts[statsField(state.WorkflowName, state.State)]++
+		}
+	}
+
+	if _, err := rb.rdb.Pipelined(ctx, func(p redis.Pipeliner) error {
+		for field, count := range counts {
+			p.HIncrBy(ctx, instanceStatsKey(), field, count)
+		}
+
+		return nil
+	}); err != nil {
+		return fmt.Errorf("updating instance counters: %w", err)
+	}
+
+	return nil
+}
+
+func instanceRef(state *instanceState) *diag.WorkflowInstanceRef {
 	return &diag.WorkflowInstanceRef{
-		Instance:    instance.Instance,
-		CreatedAt:   instance.CreatedAt,
-		CompletedAt: instance.CompletedAt,
-		State:       instance.State,
-	}, nil
+		Instance:     state.Instance,
+		WorkflowName: state.WorkflowName,
+		CreatedAt:    state.CreatedAt,
+		CompletedAt:  state.CompletedAt,
+		State:        state.State,
+		Memo:         state.Memo,
+		LastError:    state.LastError,
+	}
 }
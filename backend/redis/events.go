@@ -61,12 +61,13 @@ func addEventsToHistoryStreamP(ctx context.Context, p redis.Pipeliner, streamKey
 // ARGV[1] - timestamp
 // ARGV[2] - Instance ID
 // ARGV[3] - event payload
+// ARGV[4] - priority of the workflow instance
 var addFutureEventCmd = redis.NewScript(`
 	redis.call("ZADD", KEYS[1], ARGV[1], KEYS[2])
-	return redis.call("HSET", KEYS[2], "instance", ARGV[2], "event", ARGV[3])
+	return redis.call("HSET", KEYS[2], "instance", ARGV[2], "event", ARGV[3], "priority", ARGV[4])
 `)
 
-func addFutureEventP(ctx context.Context, p redis.Pipeliner, instance *core.WorkflowInstance, event *history.Event) error {
+func addFutureEventP(ctx context.Context, p redis.Pipeliner, instance *core.WorkflowInstance, priority core.Priority, event *history.Event) error {
 	eventData, err := json.Marshal(event)
 	if err != nil {
 		return err
@@ -78,6 +79,7 @@ func addFutureEventP(ctx context.Context, p redis.Pipeliner, instance *core.Work
 		strconv.FormatInt(event.VisibleAt.UnixMilli(), 10),
 		instance.InstanceID,
 		string(eventData),
+		int(priority.Clamp()),
 	)
 
 	return nil
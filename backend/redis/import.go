@@ -0,0 +1,94 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/paveliak/go-workflows/backend"
+	"github.com/paveliak/go-workflows/internal/core"
+	"github.com/paveliak/go-workflows/internal/history"
+	"github.com/go-redis/redis/v8"
+)
+
+var _ backend.Importer = (*redisBackend)(nil)
+
+func (rb *redisBackend) ImportWorkflowInstance(
+	ctx context.Context,
+	instance *core.WorkflowInstance,
+	state core.WorkflowInstanceState,
+	h, activityEvents, timerEvents []history.Event,
+) error {
+	a := history.StartedAttributes(h)
+	if a == nil {
+		return errors.New("history does not contain a WorkflowExecutionStarted event")
+	}
+
+	return rb.watchInstance(ctx, instance.InstanceID, func(tx *redis.Tx) error {
+		if _, err := readInstanceTx(ctx, tx, instance.InstanceID); err == nil {
+			return backend.ErrInstanceAlreadyExists
+		} else if !errors.Is(err, backend.ErrInstanceNotFound) {
+			return err
+		}
+
+		createdAt := time.Now()
+
+		instanceState := &instanceState{
+			Instance:         instance,
+			WorkflowName:     a.Name,
+			State:            state,
+			Metadata:         a.Metadata,
+			SearchAttributes: a.SearchAttributes,
+			Memo:             a.Memo,
+			CreatedAt:        createdAt,
+			LastSequenceID:   h[len(h)-1].SequenceID,
+			Priority:         a.Priority.Clamp(),
+		}
+
+		if state.Finished() {
+			instanceState.CompletedAt = &h[len(h)-1].Timestamp
+		}
+
+		if _, err := tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			// Stores and counts the new instance
+			if err := updateInstanceP(ctx, p, instance.InstanceID, instanceState); err != nil {
+				return err
+			}
+
+			p.ZAdd(ctx, instancesByCreation(), &redis.Z{
+				Member: instance.InstanceID,
+				Score:  float64(createdAt.UnixMilli()),
+			})
+
+			addSearchAttributesP(ctx, p, instance.InstanceID, a.SearchAttributes)
+
+			if err := addEventsToHistoryStreamP(ctx, p, historyKey(instance.InstanceID), h); err != nil {
+				return fmt.Errorf("adding history events: %w", err)
+			}
+
+			for _, activityEvent := range activityEvents {
+				if err := rb.activityQueue.Enqueue(ctx, p, activityEvent.ID, activityPriority(activityEvent), &activityData{
+					Instance: instance,
+					ID:       activityEvent.ID,
+					Event:    activityEvent,
+				}); err != nil {
+					return fmt.Errorf("queueing activity task: %w", err)
+				}
+			}
+
+			for _, timerEvent := range timerEvents {
+				timerEvent := timerEvent
+				if err := addFutureEventP(ctx, p, instance, instanceState.Priority, &timerEvent); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}); err != nil {
+			return fmt.Errorf("importing workflow instance: %w", err)
+		}
+
+		return nil
+	})
+}
@@ -3,6 +3,7 @@ package redis
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
@@ -25,7 +26,8 @@ func (rb *redisBackend) CreateWorkflowInstance(ctx context.Context, instance *wo
 
 	p := rb.rdb.TxPipeline()
 
-	if err := createInstanceP(ctx, p, instance, event.Attributes.(*history.ExecutionStartedAttributes).Metadata, false); err != nil {
+	a := event.Attributes.(*history.ExecutionStartedAttributes)
+	if err := createInstanceP(ctx, p, instance, a, false); err != nil {
 		return err
 	}
 
@@ -44,7 +46,7 @@ func (rb *redisBackend) CreateWorkflowInstance(ctx context.Context, instance *wo
 	})
 
 	// Queue workflow instance task
-	if err := rb.workflowQueue.Enqueue(ctx, p, instance.InstanceID, nil); err != nil {
+	if err := rb.workflowQueue.Enqueue(ctx, p, instance.InstanceID, a.Priority, nil); err != nil {
 		return fmt.Errorf("queueing workflow task: %w", err)
 	}
 
@@ -93,14 +95,14 @@ func (rb *redisBackend) GetWorkflowInstanceState(ctx context.Context, instance *
 
 func (rb *redisBackend) CancelWorkflowInstance(ctx context.Context, instance *core.WorkflowInstance, event *history.Event) error {
 	// Read the instance to check if it exists
-	_, err := readInstance(ctx, rb.rdb, instance.InstanceID)
+	instanceState, err := readInstance(ctx, rb.rdb, instance.InstanceID)
 	if err != nil {
 		return err
 	}
 
 	// Cancel instance
 	if cmds, err := rb.rdb.Pipelined(ctx, func(p redis.Pipeliner) error {
-		return rb.addWorkflowInstanceEventP(ctx, p, instance, event)
+		return rb.addWorkflowInstanceEventP(ctx, p, instance, instanceState.Priority, event)
 	}); err != nil {
 		fmt.Println(cmds)
 		return fmt.Errorf("adding cancellation event to workflow instance: %w", err)
@@ -109,40 +111,343 @@ func (rb *redisBackend) CancelWorkflowInstance(ctx context.Context, instance *co
 	return nil
 }
 
+func (rb *redisBackend) TerminateWorkflowInstance(ctx context.Context, instance *core.WorkflowInstance, event *history.Event) error {
+	// Watch the instance so that a concurrently completed workflow task doesn't overwrite the state, and the
+	// instance doesn't finish after its state has been checked
+	return rb.watchInstance(ctx, instance.InstanceID, func(tx *redis.Tx) error {
+		instanceState, err := readInstanceTx(ctx, tx, instance.InstanceID)
+		if err != nil {
+			return err
+		}
+
+		if instanceState.Instance.ExecutionID != instance.ExecutionID {
+			return backend.ErrInstanceNotFound
+		}
+
+		if instanceState.State.Finished() {
+			return backend.ErrInstanceFinished
+		}
+
+		if _, err := tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			// Suspended instances don't get workflow tasks, make them active again so the termination is processed
+			if instanceState.State == core.WorkflowInstanceStateSuspended {
+				instanceState.State = core.WorkflowInstanceStateActive
+				instanceState.Attempts = 0
+
+				if err := updateInstanceP(ctx, p, instance.InstanceID, instanceState); err != nil {
+					return err
+				}
+			}
+
+			return rb.addWorkflowInstanceEventP(ctx, p, instance, instanceState.Priority, event)
+		}); err != nil {
+			return fmt.Errorf("adding termination event to workflow instance: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// watchRetries is how often a read-modify-write of an instance is tried again when the instance changed concurrently
+const watchRetries = 3
+
+// watchInstance calls f with a transaction that watches the instance. f reads the instance with the transaction and
+// writes it in a transactional pipeline of the transaction, which fails if the instance changed after it was read.
+// Then f is called again.
+func (rb *redisBackend) watchInstance(ctx context.Context, instanceID string, f func(tx *redis.Tx) error) error {
+	for i := 0; i < watchRetries; i++ {
+		err := rb.rdb.Watch(ctx, f, instanceKey(instanceID))
+		if !errors.Is(err, redis.TxFailedErr) {
+			return err
+		}
+	}
+
+	return fmt.Errorf("updating workflow instance: %w", redis.TxFailedErr)
+}
+
+func (rb *redisBackend) RemoveWorkflowInstance(ctx context.Context, instance *core.WorkflowInstance) error {
+	// Watch the instance so that it isn't removed if it becomes active again, e.g. by a reset, after its state has
+	// been checked
+	return rb.watchInstance(ctx, instance.InstanceID, func(tx *redis.Tx) error {
+		return rb.removeWorkflowInstance(ctx, tx, instance)
+	})
+}
+
+func (rb *redisBackend) removeWorkflowInstance(ctx context.Context, tx *redis.Tx, instance *core.WorkflowInstance) error {
+	instanceState, err := readInstanceTx(ctx, tx, instance.InstanceID)
+	if err != nil {
+		return err
+	}
+
+	if instanceState.Instance.ExecutionID != instance.ExecutionID {
+		return backend.ErrInstanceNotFound
+	}
+
+	if !instanceState.State.Finished() {
+		return backend.ErrInstanceNotFinished
+	}
+
+	h, err := rb.GetWorkflowInstanceHistory(ctx, instance, nil)
+	if err != nil {
+		return fmt.Errorf("getting workflow history: %w", err)
+	}
+
+	if rb.options.Archiver != nil {
+		if err := rb.options.Archiver.ArchiveWorkflowInstance(ctx, instance, h); err != nil {
+			return fmt.Errorf("archiving workflow instance: %w", err)
+		}
+	}
+
+	if _, err := tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		// Timers might still be scheduled for the finished instance, remove them so they don't fire for an
+		// instance that doesn't exist anymore
+		for _, event := range h {
+			if event.Type == history.EventType_TimerScheduled {
+				removeFutureEventP(ctx, p, instance, &event)
+			}
+		}
+
+		p.Del(ctx, instanceKey(instance.InstanceID), pendingEventsKey(instance.InstanceID), historyKey(instance.InstanceID), instanceChildrenKey(instance.InstanceID))
+		p.ZRem(ctx, instancesByCreation(), instance.InstanceID)
+		removeSearchAttributesP(ctx, p, instance.InstanceID, instanceState.SearchAttributes)
+
+		p.HIncrBy(ctx, instanceStatsKey(), statsField(instanceState.WorkflowName, instanceState.State), -1)
+		p.ZRem(ctx, instancesByCompletion(), instance.InstanceID)
+		p.ZRem(ctx, failedInstancesByCompletion(), instance.InstanceID)
+
+		if instanceState.Instance.SubWorkflow() {
+			p.ZRem(ctx, instanceChildrenKey(instanceState.Instance.ParentInstanceID), instance.InstanceID)
+		}
+
+		return nil
+	}); err != nil {
+		if err == redis.TxFailedErr {
+			return err
+		}
+
+		return fmt.Errorf("removing workflow instance: %w", err)
+	}
+
+	return nil
+}
+
+func (rb *redisBackend) ResetWorkflowInstance(
+	ctx context.Context,
+	instance *core.WorkflowInstance,
+	executionID string,
+	toSequenceID int64,
+	activityEvents, timerEvents, pendingEvents []history.Event,
+) error {
+	return rb.watchInstance(ctx, instance.InstanceID, func(tx *redis.Tx) error {
+		instanceState, err := readInstanceTx(ctx, tx, instance.InstanceID)
+		if err != nil {
+			return err
+		}
+
+		if instanceState.Instance.ExecutionID != instance.ExecutionID {
+			return backend.ErrInstanceNotFound
+		}
+
+		h, err := rb.GetWorkflowInstanceHistory(ctx, instance, nil)
+		if err != nil {
+			return fmt.Errorf("getting workflow history: %w", err)
+		}
+
+		newInstance := *instanceState.Instance
+		newInstance.ExecutionID = executionID
+
+		// Stream ids have to be increasing, so instead of deleting the newer events, re-create the history stream
+		var keep []history.Event
+		for _, event := range h {
+			if event.SequenceID < toSequenceID {
+				keep = append(keep, event)
+			}
+		}
+
+		instanceState.Instance = &newInstance
+		// Suspended instances stay suspended until they are resumed
+		if instanceState.State != core.WorkflowInstanceStateSuspended {
+			instanceState.State = core.WorkflowInstanceStateActive
+		}
+		instanceState.CompletedAt = nil
+		instanceState.LastSequenceID = 0
+		instanceState.LastError = ""
+		instanceState.Attempts = 0
+		if len(keep) > 0 {
+			instanceState.LastSequenceID = keep[len(keep)-1].SequenceID
+		}
+
+		if _, err := tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			// Remove timers of the previous execution
+			for _, event := range h {
+				if event.Type == history.EventType_TimerScheduled {
+					removeFutureEventP(ctx, p, instance, &event)
+				}
+			}
+
+			p.Del(ctx, historyKey(instance.InstanceID), pendingEventsKey(instance.InstanceID))
+
+			if err := addEventsToHistoryStreamP(ctx, p, historyKey(instance.InstanceID), keep); err != nil {
+				return fmt.Errorf("adding history events: %w", err)
+			}
+
+			if err := updateInstanceP(ctx, p, instance.InstanceID, instanceState); err != nil {
+				return fmt.Errorf("updating workflow instance: %w", err)
+			}
+
+			for _, activityEvent := range activityEvents {
+				if err := rb.activityQueue.Enqueue(ctx, p, activityEvent.ID, activityPriority(activityEvent), &activityData{
+					Instance: &newInstance,
+					ID:       activityEvent.ID,
+					Event:    activityEvent,
+				}); err != nil {
+					return fmt.Errorf("queueing activity task: %w", err)
+				}
+			}
+
+			for _, timerEvent := range timerEvents {
+				timerEvent := timerEvent
+				if err := addFutureEventP(ctx, p, &newInstance, instanceState.Priority, &timerEvent); err != nil {
+					return err
+				}
+			}
+
+			for _, event := range pendingEvents {
+				event := event
+				if err := rb.addWorkflowInstanceEventP(ctx, p, &newInstance, instanceState.Priority, &event); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}); err != nil {
+			return fmt.Errorf("resetting workflow instance: %w", err)
+		}
+
+		return nil
+	})
+}
+
 type instanceState struct {
-	Instance *core.WorkflowInstance     `json:"instance,omitempty"`
-	State    core.WorkflowInstanceState `json:"state,omitempty"`
+	Instance     *core.WorkflowInstance     `json:"instance,omitempty"`
+	WorkflowName string                     `json:"workflow_name,omitempty"`
+	State        core.WorkflowInstanceState `json:"state,omitempty"`
 
 	Metadata *core.WorkflowMetadata `json:"metadata,omitempty"`
 
+	SearchAttributes core.SearchAttributes `json:"search_attributes,omitempty"`
+
+	Memo core.Memo `json:"memo,omitempty"`
+
 	CreatedAt   time.Time  `json:"created_at,omitempty"`
 	CompletedAt *time.Time `json:"completed_at,omitempty"`
 
 	LastSequenceID int64 `json:"last_sequence_id,omitempty"`
+
+	LastError string `json:"last_error,omitempty"`
+
+	Priority core.Priority `json:"priority,omitempty"`
+
+	// Attempts is the number of times the current workflow task has been handed out to a worker
+	Attempts int `json:"attempts,omitempty"`
+}
+
+// createInstanceCmd creates an instance if it doesn't exist yet and counts it
+//
+// KEYS[1] - instance key
+// KEYS[2] - instance stats hash
+// ARGV[1] - instance state
+// ARGV[2] - stats field of the instance
+var createInstanceCmd = redis.NewScript(`
+	if redis.call("SET", KEYS[1], ARGV[1], "NX") then
+		redis.call("HINCRBY", KEYS[2], ARGV[2], 1)
+	end
+
+	return true
+`)
+
+// updateInstanceCmd stores the state of an instance and updates the stats if its state changed
+//
+// KEYS[1] - instance key
+// KEYS[2] - instance stats hash
+// KEYS[3] - instances by completion set
+// KEYS[4] - failed instances by completion set
+// ARGV[1] - instance state
+// ARGV[2] - instance id
+// ARGV[3] - stats field of the instance
+// ARGV[4] - completion time in milliseconds, 0 if the instance is not finished
+// ARGV[5] - "1" if the instance failed
+var updateInstanceCmd = redis.NewScript(`
+	local previous = redis.call("GET", KEYS[1])
+	if previous then
+		local state = cjson.decode(previous)
+		local field = tostring(state.state or 0) .. ":" .. (state.workflow_name or "")
+		if field ~= ARGV[3] then
+			redis.call("HINCRBY", KEYS[2], field, -1)
+			redis.call("HINCRBY", KEYS[2], ARGV[3], 1)
+		end
+	else
+		redis.call("HINCRBY", KEYS[2], ARGV[3], 1)
+	end
+
+	redis.call("SET", KEYS[1], ARGV[1])
+
+	if ARGV[4] ~= "0" then
+		redis.call("ZADD", KEYS[3], ARGV[4], ARGV[2])
+	else
+		redis.call("ZREM", KEYS[3], ARGV[2])
+	end
+
+	if ARGV[5] == "1" then
+		redis.call("ZADD", KEYS[4], ARGV[4], ARGV[2])
+	else
+		redis.call("ZREM", KEYS[4], ARGV[2])
+	end
+
+	return true
+`)
+
+// statsField is the field of instances in the stats hash, the state comes first since workflow names can contain
+// any character
+func statsField(workflowName string, state core.WorkflowInstanceState) string {
+	return fmt.Sprintf("%d:%s", state, workflowName)
 }
 
-func createInstanceP(ctx context.Context, p redis.Pipeliner, instance *core.WorkflowInstance, metadata *core.WorkflowMetadata, ignoreDuplicate bool) error {
+func createInstanceP(ctx context.Context, p redis.Pipeliner, instance *core.WorkflowInstance, a *history.ExecutionStartedAttributes, ignoreDuplicate bool) error {
 	key := instanceKey(instance.InstanceID)
 
 	createdAt := time.Now()
 
 	b, err := json.Marshal(&instanceState{
-		Instance:  instance,
-		State:     core.WorkflowInstanceStateActive,
-		Metadata:  metadata,
-		CreatedAt: createdAt,
+		Instance:         instance,
+		WorkflowName:     a.Name,
+		State:            core.WorkflowInstanceStateActive,
+		Metadata:         a.Metadata,
+		SearchAttributes: a.SearchAttributes,
+		Memo:             a.Memo,
+		CreatedAt:        createdAt,
+		Priority:         a.Priority.Clamp(),
 	})
 	if err != nil {
 		return fmt.Errorf("marshaling instance state: %w", err)
 	}
 
-	p.SetNX(ctx, key, string(b), 0)
+	createInstanceCmd.Run(ctx, p, []string{key, instanceStatsKey()}, string(b), statsField(a.Name, core.WorkflowInstanceStateActive))
 
 	p.ZAdd(ctx, instancesByCreation(), &redis.Z{
 		Member: instance.InstanceID,
 		Score:  float64(createdAt.UnixMilli()),
 	})
 
+	addSearchAttributesP(ctx, p, instance.InstanceID, a.SearchAttributes)
+
+	if instance.SubWorkflow() {
+		p.ZAddNX(ctx, instanceChildrenKey(instance.ParentInstanceID), &redis.Z{
+			Member: instance.InstanceID,
+			Score:  float64(createdAt.UnixMilli()),
+		})
+	}
+
 	return nil
 }
 
@@ -154,7 +459,19 @@ func updateInstanceP(ctx context.Context, p redis.Pipeliner, instanceID string,
 		return fmt.Errorf("marshaling instance state: %w", err)
 	}
 
-	p.Set(ctx, key, string(b), 0)
+	completedAt := int64(0)
+	if state.State.Finished() && state.CompletedAt != nil {
+		completedAt = state.CompletedAt.UnixMilli()
+	}
+
+	failed := "0"
+	if state.State == core.WorkflowInstanceStateFailed {
+		failed = "1"
+	}
+
+	updateInstanceCmd.Run(ctx, p,
+		[]string{key, instanceStatsKey(), instancesByCompletion(), failedInstancesByCompletion()},
+		string(b), instanceID, statsField(state.WorkflowName, state.State), completedAt, failed)
 
 	// CreatedAt does not change, so skip updating the instancesByCreation() ZSET
 
@@ -172,6 +489,11 @@ func readInstance(ctx context.Context, rdb redis.UniversalClient, instanceID str
 	return readInstancePipelineCmd(cmd)
 }
 
+// readInstanceTx reads the instance with a transaction that watches it
+func readInstanceTx(ctx context.Context, tx *redis.Tx, instanceID string) (*instanceState, error) {
+	return readInstancePipelineCmd(tx.Get(ctx, instanceKey(instanceID)))
+}
+
 func readInstanceP(ctx context.Context, p redis.Pipeliner, instanceID string) *redis.StringCmd {
 	key := instanceKey(instanceID)
 
@@ -12,6 +12,39 @@ func instancesByCreation() string {
 	return "instances-by-creation"
 }
 
+// searchAttributeKey is the set of instances with the given search attribute value, value is its JSON encoding
+func searchAttributeKey(name string, value []byte) string {
+	return fmt.Sprintf("sa:%v:%v", name, string(value))
+}
+
+// listInstancesKey is a temporary sorted set of the instances matching a listing
+func listInstancesKey(id string) string {
+	return fmt.Sprintf("list-instances:%v", id)
+}
+
+// instanceStatsKey is a hash counting the instances by state and workflow name, see statsField
+func instanceStatsKey() string {
+	return "instance-stats"
+}
+
+// instanceStatsBackfillField is the field of the stats hash that records when the instances created before the
+// counters existed have been counted. It's not a stats field, those always contain a colon.
+const instanceStatsBackfillField = "backfilled-at"
+
+// instancesByCompletion and failedInstancesByCompletion are sorted sets of finished instances, scored by the time
+// they completed
+func instancesByCompletion() string {
+	return "instances-by-completion"
+}
+
+func failedInstancesByCompletion() string {
+	return "failed-instances-by-completion"
+}
+
+func instanceChildrenKey(instanceID string) string {
+	return fmt.Sprintf("instance-children:%v", instanceID)
+}
+
 func pendingEventsKey(instanceID string) string {
 	return fmt.Sprintf("pending-events:%v", instanceID)
 }
@@ -31,3 +64,7 @@ func futureEventsKey() string {
 func futureEventKey(instanceID string, scheduleEventID int64) string {
 	return fmt.Sprintf("future-event:%v:%v", instanceID, scheduleEventID)
 }
+
+func rateLimitKey(key string) string {
+	return fmt.Sprintf("rate-limit:%v", key)
+}
@@ -4,18 +4,25 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/paveliak/go-workflows/internal/core"
 	"github.com/go-redis/redis/v8"
 	"github.com/google/uuid"
 )
 
+// taskQueue keeps a stream per priority level. Tasks with normal priority use the base stream key, the IDs of tasks
+// with other priorities are prefixed with their priority so that they can be found in their stream again.
 type taskQueue[T any] struct {
 	tasktype   string
 	setKey     string
 	streamKey  string
 	groupName  string
 	workerName string
+
+	priorities *core.PriorityScheduler
 }
 
 type TaskItem[T any] struct {
@@ -34,29 +41,34 @@ type KeyInfo struct {
 	SetKey    string
 }
 
-func newTaskQueue[T any](rdb redis.UniversalClient, tasktype string) (*taskQueue[T], error) {
+func newTaskQueue[T any](rdb redis.UniversalClient, tasktype string, priorities *core.PriorityScheduler) (*taskQueue[T], error) {
 	tq := &taskQueue[T]{
 		tasktype:   tasktype,
 		setKey:     "task-set:" + tasktype,
 		streamKey:  "task-stream:" + tasktype,
 		groupName:  "task-workers",
 		workerName: uuid.NewString(),
+		priorities: priorities,
 	}
 
-	// Create the consumer group
-	_, err := rdb.XGroupCreateMkStream(context.Background(), tq.streamKey, tq.groupName, "0").Result()
-	if err != nil {
-		// Ugly, check since there is no UPSERT for consumer groups. Might replace with a script
-		// using XINFO & XGROUP CREATE atomically
-		if err.Error() != "BUSYGROUP Consumer Group name already exists" {
-			return nil, fmt.Errorf("creating task queue: %w", err)
+	// Create the consumer groups
+	for _, priority := range core.Priorities() {
+		_, err := rdb.XGroupCreateMkStream(context.Background(), tq.priorityStreamKey(priority), tq.groupName, "0").Result()
+		if err != nil {
+			// Ugly, check since there is no UPSERT for consumer groups. Might replace with a script
+			// using XINFO & XGROUP CREATE atomically
+			if err.Error() != "BUSYGROUP Consumer Group name already exists" {
+				return nil, fmt.Errorf("creating task queue: %w", err)
+			}
 		}
 	}
 
 	// Pre-load script
 	cmds := map[string]*redis.StringCmd{
 		"enqueueCmd":  enqueueCmd.Load(context.Background(), rdb),
+		"dequeueCmd":  dequeueCmd.Load(context.Background(), rdb),
 		"completeCmd": completeCmd.Load(context.Background(), rdb),
+		"releaseCmd":  releaseCmd.Load(context.Background(), rdb),
 	}
 
 	for name, cmd := range cmds {
@@ -70,13 +82,44 @@ func newTaskQueue[T any](rdb redis.UniversalClient, tasktype string) (*taskQueue
 	return tq, nil
 }
 
-func (q *taskQueue[T]) Keys() KeyInfo {
+// Keys returns the keys of the stream for the given priority and of the set of queued tasks
+func (q *taskQueue[T]) Keys(priority core.Priority) KeyInfo {
 	return KeyInfo{
-		StreamKey: q.streamKey,
+		StreamKey: q.priorityStreamKey(priority),
 		SetKey:    q.setKey,
 	}
 }
 
+// priorityStreamKey returns the key of the stream for the given priority. Scripts that add tasks to a queue
+// directly have to follow the same scheme.
+func (q *taskQueue[T]) priorityStreamKey(priority core.Priority) string {
+	priority = priority.Clamp()
+	if priority == core.PriorityNormal {
+		return q.streamKey
+	}
+
+	return fmt.Sprintf("%s:%d", q.streamKey, priority)
+}
+
+func taskID(priority core.Priority, msgID string) string {
+	if priority == core.PriorityNormal {
+		return msgID
+	}
+
+	return fmt.Sprintf("%d:%s", priority, msgID)
+}
+
+// parseTaskID returns the priority and stream message id of a task
+func parseTaskID(taskID string) (core.Priority, string) {
+	if p, msgID, ok := strings.Cut(taskID, ":"); ok {
+		if priority, err := strconv.Atoi(p); err == nil {
+			return core.Priority(priority), msgID
+		}
+	}
+
+	return core.PriorityNormal, taskID
+}
+
 // KEYS[1] = set
 // KEYS[2] = stream
 // ARGV[1] = caller provided id of the task
@@ -91,31 +134,76 @@ var enqueueCmd = redis.NewScript(
 	return true
 `)
 
-func (q *taskQueue[T]) Enqueue(ctx context.Context, p redis.Pipeliner, id string, data *T) error {
+func (q *taskQueue[T]) Enqueue(ctx context.Context, p redis.Pipeliner, id string, priority core.Priority, data *T) error {
 	ds, err := json.Marshal(data)
 	if err != nil {
 		return err
 	}
 
-	enqueueCmd.Run(ctx, p, []string{q.setKey, q.streamKey}, id, string(ds))
+	enqueueCmd.Run(ctx, p, []string{q.setKey, q.priorityStreamKey(priority)}, id, string(ds))
 
 	return nil
 }
 
+// Recover an abandoned task or read a new one, checking the given streams in order. Abandoned tasks of all
+// priorities are recovered before new tasks are read.
+// KEYS[1..n] = streams
+// ARGV[1] = group
+// ARGV[2] = consumer
+// ARGV[3] = idle timeout in milliseconds
+// Returns the index of the stream and the message, or nil if there are no tasks
+var dequeueCmd = redis.NewScript(
+	`for i = 1, #KEYS do
+		local claimed = redis.call("XAUTOCLAIM", KEYS[i], ARGV[1], ARGV[2], ARGV[3], "0", "COUNT", 1)
+		local msgs = claimed[2]
+		if #msgs > 0 and msgs[1][2] then
+			return {i, msgs[1][1], msgs[1][2]}
+		end
+	end
+
+	for i = 1, #KEYS do
+		local streams = redis.call("XREADGROUP", "GROUP", ARGV[1], ARGV[2], "COUNT", 1, "STREAMS", KEYS[i], ">")
+		if streams then
+			local msg = streams[1][2][1]
+			return {i, msg[1], msg[2]}
+		end
+	end
+
+	return nil
+`)
+
 func (q *taskQueue[T]) Dequeue(ctx context.Context, rdb redis.UniversalClient, lockTimeout, timeout time.Duration) (*TaskItem[T], error) {
-	// Try to recover abandoned messages
-	task, err := q.recover(ctx, rdb, lockTimeout)
-	if err != nil {
-		return nil, fmt.Errorf("checking for abandoned tasks: %w", err)
+	priorities := q.priorities.Next()
+
+	streams := make([]string, len(priorities))
+	for i, priority := range priorities {
+		streams[i] = q.priorityStreamKey(priority)
+	}
+
+	// Try to recover abandoned tasks, then check for new tasks without blocking
+	r, err := dequeueCmd.Run(ctx, rdb, streams, q.groupName, q.workerName, lockTimeout.Milliseconds()).Slice()
+	if err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("dequeueing task: %w", err)
+	}
+
+	if len(r) == 3 {
+		msg, err := scriptResultToMsg(r[1], r[2])
+		if err != nil {
+			return nil, err
+		}
+
+		return msgToTaskItem[T](priorities[r[0].(int64)-1], msg)
 	}
 
-	if task != nil {
-		return task, nil
+	// Wait for new tasks in any stream
+	args := make([]string, 0, 2*len(streams))
+	args = append(args, streams...)
+	for range streams {
+		args = append(args, ">")
 	}
 
-	// Check for new tasks
-	ids, err := rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
-		Streams:  []string{q.streamKey, ">"},
+	res, err := rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Streams:  args,
 		Group:    q.groupName,
 		Consumer: q.workerName,
 		Count:    1,
@@ -125,22 +213,43 @@ func (q *taskQueue[T]) Dequeue(ctx context.Context, rdb redis.UniversalClient, l
 		return nil, fmt.Errorf("dequeueing task: %w", err)
 	}
 
-	if len(ids) == 0 || len(ids[0].Messages) == 0 || err == redis.Nil {
-		return nil, nil
+	// Tasks might have been added to multiple streams at the same time. Take the one from the stream checked first
+	// and return the others to their queues.
+	var task *TaskItem[T]
+	for i, priority := range priorities {
+		for _, s := range res {
+			if s.Stream != streams[i] || len(s.Messages) == 0 {
+				continue
+			}
+
+			if task != nil {
+				if err := q.Release(ctx, rdb, taskID(priority, s.Messages[0].ID)); err != nil {
+					return nil, err
+				}
+
+				continue
+			}
+
+			task, err = msgToTaskItem[T](priority, &s.Messages[0])
+			if err != nil {
+				return nil, err
+			}
+		}
 	}
 
-	msg := ids[0].Messages[0]
-	return msgToTaskItem[T](&msg)
+	return task, nil
 }
 
 func (q *taskQueue[T]) Extend(ctx context.Context, p redis.Pipeliner, taskID string) error {
+	priority, msgID := parseTaskID(taskID)
+
 	// Claiming a message resets the idle timer. Don't use the `JUSTID` variant, we
 	// want to increase the retry counter.
 	_, err := p.XClaim(ctx, &redis.XClaimArgs{
-		Stream:   q.streamKey,
+		Stream:   q.priorityStreamKey(priority),
 		Group:    q.groupName,
 		Consumer: q.workerName,
-		Messages: []string{taskID},
+		Messages: []string{msgID},
 		MinIdle:  0, // Always claim this message
 	}).Result()
 	if err != nil && err != redis.Nil {
@@ -172,7 +281,9 @@ var completeCmd = redis.NewScript(
 `)
 
 func (q *taskQueue[T]) Complete(ctx context.Context, p redis.Pipeliner, taskID string) (*redis.Cmd, error) {
-	cmd := completeCmd.Run(ctx, p, []string{q.setKey, q.streamKey}, taskID, q.groupName)
+	priority, msgID := parseTaskID(taskID)
+
+	cmd := completeCmd.Run(ctx, p, []string{q.setKey, q.priorityStreamKey(priority)}, msgID, q.groupName)
 	if err := cmd.Err(); err != nil && err != redis.Nil {
 		return nil, fmt.Errorf("completing task: %w", err)
 	}
@@ -180,39 +291,125 @@ func (q *taskQueue[T]) Complete(ctx context.Context, p redis.Pipeliner, taskID s
 	return cmd, nil
 }
 
+// Re-add the task to the end of the stream, this makes it available to all workers without waiting for the
+// lock to expire. The set entry is kept, the task is still queued.
+// KEYS[1] = stream
+// ARGV[1] = task id
+// ARGV[2] = group
+var releaseCmd = redis.NewScript(
+	`local task = redis.call("XRANGE", KEYS[1], ARGV[1], ARGV[1])
+	if #task == 0 then
+		return nil
+	end
+	redis.call("XACK", KEYS[1], ARGV[2], ARGV[1])
+	redis.call("XDEL", KEYS[1], ARGV[1])
+
+	return redis.call("XADD", KEYS[1], "*", unpack(task[1][2]))
+`)
+
+func (q *taskQueue[T]) Release(ctx context.Context, rdb redis.UniversalClient, taskID string) error {
+	priority, msgID := parseTaskID(taskID)
+
+	if err := releaseCmd.Run(ctx, rdb, []string{q.priorityStreamKey(priority)}, msgID, q.groupName).Err(); err != nil && err != redis.Nil {
+		return fmt.Errorf("releasing task: %w", err)
+	}
+
+	return nil
+}
+
+// Delay keeps the task locked, but lets its lock expire after delay instead of after the full lock timeout. Any
+// worker can recover the task then. Delays longer than the lock timeout end with the lock timeout.
+func (q *taskQueue[T]) Delay(ctx context.Context, rdb redis.UniversalClient, taskID string, lockTimeout, delay time.Duration) error {
+	priority, msgID := parseTaskID(taskID)
+
+	idle := lockTimeout - delay
+	if idle < 0 {
+		idle = 0
+	}
+
+	// Claiming a message with an idle time backdates its last delivery, tasks are recovered once they have been idle
+	// for the lock timeout
+	if err := rdb.Do(
+		ctx, "XCLAIM", q.priorityStreamKey(priority), q.groupName, q.workerName, 0, msgID, "IDLE", idle.Milliseconds(), "JUSTID",
+	).Err(); err != nil && err != redis.Nil {
+		return fmt.Errorf("delaying task: %w", err)
+	}
+
+	return nil
+}
+
 func (q *taskQueue[T]) Data(ctx context.Context, p redis.Pipeliner, taskID string) (*TaskItem[T], error) {
-	msg, err := p.XRange(ctx, q.streamKey, taskID, taskID).Result()
+	priority, msgID := parseTaskID(taskID)
+
+	msg, err := p.XRange(ctx, q.priorityStreamKey(priority), msgID, msgID).Result()
 	if err != nil && err != redis.Nil {
 		return nil, fmt.Errorf("finding task: %w", err)
 	}
 
-	return msgToTaskItem[T](&msg[0])
+	return msgToTaskItem[T](priority, &msg[0])
 }
 
-func (q *taskQueue[T]) recover(ctx context.Context, rdb redis.UniversalClient, idleTimeout time.Duration) (*TaskItem[T], error) {
-	// Ignore the start argument, we are deleting tasks as they are completed, so we'll always
-	// start this scan from the beginning.
-	msgs, _, err := rdb.XAutoClaim(ctx, &redis.XAutoClaimArgs{
-		Stream:   q.streamKey,
-		Group:    q.groupName,
-		Consumer: q.workerName,
-		MinIdle:  idleTimeout,
-		Count:    1,   // Get at most one abandoned task
-		Start:    "0", // Start at the beginning of the pending items
-	}).Result()
+// Size returns the number of queued tasks, including tasks that are being worked on
+func (q *taskQueue[T]) Size(ctx context.Context, rdb redis.UniversalClient) (int64, error) {
+	return rdb.SCard(ctx, q.setKey).Result()
+}
 
-	if err != nil {
-		return nil, fmt.Errorf("recovering tasks: %w", err)
+// Oldest returns the time the oldest task in any of the streams was added, or nil if there are no tasks. Released
+// tasks are re-added and count from the time they were released.
+func (q *taskQueue[T]) Oldest(ctx context.Context, rdb redis.UniversalClient) (*time.Time, error) {
+	var oldest *time.Time
+
+	for _, priority := range core.Priorities() {
+		msgs, err := rdb.XRangeN(ctx, q.priorityStreamKey(priority), "-", "+", 1).Result()
+		if err != nil && err != redis.Nil {
+			return nil, fmt.Errorf("reading oldest task: %w", err)
+		}
+
+		if len(msgs) == 0 {
+			continue
+		}
+
+		// Stream message ids start with the time they were added in milliseconds
+		ms, _, _ := strings.Cut(msgs[0].ID, "-")
+		addedAt, err := strconv.ParseInt(ms, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parsing task id %v: %w", msgs[0].ID, err)
+		}
+
+		t := time.UnixMilli(addedAt)
+		if oldest == nil || t.Before(*oldest) {
+			oldest = &t
+		}
+	}
+
+	return oldest, nil
+}
+
+// scriptResultToMsg converts a stream message returned from a script
+func scriptResultToMsg(id interface{}, fields interface{}) (*redis.XMessage, error) {
+	msgID, ok := id.(string)
+	if !ok {
+		return nil, fmt.Errorf("unexpected message id %v", id)
+	}
+
+	values, ok := fields.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected message fields %v", fields)
+	}
+
+	msg := &redis.XMessage{
+		ID:     msgID,
+		Values: make(map[string]interface{}, len(values)/2),
 	}
 
-	if len(msgs) == 0 {
-		return nil, nil
+	for i := 0; i+1 < len(values); i += 2 {
+		msg.Values[values[i].(string)] = values[i+1]
 	}
 
-	return msgToTaskItem[T](&msgs[0])
+	return msg, nil
 }
 
-func msgToTaskItem[T any](msg *redis.XMessage) (*TaskItem[T], error) {
+func msgToTaskItem[T any](priority core.Priority, msg *redis.XMessage) (*TaskItem[T], error) {
 	id := msg.Values["id"].(string)
 	data := msg.Values["data"].(string)
 
@@ -224,7 +421,7 @@ func msgToTaskItem[T any](msg *redis.XMessage) (*TaskItem[T], error) {
 	}
 
 	return &TaskItem[T]{
-		TaskID: msg.ID,
+		TaskID: taskID(priority, msg.ID),
 		ID:     id,
 		Data:   t,
 	}, nil
@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/paveliak/go-workflows/internal/core"
 	"github.com/go-redis/redis/v8"
 	"github.com/stretchr/testify/require"
 )
@@ -33,7 +34,7 @@ func Test_TaskQueue(t *testing.T) {
 		{
 			name: "Create queue",
 			f: func(t *testing.T) {
-				q, err := newTaskQueue[any](client, "test")
+				q, err := newTaskQueue[any](client, "test", core.NewPriorityScheduler(0))
 				require.NoError(t, err)
 				require.NotNil(t, q)
 			},
@@ -41,13 +42,13 @@ func Test_TaskQueue(t *testing.T) {
 		{
 			name: "Simple enqueue/dequeue",
 			f: func(t *testing.T) {
-				q, err := newTaskQueue[any](client, "test")
+				q, err := newTaskQueue[any](client, "test", core.NewPriorityScheduler(0))
 				require.NoError(t, err)
 
 				ctx := context.Background()
 
 				_, err = client.Pipelined(ctx, func(p redis.Pipeliner) error {
-					return q.Enqueue(ctx, p, "t1", nil)
+					return q.Enqueue(ctx, p, "t1", core.PriorityNormal, nil)
 				})
 				require.NoError(t, err)
 
@@ -60,18 +61,18 @@ func Test_TaskQueue(t *testing.T) {
 		{
 			name: "Guarantee uniqueness",
 			f: func(t *testing.T) {
-				q, err := newTaskQueue[any](client, "test")
+				q, err := newTaskQueue[any](client, "test", core.NewPriorityScheduler(0))
 				require.NoError(t, err)
 
 				ctx := context.Background()
 
 				_, err = client.Pipelined(ctx, func(p redis.Pipeliner) error {
-					return q.Enqueue(ctx, p, "t1", nil)
+					return q.Enqueue(ctx, p, "t1", core.PriorityNormal, nil)
 				})
 				require.NoError(t, err)
 
 				_, err = client.Pipelined(ctx, func(p redis.Pipeliner) error {
-					return q.Enqueue(ctx, p, "t1", nil)
+					return q.Enqueue(ctx, p, "t1", core.PriorityNormal, nil)
 				})
 				require.NoError(t, err)
 
@@ -86,7 +87,7 @@ func Test_TaskQueue(t *testing.T) {
 				require.NoError(t, err)
 
 				_, err = client.Pipelined(ctx, func(p redis.Pipeliner) error {
-					return q.Enqueue(ctx, p, "t1", nil)
+					return q.Enqueue(ctx, p, "t1", core.PriorityNormal, nil)
 				})
 				require.NoError(t, err)
 			},
@@ -101,11 +102,11 @@ func Test_TaskQueue(t *testing.T) {
 
 				ctx := context.Background()
 
-				q, err := newTaskQueue[foo](client, "test")
+				q, err := newTaskQueue[foo](client, "test", core.NewPriorityScheduler(0))
 				require.NoError(t, err)
 
 				_, err = client.Pipelined(ctx, func(p redis.Pipeliner) error {
-					return q.Enqueue(ctx, p, "t1", &foo{
+					return q.Enqueue(ctx, p, "t1", core.PriorityNormal, &foo{
 						Count: 1,
 						Name:  "bar",
 					})
@@ -123,16 +124,16 @@ func Test_TaskQueue(t *testing.T) {
 		{
 			name: "Simple enqueue/dequeue different worker",
 			f: func(t *testing.T) {
-				q, _ := newTaskQueue[any](client, "test")
+				q, _ := newTaskQueue[any](client, "test", core.NewPriorityScheduler(0))
 
 				ctx := context.Background()
 
 				_, err := client.Pipelined(ctx, func(p redis.Pipeliner) error {
-					return q.Enqueue(ctx, p, "t1", nil)
+					return q.Enqueue(ctx, p, "t1", core.PriorityNormal, nil)
 				})
 				require.NoError(t, err)
 
-				q2, _ := newTaskQueue[any](client, "test")
+				q2, _ := newTaskQueue[any](client, "test", core.NewPriorityScheduler(0))
 				require.NoError(t, err)
 
 				// Dequeue using second worker
@@ -145,13 +146,13 @@ func Test_TaskQueue(t *testing.T) {
 		{
 			name: "Complete removes task",
 			f: func(t *testing.T) {
-				q, _ := newTaskQueue[any](client, "test")
-				q2, _ := newTaskQueue[any](client, "test")
+				q, _ := newTaskQueue[any](client, "test", core.NewPriorityScheduler(0))
+				q2, _ := newTaskQueue[any](client, "test", core.NewPriorityScheduler(0))
 
 				ctx := context.Background()
 
 				_, err := client.Pipelined(ctx, func(p redis.Pipeliner) error {
-					return q.Enqueue(ctx, p, "t1", nil)
+					return q.Enqueue(ctx, p, "t1", core.PriorityNormal, nil)
 				})
 				require.NoError(t, err)
 
@@ -177,16 +178,16 @@ func Test_TaskQueue(t *testing.T) {
 		{
 			name: "Recover task",
 			f: func(t *testing.T) {
-				q, _ := newTaskQueue[any](client, "test")
+				q, _ := newTaskQueue[any](client, "test", core.NewPriorityScheduler(0))
 
 				ctx := context.Background()
 
 				_, err := client.Pipelined(ctx, func(p redis.Pipeliner) error {
-					return q.Enqueue(ctx, p, "t1", nil)
+					return q.Enqueue(ctx, p, "t1", core.PriorityNormal, nil)
 				})
 				require.NoError(t, err)
 
-				q2, _ := newTaskQueue[any](client, "test")
+				q2, _ := newTaskQueue[any](client, "test", core.NewPriorityScheduler(0))
 				require.NoError(t, err)
 
 				task, err := q2.Dequeue(ctx, client, lockTimeout, blockTimeout)
@@ -203,20 +204,77 @@ func Test_TaskQueue(t *testing.T) {
 				require.Equal(t, task, recoveredTask)
 			},
 		},
+		{
+			name: "Dequeue by priority",
+			f: func(t *testing.T) {
+				q, _ := newTaskQueue[any](client, "test", core.NewPriorityScheduler(0))
+
+				ctx := context.Background()
+
+				_, err := client.Pipelined(ctx, func(p redis.Pipeliner) error {
+					if err := q.Enqueue(ctx, p, "low", core.PriorityLow, nil); err != nil {
+						return err
+					}
+
+					if err := q.Enqueue(ctx, p, "normal", core.PriorityNormal, nil); err != nil {
+						return err
+					}
+
+					return q.Enqueue(ctx, p, "high", core.PriorityHigh, nil)
+				})
+				require.NoError(t, err)
+
+				for _, id := range []string{"high", "normal", "low"} {
+					task, err := q.Dequeue(ctx, client, lockTimeout, blockTimeout)
+					require.NoError(t, err)
+					require.NotNil(t, task)
+					require.Equal(t, id, task.ID)
+
+					_, err = client.Pipelined(ctx, func(p redis.Pipeliner) error {
+						_, err := q.Complete(ctx, p, task.TaskID)
+						return err
+					})
+					require.NoError(t, err)
+				}
+			},
+		},
+		{
+			name: "Fairness prevents starvation",
+			f: func(t *testing.T) {
+				q, _ := newTaskQueue[any](client, "test", core.NewPriorityScheduler(1))
+
+				ctx := context.Background()
+
+				_, err := client.Pipelined(ctx, func(p redis.Pipeliner) error {
+					if err := q.Enqueue(ctx, p, "high", core.PriorityHighest, nil); err != nil {
+						return err
+					}
+
+					return q.Enqueue(ctx, p, "low", core.PriorityHigh, nil)
+				})
+				require.NoError(t, err)
+
+				// Every dequeue prefers a lower priority level in turn, starting with PriorityHigh
+				task, err := q.Dequeue(ctx, client, lockTimeout, blockTimeout)
+				require.NoError(t, err)
+				require.NotNil(t, task)
+				require.Equal(t, "low", task.ID)
+			},
+		},
 		{
 			name: "Extending task prevents recovering",
 			f: func(t *testing.T) {
-				q, _ := newTaskQueue[any](client, "test")
+				q, _ := newTaskQueue[any](client, "test", core.NewPriorityScheduler(0))
 
 				ctx := context.Background()
 
 				_, err := client.Pipelined(ctx, func(p redis.Pipeliner) error {
-					return q.Enqueue(ctx, p, "t1", nil)
+					return q.Enqueue(ctx, p, "t1", core.PriorityNormal, nil)
 				})
 				require.NoError(t, err)
 
 				// Create second worker (with different name)
-				q2, _ := newTaskQueue[any](client, "test")
+				q2, _ := newTaskQueue[any](client, "test", core.NewPriorityScheduler(0))
 				require.NoError(t, err)
 
 				task, err := q2.Dequeue(ctx, client, lockTimeout, blockTimeout)
@@ -0,0 +1,62 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// KEYS[1] - rate limit key
+// ARGV[1] - rate in tokens per second
+// ARGV[2] - burst
+//
+// Returns the number of seconds to wait before trying again, or 0 if a token was taken. Uses the server time so
+// that all workers agree on the refill.
+var takeRateLimitTokenCmd = redis.NewScript(`
+	local t = redis.call("TIME")
+	local now = tonumber(t[1]) + tonumber(t[2]) / 1000000
+	local rate = tonumber(ARGV[1])
+	local burst = tonumber(ARGV[2])
+
+	local bucket = redis.call("HMGET", KEYS[1], "tokens", "updated_at")
+	local tokens = tonumber(bucket[1])
+	local updatedAt = tonumber(bucket[2])
+	if tokens == nil then
+		tokens = burst
+		updatedAt = now
+	end
+
+	if now > updatedAt then
+		tokens = math.min(burst, tokens + (now - updatedAt) * rate)
+	end
+
+	local wait = 0
+	if tokens >= 1 then
+		tokens = tokens - 1
+	else
+		wait = (1 - tokens) / rate
+	end
+
+	redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "updated_at", tostring(now))
+	-- Buckets are full again after this time, no need to keep them around
+	redis.call("EXPIRE", KEYS[1], math.ceil(burst / rate) + 1)
+
+	return tostring(wait)
+`)
+
+func (rb *redisBackend) TakeRateLimitToken(ctx context.Context, key string, rate float64, burst int) (time.Duration, error) {
+	r, err := takeRateLimitTokenCmd.Run(ctx, rb.rdb, []string{rateLimitKey(key)}, rate, burst).Text()
+	if err != nil {
+		return 0, fmt.Errorf("taking rate limit token: %w", err)
+	}
+
+	wait, err := strconv.ParseFloat(r, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parsing rate limit wait time: %w", err)
+	}
+
+	return time.Duration(wait * float64(time.Second)), nil
+}
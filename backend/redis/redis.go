@@ -40,16 +40,6 @@ func WithBackendOptions(opts ...backend.BackendOption) RedisBackendOption {
 var _ backend.Backend = (*redisBackend)(nil)
 
 func NewRedisBackend(client redis.UniversalClient, opts ...RedisBackendOption) (*redisBackend, error) {
-	workflowQueue, err := newTaskQueue[any](client, "workflows")
-	if err != nil {
-		return nil, fmt.Errorf("creating workflow task queue: %w", err)
-	}
-
-	activityQueue, err := newTaskQueue[activityData](client, "activities")
-	if err != nil {
-		return nil, fmt.Errorf("creating activity task queue: %w", err)
-	}
-
 	// Default options
 	options := &RedisOptions{
 		Options:      backend.ApplyOptions(),
@@ -60,6 +50,16 @@ func NewRedisBackend(client redis.UniversalClient, opts ...RedisBackendOption) (
 		opt(options)
 	}
 
+	workflowQueue, err := newTaskQueue[any](client, "workflows", core.NewPriorityScheduler(options.PriorityFairnessInterval))
+	if err != nil {
+		return nil, fmt.Errorf("creating workflow task queue: %w", err)
+	}
+
+	activityQueue, err := newTaskQueue[activityData](client, "activities", core.NewPriorityScheduler(options.PriorityFairnessInterval))
+	if err != nil {
+		return nil, fmt.Errorf("creating activity task queue: %w", err)
+	}
+
 	rb := &redisBackend{
 		rdb:     client,
 		options: options,
@@ -73,6 +73,8 @@ func NewRedisBackend(client redis.UniversalClient, opts ...RedisBackendOption) (
 	ctx := context.Background()
 	cmds := map[string]*redis.StringCmd{
 		"addEventsToStreamCmd":   addEventsToStreamCmd.Load(ctx, rb.rdb),
+		"createInstanceCmd":      createInstanceCmd.Load(ctx, rb.rdb),
+		"updateInstanceCmd":      updateInstanceCmd.Load(ctx, rb.rdb),
 		"addFutureEventCmd":      addFutureEventCmd.Load(ctx, rb.rdb),
 		"futureEventsCmd":        futureEventsCmd.Load(ctx, rb.rdb),
 		"removeFutureEventCmd":   removeFutureEventCmd.Load(ctx, rb.rdb),
@@ -87,6 +89,10 @@ func NewRedisBackend(client redis.UniversalClient, opts ...RedisBackendOption) (
 		}
 	}
 
+	if err := rb.backfillInstanceStats(ctx); err != nil {
+		return nil, fmt.Errorf("backfilling instance counters: %w", err)
+	}
+
 	return rb, nil
 }
 
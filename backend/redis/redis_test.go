@@ -10,9 +10,12 @@ import (
 
 	"github.com/paveliak/go-workflows/backend"
 	"github.com/paveliak/go-workflows/backend/test"
+	"github.com/paveliak/go-workflows/internal/core"
 	"github.com/paveliak/go-workflows/internal/history"
 	"github.com/paveliak/go-workflows/log"
 	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
 )
 
 const (
@@ -58,6 +61,38 @@ func Test_EndToEndRedisBackend(t *testing.T) {
 	test.EndToEndBackendTest(t, setup, nil)
 }
 
+func Test_RedisBackend_BackfillsInstanceStats(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	ctx := context.Background()
+
+	client := getClient()
+	rb := getCreateBackend(client, true)().(*redisBackend)
+
+	instance := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+	require.NoError(t, rb.CreateWorkflowInstance(ctx, instance, history.NewHistoryEvent(
+		1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{Name: "wf"})))
+
+	// Instance created by a version without the counters
+	require.NoError(t, client.Del(ctx, instanceStatsKey()).Err())
+	time.Sleep(2 * time.Millisecond)
+
+	require.NoError(t, rb.backfillInstanceStats(ctx))
+
+	count, err := client.HGet(ctx, instanceStatsKey(), statsField("wf", core.WorkflowInstanceStateActive)).Int64()
+	require.NoError(t, err)
+	require.Equal(t, int64(1), count)
+
+	// The counters exist now, instances are not counted again
+	require.NoError(t, rb.backfillInstanceStats(ctx))
+
+	count, err = client.HGet(ctx, instanceStatsKey(), statsField("wf", core.WorkflowInstanceStateActive)).Int64()
+	require.NoError(t, err)
+	require.Equal(t, int64(1), count)
+}
+
 func getClient() redis.UniversalClient {
 	client := redis.NewUniversalClient(&redis.UniversalOptions{
 		Addrs:    []string{address},
@@ -0,0 +1,180 @@
+package redis
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/paveliak/go-workflows/backend"
+	"github.com/paveliak/go-workflows/internal/core"
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+// listInstancesExpiration bounds the lifetime of the temporary set of a listing, in case it's not deleted
+const listInstancesExpiration = time.Minute
+
+func (rb *redisBackend) ListWorkflowInstances(ctx context.Context, filter *backend.WorkflowInstanceFilter) ([]*backend.WorkflowInstanceRef, error) {
+	sa, err := core.NewSearchAttributes(filter.SearchAttributes)
+	if err != nil {
+		return nil, err
+	}
+
+	var maxScore *int64
+
+	if filter.AfterInstanceID != "" {
+		scores, err := rb.rdb.ZMScore(ctx, instancesByCreation(), filter.AfterInstanceID).Result()
+		if err != nil {
+			return nil, fmt.Errorf("getting instance score for %v: %w", filter.AfterInstanceID, err)
+		}
+
+		if len(scores) == 0 {
+			return nil, nil
+		}
+
+		score := int64(scores[0])
+		maxScore = &score
+	}
+
+	if !filter.CreatedBefore.IsZero() {
+		if before := filter.CreatedBefore.UnixMilli(); maxScore == nil || before < *maxScore {
+			maxScore = &before
+		}
+	}
+
+	max := "+inf"
+	if maxScore != nil {
+		max = fmt.Sprintf("(%v", *maxScore)
+	}
+
+	min := "-inf"
+	if !filter.CreatedAfter.IsZero() {
+		min = fmt.Sprintf("%v", filter.CreatedAfter.UnixMilli())
+	}
+
+	key := instancesByCreation()
+
+	if len(sa) > 0 {
+		// Intersect the instances by creation time with the sets of instances with the search attribute values. The
+		// result keeps the creation time as score, so it can be read like the full index.
+		key = listInstancesKey(uuid.NewString())
+
+		store := &redis.ZStore{
+			Keys:    []string{instancesByCreation()},
+			Weights: []float64{1},
+		}
+
+		for name, value := range sa {
+			store.Keys = append(store.Keys, searchAttributeKey(name, value))
+			store.Weights = append(store.Weights, 0)
+		}
+
+		if _, err := rb.rdb.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			p.ZInterStore(ctx, key, store)
+			p.Expire(ctx, key, listInstancesExpiration)
+
+			return nil
+		}); err != nil {
+			return nil, fmt.Errorf("intersecting search attributes: %w", err)
+		}
+
+		defer rb.rdb.Del(ctx, key)
+	}
+
+	count := filter.Limit()
+
+	var refs []*backend.WorkflowInstanceRef
+
+	// Keep reading pages until enough instances match the rest of the filter
+	for offset := int64(0); len(refs) < count; offset += int64(count) {
+		result, err := rb.rdb.ZRangeArgs(ctx, redis.ZRangeArgs{
+			Key:     key,
+			Stop:    max,
+			Start:   min,
+			ByScore: true,
+			Rev:     true,
+			Offset:  offset,
+			Count:   int64(count),
+		}).Result()
+		if err != nil {
+			return nil, fmt.Errorf("getting instances after %v: %w", max, err)
+		}
+
+		if len(result) == 0 {
+			break
+		}
+
+		keys := make([]string, 0, len(result))
+		for _, instanceID := range result {
+			keys = append(keys, instanceKey(instanceID))
+		}
+
+		instances, err := rb.rdb.MGet(ctx, keys...).Result()
+		if err != nil {
+			return nil, fmt.Errorf("getting instances: %w", err)
+		}
+
+		for _, instance := range instances {
+			if instance == nil {
+				continue
+			}
+
+			var state instanceState
+			if err := json.Unmarshal([]byte(instance.(string)), &state); err != nil {
+				return nil, fmt.Errorf("unmarshaling instance state: %w", err)
+			}
+
+			if !filter.Matches(state.WorkflowName, state.State, state.CreatedAt, state.CompletedAt) ||
+				!hasSearchAttributes(state.SearchAttributes, sa) {
+				continue
+			}
+
+			refs = append(refs, &backend.WorkflowInstanceRef{
+				Instance:         state.Instance,
+				WorkflowName:     state.WorkflowName,
+				State:            state.State,
+				CreatedAt:        state.CreatedAt,
+				CompletedAt:      state.CompletedAt,
+				SearchAttributes: state.SearchAttributes.Values(),
+				Memo:             state.Memo,
+			})
+
+			if len(refs) == count {
+				break
+			}
+		}
+
+		if len(result) < count {
+			break
+		}
+	}
+
+	return refs, nil
+}
+
+// addSearchAttributesP adds the instance to the sets of its search attribute values
+func addSearchAttributesP(ctx context.Context, p redis.Pipeliner, instanceID string, sa core.SearchAttributes) {
+	for name, value := range sa {
+		p.SAdd(ctx, searchAttributeKey(name, value), instanceID)
+	}
+}
+
+// removeSearchAttributesP removes the instance from the sets of its search attribute values
+func removeSearchAttributesP(ctx context.Context, p redis.Pipeliner, instanceID string, sa core.SearchAttributes) {
+	for name, value := range sa {
+		p.SRem(ctx, searchAttributeKey(name, value), instanceID)
+	}
+}
+
+func hasSearchAttributes(sa core.SearchAttributes, required core.SearchAttributes) bool {
+	for name, value := range required {
+		v, ok := sa[name]
+		if !ok || !bytes.Equal(v, value) {
+			return false
+		}
+	}
+
+	return true
+}
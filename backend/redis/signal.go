@@ -26,7 +26,7 @@ func (rb *redisBackend) SignalWorkflow(ctx context.Context, instanceID string, e
 	defer span.End()
 
 	if _, err = rb.rdb.TxPipelined(ctx, func(p redis.Pipeliner) error {
-		if err := rb.addWorkflowInstanceEventP(ctx, p, instanceState.Instance, &event); err != nil {
+		if err := rb.addWorkflowInstanceEventP(ctx, p, instanceState.Instance, instanceState.Priority, &event); err != nil {
 			return fmt.Errorf("adding event to stream: %w", err)
 		}
 
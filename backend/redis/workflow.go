@@ -7,6 +7,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/paveliak/go-workflows/backend"
 	"github.com/paveliak/go-workflows/internal/core"
 	"github.com/paveliak/go-workflows/internal/history"
 	"github.com/paveliak/go-workflows/internal/task"
@@ -23,7 +24,7 @@ import (
 // - Remove event from future event set and delete event data
 //
 // KEYS[1] - future event set key
-// KEYS[2] - workflow task queue stream
+// KEYS[2] - workflow task queue stream for normal priority, streams for other priorities use it as prefix
 // KEYS[3] - workflow task queue set
 // ARGV[1] - current timestamp for zrange
 //
@@ -39,10 +40,16 @@ var futureEventsCmd = redis.NewScript(`
 		local pending_events_key = "pending-events:" .. instanceID
 		redis.call("XADD", pending_events_key, "*", "event", eventData)
 
-		-- Try to queue workflow task
+		-- Try to queue workflow task, see taskQueue.priorityStreamKey
 		local already_queued = redis.call("SADD", KEYS[3], instanceID)
 		if already_queued ~= 0 then
-			redis.call("XADD", KEYS[2], "*", "id", instanceID, "data", "")
+			local stream = KEYS[2]
+			local priority = redis.call("HGET", events[i], "priority")
+			if priority and priority ~= "0" then
+				stream = stream .. ":" .. priority
+			end
+
+			redis.call("XADD", stream, "*", "id", instanceID, "data", "")
 		end
 
 		-- Delete event hash data
@@ -58,7 +65,7 @@ func (rb *redisBackend) GetWorkflowTask(ctx context.Context) (*task.Workflow, er
 	now := time.Now().UnixMilli()
 	nowStr := strconv.FormatInt(now, 10)
 
-	queueKeys := rb.workflowQueue.Keys()
+	queueKeys := rb.workflowQueue.Keys(core.PriorityNormal)
 
 	if _, err := futureEventsCmd.Run(ctx, rb.rdb, []string{
 		futureEventsKey(),
@@ -78,9 +85,42 @@ func (rb *redisBackend) GetWorkflowTask(ctx context.Context) (*task.Workflow, er
 		return nil, nil
 	}
 
-	instanceState, err := readInstance(ctx, rb.rdb, instanceTask.ID)
-	if err != nil {
-		return nil, fmt.Errorf("reading workflow instance: %w", err)
+	// Other workers can't get a task for the instance while it's locked, but it can still be suspended, terminated,
+	// or reset concurrently, so count the attempt in a transaction that watches the instance
+	var instanceState *instanceState
+	if err := rb.watchInstance(ctx, instanceTask.ID, func(tx *redis.Tx) error {
+		var err error
+		instanceState, err = readInstanceTx(ctx, tx, instanceTask.ID)
+		if err != nil {
+			return fmt.Errorf("reading workflow instance: %w", err)
+		}
+
+		if instanceState.State == core.WorkflowInstanceStateSuspended {
+			// Drop the task but keep the pending events, the instance is queued again when it's resumed
+			if _, err := tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+				_, err := rb.workflowQueue.Complete(ctx, p, instanceTask.TaskID)
+				return err
+			}); err != nil {
+				return fmt.Errorf("dropping workflow task for suspended instance: %w", err)
+			}
+
+			return nil
+		}
+
+		instanceState.Attempts++
+		if _, err := tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			return updateInstanceP(ctx, p, instanceTask.ID, instanceState)
+		}); err != nil {
+			return fmt.Errorf("updating workflow task attempts: %w", err)
+		}
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	if instanceState.State == core.WorkflowInstanceStateSuspended {
+		return nil, nil
 	}
 
 	// Read all pending events for this instance
@@ -108,6 +148,7 @@ func (rb *redisBackend) GetWorkflowTask(ctx context.Context) (*task.Workflow, er
 		LastSequenceID:        instanceState.LastSequenceID,
 		NewEvents:             newEvents,
 		CustomData:            msgs[len(msgs)-1].ID, // Id of last pending message in stream at this point
+		Attempt:               instanceState.Attempts,
 	}, nil
 }
 
@@ -119,6 +160,163 @@ func (rb *redisBackend) ExtendWorkflowTask(ctx context.Context, taskID string, i
 	return err
 }
 
+func (rb *redisBackend) ReleaseStickiness(ctx context.Context, instance *core.WorkflowInstance) error {
+	// Workflow tasks are not sticky to workers in the redis backend
+	return nil
+}
+
+func (rb *redisBackend) ReleaseWorkflowTask(ctx context.Context, taskID string, instance *core.WorkflowInstance) error {
+	// The task was not attempted, so don't count it
+	if err := rb.watchInstance(ctx, instance.InstanceID, func(tx *redis.Tx) error {
+		instanceState, err := readInstanceTx(ctx, tx, instance.InstanceID)
+		if err != nil {
+			if err == backend.ErrInstanceNotFound {
+				return nil
+			}
+
+			return err
+		}
+
+		if instanceState.Instance.ExecutionID != instance.ExecutionID || instanceState.Attempts == 0 {
+			return nil
+		}
+
+		instanceState.Attempts--
+		if _, err := tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			return updateInstanceP(ctx, p, instance.InstanceID, instanceState)
+		}); err != nil {
+			return fmt.Errorf("updating workflow task attempts: %w", err)
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	return rb.workflowQueue.Release(ctx, rb.rdb, taskID)
+}
+
+func (rb *redisBackend) AbandonWorkflowTask(ctx context.Context, t *task.Workflow, lastError string) error {
+	return rb.watchInstance(ctx, t.WorkflowInstance.InstanceID, func(tx *redis.Tx) error {
+		instanceState, err := readInstanceTx(ctx, tx, t.WorkflowInstance.InstanceID)
+		if err != nil {
+			return err
+		}
+
+		if instanceState.Instance.ExecutionID != t.WorkflowInstance.ExecutionID {
+			return fmt.Errorf("could not abandon workflow task: %w", backend.ErrWorkflowTaskDiscarded)
+		}
+
+		// Keep the task in the queue, it is retried once its lock expires
+		instanceState.LastError = lastError
+
+		if _, err := tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			return updateInstanceP(ctx, p, t.WorkflowInstance.InstanceID, instanceState)
+		}); err != nil {
+			return fmt.Errorf("abandoning workflow task: %w", err)
+		}
+
+		return nil
+	})
+}
+
+func (rb *redisBackend) SuspendWorkflowTask(ctx context.Context, t *task.Workflow, reason string) error {
+	return rb.watchInstance(ctx, t.WorkflowInstance.InstanceID, func(tx *redis.Tx) error {
+		instanceState, err := readInstanceTx(ctx, tx, t.WorkflowInstance.InstanceID)
+		if err != nil {
+			return err
+		}
+
+		if instanceState.Instance.ExecutionID != t.WorkflowInstance.ExecutionID {
+			return fmt.Errorf("could not suspend workflow instance: %w", backend.ErrWorkflowTaskDiscarded)
+		}
+
+		instanceState.State = core.WorkflowInstanceStateSuspended
+		instanceState.LastError = reason
+
+		// Remove the task from the queue without requeuing the instance, pending events are kept until it's resumed
+		if _, err := tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			if err := updateInstanceP(ctx, p, t.WorkflowInstance.InstanceID, instanceState); err != nil {
+				return err
+			}
+
+			_, err := rb.workflowQueue.Complete(ctx, p, t.ID)
+			return err
+		}); err != nil {
+			return fmt.Errorf("suspending workflow instance: %w", err)
+		}
+
+		return nil
+	})
+}
+
+func (rb *redisBackend) SuspendWorkflowInstance(ctx context.Context, instance *core.WorkflowInstance) error {
+	return rb.watchInstance(ctx, instance.InstanceID, func(tx *redis.Tx) error {
+		instanceState, err := readInstanceTx(ctx, tx, instance.InstanceID)
+		if err != nil {
+			return err
+		}
+
+		if instanceState.Instance.ExecutionID != instance.ExecutionID {
+			return backend.ErrInstanceNotFound
+		}
+
+		if instanceState.State != core.WorkflowInstanceStateActive {
+			return backend.ErrInstanceNotActive
+		}
+
+		// Queued tasks for the instance are dropped when they are dequeued, and the instance is queued again when
+		// it's resumed
+		instanceState.State = core.WorkflowInstanceStateSuspended
+
+		if _, err := tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			return updateInstanceP(ctx, p, instance.InstanceID, instanceState)
+		}); err != nil {
+			return fmt.Errorf("suspending workflow instance: %w", err)
+		}
+
+		return nil
+	})
+}
+
+func (rb *redisBackend) ResumeWorkflowInstance(ctx context.Context, instance *core.WorkflowInstance) error {
+	return rb.watchInstance(ctx, instance.InstanceID, func(tx *redis.Tx) error {
+		instanceState, err := readInstanceTx(ctx, tx, instance.InstanceID)
+		if err != nil {
+			return err
+		}
+
+		if instanceState.Instance.ExecutionID != instance.ExecutionID {
+			return backend.ErrInstanceNotFound
+		}
+
+		if instanceState.State != core.WorkflowInstanceStateSuspended {
+			return backend.ErrInstanceNotSuspended
+		}
+
+		instanceState.State = core.WorkflowInstanceStateActive
+		instanceState.Attempts = 0
+		instanceState.LastError = ""
+
+		keyInfo := rb.workflowQueue.Keys(instanceState.Priority)
+		if _, err := tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			if err := updateInstanceP(ctx, p, instance.InstanceID, instanceState); err != nil {
+				return err
+			}
+
+			// Queue the instance again if there are events to process
+			return requeueInstanceCmd.Run(ctx, p,
+				[]string{pendingEventsKey(instance.InstanceID), keyInfo.StreamKey, keyInfo.SetKey},
+				instance.InstanceID,
+			).Err()
+		}); err != nil {
+			return fmt.Errorf("resuming workflow instance: %w", err)
+		}
+
+		return nil
+	})
+}
+
 // Remove all pending events before (and including) a given message id
 // KEYS[1] - pending events stream key
 // ARGV[1] - message id
@@ -153,38 +351,119 @@ func (rb *redisBackend) CompleteWorkflowTask(
 	executedEvents, activityEvents, timerEvents []history.Event,
 	workflowEvents []history.WorkflowEvent,
 ) error {
-	instanceState, err := readInstance(ctx, rb.rdb, instance.InstanceID)
-	if err != nil {
+	var instanceState *instanceState
+	if err := rb.watchInstance(ctx, instance.InstanceID, func(tx *redis.Tx) error {
+		var err error
+		instanceState, err = rb.completeWorkflowTask(
+			ctx, tx, task, instance, state, executedEvents, activityEvents, timerEvents, workflowEvents)
+		return err
+	}); err != nil {
 		return err
 	}
 
-	// Check-point the workflow. We guarantee that no other worker is working on this workflow instance at this point via the
-	// task queue, so we don't need to WATCH the keys, we just need to make sure all commands are executed atomically to prevent
-	// a worker crashing in the middle of this execution.
-	p := rb.rdb.TxPipeline()
+	if state.Finished() {
+		ctx = tracing.UnmarshalSpan(ctx, instanceState.Metadata)
+		_, span := rb.Tracer().Start(ctx, "WorkflowComplete",
+			trace.WithAttributes(
+				attribute.String("workflow_instance_id", instanceState.Instance.InstanceID),
+			))
+		span.End()
+
+		if rb.options.Archiver != nil {
+			// The instance is already finished at this point, failing to archive it must not fail the task. The
+			// history is archived again when the instance is removed.
+			if err := rb.archiveWorkflowInstance(ctx, instance); err != nil {
+				rb.Logger().Error("archiving workflow instance", "instance_id", instance.InstanceID, "error", err.Error())
+			}
+		}
+	}
+
+	return nil
+}
+
+// completeWorkflowTask check-points the workflow with a transaction that watches the instance, and returns the
+// updated state of the instance
+func (rb *redisBackend) completeWorkflowTask(
+	ctx context.Context,
+	tx *redis.Tx,
+	task *task.Workflow,
+	instance *core.WorkflowInstance,
+	state core.WorkflowInstanceState,
+	executedEvents, activityEvents, timerEvents []history.Event,
+	workflowEvents []history.WorkflowEvent,
+) (*instanceState, error) {
+	instanceState, err := readInstanceTx(ctx, tx, instance.InstanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	if instanceState.Instance.ExecutionID != instance.ExecutionID {
+		// The instance has been reset while this task was executed. Discard the result of the task and let the new
+		// execution pick up the pending events.
+		keyInfo := rb.workflowQueue.Keys(instanceState.Priority)
+		if _, err := tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			if _, err := rb.workflowQueue.Complete(ctx, p, task.ID); err != nil {
+				return err
+			}
+
+			return requeueInstanceCmd.Run(ctx, p,
+				[]string{pendingEventsKey(instance.InstanceID), keyInfo.StreamKey, keyInfo.SetKey},
+				instance.InstanceID,
+			).Err()
+		}); err != nil {
+			return nil, fmt.Errorf("discarding workflow task: %w", err)
+		}
+
+		return nil, backend.ErrWorkflowTaskDiscarded
+	}
+
+	// Check-point the workflow. The task queue guarantees that no other worker is working on this workflow instance at
+	// this point, but the instance can still be suspended, terminated, or reset concurrently. The transaction fails if
+	// the instance changed after it was read, and all commands are executed atomically to prevent a worker crashing in
+	// the middle of this execution.
+	p := tx.TxPipeline()
 
 	// Add executed events to the history
 	if err := addEventsToHistoryStreamP(ctx, p, historyKey(instance.InstanceID), executedEvents); err != nil {
-		return fmt.Errorf("serializing : %w", err)
+		return nil, fmt.Errorf("serializing : %w", err)
 	}
 
 	for _, event := range executedEvents {
 		switch event.Type {
 		case history.EventType_TimerCanceled:
 			removeFutureEventP(ctx, p, instance, &event)
+
+		case history.EventType_SearchAttributesUpserted:
+			a := event.Attributes.(*history.SearchAttributesUpsertedAttributes)
+			if instanceState.SearchAttributes == nil {
+				instanceState.SearchAttributes = core.SearchAttributes{}
+			}
+
+			for name, value := range a.SearchAttributes {
+				if old, ok := instanceState.SearchAttributes[name]; ok {
+					p.SRem(ctx, searchAttributeKey(name, old), instance.InstanceID)
+				}
+
+				p.SAdd(ctx, searchAttributeKey(name, value), instance.InstanceID)
+
+				instanceState.SearchAttributes[name] = value
+			}
 		}
 	}
 
 	// Schedule timers
 	for _, timerEvent := range timerEvents {
-		if err := addFutureEventP(ctx, p, instance, &timerEvent); err != nil {
-			return err
+		if err := addFutureEventP(ctx, p, instance, instanceState.Priority, &timerEvent); err != nil {
+			return nil, err
 		}
 	}
 
 	// Send new workflow events to the respective streams
 	groupedEvents := history.EventsByWorkflowInstanceID(workflowEvents)
 	for targetInstanceID, events := range groupedEvents {
+		// Workflow tasks are queued with the priority of the target instance
+		var targetPriority *core.Priority
+
 		// Insert pending events for target instance
 		for _, m := range events {
 			m := m
@@ -192,29 +471,48 @@ func (rb *redisBackend) CompleteWorkflowTask(
 			if m.HistoryEvent.Type == history.EventType_WorkflowExecutionStarted {
 				// Create new instance
 				a := m.HistoryEvent.Attributes.(*history.ExecutionStartedAttributes)
-				if err := createInstanceP(ctx, p, m.WorkflowInstance, a.Metadata, true); err != nil {
-					return err
+				if err := createInstanceP(ctx, p, m.WorkflowInstance, a, true); err != nil {
+					return nil, err
 				}
+
+				targetPriority = &a.Priority
 			}
 
 			// Add pending event to stream
 			if err := addEventToStreamP(ctx, p, pendingEventsKey(targetInstanceID), &m.HistoryEvent); err != nil {
-				return err
+				return nil, err
 			}
 		}
 
 		// Try to queue workflow task
 		if targetInstanceID != instance.InstanceID {
-			if err := rb.workflowQueue.Enqueue(ctx, p, targetInstanceID, nil); err != nil {
-				return fmt.Errorf("enqueuing workflow task: %w", err)
+			if targetPriority == nil {
+				targetState, err := readInstance(ctx, rb.rdb, targetInstanceID)
+				if err != nil && err != backend.ErrInstanceNotFound {
+					return nil, fmt.Errorf("reading target workflow instance: %w", err)
+				}
+
+				priority := core.PriorityNormal
+				if targetState != nil {
+					priority = targetState.Priority
+				}
+
+				targetPriority = &priority
+			}
+
+			if err := rb.workflowQueue.Enqueue(ctx, p, targetInstanceID, *targetPriority, nil); err != nil {
+				return nil, fmt.Errorf("enqueuing workflow task: %w", err)
 			}
 		}
 	}
 
-	instanceState.State = state
+	instanceState.LastError = ""
+	instanceState.Attempts = 0
 
-	if state == core.WorkflowInstanceStateFinished {
+	// Keep the state of instances that have been suspended while this task was executed, unless they finished
+	if state.Finished() {
 		t := time.Now()
+		instanceState.State = state
 		instanceState.CompletedAt = &t
 	}
 
@@ -223,17 +521,17 @@ func (rb *redisBackend) CompleteWorkflowTask(
 	}
 
 	if err := updateInstanceP(ctx, p, instance.InstanceID, instanceState); err != nil {
-		return fmt.Errorf("updating workflow instance: %w", err)
+		return nil, fmt.Errorf("updating workflow instance: %w", err)
 	}
 
 	// Store activity data
 	for _, activityEvent := range activityEvents {
-		if err := rb.activityQueue.Enqueue(ctx, p, activityEvent.ID, &activityData{
+		if err := rb.activityQueue.Enqueue(ctx, p, activityEvent.ID, activityPriority(activityEvent), &activityData{
 			Instance: instance,
 			ID:       activityEvent.ID,
 			Event:    activityEvent,
 		}); err != nil {
-			return fmt.Errorf("queueing activity task: %w", err)
+			return nil, fmt.Errorf("queueing activity task: %w", err)
 		}
 	}
 
@@ -246,11 +544,11 @@ func (rb *redisBackend) CompleteWorkflowTask(
 	// Complete workflow task and unlock instance.
 	completeCmd, err := rb.workflowQueue.Complete(ctx, p, task.ID)
 	if err != nil {
-		return fmt.Errorf("completing workflow task: %w", err)
+		return nil, fmt.Errorf("completing workflow task: %w", err)
 	}
 
 	// If there are pending events, queue the instance again
-	keyInfo := rb.workflowQueue.Keys()
+	keyInfo := rb.workflowQueue.Keys(instanceState.Priority)
 	requeueInstanceCmd.Run(ctx, p,
 		[]string{pendingEventsKey(instance.InstanceID), keyInfo.StreamKey, keyInfo.SetKey},
 		instance.InstanceID,
@@ -260,7 +558,7 @@ func (rb *redisBackend) CompleteWorkflowTask(
 	executedCmds, err := p.Exec(ctx)
 	if err != nil {
 		if err := completeCmd.Err(); err != nil && err == redis.Nil {
-			return fmt.Errorf("could not complete workflow task: %w", err)
+			return nil, fmt.Errorf("could not complete workflow task: %w", err)
 		}
 
 		for _, cmd := range executedCmds {
@@ -269,29 +567,29 @@ func (rb *redisBackend) CompleteWorkflowTask(
 			}
 		}
 
-		return fmt.Errorf("completing workflow task: %w", err)
+		return nil, fmt.Errorf("completing workflow task: %w", err)
 	}
 
-	if state == core.WorkflowInstanceStateFinished {
-		ctx = tracing.UnmarshalSpan(ctx, instanceState.Metadata)
-		_, span := rb.Tracer().Start(ctx, "WorkflowComplete",
-			trace.WithAttributes(
-				attribute.String("workflow_instance_id", instanceState.Instance.InstanceID),
-			))
-		span.End()
+	return instanceState, nil
+}
+
+func (rb *redisBackend) archiveWorkflowInstance(ctx context.Context, instance *core.WorkflowInstance) error {
+	h, err := rb.GetWorkflowInstanceHistory(ctx, instance, nil)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	return rb.options.Archiver.ArchiveWorkflowInstance(ctx, instance, h)
 }
 
-func (rb *redisBackend) addWorkflowInstanceEventP(ctx context.Context, p redis.Pipeliner, instance *core.WorkflowInstance, event *history.Event) error {
+func (rb *redisBackend) addWorkflowInstanceEventP(ctx context.Context, p redis.Pipeliner, instance *core.WorkflowInstance, priority core.Priority, event *history.Event) error {
 	// Add event to pending events for instance
 	if err := addEventToStreamP(ctx, p, pendingEventsKey(instance.InstanceID), event); err != nil {
 		return err
 	}
 
 	// Queue workflow task
-	if err := rb.workflowQueue.Enqueue(ctx, p, instance.InstanceID, nil); err != nil {
+	if err := rb.workflowQueue.Enqueue(ctx, p, instance.InstanceID, priority, nil); err != nil {
 		return fmt.Errorf("queueing workflow: %w", err)
 	}
 
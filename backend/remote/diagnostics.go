@@ -0,0 +1,47 @@
+package remote
+
+import (
+	"context"
+	"time"
+
+	"github.com/paveliak/go-workflows/diag"
+	"github.com/paveliak/go-workflows/internal/core"
+	"github.com/paveliak/go-workflows/server"
+)
+
+func (rb *remoteBackend) GetWorkflowInstance(ctx context.Context, instanceID string) (*diag.WorkflowInstanceRef, error) {
+	var res server.WorkflowInstanceResponse
+	err := rb.call(ctx, "GetWorkflowInstance", &server.InstanceIDRequest{InstanceID: instanceID}, &res)
+	return res.Instance, err
+}
+
+func (rb *remoteBackend) GetWorkflowInstances(ctx context.Context, afterInstanceID string, count int, states ...core.WorkflowInstanceState) ([]*diag.WorkflowInstanceRef, error) {
+	var res server.WorkflowInstancesResponse
+	err := rb.call(ctx, "GetWorkflowInstances",
+		&server.GetWorkflowInstancesRequest{AfterInstanceID: afterInstanceID, Count: count, States: states}, &res)
+	return res.Instances, err
+}
+
+func (rb *remoteBackend) QueryWorkflowInstances(ctx context.Context, query *diag.WorkflowInstanceQuery) ([]*diag.WorkflowInstanceRef, error) {
+	var res server.WorkflowInstancesResponse
+	err := rb.call(ctx, "QueryWorkflowInstances", &server.QueryWorkflowInstancesRequest{Query: query}, &res)
+	return res.Instances, err
+}
+
+func (rb *remoteBackend) GetWorkflowInstanceChildren(ctx context.Context, instanceID string) ([]*diag.WorkflowInstanceRef, error) {
+	var res server.WorkflowInstancesResponse
+	err := rb.call(ctx, "GetWorkflowInstanceChildren", &server.InstanceIDRequest{InstanceID: instanceID}, &res)
+	return res.Instances, err
+}
+
+func (rb *remoteBackend) GetStats(ctx context.Context, since time.Time) (*diag.Stats, error) {
+	var res server.StatsResponse
+	err := rb.call(ctx, "GetStats", &server.GetStatsRequest{Since: since}, &res)
+	return res.Stats, err
+}
+
+func (rb *remoteBackend) TakeRateLimitToken(ctx context.Context, key string, rate float64, burst int) (time.Duration, error) {
+	var res server.TakeRateLimitTokenResponse
+	err := rb.call(ctx, "TakeRateLimitToken", &server.TakeRateLimitTokenRequest{Key: key, Rate: rate, Burst: burst}, &res)
+	return res.Wait, err
+}
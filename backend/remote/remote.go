@@ -0,0 +1,333 @@
+// Package remote implements a backend that forwards all calls to a backend served by the server package over HTTP.
+// Clients and workers using it do not need credentials for the database of the backend.
+//
+//	b := remote.NewRemoteBackend("http://workflows:8080")
+//	c := client.New(b)
+package remote
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/paveliak/go-workflows/backend"
+	"github.com/paveliak/go-workflows/diag"
+	"github.com/paveliak/go-workflows/internal/core"
+	"github.com/paveliak/go-workflows/internal/history"
+	"github.com/paveliak/go-workflows/internal/metrickeys"
+	"github.com/paveliak/go-workflows/internal/task"
+	"github.com/paveliak/go-workflows/log"
+	"github.com/paveliak/go-workflows/metrics"
+	"github.com/paveliak/go-workflows/server"
+	"github.com/paveliak/go-workflows/workflow"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type RemoteOptions struct {
+	backend.Options
+
+	// PollTimeout is the longest a GetWorkflowTask or GetActivityTask call waits for a task on the server. Calls
+	// with a context deadline return before the deadline.
+	PollTimeout time.Duration
+
+	HTTPClient *http.Client
+}
+
+type RemoteBackendOption func(*RemoteOptions)
+
+func WithPollTimeout(timeout time.Duration) RemoteBackendOption {
+	return func(o *RemoteOptions) {
+		o.PollTimeout = timeout
+	}
+}
+
+// WithHTTPClient configures the client used for requests to the server, for example to add authentication
+func WithHTTPClient(c *http.Client) RemoteBackendOption {
+	return func(o *RemoteOptions) {
+		o.HTTPClient = c
+	}
+}
+
+// WithBackendOptions configures the logger, metrics, and tracing of the backend. Options for storing workflow
+// instances, like the sticky timeout or an archiver, need to be configured for the backend of the server.
+func WithBackendOptions(opts ...backend.BackendOption) RemoteBackendOption {
+	return func(o *RemoteOptions) {
+		for _, opt := range opts {
+			opt(&o.Options)
+		}
+	}
+}
+
+var _ backend.Backend = (*remoteBackend)(nil)
+var _ diag.Backend = (*remoteBackend)(nil)
+var _ diag.StatsBackend = (*remoteBackend)(nil)
+var _ backend.RateLimiter = (*remoteBackend)(nil)
+var _ backend.Importer = (*remoteBackend)(nil)
+
+// NewRemoteBackend returns a backend for the server at the given URL
+func NewRemoteBackend(url string, opts ...RemoteBackendOption) *remoteBackend {
+	options := &RemoteOptions{
+		Options:     backend.ApplyOptions(),
+		PollTimeout: time.Second * 30,
+		HTTPClient:  http.DefaultClient,
+	}
+
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	return &remoteBackend{
+		url:     strings.TrimSuffix(url, "/"),
+		options: options,
+	}
+}
+
+type remoteBackend struct {
+	url     string
+	options *RemoteOptions
+}
+
+func (rb *remoteBackend) Logger() log.Logger {
+	return rb.options.Logger
+}
+
+func (rb *remoteBackend) Metrics() metrics.Client {
+	return rb.options.Metrics.WithTags(metrics.Tags{metrickeys.Backend: "remote"})
+}
+
+func (rb *remoteBackend) Tracer() trace.Tracer {
+	return rb.options.TracerProvider.Tracer(backend.TracerName)
+}
+
+func (rb *remoteBackend) CreateWorkflowInstance(ctx context.Context, instance *workflow.Instance, event history.Event) error {
+	return rb.call(ctx, "CreateWorkflowInstance", &server.InstanceEventRequest{Instance: instance, Event: &event}, nil)
+}
+
+func (rb *remoteBackend) CancelWorkflowInstance(ctx context.Context, instance *workflow.Instance, cancelEvent *history.Event) error {
+	return rb.call(ctx, "CancelWorkflowInstance", &server.InstanceEventRequest{Instance: instance, Event: cancelEvent}, nil)
+}
+
+func (rb *remoteBackend) TerminateWorkflowInstance(ctx context.Context, instance *workflow.Instance, terminateEvent *history.Event) error {
+	return rb.call(ctx, "TerminateWorkflowInstance", &server.InstanceEventRequest{Instance: instance, Event: terminateEvent}, nil)
+}
+
+func (rb *remoteBackend) GetWorkflowInstanceState(ctx context.Context, instance *workflow.Instance) (core.WorkflowInstanceState, error) {
+	var res server.StateResponse
+	err := rb.call(ctx, "GetWorkflowInstanceState", &server.InstanceRequest{Instance: instance}, &res)
+	return res.State, err
+}
+
+func (rb *remoteBackend) ListWorkflowInstances(ctx context.Context, filter *backend.WorkflowInstanceFilter) ([]*backend.WorkflowInstanceRef, error) {
+	var res server.ListWorkflowInstancesResponse
+	err := rb.call(ctx, "ListWorkflowInstances", &server.ListWorkflowInstancesRequest{Filter: filter}, &res)
+	return res.Instances, err
+}
+
+func (rb *remoteBackend) GetWorkflowInstanceHistory(ctx context.Context, instance *workflow.Instance, lastSequenceID *int64) ([]history.Event, error) {
+	var res server.EventsResponse
+	err := rb.call(ctx, "GetWorkflowInstanceHistory",
+		&server.GetWorkflowInstanceHistoryRequest{Instance: instance, LastSequenceID: lastSequenceID}, &res)
+	return res.Events, err
+}
+
+func (rb *remoteBackend) RemoveWorkflowInstance(ctx context.Context, instance *workflow.Instance) error {
+	return rb.call(ctx, "RemoveWorkflowInstance", &server.InstanceRequest{Instance: instance}, nil)
+}
+
+func (rb *remoteBackend) ResetWorkflowInstance(
+	ctx context.Context, instance *workflow.Instance, executionID string, toSequenceID int64,
+	activityEvents, timerEvents, pendingEvents []history.Event) error {
+	return rb.call(ctx, "ResetWorkflowInstance", &server.ResetWorkflowInstanceRequest{
+		Instance:       instance,
+		ExecutionID:    executionID,
+		ToSequenceID:   toSequenceID,
+		ActivityEvents: activityEvents,
+		TimerEvents:    timerEvents,
+		PendingEvents:  pendingEvents,
+	}, nil)
+}
+
+func (rb *remoteBackend) ImportWorkflowInstance(
+	ctx context.Context, instance *workflow.Instance, state core.WorkflowInstanceState,
+	h, activityEvents, timerEvents []history.Event) error {
+	return rb.call(ctx, "ImportWorkflowInstance", &server.ImportWorkflowInstanceRequest{
+		Instance:       instance,
+		State:          state,
+		History:        h,
+		ActivityEvents: activityEvents,
+		TimerEvents:    timerEvents,
+	}, nil)
+}
+
+func (rb *remoteBackend) SignalWorkflow(ctx context.Context, instanceID string, event history.Event) error {
+	return rb.call(ctx, "SignalWorkflow", &server.SignalWorkflowRequest{InstanceID: instanceID, Event: event}, nil)
+}
+
+func (rb *remoteBackend) GetWorkflowTask(ctx context.Context) (*task.Workflow, error) {
+	var res server.WorkflowTaskResponse
+	if err := rb.poll(ctx, "GetWorkflowTask", &res); err != nil {
+		return nil, err
+	}
+
+	return res.Task, nil
+}
+
+func (rb *remoteBackend) ExtendWorkflowTask(ctx context.Context, taskID string, instance *core.WorkflowInstance) error {
+	return rb.call(ctx, "ExtendWorkflowTask", &server.TaskRequest{TaskID: taskID, Instance: instance}, nil)
+}
+
+func (rb *remoteBackend) CompleteWorkflowTask(
+	ctx context.Context, task *task.Workflow, instance *workflow.Instance, state core.WorkflowInstanceState,
+	executedEvents, activityEvents, timerEvents []history.Event, workflowEvents []history.WorkflowEvent) error {
+	return rb.call(ctx, "CompleteWorkflowTask", &server.CompleteWorkflowTaskRequest{
+		Task:           task,
+		Instance:       instance,
+		State:          state,
+		ExecutedEvents: executedEvents,
+		ActivityEvents: activityEvents,
+		TimerEvents:    timerEvents,
+		WorkflowEvents: workflowEvents,
+	}, nil)
+}
+
+func (rb *remoteBackend) AbandonWorkflowTask(ctx context.Context, task *task.Workflow, lastError string) error {
+	return rb.call(ctx, "AbandonWorkflowTask", &server.WorkflowTaskRequest{Task: task, Reason: lastError}, nil)
+}
+
+func (rb *remoteBackend) ReleaseWorkflowTask(ctx context.Context, taskID string, instance *core.WorkflowInstance) error {
+	return rb.call(ctx, "ReleaseWorkflowTask", &server.TaskRequest{TaskID: taskID, Instance: instance}, nil)
+}
+
+func (rb *remoteBackend) SuspendWorkflowTask(ctx context.Context, task *task.Workflow, reason string) error {
+	return rb.call(ctx, "SuspendWorkflowTask", &server.WorkflowTaskRequest{Task: task, Reason: reason}, nil)
+}
+
+func (rb *remoteBackend) SuspendWorkflowInstance(ctx context.Context, instance *workflow.Instance) error {
+	return rb.call(ctx, "SuspendWorkflowInstance", &server.InstanceRequest{Instance: instance}, nil)
+}
+
+func (rb *remoteBackend) ResumeWorkflowInstance(ctx context.Context, instance *workflow.Instance) error {
+	return rb.call(ctx, "ResumeWorkflowInstance", &server.InstanceRequest{Instance: instance}, nil)
+}
+
+// ReleaseStickiness does nothing, workflow instances are not sticky to workers using a server. The server releases
+// the stickiness of an instance after each completed workflow task.
+func (rb *remoteBackend) ReleaseStickiness(ctx context.Context, instance *core.WorkflowInstance) error {
+	return nil
+}
+
+func (rb *remoteBackend) GetActivityTask(ctx context.Context) (*task.Activity, error) {
+	var res server.ActivityTaskResponse
+	if err := rb.poll(ctx, "GetActivityTask", &res); err != nil {
+		return nil, err
+	}
+
+	return res.Task, nil
+}
+
+func (rb *remoteBackend) CompleteActivityTask(ctx context.Context, instance *workflow.Instance, activityID string, event history.Event) error {
+	return rb.call(ctx, "CompleteActivityTask",
+		&server.CompleteActivityTaskRequest{Instance: instance, ActivityID: activityID, Event: event}, nil)
+}
+
+func (rb *remoteBackend) ExtendActivityTask(ctx context.Context, activityID string) error {
+	return rb.call(ctx, "ExtendActivityTask", &server.ActivityRequest{ActivityID: activityID}, nil)
+}
+
+func (rb *remoteBackend) ReleaseActivityTask(ctx context.Context, activityID string, delay time.Duration) error {
+	return rb.call(ctx, "ReleaseActivityTask", &server.ReleaseActivityTaskRequest{ActivityID: activityID, Delay: delay}, nil)
+}
+
+// poll waits on the server for a task until the poll timeout or shortly before the deadline of ctx. res is left
+// empty if there is no task.
+func (rb *remoteBackend) poll(ctx context.Context, method string, res interface{}) error {
+	wait := rb.options.PollTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		// Leave time for the response, a task returned after the caller gave up stays locked until it's released
+		if remaining := time.Until(deadline) * 9 / 10; remaining < wait {
+			wait = remaining
+		}
+	}
+
+	if wait < 0 {
+		wait = 0
+	}
+
+	if err := rb.call(ctx, method, &server.PollRequest{Wait: wait}, res); err != nil {
+		if ctx.Err() != nil {
+			// Like the other backends, return no task when the context is done
+			return nil
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// call sends a request for the given backend method to the server and decodes the response into res, if given
+func (rb *remoteBackend) call(ctx context.Context, method string, req, res interface{}) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("encoding %v request: %w", method, err)
+	}
+
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, rb.url+"/"+method, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating %v request: %w", method, err)
+	}
+
+	r.Header.Set("Content-Type", "application/json")
+
+	resp, err := rb.options.HTTPClient.Do(r)
+	if err != nil {
+		return fmt.Errorf("calling %v: %w", method, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var errRes server.ErrorResponse
+		if err := json.NewDecoder(resp.Body).Decode(&errRes); err != nil || errRes.Error == "" {
+			return fmt.Errorf("calling %v: unexpected status %v", method, resp.Status)
+		}
+
+		err := server.BackendError(errRes.Code)
+		if err != nil && err.Error() == errRes.Error {
+			// Return errors of the backend package as they are, like a local backend
+			return err
+		}
+
+		return &remoteError{message: errRes.Error, err: err}
+	}
+
+	if res == nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(res); err != nil {
+		return fmt.Errorf("decoding %v response: %w", method, err)
+	}
+
+	return nil
+}
+
+// remoteError is an error returned by the server. It wraps the matching error of the backend package, so that
+// errors.Is works like for a local backend.
+type remoteError struct {
+	message string
+	err     error
+}
+
+func (e *remoteError) Error() string {
+	return e.message
+}
+
+func (e *remoteError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,197 @@
+package remote
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/paveliak/go-workflows/backend"
+	"github.com/paveliak/go-workflows/backend/sqlite"
+	"github.com/paveliak/go-workflows/backend/test"
+	"github.com/paveliak/go-workflows/internal/core"
+	"github.com/paveliak/go-workflows/internal/history"
+	"github.com/paveliak/go-workflows/internal/task"
+	"github.com/paveliak/go-workflows/server"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RemoteBackend(t *testing.T) {
+	test.BackendTest(t, newTestBackend, closeTestBackend)
+}
+
+func Test_EndToEndRemoteBackend(t *testing.T) {
+	test.EndToEndBackendTest(t, newTestBackend, closeTestBackend)
+}
+
+func Test_RemoteBackend_GetWorkflowTaskWaitsForTask(t *testing.T) {
+	ctx := context.Background()
+
+	b := newTestBackend()
+	defer closeTestBackend(b)
+
+	rb := b.(*remoteTestBackend)
+	rb.options.PollTimeout = 10 * time.Second
+
+	wfi := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+
+		startedEvent := history.NewPendingEvent(time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{})
+		if err := b.CreateWorkflowInstance(ctx, wfi, startedEvent); err != nil {
+			panic(err)
+		}
+	}()
+
+	start := time.Now()
+	task, err := b.GetWorkflowTask(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, task)
+	require.Equal(t, wfi.InstanceID, task.WorkflowInstance.InstanceID)
+	require.Less(t, time.Since(start), 5*time.Second)
+}
+
+func Test_RemoteBackend_NotImplemented(t *testing.T) {
+	srv := httptest.NewServer(server.NewHandler(&backend.MockBackend{}))
+	defer srv.Close()
+
+	b := NewRemoteBackend(srv.URL)
+
+	_, err := b.GetWorkflowInstance(context.Background(), uuid.NewString())
+	require.ErrorIs(t, err, server.ErrNotImplemented)
+}
+
+func Test_RemoteBackend_MissingInstance(t *testing.T) {
+	srv := httptest.NewServer(server.NewHandler(&backend.MockBackend{}))
+	defer srv.Close()
+
+	b := NewRemoteBackend(srv.URL)
+
+	cancelEvent := history.NewWorkflowCancellationEvent(time.Now())
+	err := b.CancelWorkflowInstance(context.Background(), nil, &cancelEvent)
+	require.ErrorIs(t, err, server.ErrBadRequest)
+
+	_, err = b.GetWorkflowInstanceHistory(context.Background(), nil, nil)
+	require.ErrorIs(t, err, server.ErrBadRequest)
+}
+
+func Test_RemoteBackend_CompleteWorkflowTaskReleasesStickiness(t *testing.T) {
+	wfi := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+	isInstance := mock.MatchedBy(func(i *core.WorkflowInstance) bool {
+		return i.InstanceID == wfi.InstanceID && i.ExecutionID == wfi.ExecutionID
+	})
+
+	mb := &backend.MockBackend{}
+	mb.On("CompleteWorkflowTask", mock.Anything, mock.Anything, isInstance, core.WorkflowInstanceStateActive,
+		mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	mb.On("ReleaseStickiness", mock.Anything, isInstance).Return(nil)
+
+	srv := httptest.NewServer(server.NewHandler(mb))
+	defer srv.Close()
+
+	b := NewRemoteBackend(srv.URL)
+
+	err := b.CompleteWorkflowTask(
+		context.Background(), &task.Workflow{ID: uuid.NewString(), WorkflowInstance: wfi}, wfi, core.WorkflowInstanceStateActive,
+		nil, nil, nil, nil)
+	require.NoError(t, err)
+
+	mb.AssertExpectations(t)
+}
+
+// remoteTestBackend is a remote backend for a sqlite backend served on a loopback listener
+type remoteTestBackend struct {
+	*remoteBackend
+
+	srv *httptest.Server
+	dir string
+	db  *sql.DB
+}
+
+var _ test.TestBackend = (*remoteTestBackend)(nil)
+
+func newTestBackend() test.TestBackend {
+	// Use a database file, database/sql discards connections of transactions canceled by requests that stop waiting
+	// for a task, which would drop an in-memory database. Requests are handled concurrently, so take write locks when
+	// transactions begin and wait for them instead of failing.
+	dir, err := os.MkdirTemp("", "remote")
+	if err != nil {
+		panic(err)
+	}
+
+	path := filepath.Join(dir, "workflows.sqlite") + "?_busy_timeout=10000&_journal_mode=WAL&_txlock=immediate"
+
+	// Disable sticky workflow behavior for the test execution
+	b := sqlite.NewSqliteBackend(path, backend.WithStickyTimeout(0))
+
+	db, err := sql.Open("sqlite3", "file:"+path)
+	if err != nil {
+		panic(err)
+	}
+
+	srv := httptest.NewServer(server.NewHandler(b, server.WithPollInterval(10*time.Millisecond)))
+
+	return &remoteTestBackend{
+		remoteBackend: NewRemoteBackend(srv.URL, WithPollTimeout(100*time.Millisecond)),
+		srv:           srv,
+		dir:           dir,
+		db:            db,
+	}
+}
+
+func closeTestBackend(b test.TestBackend) {
+	rb := b.(*remoteTestBackend)
+	rb.srv.Close()
+	rb.db.Close()
+	os.RemoveAll(rb.dir)
+}
+
+// GetFutureEvents reads the future events from the database, the server does not expose them
+func (rb *remoteTestBackend) GetFutureEvents(ctx context.Context) ([]history.Event, error) {
+	rows, err := rb.db.QueryContext(
+		ctx,
+		"SELECT id, sequence_id, event_type, timestamp, schedule_event_id, attributes, visible_at FROM `pending_events` WHERE visible_at IS NOT NULL",
+	)
+	if err != nil {
+		return nil, fmt.Errorf("getting future events: %w", err)
+	}
+	defer rows.Close()
+
+	f := make([]history.Event, 0)
+
+	for rows.Next() {
+		var attributes []byte
+
+		fe := history.Event{}
+
+		if err := rows.Scan(
+			&fe.ID,
+			&fe.SequenceID,
+			&fe.Type,
+			&fe.Timestamp,
+			&fe.ScheduleEventID,
+			&attributes,
+			&fe.VisibleAt,
+		); err != nil {
+			return nil, fmt.Errorf("scanning event: %w", err)
+		}
+
+		a, err := history.DeserializeAttributes(fe.Type, attributes)
+		if err != nil {
+			return nil, fmt.Errorf("deserializing attributes: %w", err)
+		}
+
+		fe.Attributes = a
+
+		f = append(f, fe)
+	}
+
+	return f, rows.Err()
+}
@@ -16,7 +16,7 @@ func scheduleActivity(ctx context.Context, tx *sql.Tx, instanceID, executionID s
 	_, err = tx.ExecContext(
 		ctx,
 		`INSERT INTO activities
-			(id, instance_id, execution_id, event_type, timestamp, schedule_event_id, attributes, visible_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			(id, instance_id, execution_id, event_type, timestamp, schedule_event_id, attributes, visible_at, priority) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		event.ID,
 		instanceID,
 		executionID,
@@ -25,6 +25,7 @@ func scheduleActivity(ctx context.Context, tx *sql.Tx, instanceID, executionID s
 		event.ScheduleEventID,
 		attributes,
 		event.VisibleAt,
+		event.Attributes.(*history.ActivityScheduledAttributes).Priority.Clamp(),
 	)
 
 	return err
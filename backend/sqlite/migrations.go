@@ -0,0 +1,100 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/paveliak/go-workflows/internal/core"
+	"github.com/paveliak/go-workflows/internal/history"
+)
+
+// migration adds a column to a table of a database created with an older schema. New databases get all columns from
+// the schema, migrations only run for existing tables that miss their column.
+type migration struct {
+	table      string
+	column     string
+	definition string
+
+	// backfill statements run after the column has been added, to set its value for existing rows
+	backfill []string
+}
+
+// migrations in the order the columns were added to the schema
+var migrations = []migration{
+	{table: "instances", column: "last_error", definition: "TEXT NULL"},
+	{table: "instances", column: "priority", definition: "INTEGER NOT NULL DEFAULT 0"},
+	{table: "activities", column: "priority", definition: "INTEGER NOT NULL DEFAULT 0"},
+	{table: "instances", column: "state", definition: "INTEGER NOT NULL DEFAULT 0", backfill: []string{
+		// The outcome of instances finished before their state was recorded is unknown
+		fmt.Sprintf("UPDATE `instances` SET state = %d WHERE completed_at IS NOT NULL", core.WorkflowInstanceStateFinished),
+	}},
+	{table: "instances", column: "attempts", definition: "INTEGER NOT NULL DEFAULT 0"},
+	{table: "instances", column: "workflow_name", definition: "TEXT NULL", backfill: []string{
+		// Take the name from the started event, which is still pending for instances that haven't run yet
+		fmt.Sprintf(`UPDATE instances SET workflow_name = (
+			SELECT json_extract(CAST(e.attributes AS TEXT), '$.name') FROM (
+				SELECT instance_id, event_type, attributes FROM history
+				UNION ALL
+				SELECT instance_id, event_type, attributes FROM pending_events
+			) e WHERE e.instance_id = instances.id AND e.event_type = %d LIMIT 1)`, history.EventType_WorkflowExecutionStarted),
+	}},
+	{table: "instances", column: "memo", definition: "TEXT NULL"},
+}
+
+// migrate adds missing columns to the tables of an existing database. It runs before the schema, which creates
+// indexes on some of the added columns.
+func migrate(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, m := range migrations {
+		columns, err := tableColumns(tx, m.table)
+		if err != nil {
+			return err
+		}
+
+		// Tables that don't exist yet are created by the schema
+		if len(columns) == 0 || columns[m.column] {
+			continue
+		}
+
+		if _, err := tx.Exec(fmt.Sprintf("ALTER TABLE `%s` ADD COLUMN `%s` %s", m.table, m.column, m.definition)); err != nil {
+			return fmt.Errorf("adding column %s.%s: %w", m.table, m.column, err)
+		}
+
+		for _, stmt := range m.backfill {
+			if _, err := tx.Exec(stmt); err != nil {
+				return fmt.Errorf("backfilling column %s.%s: %w", m.table, m.column, err)
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// tableColumns returns the names of the columns of the given table, or none if the table does not exist
+func tableColumns(tx *sql.Tx, table string) (map[string]bool, error) {
+	rows, err := tx.Query(fmt.Sprintf("PRAGMA table_info(`%s`)", table))
+	if err != nil {
+		return nil, fmt.Errorf("reading columns of %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	columns := make(map[string]bool)
+
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, typ string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &defaultValue, &pk); err != nil {
+			return nil, fmt.Errorf("reading columns of %s: %w", table, err)
+		}
+
+		columns[name] = true
+	}
+
+	return columns, rows.Err()
+}
@@ -0,0 +1,48 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/paveliak/go-workflows/internal/ratelimit"
+)
+
+func (sb *sqliteBackend) TakeRateLimitToken(ctx context.Context, key string, rate float64, burst int) (time.Duration, error) {
+	tx, err := sb.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+
+	b := ratelimit.NewBucket(now, burst)
+	if err := tx.QueryRowContext(
+		ctx,
+		"SELECT tokens, updated_at FROM rate_limits WHERE id = ?",
+		key,
+	).Scan(&b.Tokens, &b.UpdatedAt); err != nil && err != sql.ErrNoRows {
+		return 0, fmt.Errorf("reading rate limit: %w", err)
+	}
+
+	wait := b.Take(now, rate, burst)
+
+	if _, err := tx.ExecContext(
+		ctx,
+		`INSERT INTO rate_limits (id, tokens, updated_at) VALUES (?, ?, ?)
+			ON CONFLICT(id) DO UPDATE SET tokens = excluded.tokens, updated_at = excluded.updated_at`,
+		key,
+		b.Tokens,
+		b.UpdatedAt,
+	); err != nil {
+		return 0, fmt.Errorf("updating rate limit: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return wait, nil
+}
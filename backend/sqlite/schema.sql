@@ -8,11 +8,19 @@ CREATE TABLE IF NOT EXISTS `instances` (
   `completed_at` DATETIME NULL,
   `locked_until` DATETIME NULL,
   `sticky_until` DATETIME NULL,
-  `worker` TEXT NULL
+  `worker` TEXT NULL,
+  `last_error` TEXT NULL,
+  `priority` INTEGER NOT NULL DEFAULT 0,
+  `state` INTEGER NOT NULL DEFAULT 0,
+  `attempts` INTEGER NOT NULL DEFAULT 0,
+  `workflow_name` TEXT NULL,
+  `memo` TEXT NULL
 );
 
 CREATE INDEX IF NOT EXISTS `idx_instances_locked_until_completed_at` ON `instances` (`locked_until`, `sticky_until`, `completed_at`, `worker`);
 CREATE INDEX IF NOT EXISTS `idx_instances_parent_instance_id` ON `instances` (`parent_instance_id`);
+CREATE INDEX IF NOT EXISTS `idx_instances_priority` ON `instances` (`priority`);
+CREATE INDEX IF NOT EXISTS `idx_instances_created_at` ON `instances` (`created_at`);
 
 CREATE TABLE IF NOT EXISTS `pending_events` (
   `id` TEXT,
@@ -52,5 +60,23 @@ CREATE TABLE IF NOT EXISTS `activities` (
   `attributes` BLOB NOT NULL,
   `visible_at` DATETIME NULL,
   `locked_until` DATETIME NULL,
-  `worker` TEXT NULL
-);
\ No newline at end of file
+  `worker` TEXT NULL,
+  `priority` INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS `idx_activities_priority` ON `activities` (`priority`);
+
+CREATE TABLE IF NOT EXISTS `rate_limits` (
+  `id` TEXT PRIMARY KEY,
+  `tokens` REAL NOT NULL,
+  `updated_at` DATETIME NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS `search_attributes` (
+  `instance_id` TEXT NOT NULL,
+  `name` TEXT NOT NULL,
+  `value` TEXT NOT NULL,
+  PRIMARY KEY(`instance_id`, `name`)
+);
+
+CREATE INDEX IF NOT EXISTS `idx_search_attributes_name_value` ON `search_attributes` (`name`, `value`);
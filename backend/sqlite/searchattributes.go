@@ -0,0 +1,186 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/paveliak/go-workflows/backend"
+	"github.com/paveliak/go-workflows/internal/core"
+)
+
+func upsertSearchAttributes(ctx context.Context, tx *sql.Tx, instanceID string, sa core.SearchAttributes) error {
+	for name, value := range sa {
+		if _, err := tx.ExecContext(
+			ctx,
+			`INSERT INTO search_attributes (instance_id, name, value) VALUES (?, ?, ?)
+				ON CONFLICT(instance_id, name) DO UPDATE SET value = excluded.value`,
+			instanceID,
+			name,
+			string(value),
+		); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (sb *sqliteBackend) ListWorkflowInstances(ctx context.Context, filter *backend.WorkflowInstanceFilter) ([]*backend.WorkflowInstanceRef, error) {
+	sa, err := core.NewSearchAttributes(filter.SearchAttributes)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := sb.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `SELECT i.id, i.execution_id, i.parent_instance_id, i.parent_schedule_event_id, i.workflow_name, i.state, i.created_at, i.completed_at, i.memo
+		FROM instances i`
+	args := []interface{}{}
+
+	if filter.AfterInstanceID != "" {
+		query += `
+		INNER JOIN (SELECT id, created_at FROM instances WHERE id = ?) ii
+			ON i.created_at < ii.created_at OR (i.created_at = ii.created_at AND i.id < ii.id)`
+		args = append(args, filter.AfterInstanceID)
+	}
+
+	where := []string{}
+
+	if filter.WorkflowName != "" {
+		where = append(where, "i.workflow_name = ?")
+		args = append(args, filter.WorkflowName)
+	}
+
+	if len(filter.States) > 0 {
+		where = append(where, "i.state IN (?"+strings.Repeat(", ?", len(filter.States)-1)+")")
+		for _, state := range filter.States {
+			args = append(args, state)
+		}
+	}
+
+	for _, r := range []struct {
+		condition string
+		t         time.Time
+	}{
+		{"julianday(i.created_at) >= julianday(?)", filter.CreatedAfter},
+		{"julianday(i.created_at) < julianday(?)", filter.CreatedBefore},
+		{"julianday(i.completed_at) >= julianday(?)", filter.CompletedAfter},
+		{"julianday(i.completed_at) < julianday(?)", filter.CompletedBefore},
+	} {
+		if !r.t.IsZero() {
+			where = append(where, r.condition)
+			args = append(args, r.t)
+		}
+	}
+
+	for name, value := range sa {
+		where = append(where, "EXISTS (SELECT 1 FROM search_attributes sa WHERE sa.instance_id = i.id AND sa.name = ? AND sa.value = ?)")
+		args = append(args, name, string(value))
+	}
+
+	if len(where) > 0 {
+		query += `
+		WHERE ` + strings.Join(where, " AND ")
+	}
+
+	query += `
+		ORDER BY i.created_at DESC, i.id DESC
+		LIMIT ?`
+	args = append(args, filter.Limit())
+
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("listing workflow instances: %w", err)
+	}
+	defer rows.Close()
+
+	var instances []*backend.WorkflowInstanceRef
+	byID := map[string]*backend.WorkflowInstanceRef{}
+
+	for rows.Next() {
+		var id, executionID string
+		var parentInstanceID *string
+		var parentEventID *int64
+		var workflowName, memoJson sql.NullString
+		var state core.WorkflowInstanceState
+		var createdAt time.Time
+		var completedAt *time.Time
+		if err := rows.Scan(&id, &executionID, &parentInstanceID, &parentEventID, &workflowName, &state, &createdAt, &completedAt, &memoJson); err != nil {
+			return nil, err
+		}
+
+		memo, err := unmarshalMemo(memoJson)
+		if err != nil {
+			return nil, err
+		}
+
+		instance := core.NewWorkflowInstance(id, executionID)
+		if parentInstanceID != nil {
+			instance = core.NewSubWorkflowInstance(id, executionID, *parentInstanceID, *parentEventID)
+		}
+
+		ref := &backend.WorkflowInstanceRef{
+			Instance:     instance,
+			WorkflowName: workflowName.String,
+			State:        state,
+			CreatedAt:    createdAt,
+			CompletedAt:  completedAt,
+			Memo:         memo,
+		}
+
+		instances = append(instances, ref)
+		byID[id] = ref
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(instances) == 0 {
+		return instances, nil
+	}
+
+	// Add search attributes
+	saArgs := make([]interface{}, 0, len(instances))
+	for _, instance := range instances {
+		saArgs = append(saArgs, instance.Instance.InstanceID)
+	}
+
+	saRows, err := tx.QueryContext(
+		ctx,
+		"SELECT instance_id, name, value FROM search_attributes WHERE instance_id IN (?"+strings.Repeat(", ?", len(saArgs)-1)+")",
+		saArgs...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("getting search attributes: %w", err)
+	}
+	defer saRows.Close()
+
+	attributes := map[string]core.SearchAttributes{}
+	for saRows.Next() {
+		var instanceID, name, value string
+		if err := saRows.Scan(&instanceID, &name, &value); err != nil {
+			return nil, err
+		}
+
+		if attributes[instanceID] == nil {
+			attributes[instanceID] = core.SearchAttributes{}
+		}
+
+		attributes[instanceID][name] = json.RawMessage(value)
+	}
+
+	for instanceID, sa := range attributes {
+		byID[instanceID].SearchAttributes = sa.Values()
+	}
+
+	return instances, saRows.Err()
+}
@@ -10,6 +10,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/paveliak/go-workflows/backend"
 	"github.com/paveliak/go-workflows/internal/core"
 	"github.com/paveliak/go-workflows/internal/history"
@@ -18,7 +19,6 @@ import (
 	"github.com/paveliak/go-workflows/log"
 	"github.com/paveliak/go-workflows/metrics"
 	"github.com/paveliak/go-workflows/workflow"
-	"github.com/google/uuid"
 	"go.opentelemetry.io/otel/trace"
 
 	_ "github.com/mattn/go-sqlite3"
@@ -46,14 +46,23 @@ func newSqliteBackend(dsn string, opts ...backend.BackendOption) *sqliteBackend
 	}
 
 	// Initialize database
+	if err := migrate(db); err != nil {
+		panic(fmt.Errorf("migrating database: %w", err))
+	}
+
 	if _, err := db.Exec(schema); err != nil {
 		panic(err)
 	}
 
+	options := backend.ApplyOptions(opts...)
+
 	return &sqliteBackend{
 		db:         db,
 		workerName: fmt.Sprintf("worker-%v", uuid.NewString()),
-		options:    backend.ApplyOptions(opts...),
+		options:    options,
+
+		workflowPriorities: core.NewPriorityScheduler(options.PriorityFairnessInterval),
+		activityPriorities: core.NewPriorityScheduler(options.PriorityFairnessInterval),
 	}
 }
 
@@ -61,6 +70,9 @@ type sqliteBackend struct {
 	db         *sql.DB
 	workerName string
 	options    backend.Options
+
+	workflowPriorities *core.PriorityScheduler
+	activityPriorities *core.PriorityScheduler
 }
 
 func (sb *sqliteBackend) Logger() log.Logger {
@@ -83,7 +95,8 @@ func (sb *sqliteBackend) CreateWorkflowInstance(ctx context.Context, instance *w
 	defer tx.Rollback()
 
 	// Create workflow instance
-	if err := createInstance(ctx, tx, instance, event.Attributes.(*history.ExecutionStartedAttributes).Metadata, false); err != nil {
+	a := event.Attributes.(*history.ExecutionStartedAttributes)
+	if err := createInstance(ctx, tx, instance, a, false); err != nil {
 		return err
 	}
 
@@ -98,7 +111,7 @@ func (sb *sqliteBackend) CreateWorkflowInstance(ctx context.Context, instance *w
 	return nil
 }
 
-func createInstance(ctx context.Context, tx *sql.Tx, wfi *workflow.Instance, metadata *workflow.Metadata, ignoreDuplicate bool) error {
+func createInstance(ctx context.Context, tx *sql.Tx, wfi *workflow.Instance, a *history.ExecutionStartedAttributes, ignoreDuplicate bool) error {
 	var parentInstanceID *string
 	var parentEventID *int64
 	if wfi.SubWorkflow() {
@@ -109,38 +122,79 @@ func createInstance(ctx context.Context, tx *sql.Tx, wfi *workflow.Instance, met
 		parentEventID = &n
 	}
 
-	metadataJson, err := json.Marshal(metadata)
+	metadataJson, err := json.Marshal(a.Metadata)
 	if err != nil {
 		return fmt.Errorf("marshaling metadata: %w", err)
 	}
 
+	memoJson, err := marshalMemo(a.Memo)
+	if err != nil {
+		return fmt.Errorf("marshaling memo: %w", err)
+	}
+
 	res, err := tx.ExecContext(
 		ctx,
-		"INSERT OR IGNORE INTO `instances` (id, execution_id, parent_instance_id, parent_schedule_event_id, metadata) VALUES (?, ?, ?, ?, ?)",
+		"INSERT OR IGNORE INTO `instances` (id, execution_id, parent_instance_id, parent_schedule_event_id, metadata, priority, workflow_name, memo) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
 		wfi.InstanceID,
 		wfi.ExecutionID,
 		parentInstanceID,
 		parentEventID,
 		string(metadataJson),
+		a.Priority.Clamp(),
+		a.Name,
+		memoJson,
 	)
 	if err != nil {
 		return fmt.Errorf("inserting workflow instance: %w", err)
 	}
 
-	if !ignoreDuplicate {
-		rows, err := res.RowsAffected()
-		if err != nil {
-			return err
-		}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
 
-		if rows != 1 {
+	if rows != 1 {
+		if !ignoreDuplicate {
 			return backend.ErrInstanceAlreadyExists
 		}
+
+		return nil
+	}
+
+	if err := upsertSearchAttributes(ctx, tx, wfi.InstanceID, a.SearchAttributes); err != nil {
+		return fmt.Errorf("indexing search attributes: %w", err)
 	}
 
 	return nil
 }
 
+func marshalMemo(memo core.Memo) (*string, error) {
+	if len(memo) == 0 {
+		return nil, nil
+	}
+
+	b, err := json.Marshal(memo)
+	if err != nil {
+		return nil, err
+	}
+
+	s := string(b)
+	return &s, nil
+}
+
+func unmarshalMemo(memoJson sql.NullString) (core.Memo, error) {
+	if !memoJson.Valid {
+		return nil, nil
+	}
+
+	var memo core.Memo
+	if err := json.Unmarshal([]byte(memoJson.String), &memo); err != nil {
+		return nil, fmt.Errorf("unmarshaling memo: %w", err)
+	}
+
+	return memo, nil
+}
+
 func (sb *sqliteBackend) CancelWorkflowInstance(ctx context.Context, instance *workflow.Instance, event *history.Event) error {
 	tx, err := sb.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -167,6 +221,113 @@ func (sb *sqliteBackend) CancelWorkflowInstance(ctx context.Context, instance *w
 	return tx.Commit()
 }
 
+func (sb *sqliteBackend) TerminateWorkflowInstance(ctx context.Context, instance *workflow.Instance, event *history.Event) error {
+	tx, err := sb.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var state core.WorkflowInstanceState
+	if err := tx.QueryRowContext(
+		ctx,
+		"SELECT state FROM instances WHERE id = ? AND execution_id = ?",
+		instance.InstanceID,
+		instance.ExecutionID,
+	).Scan(&state); err != nil {
+		if err == sql.ErrNoRows {
+			return backend.ErrInstanceNotFound
+		}
+
+		return fmt.Errorf("getting workflow instance state: %w", err)
+	}
+
+	if state.Finished() {
+		return backend.ErrInstanceFinished
+	}
+
+	// Suspended instances don't get workflow tasks, make them active again so the termination is processed
+	if state == core.WorkflowInstanceStateSuspended {
+		if _, err := tx.ExecContext(
+			ctx,
+			"UPDATE instances SET state = ?, attempts = 0 WHERE id = ? AND execution_id = ?",
+			core.WorkflowInstanceStateActive,
+			instance.InstanceID,
+			instance.ExecutionID,
+		); err != nil {
+			return fmt.Errorf("resuming workflow instance: %w", err)
+		}
+	}
+
+	if err := insertPendingEvents(ctx, tx, instance.InstanceID, []history.Event{*event}); err != nil {
+		return fmt.Errorf("inserting termination event: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+func (sb *sqliteBackend) ResetWorkflowInstance(
+	ctx context.Context,
+	instance *workflow.Instance,
+	executionID string,
+	toSequenceID int64,
+	activityEvents, timerEvents, pendingEvents []history.Event,
+) error {
+	tx, err := sb.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Start new execution. Release any lock and stickiness, tasks for the previous execution cannot be completed anymore.
+	if res, err := tx.ExecContext(
+		ctx,
+		`UPDATE instances SET execution_id = ?, completed_at = NULL, locked_until = NULL, sticky_until = NULL, worker = NULL, last_error = NULL,
+			state = CASE WHEN state = ? THEN state ELSE ? END, attempts = 0
+			WHERE id = ? AND execution_id = ?`,
+		executionID,
+		core.WorkflowInstanceStateSuspended, // Suspended instances stay suspended until they are resumed
+		core.WorkflowInstanceStateActive,
+		instance.InstanceID,
+		instance.ExecutionID,
+	); err != nil {
+		return fmt.Errorf("updating workflow instance: %w", err)
+	} else if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("checking for updated workflow instance: %w", err)
+	} else if n != 1 {
+		return backend.ErrInstanceNotFound
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM `history` WHERE instance_id = ? AND sequence_id >= ?", instance.InstanceID, toSequenceID); err != nil {
+		return fmt.Errorf("truncating history: %w", err)
+	}
+
+	// Pending events include timers of the previous execution
+	if _, err := tx.ExecContext(ctx, "DELETE FROM `pending_events` WHERE instance_id = ?", instance.InstanceID); err != nil {
+		return fmt.Errorf("removing pending events: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM `activities` WHERE instance_id = ?", instance.InstanceID); err != nil {
+		return fmt.Errorf("removing activities: %w", err)
+	}
+
+	for _, event := range activityEvents {
+		if err := scheduleActivity(ctx, tx, instance.InstanceID, executionID, event); err != nil {
+			return fmt.Errorf("scheduling activity: %w", err)
+		}
+	}
+
+	if err := insertPendingEvents(ctx, tx, instance.InstanceID, timerEvents); err != nil {
+		return fmt.Errorf("scheduling timers: %w", err)
+	}
+
+	if err := insertPendingEvents(ctx, tx, instance.InstanceID, pendingEvents); err != nil {
+		return fmt.Errorf("inserting pending events: %w", err)
+	}
+
+	return tx.Commit()
+}
+
 func (sb *sqliteBackend) GetWorkflowInstanceHistory(ctx context.Context, instance *workflow.Instance, lastSequenceID *int64) ([]history.Event, error) {
 	tx, err := sb.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -185,23 +346,21 @@ func (sb *sqliteBackend) GetWorkflowInstanceHistory(ctx context.Context, instanc
 func (s *sqliteBackend) GetWorkflowInstanceState(ctx context.Context, instance *workflow.Instance) (core.WorkflowInstanceState, error) {
 	row := s.db.QueryRowContext(
 		ctx,
-		"SELECT completed_at FROM instances WHERE id = ? AND execution_id = ?",
+		"SELECT state FROM instances WHERE id = ? AND execution_id = ?",
 		instance.InstanceID,
 		instance.ExecutionID,
 	)
 
-	var completedAt sql.NullTime
-	if err := row.Scan(&completedAt); err != nil {
+	var state core.WorkflowInstanceState
+	if err := row.Scan(&state); err != nil {
 		if err == sql.ErrNoRows {
 			return core.WorkflowInstanceStateActive, backend.ErrInstanceNotFound
 		}
-	}
 
-	if completedAt.Valid {
-		return core.WorkflowInstanceStateFinished, nil
+		return core.WorkflowInstanceStateActive, fmt.Errorf("getting workflow instance state: %w", err)
 	}
 
-	return core.WorkflowInstanceStateActive, nil
+	return state, nil
 }
 
 func (sb *sqliteBackend) SignalWorkflow(ctx context.Context, instanceID string, event history.Event) error {
@@ -237,26 +396,30 @@ func (sb *sqliteBackend) GetWorkflowTask(ctx context.Context) (*task.Workflow, e
 	row := tx.QueryRowContext(
 		ctx,
 		`UPDATE instances
-			SET locked_until = ?, worker = ?
+			SET locked_until = ?, worker = ?, attempts = attempts + 1
 			WHERE rowid = (
 				SELECT rowid FROM instances i
 					WHERE
 						(locked_until IS NULL OR locked_until < ?)
 						AND (sticky_until IS NULL OR sticky_until < ? OR worker = ?)
 						AND completed_at IS NULL
+						AND state = ?
 						AND EXISTS (
 							SELECT 1
 								FROM pending_events
 								WHERE instance_id = i.id AND execution_id = i.execution_id AND (visible_at IS NULL OR visible_at <= ?)
 						)
+					ORDER BY priority = ? DESC, priority DESC
 					LIMIT 1
-			) RETURNING id, execution_id, parent_instance_id, parent_schedule_event_id, metadata, sticky_until`,
+			) RETURNING id, execution_id, parent_instance_id, parent_schedule_event_id, metadata, sticky_until, attempts`,
 		now.Add(sb.options.WorkflowLockTimeout), // new locked_until
 		sb.workerName,
-		now,           // locked_until
-		now,           // sticky_until
-		sb.workerName, // worker
-		now,           // event.visible_at
+		now,                              // locked_until
+		now,                              // sticky_until
+		sb.workerName,                    // worker
+		core.WorkflowInstanceStateActive, // state
+		now,                              // event.visible_at
+		sb.workflowPriorities.Next()[0],  // preferred priority
 	)
 
 	var instanceID, executionID string
@@ -264,7 +427,8 @@ func (sb *sqliteBackend) GetWorkflowTask(ctx context.Context) (*task.Workflow, e
 	var parentEventID *int64
 	var metadataJson sql.NullString
 	var stickyUntil *time.Time
-	if err := row.Scan(&instanceID, &executionID, &parentInstanceID, &parentEventID, &metadataJson, &stickyUntil); err != nil {
+	var attempts int
+	if err := row.Scan(&instanceID, &executionID, &parentInstanceID, &parentEventID, &metadataJson, &stickyUntil, &attempts); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
@@ -292,6 +456,7 @@ func (sb *sqliteBackend) GetWorkflowTask(ctx context.Context) (*task.Workflow, e
 		WorkflowInstanceState: core.WorkflowInstanceStateActive,
 		Metadata:              metadata,
 		NewEvents:             []history.Event{},
+		Attempt:               attempts,
 	}
 
 	// Get new events
@@ -339,18 +504,23 @@ func (sb *sqliteBackend) CompleteWorkflowTask(
 	}
 	defer tx.Rollback()
 
+	// Keep the state of instances that have been suspended while this task was executed, unless they finished
 	var completedAt *time.Time
-	if state == core.WorkflowInstanceStateFinished {
+	var finishedState *core.WorkflowInstanceState
+	if state.Finished() {
 		t := time.Now()
 		completedAt = &t
+		finishedState = &state
 	}
 
 	// Unlock instance, but keep it sticky to the current worker
 	if res, err := tx.ExecContext(
 		ctx,
-		`UPDATE instances SET locked_until = NULL, sticky_until = ?, completed_at = ? WHERE id = ? AND execution_id = ? AND worker = ?`,
+		`UPDATE instances SET locked_until = NULL, sticky_until = ?, completed_at = ?, state = COALESCE(?, state), last_error = NULL, attempts = 0
+			WHERE id = ? AND execution_id = ? AND worker = ?`,
 		time.Now().Add(sb.options.StickyTimeout),
 		completedAt,
+		finishedState,
 		instance.InstanceID,
 		instance.ExecutionID,
 		sb.workerName,
@@ -359,7 +529,7 @@ func (sb *sqliteBackend) CompleteWorkflowTask(
 	} else if n, err := res.RowsAffected(); err != nil {
 		return fmt.Errorf("checking for unlocked workflow instances: %w", err)
 	} else if n != 1 {
-		return errors.New("could not find workflow instance to unlock")
+		return unlockError(ctx, tx, instance)
 	}
 
 	// Remove handled events from task
@@ -402,6 +572,12 @@ func (sb *sqliteBackend) CompleteWorkflowTask(
 			if err := removeFutureEvent(ctx, tx, instance.InstanceID, event.ScheduleEventID); err != nil {
 				return fmt.Errorf("removing future event: %w", err)
 			}
+
+		case history.EventType_SearchAttributesUpserted:
+			a := event.Attributes.(*history.SearchAttributesUpsertedAttributes)
+			if err := upsertSearchAttributes(ctx, tx, instance.InstanceID, a.SearchAttributes); err != nil {
+				return fmt.Errorf("indexing search attributes: %w", err)
+			}
 		}
 	}
 
@@ -413,7 +589,7 @@ func (sb *sqliteBackend) CompleteWorkflowTask(
 			if m.HistoryEvent.Type == history.EventType_WorkflowExecutionStarted {
 				a := m.HistoryEvent.Attributes.(*history.ExecutionStartedAttributes)
 				// Create new instance
-				if err := createInstance(ctx, tx, m.WorkflowInstance, a.Metadata, true); err != nil {
+				if err := createInstance(ctx, tx, m.WorkflowInstance, a, true); err != nil {
 					return err
 				}
 
@@ -431,9 +607,91 @@ func (sb *sqliteBackend) CompleteWorkflowTask(
 		}
 	}
 
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	if state.Finished() && sb.options.Archiver != nil {
+		// The instance is already finished at this point, failing to archive it must not fail the task. The
+		// history is archived again when the instance is removed.
+		if err := sb.archiveWorkflowInstance(ctx, instance); err != nil {
+			sb.Logger().Error("archiving workflow instance", "instance_id", instance.InstanceID, "error", err.Error())
+		}
+	}
+
+	return nil
+}
+
+func (sb *sqliteBackend) archiveWorkflowInstance(ctx context.Context, instance *workflow.Instance) error {
+	h, err := sb.GetWorkflowInstanceHistory(ctx, instance, nil)
+	if err != nil {
+		return err
+	}
+
+	return sb.options.Archiver.ArchiveWorkflowInstance(ctx, instance, h)
+}
+
+func (sb *sqliteBackend) RemoveWorkflowInstance(ctx context.Context, instance *workflow.Instance) error {
+	// Archive before opening the transaction that deletes the instance, the archiver might be slow
+	if sb.options.Archiver != nil {
+		state, err := sb.GetWorkflowInstanceState(ctx, instance)
+		if err != nil {
+			return err
+		}
+
+		if !state.Finished() {
+			return backend.ErrInstanceNotFinished
+		}
+
+		if err := sb.archiveWorkflowInstance(ctx, instance); err != nil {
+			return fmt.Errorf("archiving workflow instance: %w", err)
+		}
+	}
+
+	tx, err := sb.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// The instance might have been reset since it was archived
+	if err := checkInstanceFinished(ctx, tx, instance); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM instances WHERE id = ?", instance.InstanceID); err != nil {
+		return fmt.Errorf("deleting workflow instance: %w", err)
+	}
+
+	for _, table := range []string{"history", "pending_events", "activities", "search_attributes"} {
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM `%v` WHERE instance_id = ?", table), instance.InstanceID); err != nil {
+			return fmt.Errorf("deleting %v: %w", table, err)
+		}
+	}
+
 	return tx.Commit()
 }
 
+// checkInstanceFinished returns an error if the given execution of the instance does not exist or has not finished
+func checkInstanceFinished(ctx context.Context, tx *sql.Tx, instance *workflow.Instance) error {
+	row := tx.QueryRowContext(ctx, "SELECT completed_at FROM instances WHERE id = ? AND execution_id = ?", instance.InstanceID, instance.ExecutionID)
+
+	var completedAt sql.NullTime
+	if err := row.Scan(&completedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return backend.ErrInstanceNotFound
+		}
+
+		return fmt.Errorf("getting workflow instance: %w", err)
+	}
+
+	if !completedAt.Valid {
+		return backend.ErrInstanceNotFinished
+	}
+
+	return nil
+}
+
 func (sb *sqliteBackend) ExtendWorkflowTask(ctx context.Context, taskID string, instance *workflow.Instance) error {
 	tx, err := sb.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -463,6 +721,173 @@ func (sb *sqliteBackend) ExtendWorkflowTask(ctx context.Context, taskID string,
 	return tx.Commit()
 }
 
+func (sb *sqliteBackend) AbandonWorkflowTask(ctx context.Context, t *task.Workflow, lastError string) error {
+	// Keep the lock, the task is retried once the lock expires
+	res, err := sb.db.ExecContext(
+		ctx,
+		`UPDATE instances SET last_error = ? WHERE id = ? AND execution_id = ? AND worker = ?`,
+		lastError,
+		t.WorkflowInstance.InstanceID,
+		t.WorkflowInstance.ExecutionID,
+		sb.workerName,
+	)
+	if err != nil {
+		return fmt.Errorf("abandoning workflow task: %w", err)
+	}
+
+	if rowsAffected, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("determining if workflow task was abandoned: %w", err)
+	} else if rowsAffected == 0 {
+		return errors.New("could not abandon workflow task")
+	}
+
+	return nil
+}
+
+func (sb *sqliteBackend) SuspendWorkflowTask(ctx context.Context, t *task.Workflow, reason string) error {
+	// Unlock the instance and keep the pending events, they are processed once the instance is resumed
+	res, err := sb.db.ExecContext(
+		ctx,
+		`UPDATE instances SET state = ?, last_error = ?, locked_until = NULL, sticky_until = NULL, worker = NULL
+			WHERE id = ? AND execution_id = ? AND worker = ?`,
+		core.WorkflowInstanceStateSuspended,
+		reason,
+		t.WorkflowInstance.InstanceID,
+		t.WorkflowInstance.ExecutionID,
+		sb.workerName,
+	)
+	if err != nil {
+		return fmt.Errorf("suspending workflow instance: %w", err)
+	}
+
+	if rowsAffected, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("determining if workflow instance was suspended: %w", err)
+	} else if rowsAffected == 0 {
+		return errors.New("could not suspend workflow instance")
+	}
+
+	return nil
+}
+
+func (sb *sqliteBackend) SuspendWorkflowInstance(ctx context.Context, instance *workflow.Instance) error {
+	tx, err := sb.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var state core.WorkflowInstanceState
+	if err := tx.QueryRowContext(
+		ctx,
+		"SELECT state FROM instances WHERE id = ? AND execution_id = ?",
+		instance.InstanceID,
+		instance.ExecutionID,
+	).Scan(&state); err != nil {
+		if err == sql.ErrNoRows {
+			return backend.ErrInstanceNotFound
+		}
+
+		return fmt.Errorf("getting workflow instance state: %w", err)
+	}
+
+	if state != core.WorkflowInstanceStateActive {
+		return backend.ErrInstanceNotActive
+	}
+
+	// A task that is currently being executed can still be completed, but no new tasks are handed out
+	if _, err := tx.ExecContext(
+		ctx,
+		"UPDATE instances SET state = ? WHERE id = ? AND execution_id = ?",
+		core.WorkflowInstanceStateSuspended,
+		instance.InstanceID,
+		instance.ExecutionID,
+	); err != nil {
+		return fmt.Errorf("suspending workflow instance: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+func (sb *sqliteBackend) ResumeWorkflowInstance(ctx context.Context, instance *workflow.Instance) error {
+	tx, err := sb.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var state core.WorkflowInstanceState
+	if err := tx.QueryRowContext(
+		ctx,
+		"SELECT state FROM instances WHERE id = ? AND execution_id = ?",
+		instance.InstanceID,
+		instance.ExecutionID,
+	).Scan(&state); err != nil {
+		if err == sql.ErrNoRows {
+			return backend.ErrInstanceNotFound
+		}
+
+		return fmt.Errorf("getting workflow instance state: %w", err)
+	}
+
+	if state != core.WorkflowInstanceStateSuspended {
+		return backend.ErrInstanceNotSuspended
+	}
+
+	if _, err := tx.ExecContext(
+		ctx,
+		"UPDATE instances SET state = ?, attempts = 0, last_error = NULL WHERE id = ? AND execution_id = ?",
+		core.WorkflowInstanceStateActive,
+		instance.InstanceID,
+		instance.ExecutionID,
+	); err != nil {
+		return fmt.Errorf("resuming workflow instance: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+func (sb *sqliteBackend) ReleaseStickiness(ctx context.Context, instance *workflow.Instance) error {
+	// Only clear sticky_until, the worker column also tracks the owner of the lock
+	var err error
+	if instance == nil {
+		_, err = sb.db.ExecContext(
+			ctx,
+			`UPDATE instances SET sticky_until = NULL WHERE worker = ? AND sticky_until IS NOT NULL`,
+			sb.workerName,
+		)
+	} else {
+		_, err = sb.db.ExecContext(
+			ctx,
+			`UPDATE instances SET sticky_until = NULL WHERE id = ? AND execution_id = ? AND worker = ?`,
+			instance.InstanceID,
+			instance.ExecutionID,
+			sb.workerName,
+		)
+	}
+	if err != nil {
+		return fmt.Errorf("releasing workflow instance stickiness: %w", err)
+	}
+
+	return nil
+}
+
+func (sb *sqliteBackend) ReleaseWorkflowTask(ctx context.Context, taskID string, instance *workflow.Instance) error {
+	// Release the lock and stickiness, if the task has been completed in the meantime this is a no-op. The task was
+	// not attempted, so don't count it.
+	if _, err := sb.db.ExecContext(
+		ctx,
+		`UPDATE instances SET locked_until = NULL, sticky_until = NULL, worker = NULL, attempts = MAX(attempts - 1, 0)
+			WHERE id = ? AND execution_id = ? AND worker = ?`,
+		instance.InstanceID,
+		instance.ExecutionID,
+		sb.workerName,
+	); err != nil {
+		return fmt.Errorf("releasing workflow task: %w", err)
+	}
+
+	return nil
+}
+
 func (sb *sqliteBackend) GetActivityTask(ctx context.Context) (*task.Activity, error) {
 	tx, err := sb.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -478,11 +903,15 @@ func (sb *sqliteBackend) GetActivityTask(ctx context.Context) (*task.Activity, e
 		`UPDATE activities
 			SET locked_until = ?, worker = ?
 			WHERE rowid = (
-				SELECT rowid FROM activities WHERE locked_until IS NULL OR locked_until < ? LIMIT 1
+				SELECT rowid FROM activities
+					WHERE locked_until IS NULL OR locked_until < ?
+					ORDER BY priority = ? DESC, priority DESC, rowid
+					LIMIT 1
 			) RETURNING id, instance_id, execution_id, event_type, timestamp, schedule_event_id, attributes, visible_at`,
 		now.Add(sb.options.ActivityLockTimeout),
 		sb.workerName,
 		now,
+		sb.activityPriorities.Next()[0],
 	)
 	if err != nil {
 		return nil, err
@@ -508,8 +937,8 @@ func (sb *sqliteBackend) GetActivityTask(ctx context.Context) (*task.Activity, e
 
 	event.Attributes = a
 
-	var metadataJson sql.NullString
-	if err := tx.QueryRowContext(ctx, "SELECT metadata FROM instances WHERE id = ?", instanceID).Scan(&metadataJson); err != nil {
+	var metadataJson, memoJson sql.NullString
+	if err := tx.QueryRowContext(ctx, "SELECT metadata, memo FROM instances WHERE id = ?", instanceID).Scan(&metadataJson, &memoJson); err != nil {
 		return nil, fmt.Errorf("scanning metadata: %w", err)
 	}
 
@@ -518,10 +947,16 @@ func (sb *sqliteBackend) GetActivityTask(ctx context.Context) (*task.Activity, e
 		return nil, fmt.Errorf("unmarshaling metadata: %w", err)
 	}
 
+	memo, err := unmarshalMemo(memoJson)
+	if err != nil {
+		return nil, err
+	}
+
 	t := &task.Activity{
 		ID:               event.ID,
 		WorkflowInstance: core.NewWorkflowInstance(instanceID, executionID),
 		Metadata:         metadata,
+		Memo:             memo,
 		Event:            event,
 	}
 
@@ -539,6 +974,21 @@ func (sb *sqliteBackend) CompleteActivityTask(ctx context.Context, instance *wor
 	}
 	defer tx.Rollback()
 
+	// Drop results of activities scheduled by a previous execution, the instance has been reset in the meantime
+	var executionID string
+	if err := tx.QueryRowContext(ctx, "SELECT execution_id FROM instances WHERE id = ?", instance.InstanceID).Scan(&executionID); err != nil {
+		if err == sql.ErrNoRows {
+			return backend.ErrInstanceNotFound
+		}
+
+		return fmt.Errorf("getting workflow instance: %w", err)
+	}
+
+	if executionID != instance.ExecutionID {
+		sb.Logger().Debug("Discarding result of activity for previous execution", "instance_id", instance.InstanceID, "activity_id", id)
+		return nil
+	}
+
 	// Remove activity
 	if res, err := tx.ExecContext(
 		ctx,
@@ -589,3 +1039,43 @@ func (sb *sqliteBackend) ExtendActivityTask(ctx context.Context, activityID stri
 
 	return tx.Commit()
 }
+
+func (sb *sqliteBackend) ReleaseActivityTask(ctx context.Context, activityID string, delay time.Duration) error {
+	// Tasks are locked until they can be picked up again
+	var lockedUntil *time.Time
+	if delay > 0 {
+		t := time.Now().Add(delay)
+		lockedUntil = &t
+	}
+
+	if _, err := sb.db.ExecContext(
+		ctx,
+		`UPDATE activities SET locked_until = ?, worker = NULL WHERE id = ? AND worker = ?`,
+		lockedUntil,
+		activityID,
+		sb.workerName,
+	); err != nil {
+		return fmt.Errorf("releasing activity task: %w", err)
+	}
+
+	return nil
+}
+
+// unlockError returns the error for a workflow task whose instance could not be unlocked. This happens when the
+// instance has been reset while the task was executed, or when the task's lock has expired.
+func unlockError(ctx context.Context, tx *sql.Tx, instance *workflow.Instance) error {
+	var executionID string
+	if err := tx.QueryRowContext(ctx, "SELECT execution_id FROM instances WHERE id = ?", instance.InstanceID).Scan(&executionID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return backend.ErrInstanceNotFound
+		}
+
+		return fmt.Errorf("getting workflow instance: %w", err)
+	}
+
+	if executionID != instance.ExecutionID {
+		return backend.ErrWorkflowTaskDiscarded
+	}
+
+	return errors.New("could not find workflow instance to unlock")
+}
@@ -2,12 +2,19 @@ package sqlite
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/paveliak/go-workflows/archive"
 	"github.com/paveliak/go-workflows/backend"
 	"github.com/paveliak/go-workflows/backend/test"
+	"github.com/paveliak/go-workflows/internal/core"
 	"github.com/paveliak/go-workflows/internal/history"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
 )
 
 func Test_SqliteBackend(t *testing.T) {
@@ -24,8 +31,200 @@ func Test_EndToEndSqliteBackend(t *testing.T) {
 	}, nil)
 }
 
+func Test_SqliteBackend_ArchivesFinishedInstances(t *testing.T) {
+	ctx := context.Background()
+
+	a, err := archive.NewFilesystemArchiver(t.TempDir())
+	require.NoError(t, err)
+
+	b := NewInMemoryBackend(backend.WithStickyTimeout(0), backend.WithArchiver(a))
+
+	wfi := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+	startedEvent := history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{})
+	require.NoError(t, b.CreateWorkflowInstance(ctx, wfi, startedEvent))
+
+	task, err := b.GetWorkflowTask(ctx)
+	require.NoError(t, err)
+
+	events := []history.Event{
+		history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowTaskStarted, &history.WorkflowTaskStartedAttributes{}),
+		startedEvent,
+		history.NewHistoryEvent(3, time.Now(), history.EventType_WorkflowExecutionFinished, &history.ExecutionCompletedAttributes{}),
+	}
+	events[1].SequenceID = 2
+
+	require.NoError(t, b.CompleteWorkflowTask(ctx, task, wfi, core.WorkflowInstanceStateFinished, events, nil, nil, nil))
+
+	h, err := a.GetWorkflowInstanceHistory(ctx, wfi.InstanceID)
+	require.NoError(t, err)
+	require.Len(t, h, 3)
+
+	require.NoError(t, b.RemoveWorkflowInstance(ctx, wfi))
+
+	h, err = a.GetWorkflowInstanceHistory(ctx, wfi.InstanceID)
+	require.NoError(t, err)
+	require.Len(t, h, 3)
+	require.Equal(t, history.EventType_WorkflowExecutionFinished, h[2].Type)
+}
+
+func Test_SqliteBackend_ReleaseStickiness(t *testing.T) {
+	ctx := context.Background()
+
+	path := t.TempDir() + "/sticky.sqlite"
+	b1 := NewSqliteBackend(path, backend.WithStickyTimeout(time.Hour))
+	b2 := NewSqliteBackend(path, backend.WithStickyTimeout(time.Hour))
+
+	wfi := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+	startedEvent := history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{})
+	require.NoError(t, b1.CreateWorkflowInstance(ctx, wfi, startedEvent))
+
+	task, err := b1.GetWorkflowTask(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, task)
+
+	events := []history.Event{
+		history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowTaskStarted, &history.WorkflowTaskStartedAttributes{}),
+		startedEvent,
+	}
+	events[1].SequenceID = 2
+
+	require.NoError(t, b1.CompleteWorkflowTask(ctx, task, wfi, core.WorkflowInstanceStateActive, events, nil, nil, nil))
+
+	require.NoError(t, b1.SignalWorkflow(ctx, wfi.InstanceID, history.NewPendingEvent(
+		time.Now(), history.EventType_SignalReceived, &history.SignalReceivedAttributes{Name: "signal"})))
+
+	// Instance is sticky to the first worker
+	task, err = b2.GetWorkflowTask(ctx)
+	require.NoError(t, err)
+	require.Nil(t, task)
+
+	require.NoError(t, b1.ReleaseStickiness(ctx, wfi))
+
+	task, err = b2.GetWorkflowTask(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, task)
+	require.Equal(t, wfi.InstanceID, task.WorkflowInstance.InstanceID)
+}
+
 var _ test.TestBackend = (*sqliteBackend)(nil)
 
+// baselineSchema is the initial schema of the tables that got columns since, and of the tables they are backfilled
+// from
+const baselineSchema = `
+CREATE TABLE instances (
+  id TEXT PRIMARY KEY,
+  execution_id TEXT NO NULL,
+  parent_instance_id TEXT NULL,
+  parent_schedule_event_id INTEGER NULL,
+  metadata TEXT NULL,
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  completed_at DATETIME NULL,
+  locked_until DATETIME NULL,
+  sticky_until DATETIME NULL,
+  worker TEXT NULL
+);
+
+CREATE TABLE activities (
+  id TEXT PRIMARY KEY,
+  instance_id TEXT NOT NULL,
+  execution_id TEXT NOT NULL,
+  event_type INTEGER NOT NULL,
+  timestamp DATETIME NOT NULL,
+  schedule_event_id INT NOT NULL,
+  attributes BLOB NOT NULL,
+  visible_at DATETIME NULL,
+  locked_until DATETIME NULL,
+  worker TEXT NULL
+);
+
+CREATE TABLE pending_events (
+  id TEXT,
+  sequence_id INTEGER NOT NULL,
+  instance_id TEXT NOT NULL,
+  event_type INTEGER NOT NULL,
+  timestamp DATETIME NOT NULL,
+  schedule_event_id INT NOT NULL,
+  attributes BLOB NOT NULL,
+  visible_at DATETIME NULL,
+  PRIMARY KEY(id, instance_id)
+);
+
+CREATE TABLE history (
+  id TEXT,
+  sequence_id INTEGER NOT NULL,
+  instance_id TEXT NOT NULL,
+  event_type INTEGER NOT NULL,
+  timestamp DATETIME NOT NULL,
+  schedule_event_id INT NOT NULL,
+  attributes BLOB NOT NULL,
+  visible_at DATETIME NULL,
+  PRIMARY KEY(id, instance_id)
+);
+`
+
+func Test_SqliteBackend_MigratesBaselineSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "workflows.sqlite")
+
+	db, err := sql.Open("sqlite3", "file:"+path)
+	require.NoError(t, err)
+
+	_, err = db.Exec(baselineSchema)
+	require.NoError(t, err)
+
+	active := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+	finished := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+	_, err = db.Exec(
+		"INSERT INTO instances (id, execution_id, completed_at) VALUES (?, ?, NULL), (?, ?, ?)",
+		active.InstanceID, active.ExecutionID, finished.InstanceID, finished.ExecutionID, time.Now())
+	require.NoError(t, err)
+
+	_, err = db.Exec(
+		"INSERT INTO history (id, sequence_id, instance_id, event_type, timestamp, schedule_event_id, attributes) VALUES (?, 1, ?, ?, ?, -1, ?)",
+		uuid.NewString(), active.InstanceID, history.EventType_WorkflowExecutionStarted, time.Now(), []byte(`{"name":"Greet"}`))
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	b := NewSqliteBackend(path)
+	defer b.db.Close()
+
+	state, err := b.GetWorkflowInstanceState(context.Background(), active)
+	require.NoError(t, err)
+	require.Equal(t, core.WorkflowInstanceStateActive, state)
+
+	state, err = b.GetWorkflowInstanceState(context.Background(), finished)
+	require.NoError(t, err)
+	require.Equal(t, core.WorkflowInstanceStateFinished, state)
+
+	ref, err := b.GetWorkflowInstance(context.Background(), active.InstanceID)
+	require.NoError(t, err)
+	require.Equal(t, "Greet", ref.WorkflowName)
+
+	// Migrations only add missing columns
+	require.NoError(t, migrate(b.db))
+
+	// The migrated tables have the same columns as the tables of a new database
+	nb := NewInMemoryBackend()
+	defer nb.db.Close()
+
+	tx, err := b.db.Begin()
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	ntx, err := nb.db.Begin()
+	require.NoError(t, err)
+	defer ntx.Rollback()
+
+	for _, table := range []string{"instances", "activities"} {
+		columns, err := tableColumns(tx, table)
+		require.NoError(t, err)
+
+		expected, err := tableColumns(ntx, table)
+		require.NoError(t, err)
+
+		require.Equal(t, expected, columns, table)
+	}
+}
+
 func (sb *sqliteBackend) GetFutureEvents(ctx context.Context) ([]history.Event, error) {
 	tx, err := sb.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -240,6 +240,399 @@ func BackendTest(t *testing.T, setup func() TestBackend, teardown func(b TestBac
 				require.NotNil(t, s.CompletedAt)
 			},
 		},
+		{
+			name: "CompleteWorkflowTask_StoresFinalState",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				startedEvent := history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{
+					Name:     "some-workflow",
+					Inputs:   []payload.Payload{},
+					Metadata: &core.WorkflowMetadata{},
+				})
+
+				wfi := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+				err := b.CreateWorkflowInstance(ctx, wfi, startedEvent)
+				require.NoError(t, err)
+
+				task, err := b.GetWorkflowTask(ctx)
+				require.NoError(t, err)
+				require.NotNil(t, task)
+
+				events := []history.Event{
+					history.NewHistoryEvent(2, time.Now(), history.EventType_WorkflowTaskStarted, &history.WorkflowTaskStartedAttributes{}),
+					startedEvent,
+					history.NewHistoryEvent(4, time.Now(), history.EventType_WorkflowExecutionFinished, &history.ExecutionCompletedAttributes{
+						Error: "something went wrong",
+					}),
+				}
+				events[1].SequenceID = 3
+
+				err = b.CompleteWorkflowTask(ctx, task, wfi, core.WorkflowInstanceStateFailed, events, []history.Event{}, []history.Event{}, []history.WorkflowEvent{})
+				require.NoError(t, err)
+
+				s, err := b.GetWorkflowInstanceState(ctx, wfi)
+				require.NoError(t, err)
+				require.Equal(t, core.WorkflowInstanceStateFailed, s)
+
+				db := b.(diag.Backend)
+				ref, err := db.GetWorkflowInstance(ctx, wfi.InstanceID)
+				require.NoError(t, err)
+				require.Equal(t, core.WorkflowInstanceStateFailed, ref.State)
+				require.NotNil(t, ref.CompletedAt)
+
+				// Listings can be filtered by state
+				refs, err := db.GetWorkflowInstances(ctx, "", 100, core.WorkflowInstanceStateFailed)
+				require.NoError(t, err)
+				require.True(t, containsInstance(refs, wfi.InstanceID))
+				for _, ref := range refs {
+					require.Equal(t, core.WorkflowInstanceStateFailed, ref.State)
+				}
+
+				refs, err = db.GetWorkflowInstances(ctx, "", 100, core.WorkflowInstanceStateActive, core.WorkflowInstanceStateCompleted)
+				require.NoError(t, err)
+				require.False(t, containsInstance(refs, wfi.InstanceID))
+			},
+		},
+		{
+			name: "ListWorkflowInstances_FiltersInstances",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				workflowName := "list-workflow-" + uuid.NewString()
+
+				createInstance := func(name string, searchAttributes map[string]interface{}) *core.WorkflowInstance {
+					sa, err := core.NewSearchAttributes(searchAttributes)
+					require.NoError(t, err)
+
+					wfi := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+					err = b.CreateWorkflowInstance(ctx, wfi, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{
+						Name:             name,
+						Inputs:           []payload.Payload{},
+						Metadata:         &core.WorkflowMetadata{},
+						SearchAttributes: sa,
+					}))
+					require.NoError(t, err)
+
+					return wfi
+				}
+
+				// Upsert search attributes while executing a task
+				wfi2 := createInstance(workflowName, map[string]interface{}{"customer": "c2"})
+
+				task, err := b.GetWorkflowTask(ctx)
+				require.NoError(t, err)
+				require.NotNil(t, task)
+
+				sa, err := core.NewSearchAttributes(map[string]interface{}{"customer": "c3"})
+				require.NoError(t, err)
+
+				events := []history.Event{
+					history.NewHistoryEvent(2, time.Now(), history.EventType_WorkflowTaskStarted, &history.WorkflowTaskStartedAttributes{}),
+					task.NewEvents[0],
+					history.NewHistoryEvent(4, time.Now(), history.EventType_SearchAttributesUpserted, &history.SearchAttributesUpsertedAttributes{
+						SearchAttributes: sa,
+					}),
+				}
+				events[1].SequenceID = 3
+
+				err = b.CompleteWorkflowTask(ctx, task, wfi2, core.WorkflowInstanceStateActive, events, []history.Event{}, []history.Event{}, []history.WorkflowEvent{})
+				require.NoError(t, err)
+
+				wfi1 := createInstance(workflowName, map[string]interface{}{"customer": "c1", "amount": 42})
+				createInstance("other-"+workflowName, map[string]interface{}{"customer": "c1"})
+
+				list := func(filter *backend.WorkflowInstanceFilter) []string {
+					refs, err := b.ListWorkflowInstances(ctx, filter)
+					require.NoError(t, err)
+
+					ids := []string{}
+					for _, ref := range refs {
+						ids = append(ids, ref.Instance.InstanceID)
+					}
+
+					return ids
+				}
+
+				require.ElementsMatch(t, []string{wfi1.InstanceID, wfi2.InstanceID}, list(&backend.WorkflowInstanceFilter{
+					WorkflowName: workflowName,
+				}))
+
+				require.Equal(t, []string{wfi1.InstanceID}, list(&backend.WorkflowInstanceFilter{
+					WorkflowName:     workflowName,
+					SearchAttributes: map[string]interface{}{"customer": "c1"},
+				}))
+
+				require.Equal(t, []string{wfi2.InstanceID}, list(&backend.WorkflowInstanceFilter{
+					WorkflowName:     workflowName,
+					SearchAttributes: map[string]interface{}{"customer": "c3"},
+				}))
+
+				require.Empty(t, list(&backend.WorkflowInstanceFilter{
+					WorkflowName:     workflowName,
+					SearchAttributes: map[string]interface{}{"customer": "c2"},
+				}))
+
+				require.Empty(t, list(&backend.WorkflowInstanceFilter{
+					WorkflowName: workflowName,
+					States:       []core.WorkflowInstanceState{core.WorkflowInstanceStateCompleted},
+				}))
+
+				require.Len(t, list(&backend.WorkflowInstanceFilter{
+					WorkflowName: workflowName,
+					Count:        1,
+				}), 1)
+
+				require.Empty(t, list(&backend.WorkflowInstanceFilter{
+					WorkflowName: workflowName,
+					CreatedAfter: time.Now().Add(time.Hour),
+				}))
+
+				refs, err := b.ListWorkflowInstances(ctx, &backend.WorkflowInstanceFilter{
+					SearchAttributes: map[string]interface{}{"customer": "c1", "amount": 42},
+				})
+				require.NoError(t, err)
+				require.Len(t, refs, 1)
+				require.Equal(t, workflowName, refs[0].WorkflowName)
+				require.Equal(t, core.WorkflowInstanceStateActive, refs[0].State)
+				require.Equal(t, map[string]interface{}{"customer": "c1", "amount": float64(42)}, refs[0].SearchAttributes)
+			},
+		},
+		{
+			name: "GetWorkflowInstanceChildren_ReturnsSubWorkflows",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				db := b.(diag.Backend)
+
+				wfi := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+				err := b.CreateWorkflowInstance(ctx, wfi, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{
+					Name:     "parent-workflow",
+					Inputs:   []payload.Payload{},
+					Metadata: &core.WorkflowMetadata{},
+				}))
+				require.NoError(t, err)
+
+				task, err := b.GetWorkflowTask(ctx)
+				require.NoError(t, err)
+				require.NotNil(t, task)
+
+				subInstance := core.NewSubWorkflowInstance(uuid.NewString(), uuid.NewString(), wfi.InstanceID, 1)
+
+				events := []history.Event{
+					history.NewHistoryEvent(2, time.Now(), history.EventType_WorkflowTaskStarted, &history.WorkflowTaskStartedAttributes{}),
+					task.NewEvents[0],
+					history.NewHistoryEvent(4, time.Now(), history.EventType_SubWorkflowScheduled, &history.SubWorkflowScheduledAttributes{
+						SubWorkflowInstance: subInstance,
+						Name:                "child-workflow",
+					}, history.ScheduleEventID(1)),
+				}
+				events[1].SequenceID = 3
+
+				workflowEvents := []history.WorkflowEvent{
+					{
+						WorkflowInstance: subInstance,
+						HistoryEvent: history.NewPendingEvent(time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{
+							Name:     "child-workflow",
+							Inputs:   []payload.Payload{},
+							Metadata: &core.WorkflowMetadata{},
+						}),
+					},
+				}
+
+				err = b.CompleteWorkflowTask(ctx, task, wfi, core.WorkflowInstanceStateActive, events, []history.Event{}, []history.Event{}, workflowEvents)
+				require.NoError(t, err)
+
+				ref, err := db.GetWorkflowInstance(ctx, wfi.InstanceID)
+				require.NoError(t, err)
+				require.Equal(t, "parent-workflow", ref.WorkflowName)
+				require.False(t, ref.Instance.SubWorkflow())
+
+				children, err := db.GetWorkflowInstanceChildren(ctx, wfi.InstanceID)
+				require.NoError(t, err)
+				require.Len(t, children, 1)
+				require.Equal(t, subInstance.InstanceID, children[0].Instance.InstanceID)
+				require.Equal(t, wfi.InstanceID, children[0].Instance.ParentInstanceID)
+				require.Equal(t, "child-workflow", children[0].WorkflowName)
+
+				ref, err = db.GetWorkflowInstance(ctx, subInstance.InstanceID)
+				require.NoError(t, err)
+				require.Equal(t, wfi.InstanceID, ref.Instance.ParentInstanceID)
+				require.Equal(t, int64(1), ref.Instance.ParentEventID)
+
+				children, err = db.GetWorkflowInstanceChildren(ctx, subInstance.InstanceID)
+				require.NoError(t, err)
+				require.Empty(t, children)
+			},
+		},
+		{
+			name: "QueryWorkflowInstances_FiltersInstances",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				db := b.(diag.Backend)
+
+				workflowName := "query-workflow-" + uuid.NewString()
+				prefix := uuid.NewString()
+
+				instanceIDs := []string{prefix + "-1", prefix + "-2", uuid.NewString()}
+				for _, instanceID := range instanceIDs {
+					err := b.CreateWorkflowInstance(ctx, core.NewWorkflowInstance(instanceID, uuid.NewString()), history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{
+						Name:     workflowName,
+						Inputs:   []payload.Payload{},
+						Metadata: &core.WorkflowMetadata{},
+					}))
+					require.NoError(t, err)
+				}
+
+				ids := func(refs []*diag.WorkflowInstanceRef) []string {
+					r := make([]string, 0, len(refs))
+					for _, ref := range refs {
+						r = append(r, ref.Instance.InstanceID)
+					}
+					return r
+				}
+
+				refs, err := db.QueryWorkflowInstances(ctx, &diag.WorkflowInstanceQuery{WorkflowName: workflowName})
+				require.NoError(t, err)
+				require.ElementsMatch(t, instanceIDs, ids(refs))
+
+				refs, err = db.QueryWorkflowInstances(ctx, &diag.WorkflowInstanceQuery{WorkflowName: workflowName, InstanceIDPrefix: prefix})
+				require.NoError(t, err)
+				require.ElementsMatch(t, instanceIDs[:2], ids(refs))
+
+				// Paging returns the remaining instance
+				refs, err = db.QueryWorkflowInstances(ctx, &diag.WorkflowInstanceQuery{InstanceIDPrefix: prefix, Count: 1})
+				require.NoError(t, err)
+				require.Len(t, refs, 1)
+
+				page2, err := db.QueryWorkflowInstances(ctx, &diag.WorkflowInstanceQuery{InstanceIDPrefix: prefix, AfterInstanceID: refs[0].Instance.InstanceID})
+				require.NoError(t, err)
+				require.ElementsMatch(t, instanceIDs[:2], append(ids(refs), ids(page2)...))
+
+				refs, err = db.QueryWorkflowInstances(ctx, &diag.WorkflowInstanceQuery{
+					WorkflowName: workflowName,
+					States:       []core.WorkflowInstanceState{core.WorkflowInstanceStateCompleted},
+				})
+				require.NoError(t, err)
+				require.Empty(t, refs)
+
+				refs, err = db.QueryWorkflowInstances(ctx, &diag.WorkflowInstanceQuery{
+					WorkflowName:  workflowName,
+					States:        []core.WorkflowInstanceState{core.WorkflowInstanceStateActive},
+					CreatedAfter:  time.Now().Add(-time.Hour),
+					CreatedBefore: time.Now().Add(time.Hour),
+				})
+				require.NoError(t, err)
+				require.Len(t, refs, 3)
+
+				refs, err = db.QueryWorkflowInstances(ctx, &diag.WorkflowInstanceQuery{WorkflowName: workflowName, CreatedAfter: time.Now().Add(time.Hour)})
+				require.NoError(t, err)
+				require.Empty(t, refs)
+
+				refs, err = db.QueryWorkflowInstances(ctx, &diag.WorkflowInstanceQuery{WorkflowName: workflowName, CompletedBefore: time.Now().Add(time.Hour)})
+				require.NoError(t, err)
+				require.Empty(t, refs)
+			},
+		},
+		{
+			name: "GetStats_CountsInstancesAndTasks",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				sb, ok := b.(diag.StatsBackend)
+				if !ok {
+					t.Skip("backend does not implement stats")
+				}
+
+				workflowName := "stats-workflow-" + uuid.NewString()
+				workflowStats := func(stats *diag.Stats) *diag.WorkflowStats {
+					for _, ws := range stats.Workflows {
+						if ws.WorkflowName == workflowName {
+							return ws
+						}
+					}
+
+					return nil
+				}
+
+				wfi := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+				err := b.CreateWorkflowInstance(ctx, wfi, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{
+					Name:     workflowName,
+					Inputs:   []payload.Payload{},
+					Metadata: &core.WorkflowMetadata{},
+				}))
+				require.NoError(t, err)
+
+				stats, err := sb.GetStats(ctx, time.Now().Add(-time.Hour))
+				require.NoError(t, err)
+				require.Equal(t, &diag.WorkflowStats{WorkflowName: workflowName, Running: 1}, workflowStats(stats))
+				require.NotNil(t, stats.OldestPendingTask)
+
+				task, err := b.GetWorkflowTask(ctx)
+				require.NoError(t, err)
+				require.NotNil(t, task)
+
+				events := []history.Event{
+					history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowTaskStarted, &history.WorkflowTaskStartedAttributes{}),
+					task.NewEvents[0],
+					history.NewHistoryEvent(3, time.Now(), history.EventType_WorkflowExecutionFinished, &history.ExecutionCompletedAttributes{Error: "boom"}),
+				}
+				events[1].SequenceID = 2
+
+				err = b.CompleteWorkflowTask(ctx, task, wfi, core.WorkflowInstanceStateFailed, events, []history.Event{}, []history.Event{}, []history.WorkflowEvent{})
+				require.NoError(t, err)
+
+				stats, err = sb.GetStats(ctx, time.Now().Add(-time.Hour))
+				require.NoError(t, err)
+				require.Equal(t, &diag.WorkflowStats{WorkflowName: workflowName, Failed: 1}, workflowStats(stats))
+				require.GreaterOrEqual(t, stats.RecentlyFailed, int64(1))
+				require.GreaterOrEqual(t, stats.RecentlyFinished, stats.RecentlyFailed)
+				require.Greater(t, stats.FailureRate(), 0.0)
+
+				stats, err = sb.GetStats(ctx, time.Now().Add(time.Hour))
+				require.NoError(t, err)
+				require.Zero(t, stats.RecentlyFinished)
+			},
+		},
+		{
+			name: "AbandonWorkflowTask_RecordsLastError",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				startedEvent := history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{
+					Name:     "some-workflow",
+					Inputs:   []payload.Payload{},
+					Metadata: &core.WorkflowMetadata{},
+				})
+
+				wfi := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+				err := b.CreateWorkflowInstance(ctx, wfi, startedEvent)
+				require.NoError(t, err)
+
+				task, err := b.GetWorkflowTask(ctx)
+				require.NoError(t, err)
+				require.NotNil(t, task)
+
+				err = b.AbandonWorkflowTask(ctx, task, "panic: something went wrong")
+				require.NoError(t, err)
+
+				db := b.(diag.Backend)
+				s, err := db.GetWorkflowInstance(ctx, wfi.InstanceID)
+				require.NoError(t, err)
+				require.Equal(t, core.WorkflowInstanceStateActive, s.State)
+				require.Equal(t, "panic: something went wrong", s.LastError)
+
+				h, err := b.GetWorkflowInstanceHistory(ctx, wfi, nil)
+				require.NoError(t, err)
+				require.Empty(t, h)
+
+				// Task stays locked until the lock expires
+				task2, err := b.GetWorkflowTask(ctx)
+				require.NoError(t, err)
+				require.Nil(t, task2)
+
+				// Completing a task clears the last error
+				events := []history.Event{
+					history.NewHistoryEvent(2, time.Now(), history.EventType_WorkflowTaskStarted, &history.WorkflowTaskStartedAttributes{}),
+				}
+
+				err = b.CompleteWorkflowTask(ctx, task, wfi, core.WorkflowInstanceStateActive, events, []history.Event{}, []history.Event{}, []history.WorkflowEvent{})
+				require.NoError(t, err)
+
+				s, err = db.GetWorkflowInstance(ctx, wfi.InstanceID)
+				require.NoError(t, err)
+				require.Empty(t, s.LastError)
+			},
+		},
 		{
 			name: "SignalWorkflow_ErrorWhenInstanceDoesNotExist",
 			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
@@ -307,43 +700,740 @@ func BackendTest(t *testing.T, setup func() TestBackend, teardown func(b TestBac
 			},
 		},
 		{
-			name: "GetActivityTask_ReturnsNilWhenTimeout",
+			name: "RemoveWorkflowInstance_ErrorWhenInstanceDoesNotExist",
 			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
-				ctx, cancel := context.WithTimeout(ctx, time.Millisecond)
-				defer cancel()
+				err := b.RemoveWorkflowInstance(ctx, core.NewWorkflowInstance(uuid.NewString(), uuid.NewString()))
+				require.Error(t, err)
+				require.ErrorIs(t, err, backend.ErrInstanceNotFound)
+			},
+		},
+		{
+			name: "RemoveWorkflowInstance_ErrorWhenInstanceNotFinished",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				wfi := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+				err := b.CreateWorkflowInstance(
+					ctx, wfi, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{}),
+				)
+				require.NoError(t, err)
 
-				task, _ := b.GetActivityTask(ctx)
-				require.Nil(t, task)
+				err = b.RemoveWorkflowInstance(ctx, wfi)
+				require.Error(t, err)
+				require.ErrorIs(t, err, backend.ErrInstanceNotFinished)
 			},
 		},
-	}
+		{
+			name: "RemoveWorkflowInstance_RemovesFinishedInstance",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				startedEvent := history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{
+					Name:     "some-workflow",
+					Inputs:   []payload.Payload{},
+					Metadata: &core.WorkflowMetadata{},
+				})
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			b := setup()
-			ctx := context.Background()
+				wfi := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+				err := b.CreateWorkflowInstance(ctx, wfi, startedEvent)
+				require.NoError(t, err)
 
-			t.Cleanup(func() {
-				if teardown != nil {
-					teardown(b)
+				task, err := b.GetWorkflowTask(ctx)
+				require.NoError(t, err)
+
+				events := []history.Event{
+					history.NewHistoryEvent(2, time.Now(), history.EventType_WorkflowTaskStarted, &history.WorkflowTaskStartedAttributes{}),
+					startedEvent,
+					history.NewHistoryEvent(4, time.Now(), history.EventType_WorkflowExecutionFinished, &history.ExecutionCompletedAttributes{}),
 				}
-			})
+				events[1].SequenceID = 3
 
-			tt.f(t, ctx, b)
-		})
-	}
-}
+				err = b.CompleteWorkflowTask(ctx, task, wfi, core.WorkflowInstanceStateFinished, events, []history.Event{}, []history.Event{}, []history.WorkflowEvent{})
+				require.NoError(t, err)
 
-func startWorkflow(t *testing.T, ctx context.Context, b backend.Backend, c client.Client, instance *core.WorkflowInstance) {
-	err := b.CreateWorkflowInstance(
-		ctx, instance, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{}))
-	require.NoError(t, err)
+				err = b.RemoveWorkflowInstance(ctx, wfi)
+				require.NoError(t, err)
 
-	// Get task to clear initial event
-	task, err := b.GetWorkflowTask(ctx)
-	require.NoError(t, err)
+				_, err = b.GetWorkflowInstanceState(ctx, wfi)
+				require.ErrorIs(t, err, backend.ErrInstanceNotFound)
 
-	err = b.CompleteWorkflowTask(
-		ctx, task, instance, core.WorkflowInstanceStateActive, task.NewEvents, []history.Event{}, []history.Event{}, []history.WorkflowEvent{})
-	require.NoError(t, err)
+				h, err := b.GetWorkflowInstanceHistory(ctx, wfi, nil)
+				require.NoError(t, err)
+				require.Len(t, h, 0)
+			},
+		},
+		{
+			name: "ResetWorkflowInstance_ErrorWhenInstanceDoesNotExist",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				err := b.ResetWorkflowInstance(
+					ctx, core.NewWorkflowInstance(uuid.NewString(), uuid.NewString()), uuid.NewString(), 1, nil, nil, nil)
+				require.Error(t, err)
+				require.ErrorIs(t, err, backend.ErrInstanceNotFound)
+			},
+		},
+		{
+			name: "ResetWorkflowInstance_TruncatesHistory",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				startedEvent := history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{
+					Name:     "some-workflow",
+					Inputs:   []payload.Payload{},
+					Metadata: &core.WorkflowMetadata{},
+				})
+
+				wfi := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+				err := b.CreateWorkflowInstance(ctx, wfi, startedEvent)
+				require.NoError(t, err)
+
+				task, err := b.GetWorkflowTask(ctx)
+				require.NoError(t, err)
+
+				events := []history.Event{
+					history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowTaskStarted, &history.WorkflowTaskStartedAttributes{}),
+					startedEvent,
+					history.NewHistoryEvent(3, time.Now(), history.EventType_WorkflowTaskStarted, &history.WorkflowTaskStartedAttributes{}),
+					history.NewHistoryEvent(4, time.Now(), history.EventType_WorkflowExecutionFinished, &history.ExecutionCompletedAttributes{}),
+				}
+				events[1].SequenceID = 2
+
+				err = b.CompleteWorkflowTask(ctx, task, wfi, core.WorkflowInstanceStateFinished, events, []history.Event{}, []history.Event{}, []history.WorkflowEvent{})
+				require.NoError(t, err)
+
+				executionID := uuid.NewString()
+				err = b.ResetWorkflowInstance(ctx, wfi, executionID, 3, nil, nil, []history.Event{
+					history.NewPendingEvent(time.Now(), history.EventType_WorkflowExecutionReset, &history.ExecutionResetAttributes{}),
+				})
+				require.NoError(t, err)
+
+				newWfi := core.NewWorkflowInstance(wfi.InstanceID, executionID)
+
+				s, err := b.GetWorkflowInstanceState(ctx, newWfi)
+				require.NoError(t, err)
+				require.Equal(t, core.WorkflowInstanceStateActive, s)
+
+				h, err := b.GetWorkflowInstanceHistory(ctx, newWfi, nil)
+				require.NoError(t, err)
+				require.Len(t, h, 2)
+				require.Equal(t, int64(2), h[1].SequenceID)
+
+				task, err = b.GetWorkflowTask(ctx)
+				require.NoError(t, err)
+				require.NotNil(t, task)
+				require.Equal(t, executionID, task.WorkflowInstance.ExecutionID)
+				require.Equal(t, int64(2), task.LastSequenceID)
+				require.Len(t, task.NewEvents, 1)
+				require.Equal(t, history.EventType_WorkflowExecutionReset, task.NewEvents[0].Type)
+			},
+		},
+		{
+			name: "ResetWorkflowInstance_KeepsSuspendedState",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				startedEvent := history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{
+					Name:     "some-workflow",
+					Inputs:   []payload.Payload{},
+					Metadata: &core.WorkflowMetadata{},
+				})
+
+				wfi := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+				require.NoError(t, b.CreateWorkflowInstance(ctx, wfi, startedEvent))
+
+				task, err := b.GetWorkflowTask(ctx)
+				require.NoError(t, err)
+				require.NotNil(t, task)
+
+				events := []history.Event{
+					history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowTaskStarted, &history.WorkflowTaskStartedAttributes{}),
+					startedEvent,
+				}
+				events[1].SequenceID = 2
+
+				require.NoError(t, b.CompleteWorkflowTask(ctx, task, wfi, core.WorkflowInstanceStateActive, events, []history.Event{}, []history.Event{}, []history.WorkflowEvent{}))
+				require.NoError(t, b.SuspendWorkflowInstance(ctx, wfi))
+
+				executionID := uuid.NewString()
+				require.NoError(t, b.ResetWorkflowInstance(ctx, wfi, executionID, 1, nil, nil, []history.Event{
+					history.NewPendingEvent(time.Now(), history.EventType_WorkflowExecutionReset, &history.ExecutionResetAttributes{}),
+				}))
+
+				newWfi := core.NewWorkflowInstance(wfi.InstanceID, executionID)
+
+				s, err := b.GetWorkflowInstanceState(ctx, newWfi)
+				require.NoError(t, err)
+				require.Equal(t, core.WorkflowInstanceStateSuspended, s)
+
+				task, err = b.GetWorkflowTask(ctx)
+				require.NoError(t, err)
+				require.Nil(t, task)
+
+				require.NoError(t, b.ResumeWorkflowInstance(ctx, newWfi))
+
+				task, err = b.GetWorkflowTask(ctx)
+				require.NoError(t, err)
+				require.NotNil(t, task)
+				require.Equal(t, executionID, task.WorkflowInstance.ExecutionID)
+			},
+		},
+		{
+			name: "CompleteWorkflowTask_DiscardedAfterReset",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				startedEvent := history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{
+					Name:     "some-workflow",
+					Inputs:   []payload.Payload{},
+					Metadata: &core.WorkflowMetadata{},
+				})
+
+				wfi := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+				require.NoError(t, b.CreateWorkflowInstance(ctx, wfi, startedEvent))
+
+				task, err := b.GetWorkflowTask(ctx)
+				require.NoError(t, err)
+				require.NotNil(t, task)
+
+				// Reset the instance while the task is being executed
+				require.NoError(t, b.ResetWorkflowInstance(ctx, wfi, uuid.NewString(), 1, nil, nil, []history.Event{
+					history.NewPendingEvent(time.Now(), history.EventType_WorkflowExecutionReset, &history.ExecutionResetAttributes{}),
+				}))
+
+				events := []history.Event{
+					history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowTaskStarted, &history.WorkflowTaskStartedAttributes{}),
+					startedEvent,
+				}
+				events[1].SequenceID = 2
+
+				err = b.CompleteWorkflowTask(ctx, task, wfi, core.WorkflowInstanceStateActive, events, []history.Event{}, []history.Event{}, []history.WorkflowEvent{})
+				require.ErrorIs(t, err, backend.ErrWorkflowTaskDiscarded)
+
+				h, err := b.GetWorkflowInstanceHistory(ctx, wfi, nil)
+				require.NoError(t, err)
+				require.Len(t, h, 0)
+			},
+		},
+		{
+			name: "ReleaseWorkflowTask_MakesTaskAvailable",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				wfi := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+				err := b.CreateWorkflowInstance(ctx, wfi, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{
+					Name:     "some-workflow",
+					Inputs:   []payload.Payload{},
+					Metadata: &core.WorkflowMetadata{},
+				}))
+				require.NoError(t, err)
+
+				task, err := b.GetWorkflowTask(ctx)
+				require.NoError(t, err)
+				require.NotNil(t, task)
+				require.Equal(t, 1, task.Attempt)
+
+				err = b.ReleaseWorkflowTask(ctx, task.ID, task.WorkflowInstance)
+				require.NoError(t, err)
+
+				task2, err := b.GetWorkflowTask(ctx)
+				require.NoError(t, err)
+				require.NotNil(t, task2)
+				require.Equal(t, wfi.InstanceID, task2.WorkflowInstance.InstanceID)
+				require.Len(t, task2.NewEvents, 1)
+
+				// Released tasks don't count as attempts
+				require.Equal(t, 1, task2.Attempt)
+			},
+		},
+		{
+			name: "SuspendWorkflowTask_SkipsInstanceUntilResumed",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				wfi := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+				err := b.CreateWorkflowInstance(ctx, wfi, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{
+					Name:     "some-workflow",
+					Inputs:   []payload.Payload{},
+					Metadata: &core.WorkflowMetadata{},
+				}))
+				require.NoError(t, err)
+
+				task, err := b.GetWorkflowTask(ctx)
+				require.NoError(t, err)
+				require.NotNil(t, task)
+
+				err = b.SuspendWorkflowTask(ctx, task, "too many attempts")
+				require.NoError(t, err)
+
+				s, err := b.GetWorkflowInstanceState(ctx, wfi)
+				require.NoError(t, err)
+				require.Equal(t, core.WorkflowInstanceStateSuspended, s)
+
+				db := b.(diag.Backend)
+				ref, err := db.GetWorkflowInstance(ctx, wfi.InstanceID)
+				require.NoError(t, err)
+				require.Equal(t, core.WorkflowInstanceStateSuspended, ref.State)
+				require.Equal(t, "too many attempts", ref.LastError)
+
+				// Suspended instances are skipped
+				task2, err := b.GetWorkflowTask(ctx)
+				require.NoError(t, err)
+				require.Nil(t, task2)
+
+				err = b.ResumeWorkflowInstance(ctx, wfi)
+				require.NoError(t, err)
+
+				err = b.ResumeWorkflowInstance(ctx, wfi)
+				require.ErrorIs(t, err, backend.ErrInstanceNotSuspended)
+
+				// Pending events are kept and the attempt count starts over
+				task3, err := b.GetWorkflowTask(ctx)
+				require.NoError(t, err)
+				require.NotNil(t, task3)
+				require.Equal(t, wfi.InstanceID, task3.WorkflowInstance.InstanceID)
+				require.Len(t, task3.NewEvents, 1)
+				require.Equal(t, 1, task3.Attempt)
+			},
+		},
+		{
+			name: "SuspendWorkflowInstance_DefersEventsUntilResumed",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				wfi := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+				err := b.CreateWorkflowInstance(ctx, wfi, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{
+					Name:     "some-workflow",
+					Inputs:   []payload.Payload{},
+					Metadata: &core.WorkflowMetadata{},
+				}))
+				require.NoError(t, err)
+
+				task, err := b.GetWorkflowTask(ctx)
+				require.NoError(t, err)
+				require.NotNil(t, task)
+
+				// Suspend while the task is being executed
+				err = b.SuspendWorkflowInstance(ctx, wfi)
+				require.NoError(t, err)
+
+				err = b.SuspendWorkflowInstance(ctx, wfi)
+				require.ErrorIs(t, err, backend.ErrInstanceNotActive)
+
+				now := time.Now()
+				events := []history.Event{
+					history.NewHistoryEvent(1, now, history.EventType_WorkflowTaskStarted, &history.WorkflowTaskStartedAttributes{}),
+					task.NewEvents[0],
+					history.NewHistoryEvent(3, now, history.EventType_TimerScheduled, &history.TimerScheduledAttributes{At: now}, history.ScheduleEventID(1)),
+				}
+				events[1].SequenceID = 2
+				timerEvents := []history.Event{
+					history.NewPendingEvent(now, history.EventType_TimerFired, &history.TimerFiredAttributes{At: now}, history.ScheduleEventID(1), history.VisibleAt(now)),
+				}
+
+				err = b.CompleteWorkflowTask(ctx, task, wfi, core.WorkflowInstanceStateActive, events, []history.Event{}, timerEvents, []history.WorkflowEvent{})
+				require.NoError(t, err)
+
+				// Completing the task does not resume the instance
+				s, err := b.GetWorkflowInstanceState(ctx, wfi)
+				require.NoError(t, err)
+				require.Equal(t, core.WorkflowInstanceStateSuspended, s)
+
+				c := client.New(b)
+				err = c.SignalWorkflow(ctx, wfi.InstanceID, "signal", "value")
+				require.NoError(t, err)
+
+				// Neither the fired timer nor the signal are delivered
+				task2, err := b.GetWorkflowTask(ctx)
+				require.NoError(t, err)
+				require.Nil(t, task2)
+
+				err = c.ResumeWorkflowInstance(ctx, wfi)
+				require.NoError(t, err)
+
+				task3, err := b.GetWorkflowTask(ctx)
+				require.NoError(t, err)
+				require.NotNil(t, task3)
+				require.Len(t, task3.NewEvents, 2)
+				require.ElementsMatch(t,
+					[]history.EventType{history.EventType_TimerFired, history.EventType_SignalReceived},
+					[]history.EventType{task3.NewEvents[0].Type, task3.NewEvents[1].Type})
+			},
+		},
+		{
+			name: "SuspendWorkflowInstance_ErrorWhenInstanceDoesNotExist",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				err := b.SuspendWorkflowInstance(ctx, core.NewWorkflowInstance(uuid.NewString(), uuid.NewString()))
+				require.ErrorIs(t, err, backend.ErrInstanceNotFound)
+			},
+		},
+		{
+			name: "ResumeWorkflowInstance_ErrorWhenInstanceDoesNotExist",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				err := b.ResumeWorkflowInstance(ctx, core.NewWorkflowInstance(uuid.NewString(), uuid.NewString()))
+				require.ErrorIs(t, err, backend.ErrInstanceNotFound)
+			},
+		},
+		{
+			name: "TerminateWorkflowInstance_ErrorWhenInstanceDoesNotExist",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				event := history.NewWorkflowTerminationEvent(time.Now(), "")
+				err := b.TerminateWorkflowInstance(ctx, core.NewWorkflowInstance(uuid.NewString(), uuid.NewString()), &event)
+				require.ErrorIs(t, err, backend.ErrInstanceNotFound)
+			},
+		},
+		{
+			name: "TerminateWorkflowInstance_ResumesSuspendedInstance",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				wfi := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+				err := b.CreateWorkflowInstance(ctx, wfi, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{
+					Name:     "some-workflow",
+					Inputs:   []payload.Payload{},
+					Metadata: &core.WorkflowMetadata{},
+				}))
+				require.NoError(t, err)
+
+				task, err := b.GetWorkflowTask(ctx)
+				require.NoError(t, err)
+				require.NotNil(t, task)
+
+				events := []history.Event{
+					history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowTaskStarted, &history.WorkflowTaskStartedAttributes{}),
+					task.NewEvents[0],
+				}
+				events[1].SequenceID = 2
+
+				err = b.CompleteWorkflowTask(ctx, task, wfi, core.WorkflowInstanceStateActive, events, []history.Event{}, []history.Event{}, []history.WorkflowEvent{})
+				require.NoError(t, err)
+
+				err = b.SuspendWorkflowInstance(ctx, wfi)
+				require.NoError(t, err)
+
+				c := client.New(b)
+				err = c.TerminateWorkflowInstance(ctx, wfi, "stuck")
+				require.NoError(t, err)
+
+				s, err := b.GetWorkflowInstanceState(ctx, wfi)
+				require.NoError(t, err)
+				require.Equal(t, core.WorkflowInstanceStateActive, s)
+
+				task2, err := b.GetWorkflowTask(ctx)
+				require.NoError(t, err)
+				require.NotNil(t, task2)
+				require.Len(t, task2.NewEvents, 1)
+				require.Equal(t, history.EventType_WorkflowExecutionTerminated, task2.NewEvents[0].Type)
+				require.Equal(t, "stuck", task2.NewEvents[0].Attributes.(*history.ExecutionTerminatedAttributes).Reason)
+
+				events2 := []history.Event{
+					history.NewHistoryEvent(3, time.Now(), history.EventType_WorkflowTaskStarted, &history.WorkflowTaskStartedAttributes{}),
+					task2.NewEvents[0],
+				}
+				events2[1].SequenceID = 4
+
+				err = b.CompleteWorkflowTask(ctx, task2, wfi, core.WorkflowInstanceStateTerminated, events2, []history.Event{}, []history.Event{}, []history.WorkflowEvent{})
+				require.NoError(t, err)
+
+				err = c.TerminateWorkflowInstance(ctx, wfi, "again")
+				require.ErrorIs(t, err, backend.ErrInstanceFinished)
+			},
+		},
+		{
+			name: "ReleaseActivityTask_MakesTaskAvailable",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				wfi := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+				err := b.CreateWorkflowInstance(ctx, wfi, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{
+					Name:     "some-workflow",
+					Inputs:   []payload.Payload{},
+					Metadata: &core.WorkflowMetadata{},
+				}))
+				require.NoError(t, err)
+
+				task, err := b.GetWorkflowTask(ctx)
+				require.NoError(t, err)
+
+				activityScheduled := history.NewHistoryEvent(3, time.Now(), history.EventType_ActivityScheduled, &history.ActivityScheduledAttributes{
+					Name:   "some-activity",
+					Inputs: []payload.Payload{},
+				}, history.ScheduleEventID(1))
+
+				events := []history.Event{
+					history.NewHistoryEvent(2, time.Now(), history.EventType_WorkflowTaskStarted, &history.WorkflowTaskStartedAttributes{}),
+					activityScheduled,
+				}
+
+				err = b.CompleteWorkflowTask(ctx, task, wfi, core.WorkflowInstanceStateActive, events, []history.Event{activityScheduled}, []history.Event{}, []history.WorkflowEvent{})
+				require.NoError(t, err)
+
+				activityTask, err := b.GetActivityTask(ctx)
+				require.NoError(t, err)
+				require.NotNil(t, activityTask)
+
+				err = b.ReleaseActivityTask(ctx, activityTask.ID, 0)
+				require.NoError(t, err)
+
+				activityTask2, err := b.GetActivityTask(ctx)
+				require.NoError(t, err)
+				require.NotNil(t, activityTask2)
+				require.Equal(t, activityScheduled.ID, activityTask2.Event.ID)
+			},
+		},
+		{
+			name: "ReleaseActivityTask_Delayed",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				wfi := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+				err := b.CreateWorkflowInstance(ctx, wfi, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{
+					Name:     "some-workflow",
+					Inputs:   []payload.Payload{},
+					Metadata: &core.WorkflowMetadata{},
+				}))
+				require.NoError(t, err)
+
+				task, err := b.GetWorkflowTask(ctx)
+				require.NoError(t, err)
+
+				activityScheduled := history.NewHistoryEvent(3, time.Now(), history.EventType_ActivityScheduled, &history.ActivityScheduledAttributes{
+					Name:   "some-activity",
+					Inputs: []payload.Payload{},
+				}, history.ScheduleEventID(1))
+
+				events := []history.Event{
+					history.NewHistoryEvent(2, time.Now(), history.EventType_WorkflowTaskStarted, &history.WorkflowTaskStartedAttributes{}),
+					activityScheduled,
+				}
+
+				err = b.CompleteWorkflowTask(ctx, task, wfi, core.WorkflowInstanceStateActive, events, []history.Event{activityScheduled}, []history.Event{}, []history.WorkflowEvent{})
+				require.NoError(t, err)
+
+				activityTask, err := b.GetActivityTask(ctx)
+				require.NoError(t, err)
+				require.NotNil(t, activityTask)
+
+				err = b.ReleaseActivityTask(ctx, activityTask.ID, time.Hour)
+				require.NoError(t, err)
+
+				// The task is not available until the delay has passed
+				activityTask2, err := b.GetActivityTask(ctx)
+				require.NoError(t, err)
+				require.Nil(t, activityTask2)
+			},
+		},
+		{
+			name: "ImportWorkflowInstance_CreatesInstanceWithHistory",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				im, ok := b.(backend.Importer)
+				if !ok {
+					t.Skip("backend does not implement backend.Importer")
+				}
+
+				activityScheduled := history.NewHistoryEvent(3, time.Now(), history.EventType_ActivityScheduled, &history.ActivityScheduledAttributes{
+					Name:   "some-activity",
+					Inputs: []payload.Payload{},
+				}, history.ScheduleEventID(1))
+
+				h := []history.Event{
+					history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowTaskStarted, &history.WorkflowTaskStartedAttributes{}),
+					history.NewHistoryEvent(2, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{
+						Name:     "some-workflow",
+						Inputs:   []payload.Payload{},
+						Metadata: &core.WorkflowMetadata{},
+					}),
+					activityScheduled,
+				}
+
+				wfi := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+				require.NoError(t, im.ImportWorkflowInstance(ctx, wfi, core.WorkflowInstanceStateActive, h, []history.Event{
+					history.NewPendingEvent(time.Now(), history.EventType_ActivityScheduled, activityScheduled.Attributes, history.ScheduleEventID(1)),
+				}, nil))
+
+				s, err := b.GetWorkflowInstanceState(ctx, wfi)
+				require.NoError(t, err)
+				require.Equal(t, core.WorkflowInstanceStateActive, s)
+
+				ih, err := b.GetWorkflowInstanceHistory(ctx, wfi, nil)
+				require.NoError(t, err)
+				require.Len(t, ih, 3)
+				require.Equal(t, activityScheduled.ID, ih[2].ID)
+				require.Equal(t, int64(3), ih[2].SequenceID)
+
+				activityTask, err := b.GetActivityTask(ctx)
+				require.NoError(t, err)
+				require.NotNil(t, activityTask)
+				require.Equal(t, wfi.ExecutionID, activityTask.WorkflowInstance.ExecutionID)
+				require.Equal(t, int64(1), activityTask.Event.ScheduleEventID)
+
+				err = im.ImportWorkflowInstance(ctx, wfi, core.WorkflowInstanceStateActive, h, nil, nil)
+				require.ErrorIs(t, err, backend.ErrInstanceAlreadyExists)
+			},
+		},
+		{
+			name: "CreateWorkflowInstance_StoresMemo",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				workflowName := "memo-workflow-" + uuid.NewString()
+				memo := core.Memo{"customer": "c1", "region": "eu"}
+
+				wfi := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+				err := b.CreateWorkflowInstance(ctx, wfi, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{
+					Name:     workflowName,
+					Inputs:   []payload.Payload{},
+					Metadata: &core.WorkflowMetadata{},
+					Memo:     memo,
+				}))
+				require.NoError(t, err)
+
+				task, err := b.GetWorkflowTask(ctx)
+				require.NoError(t, err)
+				require.NotNil(t, task)
+
+				activityScheduled := history.NewHistoryEvent(3, time.Now(), history.EventType_ActivityScheduled, &history.ActivityScheduledAttributes{
+					Name:   "some-activity",
+					Inputs: []payload.Payload{},
+				}, history.ScheduleEventID(1))
+
+				events := []history.Event{
+					history.NewHistoryEvent(2, time.Now(), history.EventType_WorkflowTaskStarted, &history.WorkflowTaskStartedAttributes{}),
+					activityScheduled,
+				}
+
+				err = b.CompleteWorkflowTask(ctx, task, wfi, core.WorkflowInstanceStateActive, events, []history.Event{activityScheduled}, []history.Event{}, []history.WorkflowEvent{})
+				require.NoError(t, err)
+
+				// Activities get the memo of their workflow instance
+				activityTask, err := b.GetActivityTask(ctx)
+				require.NoError(t, err)
+				require.NotNil(t, activityTask)
+				require.Equal(t, memo, activityTask.Memo)
+
+				refs, err := b.ListWorkflowInstances(ctx, &backend.WorkflowInstanceFilter{
+					WorkflowName: workflowName,
+				})
+				require.NoError(t, err)
+				require.Len(t, refs, 1)
+				require.Equal(t, memo, refs[0].Memo)
+
+				ref, err := b.(diag.Backend).GetWorkflowInstance(ctx, wfi.InstanceID)
+				require.NoError(t, err)
+				require.Equal(t, memo, ref.Memo)
+			},
+		},
+		{
+			name: "GetWorkflowTask_ReturnsHigherPriorityFirst",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				low := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+				require.NoError(t, b.CreateWorkflowInstance(ctx, low, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{
+					Priority: core.PriorityLow,
+				})))
+
+				high := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+				require.NoError(t, b.CreateWorkflowInstance(ctx, high, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{
+					Priority: core.PriorityHigh,
+				})))
+
+				task, err := b.GetWorkflowTask(ctx)
+				require.NoError(t, err)
+				require.NotNil(t, task)
+				require.Equal(t, high.InstanceID, task.WorkflowInstance.InstanceID)
+
+				task, err = b.GetWorkflowTask(ctx)
+				require.NoError(t, err)
+				require.NotNil(t, task)
+				require.Equal(t, low.InstanceID, task.WorkflowInstance.InstanceID)
+			},
+		},
+		{
+			name: "GetActivityTask_ReturnsHigherPriorityFirst",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				wfi := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+				require.NoError(t, b.CreateWorkflowInstance(ctx, wfi, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{
+					Name:     "some-workflow",
+					Inputs:   []payload.Payload{},
+					Metadata: &core.WorkflowMetadata{},
+				})))
+
+				task, err := b.GetWorkflowTask(ctx)
+				require.NoError(t, err)
+
+				lowActivity := history.NewHistoryEvent(3, time.Now(), history.EventType_ActivityScheduled, &history.ActivityScheduledAttributes{
+					Name:     "low-activity",
+					Priority: core.PriorityLowest,
+				}, history.ScheduleEventID(1))
+				highActivity := history.NewHistoryEvent(4, time.Now(), history.EventType_ActivityScheduled, &history.ActivityScheduledAttributes{
+					Name:     "high-activity",
+					Priority: core.PriorityHighest,
+				}, history.ScheduleEventID(2))
+
+				events := []history.Event{
+					history.NewHistoryEvent(2, time.Now(), history.EventType_WorkflowTaskStarted, &history.WorkflowTaskStartedAttributes{}),
+					lowActivity,
+					highActivity,
+				}
+
+				err = b.CompleteWorkflowTask(ctx, task, wfi, core.WorkflowInstanceStateActive, events, []history.Event{lowActivity, highActivity}, []history.Event{}, []history.WorkflowEvent{})
+				require.NoError(t, err)
+
+				activityTask, err := b.GetActivityTask(ctx)
+				require.NoError(t, err)
+				require.NotNil(t, activityTask)
+				require.Equal(t, highActivity.ID, activityTask.Event.ID)
+
+				activityTask, err = b.GetActivityTask(ctx)
+				require.NoError(t, err)
+				require.NotNil(t, activityTask)
+				require.Equal(t, lowActivity.ID, activityTask.Event.ID)
+			},
+		},
+		{
+			name: "TakeRateLimitToken_LimitsRate",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				rl, ok := b.(backend.RateLimiter)
+				if !ok {
+					t.Skip("backend does not support shared rate limits")
+				}
+
+				key := uuid.NewString()
+
+				// Bucket starts full
+				for i := 0; i < 2; i++ {
+					wait, err := rl.TakeRateLimitToken(ctx, key, 1, 2)
+					require.NoError(t, err)
+					require.Zero(t, wait)
+				}
+
+				wait, err := rl.TakeRateLimitToken(ctx, key, 1, 2)
+				require.NoError(t, err)
+				require.Greater(t, wait, time.Duration(0))
+				require.LessOrEqual(t, wait, time.Second)
+			},
+		},
+		{
+			name: "GetActivityTask_ReturnsNilWhenTimeout",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				ctx, cancel := context.WithTimeout(ctx, time.Millisecond)
+				defer cancel()
+
+				task, _ := b.GetActivityTask(ctx)
+				require.Nil(t, task)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := setup()
+			ctx := context.Background()
+
+			t.Cleanup(func() {
+				if teardown != nil {
+					teardown(b)
+				}
+			})
+
+			tt.f(t, ctx, b)
+		})
+	}
+}
+
+func startWorkflow(t *testing.T, ctx context.Context, b backend.Backend, c client.Client, instance *core.WorkflowInstance) {
+	err := b.CreateWorkflowInstance(
+		ctx, instance, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{}))
+	require.NoError(t, err)
+
+	// Get task to clear initial event
+	task, err := b.GetWorkflowTask(ctx)
+	require.NoError(t, err)
+
+	err = b.CompleteWorkflowTask(
+		ctx, task, instance, core.WorkflowInstanceStateActive, task.NewEvents, []history.Event{}, []history.Event{}, []history.WorkflowEvent{})
+	require.NoError(t, err)
+}
+
+func containsInstance(refs []*diag.WorkflowInstanceRef, instanceID string) bool {
+	for _, ref := range refs {
+		if ref.Instance.InstanceID == instanceID {
+			return true
+		}
+	}
+
+	return false
 }
@@ -2,11 +2,14 @@ package test
 
 import (
 	"context"
+	"errors"
 	"log"
+	"strings"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/paveliak/go-workflows/activity"
 	"github.com/paveliak/go-workflows/backend"
 	"github.com/paveliak/go-workflows/client"
 	"github.com/paveliak/go-workflows/internal/core"
@@ -346,6 +349,57 @@ func EndToEndBackendTest(t *testing.T, setup func() TestBackend, teardown func(b
 				require.Equal(t, 2, r)
 			},
 		},
+		{
+			name: "SubWorkflow_Terminate",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				swInstances := make(chan *workflow.Instance, 1)
+
+				swfDone := false
+				swf := func(ctx workflow.Context) error {
+					select {
+					case swInstances <- workflow.WorkflowInstance(ctx):
+					default:
+					}
+
+					workflow.NewSignalChannel[string](ctx, "signal").Receive(ctx)
+					swfDone = true
+
+					return nil
+				}
+				wf := func(ctx workflow.Context) (string, error) {
+					_, err := workflow.CreateSubWorkflowInstance[any](ctx, workflow.DefaultSubWorkflowOptions, swf).Get(ctx)
+					if err == nil {
+						return "", errors.New("expected sub-workflow to fail")
+					}
+
+					return err.Error(), nil
+				}
+				register(t, ctx, w, []interface{}{wf, swf}, nil)
+
+				instance := runWorkflow(t, ctx, c, wf)
+
+				var swInstance *workflow.Instance
+				select {
+				case swInstance = <-swInstances:
+				case <-time.After(time.Second * 5):
+					require.FailNow(t, "sub-workflow not started")
+				}
+
+				require.NoError(t, c.TerminateWorkflowInstance(ctx, swInstance, "stuck"))
+
+				_, err := client.GetWorkflowResult[any](ctx, c, swInstance, time.Second*5)
+				require.ErrorIs(t, err, client.ErrWorkflowTerminated)
+				require.False(t, swfDone)
+
+				s, err := b.GetWorkflowInstanceState(ctx, swInstance)
+				require.NoError(t, err)
+				require.Equal(t, core.WorkflowInstanceStateTerminated, s)
+
+				r, err := client.GetWorkflowResult[string](ctx, c, instance, time.Second*5)
+				require.NoError(t, err)
+				require.Equal(t, "workflow terminated: stuck", r)
+			},
+		},
 		{
 			name: "Timer_CancelWorkflowInstance",
 			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
@@ -497,6 +551,159 @@ func EndToEndBackendTest(t *testing.T, setup func() TestBackend, teardown func(b
 				require.Len(t, futureEvents, 0, "no future events should be scheduled")
 			},
 		},
+		{
+			name: "Reset_RerunsActivity",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				var calls int32
+				a := func(ctx context.Context) (int, error) {
+					if atomic.AddInt32(&calls, 1) == 1 {
+						return 0, errors.New("activity failed")
+					}
+
+					return 42, nil
+				}
+				wf := func(ctx workflow.Context) (int, error) {
+					return workflow.ExecuteActivity[int](ctx, workflow.ActivityOptions{
+						RetryOptions: workflow.RetryOptions{
+							MaxAttempts: 1,
+						},
+					}, a).Get(ctx)
+				}
+				register(t, ctx, w, []interface{}{wf}, []interface{}{a})
+
+				instance := runWorkflow(t, ctx, c, wf)
+
+				_, err := client.GetWorkflowResult[int](ctx, c, instance, time.Second*10)
+				require.ErrorContains(t, err, "activity failed")
+
+				// Reset to the workflow task in which the activity failed
+				var toSequenceID int64
+				historyIterate(ctx, t, b, instance, func(event *history.Event) bool {
+					if event.Type == history.EventType_WorkflowTaskStarted {
+						toSequenceID = event.SequenceID
+					}
+
+					return true
+				})
+
+				newInstance, err := c.ResetWorkflowInstance(ctx, instance, toSequenceID, "retry activity")
+				require.NoError(t, err)
+				require.Equal(t, instance.InstanceID, newInstance.InstanceID)
+				require.NotEqual(t, instance.ExecutionID, newInstance.ExecutionID)
+
+				r, err := client.GetWorkflowResult[int](ctx, c, newInstance, time.Second*10)
+				require.NoError(t, err)
+				require.Equal(t, 42, r)
+
+				historyContains(ctx, t, b, newInstance,
+					history.EventType_ActivityScheduled,
+					history.EventType_WorkflowTaskStarted,
+					history.EventType_WorkflowExecutionReset,
+					history.EventType_ActivityCompleted,
+					history.EventType_WorkflowExecutionFinished,
+				)
+			},
+		},
+		{
+			name: "SearchAttributes_UpsertedByWorkflow",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				wf := func(ctx workflow.Context) error {
+					return workflow.UpsertSearchAttributes(ctx, map[string]interface{}{
+						"status": "shipped",
+					})
+				}
+				register(t, ctx, w, []interface{}{wf}, nil)
+
+				orderID := uuid.NewString()
+				instance, err := c.CreateWorkflowInstance(ctx, client.WorkflowInstanceOptions{
+					InstanceID: uuid.NewString(),
+					SearchAttributes: map[string]interface{}{
+						"order": orderID,
+					},
+				}, wf)
+				require.NoError(t, err)
+				require.NoError(t, c.WaitForWorkflowInstance(ctx, instance, time.Second*10))
+
+				refs, err := c.ListWorkflowInstances(ctx, &client.WorkflowInstanceFilter{
+					SearchAttributes: map[string]interface{}{
+						"order":  orderID,
+						"status": "shipped",
+					},
+				})
+				require.NoError(t, err)
+				require.Len(t, refs, 1)
+				require.Equal(t, instance.InstanceID, refs[0].Instance.InstanceID)
+				require.Equal(t, workflow.InstanceStateCompleted, refs[0].State)
+				require.Equal(t, map[string]interface{}{"order": orderID, "status": "shipped"}, refs[0].SearchAttributes)
+
+				historyContains(ctx, t, b, instance,
+					history.EventType_SearchAttributesUpserted,
+					history.EventType_WorkflowExecutionFinished,
+				)
+			},
+		},
+		{
+			name: "Memo_AvailableToWorkflowAndActivities",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				act := func(ctx context.Context) (string, error) {
+					return activity.Memo(ctx)["customer"], nil
+				}
+				subwf := func(ctx workflow.Context) (string, error) {
+					return workflow.Memo(ctx)["customer"], nil
+				}
+				wf := func(ctx workflow.Context) (string, error) {
+					r1, err := workflow.ExecuteActivity[string](ctx, workflow.DefaultActivityOptions, act).Get(ctx)
+					if err != nil {
+						return "", err
+					}
+
+					r2, err := workflow.CreateSubWorkflowInstance[string](ctx, workflow.SubWorkflowOptions{
+						PropagateMemo: true,
+					}, subwf).Get(ctx)
+					if err != nil {
+						return "", err
+					}
+
+					return workflow.Memo(ctx)["customer"] + r1 + r2, nil
+				}
+				register(t, ctx, w, []interface{}{wf, subwf}, []interface{}{act})
+
+				instance, err := c.CreateWorkflowInstance(ctx, client.WorkflowInstanceOptions{
+					InstanceID: uuid.NewString(),
+					Memo:       map[string]string{"customer": "c1"},
+				}, wf)
+				require.NoError(t, err)
+
+				r, err := client.GetWorkflowResult[string](ctx, c, instance, time.Second*10)
+				require.NoError(t, err)
+				require.Equal(t, "c1c1c1", r)
+			},
+		},
+		{
+			name: "QueryWorkflowInstance_StackTrace",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				wf := func(ctx workflow.Context) (int, error) {
+					r, _ := workflow.NewSignalChannel[int](ctx, "signal").Receive(ctx)
+					return r, nil
+				}
+				register(t, ctx, w, []interface{}{wf}, nil)
+
+				instance := runWorkflow(t, ctx, c, wf)
+
+				require.Eventually(t, func() bool {
+					stackTrace, err := worker.QueryWorkflowInstance[string](ctx, w, instance, worker.StackTraceQuery)
+					require.NoError(t, err)
+
+					return strings.Contains(stackTrace, "Receive")
+				}, time.Second*10, time.Millisecond*10)
+
+				require.NoError(t, c.SignalWorkflow(ctx, instance.InstanceID, "signal", 42))
+
+				r, err := client.GetWorkflowResult[int](ctx, c, instance, time.Second*10)
+				require.NoError(t, err)
+				require.Equal(t, 42, r)
+			},
+		},
 		{
 			name:         "NonDeterminism",
 			withoutCache: true,
@@ -584,6 +791,11 @@ func (*noopWorkflowExecutorCache) Get(ctx context.Context, instance *core.Workfl
 	return nil, false, nil
 }
 
+// Evict implements workflow.ExecutorCache
+func (*noopWorkflowExecutorCache) Evict(ctx context.Context, instance *core.WorkflowInstance) error {
+	return nil
+}
+
 // StartEviction implements workflow.ExecutorCache
 func (*noopWorkflowExecutorCache) StartEviction(ctx context.Context) {
 }
@@ -29,18 +29,42 @@ var ErrWorkflowTerminated = errors.New("workflow terminated")
 type WorkflowInstanceOptions struct {
 	InstanceID string
 
-	// FUTURE: Expose this to callers of the API. Use it only internally for now.
-	// Metadata *core.WorkflowInstanceMetadata
+	// Priority of the workflow instance's tasks, defaults to workflow.PriorityNormal
+	Priority workflow.Priority
+
+	// SearchAttributes are indexed values used to find the workflow instance with ListWorkflowInstances. Values
+	// have to be JSON serializable.
+	SearchAttributes map[string]interface{}
+
+	// Memo holds immutable key/values of the workflow instance. It can be read with workflow.Memo in the workflow and
+	// activity.Memo in its activities and is returned by ListWorkflowInstances.
+	Memo map[string]string
 }
 
 type Client interface {
+	// CreateWorkflowInstance starts a new instance of the given workflow. wf can also be the name of a workflow
+	// registered with the worker, for example when starting workflows from tools that do not import them.
 	CreateWorkflowInstance(ctx context.Context, options WorkflowInstanceOptions, wf workflow.Workflow, args ...interface{}) (*workflow.Instance, error)
 
 	CancelWorkflowInstance(ctx context.Context, instance *workflow.Instance) error
 
+	TerminateWorkflowInstance(ctx context.Context, instance *workflow.Instance, reason string) error
+
+	SuspendWorkflowInstance(ctx context.Context, instance *workflow.Instance) error
+
+	ResumeWorkflowInstance(ctx context.Context, instance *workflow.Instance) error
+
 	WaitForWorkflowInstance(ctx context.Context, instance *workflow.Instance, timeout time.Duration) error
 
 	SignalWorkflow(ctx context.Context, instanceID string, name string, arg interface{}) error
+
+	ResetWorkflowInstance(ctx context.Context, instance *workflow.Instance, toSequenceID int64, reason string, opts ...ResetOption) (*workflow.Instance, error)
+
+	// ImportWorkflowInstance creates a workflow instance with an existing history, if the backend implements
+	// backend.Importer
+	ImportWorkflowInstance(ctx context.Context, instanceID string, h []history.Event) (*workflow.Instance, error)
+
+	ListWorkflowInstances(ctx context.Context, filter *WorkflowInstanceFilter) ([]*WorkflowInstanceRef, error)
 }
 
 type client struct {
@@ -75,13 +99,21 @@ func (c *client) CreateWorkflowInstance(ctx context.Context, options WorkflowIns
 
 	tracing.MarshalSpan(sctx, metadata)
 
+	searchAttributes, err := core.NewSearchAttributes(options.SearchAttributes)
+	if err != nil {
+		return nil, err
+	}
+
 	startedEvent := history.NewPendingEvent(
 		c.clock.Now(),
 		history.EventType_WorkflowExecutionStarted,
 		&history.ExecutionStartedAttributes{
-			Metadata: metadata,
-			Name:     workflowName,
-			Inputs:   inputs,
+			Metadata:         metadata,
+			Name:             workflowName,
+			Inputs:           inputs,
+			Priority:         options.Priority.Clamp(),
+			SearchAttributes: searchAttributes,
+			Memo:             core.Memo(options.Memo).Clone(),
 		})
 
 	if err := c.backend.CreateWorkflowInstance(ctx, wfi, startedEvent); err != nil {
@@ -100,6 +132,27 @@ func (c *client) CancelWorkflowInstance(ctx context.Context, instance *workflow.
 	return c.backend.CancelWorkflowInstance(ctx, instance, &cancellationEvent)
 }
 
+// TerminateWorkflowInstance stops a workflow instance without running any more workflow code. Unlike cancellation,
+// the workflow cannot react to the termination, activities and timers it started are abandoned. Use this to stop
+// instances that cannot make progress anymore.
+func (c *client) TerminateWorkflowInstance(ctx context.Context, instance *workflow.Instance, reason string) error {
+	terminationEvent := history.NewWorkflowTerminationEvent(c.clock.Now(), reason)
+	return c.backend.TerminateWorkflowInstance(ctx, instance, &terminationEvent)
+}
+
+// SuspendWorkflowInstance suspends an active workflow instance without canceling it. While it's suspended, the
+// instance is not executed and its events, including signals and fired timers, are kept until it's resumed.
+func (c *client) SuspendWorkflowInstance(ctx context.Context, instance *workflow.Instance) error {
+	return c.backend.SuspendWorkflowInstance(ctx, instance)
+}
+
+// ResumeWorkflowInstance resumes a workflow instance that has been suspended, either explicitly or because its
+// workflow task exceeded the maximum number of attempts. Pending events are processed again, with a fresh attempt
+// count.
+func (c *client) ResumeWorkflowInstance(ctx context.Context, instance *workflow.Instance) error {
+	return c.backend.ResumeWorkflowInstance(ctx, instance)
+}
+
 func (c *client) SignalWorkflow(ctx context.Context, instanceID string, name string, arg interface{}) error {
 	input, err := converter.DefaultConverter.To(arg)
 	if err != nil {
@@ -150,7 +203,7 @@ func (c *client) WaitForWorkflowInstance(ctx context.Context, instance *workflow
 			return fmt.Errorf("getting workflow state: %w", err)
 		}
 
-		if s == core.WorkflowInstanceStateFinished {
+		if s.Finished() {
 			return nil
 		}
 	}
@@ -120,3 +120,191 @@ func Test_Client_SignalWorkflow_WithArgs(t *testing.T) {
 	require.Nil(t, err)
 	b.AssertExpectations(t)
 }
+
+func Test_Client_ResetWorkflowInstance(t *testing.T) {
+	instance := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+
+	ctx := context.Background()
+
+	timerAt := time.Now().Add(time.Hour)
+	signalArg, _ := converter.DefaultConverter.To(42)
+
+	h := []history.Event{
+		history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowTaskStarted, &history.WorkflowTaskStartedAttributes{}),
+		history.NewHistoryEvent(2, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{}),
+		history.NewHistoryEvent(3, time.Now(), history.EventType_ActivityScheduled, &history.ActivityScheduledAttributes{Name: "a"}, history.ScheduleEventID(1)),
+		history.NewHistoryEvent(4, time.Now(), history.EventType_TimerScheduled, &history.TimerScheduledAttributes{At: timerAt}, history.ScheduleEventID(2)),
+		history.NewHistoryEvent(5, time.Now(), history.EventType_WorkflowTaskStarted, &history.WorkflowTaskStartedAttributes{}),
+		history.NewHistoryEvent(6, time.Now(), history.EventType_ActivityCompleted, &history.ActivityCompletedAttributes{}, history.ScheduleEventID(1)),
+		history.NewHistoryEvent(7, time.Now(), history.EventType_SignalReceived, &history.SignalReceivedAttributes{Name: "signal", Arg: signalArg}),
+	}
+
+	b := &backend.MockBackend{}
+	b.On("Logger").Return(logger.NewDefaultLogger())
+	b.On("GetWorkflowInstanceHistory", ctx, instance, (*int64)(nil)).Return(h, nil)
+	b.On("ResetWorkflowInstance", ctx, instance, mock.AnythingOfType("string"), int64(5),
+		mock.MatchedBy(func(events []history.Event) bool {
+			return len(events) == 1 &&
+				events[0].Type == history.EventType_ActivityScheduled &&
+				events[0].ScheduleEventID == 1 &&
+				events[0].ID != h[2].ID
+		}),
+		mock.MatchedBy(func(events []history.Event) bool {
+			return len(events) == 1 &&
+				events[0].Type == history.EventType_TimerFired &&
+				events[0].ScheduleEventID == 2 &&
+				events[0].VisibleAt.Equal(timerAt)
+		}),
+		mock.MatchedBy(func(events []history.Event) bool {
+			return len(events) == 2 &&
+				events[0].Type == history.EventType_WorkflowExecutionReset &&
+				events[0].Attributes.(*history.ExecutionResetAttributes).Reason == "reason" &&
+				events[1].Type == history.EventType_SignalReceived
+		}),
+	).Return(nil)
+
+	c := &client{
+		backend: b,
+		clock:   clock.New(),
+	}
+
+	newInstance, err := c.ResetWorkflowInstance(ctx, instance, 5, "reason", WithReapplySignals())
+	require.NoError(t, err)
+	require.Equal(t, instance.InstanceID, newInstance.InstanceID)
+	require.NotEqual(t, instance.ExecutionID, newInstance.ExecutionID)
+	b.AssertExpectations(t)
+}
+
+func Test_Client_ResetWorkflowInstance_CancelsRunningSubWorkflows(t *testing.T) {
+	instance := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+	completedSubWorkflow := core.NewSubWorkflowInstance(uuid.NewString(), uuid.NewString(), instance.InstanceID, 1)
+	runningSubWorkflow := core.NewSubWorkflowInstance(uuid.NewString(), uuid.NewString(), instance.InstanceID, 2)
+
+	ctx := context.Background()
+
+	h := []history.Event{
+		history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowTaskStarted, &history.WorkflowTaskStartedAttributes{}),
+		history.NewHistoryEvent(2, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{}),
+		history.NewHistoryEvent(3, time.Now(), history.EventType_WorkflowTaskStarted, &history.WorkflowTaskStartedAttributes{}),
+		history.NewHistoryEvent(4, time.Now(), history.EventType_SubWorkflowScheduled, &history.SubWorkflowScheduledAttributes{
+			SubWorkflowInstance: completedSubWorkflow,
+		}, history.ScheduleEventID(1)),
+		history.NewHistoryEvent(5, time.Now(), history.EventType_SubWorkflowScheduled, &history.SubWorkflowScheduledAttributes{
+			SubWorkflowInstance: runningSubWorkflow,
+		}, history.ScheduleEventID(2)),
+		history.NewHistoryEvent(6, time.Now(), history.EventType_WorkflowTaskStarted, &history.WorkflowTaskStartedAttributes{}),
+		history.NewHistoryEvent(7, time.Now(), history.EventType_SubWorkflowCompleted, &history.SubWorkflowCompletedAttributes{
+			SubWorkflowInstance: completedSubWorkflow,
+		}, history.ScheduleEventID(1)),
+	}
+
+	b := &backend.MockBackend{}
+	b.On("Logger").Return(logger.NewDefaultLogger())
+	b.On("GetWorkflowInstanceHistory", ctx, instance, (*int64)(nil)).Return(h, nil)
+	b.On("ResetWorkflowInstance", ctx, instance, mock.AnythingOfType("string"), int64(3),
+		mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	b.On("CancelWorkflowInstance", ctx, runningSubWorkflow, mock.MatchedBy(func(event *history.Event) bool {
+		return event.Type == history.EventType_WorkflowExecutionCanceled
+	})).Return(nil)
+
+	c := &client{
+		backend: b,
+		clock:   clock.New(),
+	}
+
+	_, err := c.ResetWorkflowInstance(ctx, instance, 3, "reason")
+	require.NoError(t, err)
+	b.AssertExpectations(t)
+	b.AssertNumberOfCalls(t, "CancelWorkflowInstance", 1)
+}
+
+func Test_Client_ResetWorkflowInstance_OnlyToWorkflowTaskStarted(t *testing.T) {
+	instance := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+
+	ctx := context.Background()
+
+	b := &backend.MockBackend{}
+	b.On("GetWorkflowInstanceHistory", ctx, instance, (*int64)(nil)).Return([]history.Event{
+		history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowTaskStarted, &history.WorkflowTaskStartedAttributes{}),
+		history.NewHistoryEvent(2, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{}),
+	}, nil)
+
+	c := &client{
+		backend: b,
+		clock:   clock.New(),
+	}
+
+	_, err := c.ResetWorkflowInstance(ctx, instance, 2, "reason")
+	require.EqualError(t, err, "workflow instances can only be reset to a WorkflowTaskStarted event")
+	b.AssertExpectations(t)
+}
+
+type importingBackend struct {
+	*backend.MockBackend
+}
+
+func (b *importingBackend) ImportWorkflowInstance(
+	ctx context.Context, instance *core.WorkflowInstance, state core.WorkflowInstanceState,
+	h, activityEvents, timerEvents []history.Event) error {
+	return b.Called(ctx, instance, state, h, activityEvents, timerEvents).Error(0)
+}
+
+func Test_Client_ImportWorkflowInstance(t *testing.T) {
+	ctx := context.Background()
+
+	h := []history.Event{
+		history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowTaskStarted, &history.WorkflowTaskStartedAttributes{}),
+		history.NewHistoryEvent(2, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{Name: "wf"}),
+		history.NewHistoryEvent(3, time.Now(), history.EventType_ActivityScheduled, &history.ActivityScheduledAttributes{Name: "a"}, history.ScheduleEventID(1)),
+	}
+
+	b := &importingBackend{&backend.MockBackend{}}
+	b.On("Logger").Return(logger.NewDefaultLogger())
+	b.On("ImportWorkflowInstance", ctx, mock.MatchedBy(func(instance *core.WorkflowInstance) bool {
+		return instance.InstanceID == "imported" && instance.ExecutionID != ""
+	}), core.WorkflowInstanceStateActive, h,
+		mock.MatchedBy(func(events []history.Event) bool {
+			return len(events) == 1 &&
+				events[0].Type == history.EventType_ActivityScheduled &&
+				events[0].ScheduleEventID == 1 &&
+				events[0].ID != h[2].ID
+		}),
+		mock.MatchedBy(func(events []history.Event) bool {
+			return len(events) == 0
+		}),
+	).Return(nil)
+
+	c := &client{
+		backend: b,
+		clock:   clock.New(),
+	}
+
+	instance, err := c.ImportWorkflowInstance(ctx, "imported", h)
+	require.NoError(t, err)
+	require.Equal(t, "imported", instance.InstanceID)
+	b.AssertExpectations(t)
+}
+
+func Test_Client_ImportWorkflowInstance_NotSupported(t *testing.T) {
+	c := &client{
+		backend: &backend.MockBackend{},
+		clock:   clock.New(),
+	}
+
+	_, err := c.ImportWorkflowInstance(context.Background(), "imported", nil)
+	require.EqualError(t, err, "backend does not support importing workflow instances")
+}
+
+func Test_HistoryState(t *testing.T) {
+	started := history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{})
+	canceled := history.NewHistoryEvent(2, time.Now(), history.EventType_WorkflowExecutionCanceled, &history.ExecutionCanceledAttributes{})
+	terminated := history.NewHistoryEvent(2, time.Now(), history.EventType_WorkflowExecutionTerminated, &history.ExecutionTerminatedAttributes{})
+	completed := history.NewHistoryEvent(3, time.Now(), history.EventType_WorkflowExecutionFinished, &history.ExecutionCompletedAttributes{})
+	failed := history.NewHistoryEvent(3, time.Now(), history.EventType_WorkflowExecutionFinished, &history.ExecutionCompletedAttributes{Error: "error"})
+
+	require.Equal(t, core.WorkflowInstanceStateActive, historyState([]history.Event{started}))
+	require.Equal(t, core.WorkflowInstanceStateCompleted, historyState([]history.Event{started, completed}))
+	require.Equal(t, core.WorkflowInstanceStateFailed, historyState([]history.Event{started, failed}))
+	require.Equal(t, core.WorkflowInstanceStateCanceled, historyState([]history.Event{started, canceled, failed}))
+	require.Equal(t, core.WorkflowInstanceStateTerminated, historyState([]history.Event{started, terminated}))
+}
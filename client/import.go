@@ -0,0 +1,79 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/paveliak/go-workflows/backend"
+	"github.com/paveliak/go-workflows/internal/core"
+	"github.com/paveliak/go-workflows/internal/history"
+	"github.com/paveliak/go-workflows/workflow"
+	"github.com/google/uuid"
+)
+
+// ImportWorkflowInstance creates a workflow instance with the given id and the history h of another instance, for
+// example one exported from another backend. The instance gets a new execution id and the state its history ended in.
+//
+// Activities and timers that were pending at the end of the history are scheduled again. Sub-workflows are not
+// imported, the instance does not receive results of sub-workflows that were still running.
+func (c *client) ImportWorkflowInstance(ctx context.Context, instanceID string, h []history.Event) (*workflow.Instance, error) {
+	importer, ok := c.backend.(backend.Importer)
+	if !ok {
+		return nil, errors.New("backend does not support importing workflow instances")
+	}
+
+	if history.StartedAttributes(h) == nil {
+		return nil, errors.New("history does not contain a WorkflowExecutionStarted event")
+	}
+
+	state := historyState(h)
+
+	var activityEvents, timerEvents []history.Event
+	if !state.Finished() {
+		activityEvents, timerEvents, _ = resetWork(h, h[len(h)-1].SequenceID+1, c.clock.Now(), false)
+	}
+
+	instance := core.NewWorkflowInstance(instanceID, uuid.NewString())
+
+	if err := importer.ImportWorkflowInstance(ctx, instance, state, h, activityEvents, timerEvents); err != nil {
+		return nil, fmt.Errorf("importing workflow instance: %w", err)
+	}
+
+	c.backend.Logger().Debug("Imported workflow instance",
+		"instance_id", instance.InstanceID,
+		"execution_id", instance.ExecutionID,
+		"state", state,
+	)
+
+	return instance, nil
+}
+
+// historyState returns the state of a workflow instance with the history h
+func historyState(h []history.Event) core.WorkflowInstanceState {
+	canceled := false
+
+	for _, event := range h {
+		switch event.Type {
+		case history.EventType_WorkflowExecutionCanceled:
+			canceled = true
+
+		case history.EventType_WorkflowExecutionTerminated:
+			return core.WorkflowInstanceStateTerminated
+
+		case history.EventType_WorkflowExecutionFinished:
+			a := event.Attributes.(*history.ExecutionCompletedAttributes)
+			if a.Error == "" {
+				return core.WorkflowInstanceStateCompleted
+			}
+
+			if canceled {
+				return core.WorkflowInstanceStateCanceled
+			}
+
+			return core.WorkflowInstanceStateFailed
+		}
+	}
+
+	return core.WorkflowInstanceStateActive
+}
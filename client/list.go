@@ -0,0 +1,23 @@
+package client
+
+import (
+	"context"
+
+	"github.com/paveliak/go-workflows/backend"
+)
+
+// WorkflowInstanceFilter selects the workflow instances returned by ListWorkflowInstances
+type WorkflowInstanceFilter = backend.WorkflowInstanceFilter
+
+// WorkflowInstanceRef describes a workflow instance returned by ListWorkflowInstances
+type WorkflowInstanceRef = backend.WorkflowInstanceRef
+
+// ListWorkflowInstances returns workflow instances matching the given filter, most recently created first. Pass the
+// id of the last returned instance as filter.AfterInstanceID to get the next page.
+func (c *client) ListWorkflowInstances(ctx context.Context, filter *WorkflowInstanceFilter) ([]*WorkflowInstanceRef, error) {
+	if filter == nil {
+		filter = &WorkflowInstanceFilter{}
+	}
+
+	return c.backend.ListWorkflowInstances(ctx, filter)
+}
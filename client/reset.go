@@ -0,0 +1,221 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/paveliak/go-workflows/backend"
+	"github.com/paveliak/go-workflows/internal/history"
+	"github.com/paveliak/go-workflows/workflow"
+	"github.com/google/uuid"
+)
+
+type resetOptions struct {
+	reapplySignals bool
+}
+
+type ResetOption func(*resetOptions)
+
+// WithReapplySignals re-applies signals the workflow instance received after the point it is reset to.
+func WithReapplySignals() ResetOption {
+	return func(o *resetOptions) {
+		o.reapplySignals = true
+	}
+}
+
+// ResetWorkflowInstance rewinds the given workflow instance to the WorkflowTaskStarted event with the given sequence id
+// and starts a new execution from there. It returns the instance with the new execution id.
+//
+// Activities and timers that were pending at that point are scheduled again. Results of sub-workflows that completed
+// after that point are delivered again, sub-workflows started after that point that are still running are canceled.
+// Suspended instances stay suspended until they are resumed.
+func (c *client) ResetWorkflowInstance(ctx context.Context, instance *workflow.Instance, toSequenceID int64, reason string, opts ...ResetOption) (*workflow.Instance, error) {
+	o := &resetOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	h, err := c.backend.GetWorkflowInstanceHistory(ctx, instance, nil)
+	if err != nil {
+		return nil, fmt.Errorf("getting workflow history: %w", err)
+	}
+
+	var resetEvent *history.Event
+	for i, event := range h {
+		if event.SequenceID == toSequenceID {
+			resetEvent = &h[i]
+			break
+		}
+	}
+
+	if resetEvent == nil {
+		return nil, fmt.Errorf("could not find event with sequence id %v", toSequenceID)
+	}
+
+	if resetEvent.Type != history.EventType_WorkflowTaskStarted {
+		return nil, errors.New("workflow instances can only be reset to a WorkflowTaskStarted event")
+	}
+
+	activityEvents, timerEvents, pendingEvents := resetWork(h, toSequenceID, c.clock.Now(), o.reapplySignals)
+
+	// Add the reset event first, it's the first event the new execution sees
+	pendingEvents = append([]history.Event{
+		history.NewPendingEvent(
+			c.clock.Now(),
+			history.EventType_WorkflowExecutionReset,
+			&history.ExecutionResetAttributes{
+				Reason:              reason,
+				PreviousExecutionID: instance.ExecutionID,
+				ToSequenceID:        toSequenceID,
+			},
+		),
+	}, pendingEvents...)
+
+	newInstance := *instance
+	newInstance.ExecutionID = uuid.NewString()
+
+	if err := c.backend.ResetWorkflowInstance(
+		ctx, instance, newInstance.ExecutionID, toSequenceID, activityEvents, timerEvents, pendingEvents); err != nil {
+		return nil, fmt.Errorf("resetting workflow instance: %w", err)
+	}
+
+	c.backend.Logger().Debug("Reset workflow instance",
+		"instance_id", instance.InstanceID,
+		"execution_id", newInstance.ExecutionID,
+		"previous_execution_id", instance.ExecutionID,
+		"to_sequence_id", toSequenceID,
+	)
+
+	// The new execution doesn't know about sub-workflows started after the reset point, cancel them. Their results
+	// are discarded by the new execution.
+	for _, subWorkflowInstance := range runningSubWorkflows(h, toSequenceID) {
+		cancellationEvent := history.NewWorkflowCancellationEvent(c.clock.Now())
+		if err := c.backend.CancelWorkflowInstance(ctx, subWorkflowInstance, &cancellationEvent); err != nil && !errors.Is(err, backend.ErrInstanceNotFound) {
+			c.backend.Logger().Error("could not cancel sub-workflow instance",
+				"instance_id", instance.InstanceID,
+				"sub_workflow_instance_id", subWorkflowInstance.InstanceID,
+				"error", err,
+			)
+		}
+	}
+
+	return &newInstance, nil
+}
+
+// runningSubWorkflows returns the sub-workflow instances started at or after toSequenceID without a result in the
+// history h
+func runningSubWorkflows(h []history.Event, toSequenceID int64) []*workflow.Instance {
+	running := make(map[int64]*workflow.Instance)
+	order := make([]int64, 0)
+
+	for _, event := range h {
+		if event.SequenceID < toSequenceID {
+			continue
+		}
+
+		switch event.Type {
+		case history.EventType_SubWorkflowScheduled:
+			running[event.ScheduleEventID] = event.Attributes.(*history.SubWorkflowScheduledAttributes).SubWorkflowInstance
+			order = append(order, event.ScheduleEventID)
+
+		case history.EventType_SubWorkflowCompleted, history.EventType_SubWorkflowFailed:
+			delete(running, event.ScheduleEventID)
+		}
+	}
+
+	instances := make([]*workflow.Instance, 0)
+	for _, id := range order {
+		if instance, ok := running[id]; ok {
+			instances = append(instances, instance)
+		}
+	}
+
+	return instances
+}
+
+// resetWork determines the work that is outstanding when truncating the history h before toSequenceID
+func resetWork(h []history.Event, toSequenceID int64, now time.Time, reapplySignals bool) (activityEvents, timerEvents, pendingEvents []history.Event) {
+	activityEvents = make([]history.Event, 0)
+	timerEvents = make([]history.Event, 0)
+	pendingEvents = make([]history.Event, 0)
+
+	// Commands scheduled before the reset point without a result before the reset point, by schedule event id
+	outstanding := make(map[int64]history.Event)
+	order := make([]int64, 0)
+
+	for _, event := range h {
+		if event.SequenceID >= toSequenceID {
+			break
+		}
+
+		switch event.Type {
+		case history.EventType_ActivityScheduled,
+			history.EventType_TimerScheduled,
+			history.EventType_SubWorkflowScheduled:
+			outstanding[event.ScheduleEventID] = event
+			order = append(order, event.ScheduleEventID)
+
+		case history.EventType_ActivityCompleted,
+			history.EventType_ActivityFailed,
+			history.EventType_TimerFired,
+			history.EventType_TimerCanceled,
+			history.EventType_SubWorkflowCompleted,
+			history.EventType_SubWorkflowFailed:
+			delete(outstanding, event.ScheduleEventID)
+		}
+	}
+
+	for _, id := range order {
+		event, ok := outstanding[id]
+		if !ok {
+			continue
+		}
+
+		switch event.Type {
+		case history.EventType_ActivityScheduled:
+			// Use a new id, the activity might still be executing for the previous execution
+			activityEvents = append(activityEvents, history.NewPendingEvent(
+				now, event.Type, event.Attributes, history.ScheduleEventID(event.ScheduleEventID)))
+
+		case history.EventType_TimerScheduled:
+			a := event.Attributes.(*history.TimerScheduledAttributes)
+			timerEvents = append(timerEvents, history.NewPendingEvent(
+				now,
+				history.EventType_TimerFired,
+				&history.TimerFiredAttributes{
+					At: a.At,
+				},
+				history.ScheduleEventID(event.ScheduleEventID),
+				history.VisibleAt(a.At),
+			))
+		}
+	}
+
+	for _, event := range h {
+		if event.SequenceID < toSequenceID {
+			continue
+		}
+
+		switch event.Type {
+		case history.EventType_WorkflowExecutionStarted:
+			// History is truncated before the workflow was started, start it again
+			pendingEvents = append(pendingEvents, history.NewPendingEvent(now, event.Type, event.Attributes))
+
+		case history.EventType_SubWorkflowCompleted, history.EventType_SubWorkflowFailed:
+			// The sub-workflow won't complete again, deliver its result to the new execution
+			if o, ok := outstanding[event.ScheduleEventID]; ok && o.Type == history.EventType_SubWorkflowScheduled {
+				pendingEvents = append(pendingEvents, history.NewPendingEvent(
+					now, event.Type, event.Attributes, history.ScheduleEventID(event.ScheduleEventID)))
+			}
+
+		case history.EventType_SignalReceived:
+			if reapplySignals {
+				pendingEvents = append(pendingEvents, history.NewPendingEvent(now, event.Type, event.Attributes))
+			}
+		}
+	}
+
+	return activityEvents, timerEvents, pendingEvents
+}
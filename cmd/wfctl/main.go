@@ -0,0 +1,13 @@
+// Command wfctl operates workflow instances in a sqlite, MySQL, or Redis backend. Run wfctl -h for a list of
+// commands. To replay histories, build a binary with your workflows registered, see package wfctl.
+package main
+
+import (
+	"os"
+
+	"github.com/paveliak/go-workflows/wfctl"
+)
+
+func main() {
+	os.Exit(wfctl.Main(os.Args[1:]))
+}
@@ -0,0 +1,205 @@
+package diag
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/paveliak/go-workflows/backend"
+	"github.com/paveliak/go-workflows/client"
+	"github.com/paveliak/go-workflows/internal/core"
+)
+
+// Action is an operation on workflow instances that can be performed through the diagnostics API
+type Action string
+
+const (
+	// ActionView allows reading workflow instances and their history
+	ActionView Action = "view"
+
+	// ActionCancel allows canceling a workflow instance
+	ActionCancel Action = "cancel"
+
+	// ActionTerminate allows terminating a workflow instance without running any more workflow code
+	ActionTerminate Action = "terminate"
+
+	// ActionSignal allows sending a signal to a workflow instance
+	ActionSignal Action = "signal"
+
+	// ActionRetry allows resuming a suspended workflow instance, for example after its workflow task exceeded the
+	// maximum number of attempts
+	ActionRetry Action = "retry"
+
+	// ActionDelete allows removing a finished workflow instance from the backend
+	ActionDelete Action = "delete"
+)
+
+// instanceActions are the actions that modify a workflow instance
+var instanceActions = []Action{ActionCancel, ActionTerminate, ActionSignal, ActionRetry, ActionDelete}
+
+// Authorizer decides whether a request to the diagnostics API may perform an action. instanceID is empty when the
+// request is not for a single workflow instance, for example when listing instances. A non-nil error rejects the
+// request with http.StatusForbidden.
+type Authorizer interface {
+	Authorize(r *http.Request, action Action, instanceID string) error
+}
+
+// AuthorizerFunc adapts a function to an Authorizer
+type AuthorizerFunc func(r *http.Request, action Action, instanceID string) error
+
+func (f AuthorizerFunc) Authorize(r *http.Request, action Action, instanceID string) error {
+	return f(r, action, instanceID)
+}
+
+// maxActionBodySize limits the size of action request bodies
+const maxActionBodySize = 1 << 20
+
+// applicable returns whether the action can be performed on an instance in the given state
+func (a Action) applicable(instance *WorkflowInstanceRef) bool {
+	if instance.Archived {
+		return false
+	}
+
+	switch a {
+	case ActionCancel:
+		return instance.State == core.WorkflowInstanceStateActive
+	case ActionTerminate, ActionSignal:
+		return !instance.State.Finished()
+	case ActionRetry:
+		return instance.State == core.WorkflowInstanceStateSuspended
+	case ActionDelete:
+		return instance.State.Finished()
+	}
+
+	return false
+}
+
+// allowedActions returns the actions the request may perform on the given instance
+func allowedActions(r *http.Request, o *options, instance *WorkflowInstanceRef) []Action {
+	if o.authorizer == nil {
+		return nil
+	}
+
+	actions := make([]Action, 0)
+	for _, action := range instanceActions {
+		if action.applicable(instance) && o.authorizer.Authorize(r, action, instance.Instance.InstanceID) == nil {
+			actions = append(actions, action)
+		}
+	}
+
+	return actions
+}
+
+func handleAction(w http.ResponseWriter, r *http.Request, o *options, c client.Client, b Backend, instanceID string, action Action) {
+	known := false
+	for _, a := range instanceActions {
+		known = known || a == action
+	}
+
+	if !known {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	// Actions are disabled unless an authorizer is configured
+	if o.authorizer == nil {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	// Only accept JSON requests. Browsers don't send these cross-origin without a preflight request, which protects
+	// against cross-site request forgery.
+	if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mediaType != "application/json" {
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		return
+	}
+
+	if err := o.authorizer.Authorize(r, action, instanceID); err != nil {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	ctx := r.Context()
+
+	instance, err := b.GetWorkflowInstance(ctx, instanceID)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if instance == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if !action.applicable(instance) {
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
+
+	body := http.MaxBytesReader(w, r.Body, maxActionBodySize)
+
+	switch action {
+	case ActionCancel:
+		err = c.CancelWorkflowInstance(ctx, instance.Instance)
+
+	case ActionTerminate:
+		var req TerminateRequest
+		if err := decodeActionRequest(body, &req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		err = c.TerminateWorkflowInstance(ctx, instance.Instance, req.Reason)
+
+	case ActionSignal:
+		var req SignalRequest
+		if err := decodeActionRequest(body, &req); err != nil || req.Name == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		var arg interface{}
+		if len(req.Arg) > 0 {
+			arg = req.Arg
+		}
+
+		err = c.SignalWorkflow(ctx, instanceID, req.Name, arg)
+
+	case ActionRetry:
+		err = c.ResumeWorkflowInstance(ctx, instance.Instance)
+
+	case ActionDelete:
+		err = b.RemoveWorkflowInstance(ctx, instance.Instance)
+	}
+
+	if err != nil {
+		switch {
+		case errors.Is(err, backend.ErrInstanceNotFound):
+			w.WriteHeader(http.StatusNotFound)
+		case errors.Is(err, backend.ErrInstanceFinished),
+			errors.Is(err, backend.ErrInstanceNotFinished),
+			errors.Is(err, backend.ErrInstanceNotActive),
+			errors.Is(err, backend.ErrInstanceNotSuspended):
+			// The instance changed state since it was read
+			w.WriteHeader(http.StatusConflict)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// decodeActionRequest decodes the JSON body of an action request into v, an empty body leaves v unchanged
+func decodeActionRequest(body io.Reader, v interface{}) error {
+	if err := json.NewDecoder(body).Decode(v); err != nil && err != io.EOF {
+		return err
+	}
+
+	return nil
+}
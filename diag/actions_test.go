@@ -0,0 +1,73 @@
+package diag_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/paveliak/go-workflows/backend/sqlite"
+	"github.com/paveliak/go-workflows/client"
+	"github.com/paveliak/go-workflows/diag"
+	"github.com/paveliak/go-workflows/workflow"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func wf(ctx workflow.Context) error {
+	return nil
+}
+
+func Test_Actions(t *testing.T) {
+	ctx := context.Background()
+
+	b := sqlite.NewInMemoryBackend()
+	c := client.New(b)
+
+	instance, err := c.CreateWorkflowInstance(ctx, client.WorkflowInstanceOptions{
+		InstanceID: uuid.NewString(),
+	}, wf)
+	require.NoError(t, err)
+
+	post := func(h http.Handler, action, contentType, body string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/"+instance.InstanceID+"/"+action, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		return rec.Code
+	}
+
+	// Actions are disabled without an authorizer
+	require.Equal(t, http.StatusForbidden, post(diag.NewServeMux(b), "terminate", "application/json", "{}"))
+
+	mux := diag.NewServeMux(b, diag.WithAuthorizer(diag.AuthorizerFunc(func(r *http.Request, action diag.Action, instanceID string) error {
+		if action == diag.ActionDelete {
+			return errors.New("not allowed")
+		}
+
+		return nil
+	})))
+
+	require.Equal(t, http.StatusNotFound, post(mux, "unknown", "application/json", "{}"))
+	require.Equal(t, http.StatusUnsupportedMediaType, post(mux, "cancel", "text/plain", ""))
+	require.Equal(t, http.StatusBadRequest, post(mux, "signal", "application/json", `{"arg": 42}`))
+
+	// Only applicable and authorized actions are offered
+	req := httptest.NewRequest(http.MethodGet, "/api/"+instance.InstanceID, nil)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var info diag.WorkflowInstanceInfo
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&info))
+	require.Equal(t, []diag.Action{diag.ActionCancel, diag.ActionTerminate, diag.ActionSignal}, info.Actions)
+
+	require.Equal(t, http.StatusConflict, post(mux, "retry", "application/json", "{}"))
+	require.Equal(t, http.StatusNoContent, post(mux, "signal", "application/json", `{"name": "signal", "arg": {"a": 1}}`))
+	require.Equal(t, http.StatusNoContent, post(mux, "terminate", "application/json", `{"reason": "stuck"}`))
+}
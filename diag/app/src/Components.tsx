@@ -1,30 +1,47 @@
 import React from "react";
 import { Badge } from "react-bootstrap";
 import { Color } from "react-bootstrap/esm/types";
+import { Link } from "react-router-dom";
+import {
+  DecodedPayload,
+  WorkflowInstanceState,
+  WorkflowInstanceTree,
+} from "./client";
+
+function payloadValue(payload: DecodedPayload): any {
+  if (payload.error !== undefined) {
+    return { raw: payload.raw, error: payload.error };
+  }
+
+  return payload.value ?? null;
+}
 
-export function decodePayload(payload: string): string {
-  try {
-    return atob(payload);
-  } catch {
-    return payload;
+// formatPayload formats a decoded payload for display, payloads that could not be decoded are shown as raw bytes
+export function formatPayload(payload: DecodedPayload): string {
+  if (payload.error !== undefined) {
+    return `${payload.raw ?? ""}\n\n(raw bytes, could not decode: ${payload.error})`;
   }
+
+  return JSON.stringify(payload.value ?? null, undefined, 2);
 }
 
-export function decodePayloads(payload: { [key: string]: any }): any {
+// decodePayloads replaces the decoded payloads in the attributes of a history event with their values
+export function decodePayloads(attributes: { [key: string]: any }): any {
   const r: any = {};
 
-  for (const key of Object.keys(payload)) {
+  for (const key of Object.keys(attributes)) {
     switch (key) {
       case "inputs":
-        r[key] = payload[key].map((p: any) => decodePayload(p));
+        r[key] = attributes[key].map((p: DecodedPayload) => payloadValue(p));
         break;
 
       case "result":
-        r[key] = decodePayload(payload[key]);
+      case "arg":
+        r[key] = payloadValue(attributes[key]);
         break;
 
       default:
-        r[key] = payload[key];
+        r[key] = attributes[key];
     }
   }
 
@@ -84,6 +101,67 @@ export const ScheduleEventID: React.FC<{ id: number }> = ({ id }) => {
   );
 };
 
+export const InstanceState: React.FC<{ state: WorkflowInstanceState }> = ({
+  state,
+}) => {
+  return <Badge bg={stateColor(state)}>{WorkflowInstanceState[state]}</Badge>;
+};
+
+export const InstanceTree: React.FC<{
+  tree: WorkflowInstanceTree;
+  currentInstanceId?: string;
+}> = ({ tree, currentInstanceId }) => {
+  const instanceId = tree.instance.instance_id;
+
+  return (
+    <li>
+      {instanceId === currentInstanceId ? (
+        <strong>
+          <code>{tree.workflow_name || instanceId}</code>
+        </strong>
+      ) : (
+        <Link to={`/${instanceId}`}>
+          <code>{tree.workflow_name || instanceId}</code>
+        </Link>
+      )}{" "}
+      <InstanceState state={tree.state} />
+      {tree.children && tree.children.length > 0 && (
+        <ul>
+          {tree.children.map((child) => (
+            <InstanceTree
+              key={child.instance.instance_id}
+              tree={child}
+              currentInstanceId={currentInstanceId}
+            />
+          ))}
+        </ul>
+      )}
+    </li>
+  );
+};
+
+function stateColor(state: WorkflowInstanceState): string {
+  switch (state) {
+    case WorkflowInstanceState.Active:
+      return "info";
+
+    case WorkflowInstanceState.Completed:
+    case WorkflowInstanceState.Finished:
+      return "success";
+
+    case WorkflowInstanceState.Failed:
+    case WorkflowInstanceState.Suspended:
+      return "danger";
+
+    case WorkflowInstanceState.Canceled:
+    case WorkflowInstanceState.Terminated:
+      return "warning";
+
+    default:
+      return "secondary";
+  }
+}
+
 function eventColor(event: string): [Color, string] {
   switch (event) {
     case "SubWorkflowScheduled":
@@ -106,11 +184,16 @@ function eventColor(event: string): [Color, string] {
       return ["light", "dark"];
 
     case "SideEffectResult":
+    case "SearchAttributesUpserted":
       return ["dark", "secondary"];
 
     case "WorkflowTaskStarted":
       return ["dark", "light"];
 
+    case "WorkflowExecutionReset":
+    case "WorkflowExecutionTerminated":
+      return ["light", "danger"];
+
     default:
       return ["dark", "info"];
   }
@@ -1,10 +1,21 @@
-import { Pagination, Table } from "react-bootstrap";
-import { Link, useLocation } from "react-router-dom";
+import { Button, Col, Form, Nav, Pagination, Row, Table } from "react-bootstrap";
+import { Link, useLocation, useNavigate } from "react-router-dom";
 
 import React from "react";
 import useFetch from "react-fetch-hook";
 import { LinkContainer } from "react-router-bootstrap";
-import { WorkflowInstanceRef } from "./client";
+import { WorkflowInstanceRef, WorkflowInstanceState } from "./client";
+import { InstanceState } from "./Components";
+import Stats from "./Stats";
+
+const stateFilters = [
+  WorkflowInstanceState.Active,
+  WorkflowInstanceState.Suspended,
+  WorkflowInstanceState.Completed,
+  WorkflowInstanceState.Failed,
+  WorkflowInstanceState.Canceled,
+  WorkflowInstanceState.Terminated,
+];
 
 function useQuery() {
   const { search } = useLocation();
@@ -12,25 +23,171 @@ function useQuery() {
   return React.useMemo(() => new URLSearchParams(search), [search]);
 }
 
+// Query parameters of the instances API that filter the list, kept when paging
+const filterParams = [
+  "state",
+  "name",
+  "prefix",
+  "created_after",
+  "created_before",
+  "completed_after",
+  "completed_before",
+];
+
+const timeFilters: [string, string][] = [
+  ["created_after", "Created after"],
+  ["created_before", "Created before"],
+  ["completed_after", "Completed after"],
+  ["completed_before", "Completed before"],
+];
+
+// Convert between RFC 3339 timestamps used by the API and the local time of datetime-local inputs
+function toLocalInput(value: string | null): string {
+  if (!value) {
+    return "";
+  }
+
+  const d = new Date(value);
+  return new Date(d.getTime() - d.getTimezoneOffset() * 60000)
+    .toISOString()
+    .slice(0, 16);
+}
+
+function fromLocalInput(value: string): string {
+  return value ? new Date(value).toISOString() : "";
+}
+
+function Filters({ query }: { query: URLSearchParams }) {
+  const navigate = useNavigate();
+
+  const [name, setName] = React.useState(query.get("name") || "");
+  const [prefix, setPrefix] = React.useState(query.get("prefix") || "");
+  const [times, setTimes] = React.useState<{ [param: string]: string }>(() =>
+    Object.fromEntries(
+      timeFilters.map(([param]) => [param, toLocalInput(query.get(param))])
+    )
+  );
+
+  const submit = (e: React.FormEvent) => {
+    e.preventDefault();
+
+    const params = new URLSearchParams();
+    const state = query.get("state");
+    if (state) {
+      params.set("state", state);
+    }
+    if (name) {
+      params.set("name", name);
+    }
+    if (prefix) {
+      params.set("prefix", prefix);
+    }
+    for (const [param] of timeFilters) {
+      if (times[param]) {
+        params.set(param, fromLocalInput(times[param]));
+      }
+    }
+
+    navigate(`/?${params}`);
+  };
+
+  return (
+    <Form onSubmit={submit} className="mb-3">
+      <Row className="g-2 mb-2">
+        <Col md>
+          <Form.Control
+            placeholder="Workflow name"
+            value={name}
+            onChange={(e) => setName(e.target.value)}
+          />
+        </Col>
+        <Col md>
+          <Form.Control
+            placeholder="Instance ID prefix"
+            value={prefix}
+            onChange={(e) => setPrefix(e.target.value)}
+          />
+        </Col>
+      </Row>
+      <Row className="g-2 align-items-end">
+        {timeFilters.map(([param, label]) => (
+          <Col md key={param}>
+            <Form.Label className="small mb-0">{label}</Form.Label>
+            <Form.Control
+              type="datetime-local"
+              size="sm"
+              value={times[param]}
+              onChange={(e) => setTimes({ ...times, [param]: e.target.value })}
+            />
+          </Col>
+        ))}
+        <Col md="auto">
+          <Button type="submit" size="sm">
+            Search
+          </Button>
+        </Col>
+      </Row>
+    </Form>
+  );
+}
+
 function Home() {
   const count = 20;
 
   const query = useQuery();
   const afterId = query.get("after");
   const page = +(query.get("page") || 1);
+  const state = query.get("state");
+
+  // Filters other than the state, kept when switching between states
+  const otherFilters = new URLSearchParams();
+  for (const param of filterParams) {
+    const value = query.get(param);
+    if (value && param !== "state") {
+      otherFilters.set(param, value);
+    }
+  }
+  const otherQuery = otherFilters.toString()
+    ? `&${otherFilters.toString()}`
+    : "";
+  const filterQuery = (state ? `&state=${state}` : "") + otherQuery;
 
   const { isLoading, data, error } = useFetch<WorkflowInstanceRef[]>(
     document.location.pathname +
       `api/?count=${count}` +
-      (afterId ? `&after=${afterId}` : "")
+      (afterId ? `&after=${afterId}` : "") +
+      filterQuery
   );
 
   return (
     <div className="App">
+      <Stats />
+
       <header className="App-header">
         <h2>Instances</h2>
       </header>
 
+      <Filters key={query.toString()} query={query} />
+
+      <Nav variant="pills" className="mb-3" activeKey={state || ""}>
+        <Nav.Item>
+          <LinkContainer to={`/?${otherQuery}`}>
+            <Nav.Link eventKey="">All</Nav.Link>
+          </LinkContainer>
+        </Nav.Item>
+        {stateFilters.map((s) => (
+          <Nav.Item key={s}>
+            <LinkContainer
+              to={`/?state=${WorkflowInstanceState[s]}${otherQuery}`}
+            >
+              <Nav.Link eventKey={WorkflowInstanceState[s]}>
+                {WorkflowInstanceState[s]}
+              </Nav.Link>
+            </LinkContainer>
+          </Nav.Item>
+        ))}
+      </Nav>
+
       {isLoading && <div>Loading...</div>}
 
       {!isLoading && (
@@ -39,8 +196,10 @@ function Home() {
             <thead>
               <tr>
                 <th>Instance ID</th>
+                <th>Workflow</th>
                 <th>Execution ID</th>
                 <th>Parent Instance ID</th>
+                <th>State</th>
                 <th>Created At</th>
               </tr>
             </thead>
@@ -52,13 +211,21 @@ function Home() {
                       <code>{i.instance.instance_id}</code>
                     </Link>
                   </td>
+                  <td>
+                    <code>{i.workflow_name}</code>
+                  </td>
                   <td>
                     <code>{i.instance.execution_id}</code>
                   </td>
                   <td>
-                    <Link to={`/${i.instance.parent_instance}`}>
-                      <code>{i.instance.parent_instance}</code>
-                    </Link>
+                    {i.instance.parent_instance && (
+                      <Link to={`/${i.instance.parent_instance}`}>
+                        <code>{i.instance.parent_instance}</code>
+                      </Link>
+                    )}
+                  </td>
+                  <td>
+                    <InstanceState state={i.state} />
                   </td>
                   <td>
                     <code>{i.created_at}</code>
@@ -70,14 +237,17 @@ function Home() {
 
           <div className="d-flex justify-content-center">
             <Pagination>
-              <LinkContainer to="/?">
+              <LinkContainer to={`/?${filterQuery}`}>
                 <Pagination.First disabled={!afterId} />
               </LinkContainer>
               <Pagination.Item active>{page}</Pagination.Item>
               <LinkContainer
                 to={`/?after=${
-                  (data && data[data.length - 1].instance.instance_id) || ""
-                }&page=${page + 1}`}
+                  (data &&
+                    data.length > 0 &&
+                    data[data.length - 1].instance.instance_id) ||
+                  ""
+                }&page=${page + 1}${filterQuery}`}
               >
                 <Pagination.Next disabled={!data || data.length < count} />
               </LinkContainer>
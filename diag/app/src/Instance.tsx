@@ -1,34 +1,123 @@
 import { Accordion, Alert, Badge, Card } from "react-bootstrap";
-import { Link, useParams } from "react-router-dom";
+import { Link, useNavigate, useParams } from "react-router-dom";
 import {
+  DecodedPayload,
   ExecutionCompletedAttributes,
+  ExecutionResetAttributes,
   ExecutionStartedAttributes,
+  ExecutionTerminatedAttributes,
   HistoryEvent,
+  isFinished,
+  StackTrace,
+  Timeline as TimelineData,
   WorkflowInstanceInfo,
+  WorkflowInstanceRef,
+  WorkflowInstanceState,
+  WorkflowInstanceTree,
 } from "./client";
 import {
-  decodePayload,
   decodePayloads,
   EventType,
+  formatPayload,
+  InstanceState,
+  InstanceTree,
   Payload,
   ScheduleEventID,
 } from "./Components";
+import InstanceActions from "./InstanceActions";
+import Timeline from "./Timeline";
 
+import React, { useEffect, useState } from "react";
 import useFetch from "react-fetch-hook";
 
+// useInstanceUpdates follows changes to a running instance through server-sent events
+function useInstanceUpdates(
+  instance: WorkflowInstanceInfo | undefined
+): WorkflowInstanceInfo | undefined {
+  const [updated, setUpdated] = useState(instance);
+
+  useEffect(() => {
+    setUpdated(instance);
+
+    if (!instance || instance.archived || isFinished(instance.state)) {
+      return;
+    }
+
+    const lastSequenceId = instance.history.length
+      ? instance.history[instance.history.length - 1].sequence_id
+      : 0;
+
+    const source = new EventSource(
+      document.location.pathname +
+        "api/" +
+        encodeURIComponent(instance.instance.instance_id) +
+        "/events?after=" +
+        lastSequenceId
+    );
+
+    source.addEventListener("instance", (e) => {
+      const ref = JSON.parse((e as MessageEvent).data) as WorkflowInstanceRef;
+      if (ref.instance.execution_id !== instance.instance.execution_id) {
+        // The instance has been reset, start over with the new execution
+        source.close();
+        window.location.reload();
+        return;
+      }
+
+      setUpdated((current) => current && { ...current, ...ref });
+    });
+
+    source.addEventListener("history", (e) => {
+      const events = JSON.parse((e as MessageEvent).data) as HistoryEvent<any>[];
+      setUpdated(
+        (current) =>
+          current && { ...current, history: [...current.history, ...events] }
+      );
+    });
+
+    // Prevent the browser from reconnecting once the stream has ended
+    source.addEventListener("finished", () => source.close());
+
+    return () => source.close();
+  }, [instance]);
+
+  return updated ?? instance;
+}
+
 function Instance() {
   let params = useParams();
+  const navigate = useNavigate();
 
   const instanceId = params.instanceId;
 
   const {
     isLoading,
-    data: instance,
+    data: fetchedInstance,
     error,
   } = useFetch<WorkflowInstanceInfo>(
     document.location.pathname + "api/" + instanceId
   );
 
+  const instance = useInstanceUpdates(fetchedInstance);
+
+  // Fetch the timeline again whenever new history events are received
+  const { data: timeline } = useFetch<TimelineData>(
+    document.location.pathname +
+      "api/" +
+      instanceId +
+      "/timeline?events=" +
+      (instance?.history.length ?? 0)
+  );
+
+  const { data: tree } = useFetch<WorkflowInstanceTree>(
+    document.location.pathname + "api/" + instanceId + "/tree"
+  );
+
+  // Only available when the diagnostics app is served by a worker process
+  const { data: stackTrace } = useFetch<StackTrace>(
+    document.location.pathname + "api/" + instanceId + "/stacktrace"
+  );
+
   if (isLoading) {
     return <div>Loading...</div>;
   }
@@ -45,12 +134,13 @@ function Instance() {
 
   const startedEvent = instance.history.find(
     (e) => e.type === "WorkflowExecutionStarted"
-  ) as HistoryEvent<ExecutionStartedAttributes>;
+  ) as HistoryEvent<ExecutionStartedAttributes> | undefined;
 
-  const workflowName = startedEvent.attributes.name;
-  const inputs = startedEvent.attributes.inputs;
+  // Instances blocked on their first workflow task do not have any history yet
+  const workflowName = instance.workflow_name || startedEvent?.attributes.name;
+  const inputs = startedEvent?.attributes.inputs ?? [];
 
-  let wfResult: string | undefined;
+  let wfResult: DecodedPayload | undefined;
   let wfError: string | undefined;
   const finishedEvent = instance.history.find(
     (e) => e.type === "WorkflowExecutionFinished"
@@ -60,14 +150,78 @@ function Instance() {
     wfError = finishedEvent.attributes.error;
   }
 
+  // Only the most recent reset is relevant for the current execution
+  const resetEvent = [...instance.history]
+    .reverse()
+    .find((e) => e.type === "WorkflowExecutionReset") as
+    | HistoryEvent<ExecutionResetAttributes>
+    | undefined;
+
+  const terminatedEvent = instance.history.find(
+    (e) => e.type === "WorkflowExecutionTerminated"
+  ) as HistoryEvent<ExecutionTerminatedAttributes> | undefined;
+
   return (
     <div>
       <div className="d-flex align-items-center">
         <h2>
           Workflow: <code>{workflowName}</code>
         </h2>
+        {instance.archived && (
+          <Badge bg="secondary" className="ms-2">
+            Archived
+          </Badge>
+        )}
       </div>
 
+      <InstanceActions
+        instanceId={instance.instance.instance_id}
+        actions={instance.actions ?? []}
+        onDone={(action) =>
+          action === "delete" ? navigate("/") : window.location.reload()
+        }
+      />
+
+      {terminatedEvent && (
+        <Alert variant="danger">
+          <Alert.Heading>Workflow instance terminated</Alert.Heading>
+          <p className="mb-0">
+            {terminatedEvent.attributes.reason || <i>no reason given</i>}
+          </p>
+        </Alert>
+      )}
+
+      {instance.state === WorkflowInstanceState.Suspended && !instance.last_error && (
+        <Alert variant="info">
+          <Alert.Heading>Workflow instance suspended</Alert.Heading>
+          <p className="mb-0">
+            New events are kept but not processed until the instance is
+            resumed.
+          </p>
+        </Alert>
+      )}
+
+      {instance.last_error &&
+        (instance.state === WorkflowInstanceState.Suspended ? (
+          <Alert variant="danger">
+            <Alert.Heading>Workflow instance suspended</Alert.Heading>
+            <p>
+              The workflow task kept failing and is not retried until the
+              instance is resumed.
+            </p>
+            <pre className="mb-0">{instance.last_error}</pre>
+          </Alert>
+        ) : (
+          <Alert variant="warning">
+            <Alert.Heading>Workflow task abandoned</Alert.Heading>
+            <p>
+              The last workflow task could not be completed and will be
+              retried.
+            </p>
+            <pre className="mb-0">{instance.last_error}</pre>
+          </Alert>
+        ))}
+
       <dl className="row">
         <dt className="col-sm-4">InstanceID</dt>
         <dd className="col-sm-8">
@@ -79,6 +233,19 @@ function Instance() {
           <code>{instance.instance.execution_id}</code>
         </dd>
 
+        {resetEvent && (
+          <>
+            <dt className="col-sm-4">Reset from</dt>
+            <dd className="col-sm-8">
+              <code>{resetEvent.attributes.previous_execution_id}</code> at #
+              {resetEvent.attributes.to_sequence_id}
+              {resetEvent.attributes.reason && (
+                <>: {resetEvent.attributes.reason}</>
+              )}
+            </dd>
+          </>
+        )}
+
         {!!instance.instance.parent_instance && (
           <>
             <dt className="col-sm-4">Parent InstanceID</dt>
@@ -92,11 +259,7 @@ function Instance() {
 
         <dt className="col-sm-4">State</dt>
         <dd className="col-sm-8">
-          {instance.state === 0 ? (
-            <Badge bg="info">Active</Badge>
-          ) : (
-            <Badge bg="success">Completed</Badge>
-          )}
+          <InstanceState state={instance.state} />
         </dd>
 
         <dt className="col-sm-4">Created at</dt>
@@ -106,23 +269,67 @@ function Instance() {
         <dd className="col-sm-8">
           {!instance.completed_at ? <i>pending</i> : instance.completed_at}
         </dd>
+
+        {instance.memo &&
+          Object.keys(instance.memo)
+            .sort()
+            .map((key) => (
+              <React.Fragment key={key}>
+                <dt className="col-sm-4">
+                  Memo <code>{key}</code>
+                </dt>
+                <dd className="col-sm-8">{instance.memo![key]}</dd>
+              </React.Fragment>
+            ))}
       </dl>
 
       <Card>
         <Card.Header as="h5">Input</Card.Header>
         <Card.Body>
-          <Payload payloads={inputs.map((i) => decodePayload(i))} />
+          <Payload payloads={inputs.map((i) => formatPayload(i))} />
         </Card.Body>
       </Card>
 
       <Card className="mt-3">
         <Card.Header as="h5">Result</Card.Header>
         <Card.Body>
-          {wfResult && <Payload payloads={[decodePayload(wfResult)]} />}
+          {wfResult && <Payload payloads={[formatPayload(wfResult)]} />}
           {wfError && <Payload payloads={[wfError]} />}
         </Card.Body>
       </Card>
 
+      {tree && (tree.instance.parent_instance || !!tree.children?.length) && (
+        <Card className="mt-3">
+          <Card.Header as="h5">Sub-workflows</Card.Header>
+          <Card.Body>
+            <ul className="mb-0">
+              <InstanceTree
+                tree={tree}
+                currentInstanceId={instance.instance.instance_id}
+              />
+            </ul>
+          </Card.Body>
+        </Card>
+      )}
+
+      {instance.state === WorkflowInstanceState.Active && stackTrace && (
+        <Card className="mt-3">
+          <Card.Header as="h5">Stack trace</Card.Header>
+          <Card.Body>
+            <pre>{stackTrace.stack_trace || <i>no running coroutines</i>}</pre>
+          </Card.Body>
+        </Card>
+      )}
+
+      {timeline && (
+        <Card className="mt-3">
+          <Card.Header as="h5">Timeline</Card.Header>
+          <Card.Body>
+            <Timeline timeline={timeline} />
+          </Card.Body>
+        </Card>
+      )}
+
       <h2 className="mt-3">History</h2>
       <Accordion alwaysOpen>
         {instance.history.map((event, idx) => (
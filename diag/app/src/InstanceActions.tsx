@@ -0,0 +1,189 @@
+import { Alert, Button, ButtonGroup, Form, Modal } from "react-bootstrap";
+import { Action, performAction } from "./client";
+
+import React, { useState } from "react";
+
+const actionLabels: { [action in Action]: string } = {
+  cancel: "Cancel",
+  terminate: "Terminate",
+  signal: "Signal",
+  retry: "Retry",
+  delete: "Delete",
+};
+
+const actionDescriptions: { [action in Action]: string } = {
+  cancel:
+    "The workflow is canceled and can run cleanup code before it finishes.",
+  terminate:
+    "The workflow is stopped immediately without running any more workflow code. Activities and timers it started are abandoned.",
+  signal: "The signal is delivered to the workflow instance.",
+  retry:
+    "The instance is resumed and its pending workflow task is retried with a fresh attempt count.",
+  delete:
+    "The instance and its history are removed from the backend. This cannot be undone.",
+};
+
+function InstanceActions({
+  instanceId,
+  actions,
+  onDone,
+}: {
+  instanceId: string;
+  actions: Action[];
+  onDone: (action: Action) => void;
+}) {
+  const [action, setAction] = useState<Action | undefined>();
+  const [reason, setReason] = useState("");
+  const [signalName, setSignalName] = useState("");
+  const [signalArg, setSignalArg] = useState("");
+  const [error, setError] = useState<string | undefined>();
+  const [pending, setPending] = useState(false);
+
+  const open = (a: Action) => {
+    setAction(a);
+    setReason("");
+    setSignalName("");
+    setSignalArg("");
+    setError(undefined);
+  };
+
+  const close = () => {
+    if (!pending) {
+      setAction(undefined);
+    }
+  };
+
+  const confirm = async () => {
+    if (!action) {
+      return;
+    }
+
+    let body;
+    switch (action) {
+      case "terminate":
+        body = { reason };
+        break;
+
+      case "signal": {
+        let arg;
+        if (signalArg.trim() !== "") {
+          try {
+            arg = JSON.parse(signalArg);
+          } catch (e) {
+            setError("Signal argument is not valid JSON");
+            return;
+          }
+        }
+
+        body = { name: signalName, arg };
+        break;
+      }
+    }
+
+    setPending(true);
+    try {
+      await performAction(instanceId, action, body);
+      setAction(undefined);
+      onDone(action);
+    } catch (e) {
+      setError((e as Error).message);
+    } finally {
+      setPending(false);
+    }
+  };
+
+  if (actions.length === 0) {
+    return null;
+  }
+
+  const destructive =
+    action === "terminate" || action === "delete" || action === "cancel";
+
+  return (
+    <>
+      <ButtonGroup className="mb-3">
+        {actions.map((a) => (
+          <Button
+            key={a}
+            variant={
+              a === "terminate" || a === "delete"
+                ? "outline-danger"
+                : "outline-primary"
+            }
+            onClick={() => open(a)}
+          >
+            {actionLabels[a]}
+          </Button>
+        ))}
+      </ButtonGroup>
+
+      <Modal show={!!action} onHide={close}>
+        {action && (
+          <>
+            <Modal.Header closeButton>
+              <Modal.Title>{actionLabels[action]} workflow instance</Modal.Title>
+            </Modal.Header>
+            <Modal.Body>
+              <p>
+                {actionLabels[action]} <code>{instanceId}</code>?{" "}
+                {actionDescriptions[action]}
+              </p>
+
+              {action === "terminate" && (
+                <Form.Group>
+                  <Form.Label>Reason</Form.Label>
+                  <Form.Control
+                    value={reason}
+                    onChange={(e) => setReason(e.target.value)}
+                  />
+                </Form.Group>
+              )}
+
+              {action === "signal" && (
+                <>
+                  <Form.Group className="mb-3">
+                    <Form.Label>Signal name</Form.Label>
+                    <Form.Control
+                      value={signalName}
+                      onChange={(e) => setSignalName(e.target.value)}
+                    />
+                  </Form.Group>
+                  <Form.Group>
+                    <Form.Label>Argument (JSON)</Form.Label>
+                    <Form.Control
+                      as="textarea"
+                      rows={4}
+                      className="font-monospace"
+                      value={signalArg}
+                      onChange={(e) => setSignalArg(e.target.value)}
+                    />
+                  </Form.Group>
+                </>
+              )}
+
+              {error && (
+                <Alert variant="danger" className="mt-3 mb-0">
+                  {error}
+                </Alert>
+              )}
+            </Modal.Body>
+            <Modal.Footer>
+              <Button variant="secondary" onClick={close} disabled={pending}>
+                Close
+              </Button>
+              <Button
+                variant={destructive ? "danger" : "primary"}
+                onClick={confirm}
+                disabled={pending || (action === "signal" && !signalName)}
+              >
+                {actionLabels[action]}
+              </Button>
+            </Modal.Footer>
+          </>
+        )}
+      </Modal>
+    </>
+  );
+}
+
+export default InstanceActions;
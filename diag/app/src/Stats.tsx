@@ -0,0 +1,106 @@
+import { Card, Col, Row, Table } from "react-bootstrap";
+import { Link } from "react-router-dom";
+import { Stats as StatsData } from "./client";
+import { formatDuration } from "./Timeline";
+
+import React from "react";
+import useFetch from "react-fetch-hook";
+
+function Metric({ title, value }: { title: string; value: React.ReactNode }) {
+  return (
+    <Card>
+      <Card.Body>
+        <Card.Subtitle className="text-secondary mb-1">{title}</Card.Subtitle>
+        <Card.Title as="h4" className="mb-0">
+          {value}
+        </Card.Title>
+      </Card.Body>
+    </Card>
+  );
+}
+
+// Stats shows an overview of the backend, it's hidden when the backend does not implement diag.StatsBackend
+function Stats() {
+  const { data } = useFetch<StatsData>(document.location.pathname + "api/stats");
+
+  if (!data) {
+    return null;
+  }
+
+  const oldest = data.oldest_pending_task
+    ? formatDuration(
+        Math.max(Date.now() - new Date(data.oldest_pending_task).getTime(), 0)
+      )
+    : "-";
+
+  return (
+    <div className="mb-4">
+      <header className="App-header">
+        <h2>Overview</h2>
+      </header>
+
+      <Row className="mb-3">
+        <Col>
+          <Metric title="Activity queue depth" value={data.activity_queue_depth} />
+        </Col>
+        <Col>
+          <Metric title="Oldest pending task" value={oldest} />
+        </Col>
+        <Col>
+          <Metric
+            title="Failure rate (last hour)"
+            value={
+              <>
+                {(data.failure_rate * 100).toFixed(1)}%{" "}
+                <small className="text-secondary">
+                  {data.recently_failed} of {data.recently_finished}
+                </small>
+              </>
+            }
+          />
+        </Col>
+      </Row>
+
+      {data.workflows.length > 0 && (
+        <Table bordered hover size="sm">
+          <thead>
+            <tr>
+              <th>Workflow</th>
+              <th>Running</th>
+              <th>Suspended</th>
+              <th>Completed</th>
+              <th>Failed</th>
+              <th>Canceled</th>
+              <th>Terminated</th>
+            </tr>
+          </thead>
+          <tbody>
+            {data.workflows.map((w) => (
+              <tr key={w.workflow_name}>
+                <td>
+                  {w.workflow_name ? (
+                    <Link to={`/?name=${encodeURIComponent(w.workflow_name)}`}>
+                      <code>{w.workflow_name}</code>
+                    </Link>
+                  ) : (
+                    <i>unknown</i>
+                  )}
+                </td>
+                <td>{w.running}</td>
+                <td>{w.suspended}</td>
+                <td>{w.completed}</td>
+                <td className={w.failed ? "text-danger" : undefined}>
+                  {w.failed}
+                </td>
+                <td>{w.canceled}</td>
+                <td>{w.terminated}</td>
+              </tr>
+            ))}
+          </tbody>
+        </Table>
+      )}
+    </div>
+  );
+}
+
+export default Stats;
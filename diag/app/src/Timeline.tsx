@@ -0,0 +1,128 @@
+import { Badge, OverlayTrigger, Tooltip } from "react-bootstrap";
+import { Link } from "react-router-dom";
+import { Span, SpanKind, SpanStatus, Timeline as TimelineData } from "./client";
+
+import React from "react";
+
+const kindLabels: { [kind in SpanKind]: string } = {
+  activity: "Activity",
+  timer: "Timer",
+  sub_workflow: "Sub-workflow",
+  signal: "Signal",
+  side_effect: "Side effect",
+};
+
+const statusColors: { [status in SpanStatus]: string } = {
+  pending: "primary",
+  completed: "success",
+  failed: "danger",
+  canceled: "secondary",
+};
+
+// formatDuration formats a duration in milliseconds for display
+export function formatDuration(ms: number): string {
+  if (ms < 1000) {
+    return `${ms}ms`;
+  }
+
+  const s = ms / 1000;
+  if (s < 60) {
+    return `${s.toFixed(s < 10 ? 2 : 1)}s`;
+  }
+
+  const m = Math.floor(s / 60);
+  if (m < 60) {
+    return `${m}m ${Math.round(s % 60)}s`;
+  }
+
+  return `${Math.floor(m / 60)}h ${m % 60}m`;
+}
+
+function SpanLabel({ span }: { span: Span }) {
+  return (
+    <>
+      <span className="text-secondary">{kindLabels[span.kind]}</span>{" "}
+      {span.sub_workflow_instance_id ? (
+        <Link to={`/${span.sub_workflow_instance_id}`}>
+          <code>{span.name}</code>
+        </Link>
+      ) : (
+        <code>{span.name}</code>
+      )}
+      {!!span.attempt && (
+        <Badge bg="warning" text="dark" className="ms-1">
+          retry {span.attempt}
+        </Badge>
+      )}
+    </>
+  );
+}
+
+// Timeline renders the spans of a workflow instance as a Gantt chart, relative to the duration of the instance
+function Timeline({ timeline }: { timeline: TimelineData }) {
+  const start = new Date(timeline.start).getTime();
+  const now = Date.now();
+  const end = timeline.end ? new Date(timeline.end).getTime() : now;
+  const total = Math.max(end - start, 1);
+
+  if (timeline.spans.length === 0) {
+    return <i>No activities, timers, or sub-workflows</i>;
+  }
+
+  return (
+    <div>
+      <div className="d-flex text-secondary small mb-1">
+        <div style={{ width: "30%" }} />
+        <div className="flex-grow-1 d-flex justify-content-between">
+          <span>0</span>
+          <span>{formatDuration(end - start)}</span>
+        </div>
+      </div>
+      {timeline.spans.map((span, idx) => {
+        const spanStart = new Date(span.start).getTime();
+        const spanEnd = span.end ? new Date(span.end).getTime() : end;
+        const duration = spanEnd - spanStart;
+
+        const left = ((spanStart - start) / total) * 100;
+        const width = Math.max((duration / total) * 100, 0.5);
+
+        return (
+          <div
+            className="d-flex align-items-center border-bottom py-1"
+            key={idx}
+          >
+            <div
+              className="text-truncate pe-2 small"
+              style={{ width: "30%" }}
+              title={span.name}
+            >
+              <SpanLabel span={span} />
+            </div>
+            <div className="flex-grow-1 position-relative" style={{ height: 16 }}>
+              <OverlayTrigger
+                placement="top"
+                overlay={
+                  <Tooltip>
+                    {span.status} ·{" "}
+                    {span.end ? formatDuration(duration) : "running"}
+                    {span.error && <div>{span.error}</div>}
+                    <div>events #{span.sequence_ids.join(", #")}</div>
+                  </Tooltip>
+                }
+              >
+                <div
+                  className={`position-absolute h-100 rounded bg-${
+                    statusColors[span.status]
+                  }${span.status === "pending" ? " progress-bar-striped" : ""}`}
+                  style={{ left: `${left}%`, width: `${width}%` }}
+                />
+              </OverlayTrigger>
+            </div>
+          </div>
+        );
+      })}
+    </div>
+  );
+}
+
+export default Timeline;
@@ -4,17 +4,55 @@ export interface WorkflowInstance {
   execution_id: string;
 }
 
+// Keep in sync with core.WorkflowInstanceState
+export enum WorkflowInstanceState {
+  Active = 0,
+  Finished = 1,
+  Suspended = 2,
+  Completed = 3,
+  Failed = 4,
+  Canceled = 5,
+  Terminated = 6,
+}
+
+// Keep in sync with core.WorkflowInstanceState.Finished
+export function isFinished(state: WorkflowInstanceState): boolean {
+  return (
+    state !== WorkflowInstanceState.Active &&
+    state !== WorkflowInstanceState.Suspended
+  );
+}
+
 export interface WorkflowInstanceRef {
   instance: WorkflowInstance;
 
+  workflow_name?: string;
+
   created_at: string;
   completed_at?: string;
 
-  state: number;
+  state: WorkflowInstanceState;
+
+  memo?: { [key: string]: string };
+
+  last_error?: string;
+
+  archived?: boolean;
 }
 
+// Keep in sync with diag.Action
+export type Action = "cancel" | "terminate" | "signal" | "retry" | "delete";
+
 export type WorkflowInstanceInfo = WorkflowInstanceRef & {
   history: HistoryEvent<any>[];
+
+  actions?: Action[];
+
+  custom_payload_decoder?: boolean;
+};
+
+export type WorkflowInstanceTree = WorkflowInstanceRef & {
+  children?: WorkflowInstanceTree[];
 };
 
 export interface HistoryEvent<TAttributes> {
@@ -27,12 +65,116 @@ export interface HistoryEvent<TAttributes> {
   visible_at?: string;
 }
 
+// DecodedPayload is a payload decoded by the payload decoder of the diagnostics server. Payloads that could not be
+// decoded contain the base64 encoded raw bytes and the reason. Empty payloads have neither.
+export interface DecodedPayload {
+  value?: any;
+  raw?: string;
+  error?: string;
+}
+
 export interface ExecutionStartedAttributes {
   name: string;
-  inputs: string[];
+  inputs?: DecodedPayload[];
 }
 
 export interface ExecutionCompletedAttributes {
-  result: string;
-  error: string;
+  result?: DecodedPayload;
+  error?: string;
+}
+
+export interface ExecutionResetAttributes {
+  reason?: string;
+  previous_execution_id: string;
+  to_sequence_id: number;
+}
+
+export interface ExecutionTerminatedAttributes {
+  reason?: string;
+}
+
+export interface TerminateRequest {
+  reason?: string;
+}
+
+export interface SignalRequest {
+  name: string;
+  arg?: any;
+}
+
+// performAction runs an action on a workflow instance, the returned promise is rejected if the request fails
+export async function performAction(
+  instanceId: string,
+  action: Action,
+  body?: TerminateRequest | SignalRequest
+): Promise<void> {
+  const res = await fetch(
+    document.location.pathname +
+      "api/" +
+      encodeURIComponent(instanceId) +
+      "/" +
+      action,
+    {
+      method: "POST",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify(body ?? {}),
+    }
+  );
+
+  if (!res.ok) {
+    throw new Error(`${action} failed: ${res.status} ${res.statusText}`);
+  }
+}
+
+// Keep in sync with diag.Timeline
+export type SpanKind =
+  | "activity"
+  | "timer"
+  | "sub_workflow"
+  | "signal"
+  | "side_effect";
+
+export type SpanStatus = "pending" | "completed" | "failed" | "canceled";
+
+export interface Span {
+  kind: SpanKind;
+  status: SpanStatus;
+  name?: string;
+  schedule_event_id?: number;
+  attempt?: number;
+  sub_workflow_instance_id?: string;
+  start: string;
+  end?: string;
+  error?: string;
+  sequence_ids: number[];
+}
+
+export interface Timeline {
+  start: string;
+  end?: string;
+  spans: Span[];
+}
+
+// Keep in sync with diag.Stats
+export interface WorkflowStats {
+  workflow_name: string;
+  running: number;
+  suspended: number;
+  completed: number;
+  failed: number;
+  canceled: number;
+  terminated: number;
+}
+
+export interface Stats {
+  workflows: WorkflowStats[];
+  activity_queue_depth: number;
+  oldest_pending_task?: string;
+  recently_finished: number;
+  recently_failed: number;
+  failure_rate: number;
+}
+
+export interface StackTrace {
+  stack_trace: string;
 }
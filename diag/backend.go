@@ -2,6 +2,7 @@ package diag
 
 import (
 	"context"
+	"encoding/json"
 	"time"
 
 	"github.com/paveliak/go-workflows/backend"
@@ -11,10 +12,21 @@ import (
 // json: serialization in this file needs to be kept in sync with client.ts in the web app
 
 type WorkflowInstanceRef struct {
-	Instance    *core.WorkflowInstance     `json:"instance,omitempty"`
-	CreatedAt   time.Time                  `json:"created_at,omitempty"`
-	CompletedAt *time.Time                 `json:"completed_at,omitempty"`
-	State       core.WorkflowInstanceState `json:"state"`
+	Instance     *core.WorkflowInstance     `json:"instance,omitempty"`
+	WorkflowName string                     `json:"workflow_name,omitempty"`
+	CreatedAt    time.Time                  `json:"created_at,omitempty"`
+	CompletedAt  *time.Time                 `json:"completed_at,omitempty"`
+	State        core.WorkflowInstanceState `json:"state"`
+
+	// Memo holds the immutable key/values the instance was created with
+	Memo core.Memo `json:"memo,omitempty"`
+
+	// LastError is the error of the last abandoned workflow task, for example a panic in workflow code when the
+	// worker is configured to block workflows on panics. It is cleared once a workflow task completes.
+	LastError string `json:"last_error,omitempty"`
+
+	// Archived is set when the instance has been removed from the backend and was read from the archive
+	Archived bool `json:"archived,omitempty"`
 }
 
 type Event struct {
@@ -31,11 +43,49 @@ type WorkflowInstanceInfo struct {
 	*WorkflowInstanceRef
 
 	History []*Event `json:"history,omitempty"`
+
+	// Actions are the actions the requester may perform on the instance in its current state
+	Actions []Action `json:"actions,omitempty"`
+
+	// CustomPayloadDecoder is true if the payloads in the history were decoded with a decoder configured with
+	// WithPayloadDecoder. Their values cannot be converted back to the original payloads.
+	CustomPayloadDecoder bool `json:"custom_payload_decoder,omitempty"`
+}
+
+// WorkflowInstanceTree is a workflow instance with the sub-workflow instances it started
+type WorkflowInstanceTree struct {
+	*WorkflowInstanceRef
+
+	Children []*WorkflowInstanceTree `json:"children,omitempty"`
+}
+
+// TerminateRequest is the body of a request to terminate a workflow instance
+type TerminateRequest struct {
+	Reason string `json:"reason,omitempty"`
+}
+
+// SignalRequest is the body of a request to signal a workflow instance
+type SignalRequest struct {
+	Name string          `json:"name"`
+	Arg  json.RawMessage `json:"arg,omitempty"`
+}
+
+type StackTrace struct {
+	StackTrace string `json:"stack_trace"`
 }
 
 type Backend interface {
 	backend.Backend
 
 	GetWorkflowInstance(ctx context.Context, instanceID string) (*WorkflowInstanceRef, error)
-	GetWorkflowInstances(ctx context.Context, afterInstanceID string, count int) ([]*WorkflowInstanceRef, error)
+
+	// GetWorkflowInstances returns up to count instances, newest first, created before the instance afterInstanceID.
+	// If states are given, only instances in one of these states are returned.
+	GetWorkflowInstances(ctx context.Context, afterInstanceID string, count int, states ...core.WorkflowInstanceState) ([]*WorkflowInstanceRef, error)
+
+	// QueryWorkflowInstances returns the instances matching the given query, newest first
+	QueryWorkflowInstances(ctx context.Context, query *WorkflowInstanceQuery) ([]*WorkflowInstanceRef, error)
+
+	// GetWorkflowInstanceChildren returns the sub-workflow instances started by the given instance, oldest first
+	GetWorkflowInstanceChildren(ctx context.Context, instanceID string) ([]*WorkflowInstanceRef, error)
 }
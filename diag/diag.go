@@ -1,12 +1,21 @@
 package diag
 
 import (
+	"context"
 	"embed"
 	"encoding/json"
+	"errors"
 	"io/fs"
 	"net/http"
-	"strconv"
 	"strings"
+	"time"
+
+	"github.com/paveliak/go-workflows/archive"
+	"github.com/paveliak/go-workflows/client"
+	"github.com/paveliak/go-workflows/internal/converter"
+	"github.com/paveliak/go-workflows/internal/core"
+	"github.com/paveliak/go-workflows/internal/history"
+	"github.com/paveliak/go-workflows/internal/workflow"
 )
 
 //go:embed app/build
@@ -14,36 +23,63 @@ var embeddedFiles embed.FS
 
 // NewServeMux returns an *http.ServeMux that serves the diagnostics web app at / and the diagnostics API at /api which is
 // used by the web app.
-func NewServeMux(backend Backend) *http.ServeMux {
+func NewServeMux(backend Backend, opts ...Option) *http.ServeMux {
+	o := &options{
+		payloadDecoder: &converterDecoder{converter.DefaultConverter},
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	c := client.New(backend)
+
 	mux := http.NewServeMux()
 
 	// API
 	mux.HandleFunc("/api/", func(w http.ResponseWriter, r *http.Request) {
-		// Only support GET requests
+		relativeURL := strings.TrimPrefix(r.URL.Path, "/api/")
+		segments := strings.Split(relativeURL, "/")
+
+		// POST /api/{instanceID}/{action}
+		if r.Method == http.MethodPost {
+			if len(segments) != 2 || segments[0] == "" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+
+			handleAction(w, r, o, c, backend, segments[0], Action(segments[1]))
+			return
+		}
+
+		// Otherwise only support GET requests
 		if r.Method != http.MethodGet {
 			w.WriteHeader(http.StatusMethodNotAllowed)
 			return
 		}
 
-		relativeURL := strings.TrimPrefix(r.URL.Path, "/api/")
+		// /api/stats is not specific to an instance
+		instanceID := segments[0]
+		if relativeURL == "stats" {
+			instanceID = ""
+		}
+
+		if o.authorizer != nil {
+			if err := o.authorizer.Authorize(r, ActionView, instanceID); err != nil {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+		}
 
 		// /api/
 		if relativeURL == "" {
 			// Index
-			query := r.URL.Query()
-
-			count := 25
-			countStr := query.Get("count")
-			if countStr != "" {
-				var err error
-				count, err = strconv.Atoi(countStr)
-				if err != nil {
-					w.WriteHeader(http.StatusBadRequest)
-					return
-				}
+			query, err := ParseQuery(r.URL.Query())
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
 			}
 
-			instances, err := backend.GetWorkflowInstances(r.Context(), query.Get("after"), count)
+			instances, err := backend.QueryWorkflowInstances(r.Context(), query)
 			if err != nil {
 				w.WriteHeader(http.StatusInternalServerError)
 				return
@@ -58,49 +94,142 @@ func NewServeMux(backend Backend) *http.ServeMux {
 			return
 		}
 
-		segments := strings.Split(relativeURL, "/")
+		// /api/stats
+		if relativeURL == "stats" {
+			sb, ok := backend.(StatsBackend)
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+
+			stats, err := sb.GetStats(r.Context(), time.Now().Add(-StatsWindow))
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Add("Content-Type", "application/json")
+			if err := json.NewEncoder(w).Encode(stats); err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			return
+		}
 
 		// /api/{instanceID}
 		if len(segments) == 1 {
-			instanceID := segments[0]
-
-			instance, err := backend.GetWorkflowInstance(r.Context(), instanceID)
+			instance, h, err := getInstance(r.Context(), backend, o, segments[0])
 			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			if instance == nil {
 				w.WriteHeader(http.StatusNotFound)
 				return
 			}
 
+			newHistory := make([]*Event, 0)
+			for _, event := range h {
+				newHistory = append(newHistory, newEvent(o.payloadDecoder, event))
+			}
+
+			_, defaultDecoder := o.payloadDecoder.(*converterDecoder)
+
+			result := &WorkflowInstanceInfo{
+				WorkflowInstanceRef:  instance,
+				History:              newHistory,
+				Actions:              allowedActions(r, o, instance),
+				CustomPayloadDecoder: !defaultDecoder,
+			}
+
+			w.Header().Add("Content-Type", "application/json")
+			if err := json.NewEncoder(w).Encode(result); err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			return
+		}
+
+		// /api/{instanceID}/timeline
+		if len(segments) == 2 && segments[1] == "timeline" {
+			instance, h, err := getInstance(r.Context(), backend, o, segments[0])
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
 			if instance == nil {
 				w.WriteHeader(http.StatusNotFound)
 				return
 			}
 
-			history, err := backend.GetWorkflowInstanceHistory(r.Context(), instance.Instance, nil)
+			w.Header().Add("Content-Type", "application/json")
+			if err := json.NewEncoder(w).Encode(NewTimeline(h)); err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			return
+		}
+
+		// /api/{instanceID}/tree
+		if len(segments) == 2 && segments[1] == "tree" {
+			tree, err := getWorkflowInstanceTree(r.Context(), backend, segments[0])
 			if err != nil {
 				w.WriteHeader(http.StatusInternalServerError)
 				return
 			}
 
-			newHistory := make([]*Event, 0)
-			for _, event := range history {
-				newHistory = append(newHistory, &Event{
-					ID:              event.ID,
-					SequenceID:      event.SequenceID,
-					Type:            event.Type.String(),
-					Timestamp:       event.Timestamp,
-					ScheduleEventID: event.ScheduleEventID,
-					Attributes:      event.Attributes,
-					VisibleAt:       event.VisibleAt,
-				})
+			if tree == nil {
+				w.WriteHeader(http.StatusNotFound)
+				return
 			}
 
-			result := &WorkflowInstanceInfo{
-				WorkflowInstanceRef: instance,
-				History:             newHistory,
+			w.Header().Add("Content-Type", "application/json")
+			if err := json.NewEncoder(w).Encode(tree); err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			return
+		}
+
+		// /api/{instanceID}/events
+		if len(segments) == 2 && segments[1] == "events" {
+			streamInstance(w, r, backend, o.payloadDecoder, segments[0])
+			return
+		}
+
+		// /api/{instanceID}/stacktrace
+		if len(segments) == 2 && segments[1] == "stacktrace" {
+			if o.querier == nil {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+
+			instance, err := backend.GetWorkflowInstance(r.Context(), segments[0])
+			if err != nil || instance == nil {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+
+			p, err := o.querier.QueryWorkflowInstance(r.Context(), instance.Instance, workflow.StackTraceQuery)
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			var stackTrace string
+			if err := converter.DefaultConverter.From(p, &stackTrace); err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
 			}
 
 			w.Header().Add("Content-Type", "application/json")
-			if err := json.NewEncoder(w).Encode(result); err != nil {
+			if err := json.NewEncoder(w).Encode(&StackTrace{StackTrace: stackTrace}); err != nil {
 				w.WriteHeader(http.StatusInternalServerError)
 				return
 			}
@@ -115,6 +244,118 @@ func NewServeMux(backend Backend) *http.ServeMux {
 	return mux
 }
 
+// getInstance returns a workflow instance and its history from the backend, or from the archive if the instance
+// has been removed from the backend. Returns nil if the instance cannot be found.
+func getInstance(ctx context.Context, b Backend, o *options, instanceID string) (*WorkflowInstanceRef, []history.Event, error) {
+	var h []history.Event
+
+	instance, err := b.GetWorkflowInstance(ctx, instanceID)
+	if err == nil && instance != nil {
+		h, err = b.GetWorkflowInstanceHistory(ctx, instance.Instance, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+	} else if o.archive != nil {
+		// Instance might have been removed from the backend, try the archive
+		instance, h, err = getArchivedInstance(ctx, o.archive, instanceID)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if instance == nil {
+		return nil, nil, nil
+	}
+
+	if instance.WorkflowName == "" {
+		// Instances created before the workflow name was stored, get it from the history
+		instance.WorkflowName = workflowName(h)
+	}
+
+	return instance, h, nil
+}
+
+func getArchivedInstance(ctx context.Context, r archive.Reader, instanceID string) (*WorkflowInstanceRef, []history.Event, error) {
+	h, err := r.GetWorkflowInstanceHistory(ctx, instanceID)
+	if err != nil {
+		if errors.Is(err, archive.ErrInstanceNotFound) {
+			return nil, nil, nil
+		}
+
+		return nil, nil, err
+	}
+
+	// The archive only contains the history, reconstruct as much of the instance as possible from it
+	instance := &WorkflowInstanceRef{
+		Instance: core.NewWorkflowInstance(instanceID, ""),
+		State:    core.WorkflowInstanceStateFinished,
+		Archived: true,
+	}
+
+	if len(h) > 0 {
+		instance.CreatedAt = h[0].Timestamp
+	}
+
+	canceled := false
+	for _, event := range h {
+		switch event.Type {
+		case history.EventType_WorkflowExecutionStarted:
+			if a, ok := event.Attributes.(*history.ExecutionStartedAttributes); ok {
+				instance.Memo = a.Memo
+			}
+
+		case history.EventType_WorkflowExecutionCanceled:
+			canceled = true
+
+		case history.EventType_WorkflowExecutionTerminated:
+			instance.State = core.WorkflowInstanceStateTerminated
+
+			// Terminated instances don't record a finished event
+			completedAt := event.Timestamp
+			instance.CompletedAt = &completedAt
+
+		case history.EventType_WorkflowExecutionFinished:
+			completedAt := event.Timestamp
+			instance.CompletedAt = &completedAt
+
+			if a, ok := event.Attributes.(*history.ExecutionCompletedAttributes); ok {
+				switch {
+				case a.Error == "":
+					instance.State = core.WorkflowInstanceStateCompleted
+				case canceled:
+					instance.State = core.WorkflowInstanceStateCanceled
+				default:
+					instance.State = core.WorkflowInstanceStateFailed
+				}
+			}
+		}
+	}
+
+	return instance, h, nil
+}
+
+// workflowName returns the name of the workflow started in the given history
+func workflowName(h []history.Event) string {
+	for _, event := range h {
+		if a, ok := event.Attributes.(*history.ExecutionStartedAttributes); ok {
+			return a.Name
+		}
+	}
+
+	return ""
+}
+
+// parseState parses a state name as returned by core.WorkflowInstanceState.String, ignoring case
+func parseState(name string) (core.WorkflowInstanceState, bool) {
+	for _, state := range core.WorkflowInstanceStates() {
+		if strings.EqualFold(state.String(), name) {
+			return state, true
+		}
+	}
+
+	return core.WorkflowInstanceStateActive, false
+}
+
 func getFileSystem() http.FileSystem {
 	// Get the build subdirectory as the
 	// root directory so that it can be passed
@@ -0,0 +1,59 @@
+package diag
+
+import (
+	"context"
+
+	"github.com/paveliak/go-workflows/archive"
+	"github.com/paveliak/go-workflows/internal/core"
+	"github.com/paveliak/go-workflows/internal/payload"
+)
+
+// Querier runs queries against the state of workflow instances. It's implemented by worker.Worker.
+type Querier interface {
+	QueryWorkflowInstance(ctx context.Context, instance *core.WorkflowInstance, query string) (payload.Payload, error)
+}
+
+type options struct {
+	archive archive.Reader
+
+	querier Querier
+
+	authorizer Authorizer
+
+	payloadDecoder PayloadDecoder
+}
+
+type Option func(*options)
+
+// WithArchive configures a reader for archived workflow histories. Instances that cannot be found in the backend
+// anymore are looked up in the archive.
+func WithArchive(r archive.Reader) Option {
+	return func(o *options) {
+		o.archive = r
+	}
+}
+
+// WithQuerier configures a querier to retrieve the stack traces of workflow instances. This is only useful when the
+// web app is served from the process running the worker.
+func WithQuerier(q Querier) Option {
+	return func(o *options) {
+		o.querier = q
+	}
+}
+
+// WithAuthorizer configures an authorizer for requests to the diagnostics API. Actions that modify workflow
+// instances, like canceling or terminating them, are only available when an authorizer is configured.
+func WithAuthorizer(a Authorizer) Option {
+	return func(o *options) {
+		o.authorizer = a
+	}
+}
+
+// WithPayloadDecoder configures how payloads like inputs, results and signal arguments are decoded for display. By
+// default payloads are decoded with the JSON converter used by workflows and activities. Payloads that cannot be
+// decoded are returned as raw bytes.
+func WithPayloadDecoder(d PayloadDecoder) Option {
+	return func(o *options) {
+		o.payloadDecoder = d
+	}
+}
@@ -0,0 +1,141 @@
+package diag
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+
+	"github.com/paveliak/go-workflows/internal/converter"
+	"github.com/paveliak/go-workflows/internal/payload"
+)
+
+// PayloadDecoder decodes the payloads of workflow instances, like inputs, results and signal arguments, into JSON for
+// the diagnostics API. Configure a custom decoder with WithPayloadDecoder if payloads are not plain JSON, for example
+// because they are encrypted or compressed.
+type PayloadDecoder interface {
+	Decode(p []byte) (json.RawMessage, error)
+}
+
+// PayloadDecoderFunc adapts a function to a PayloadDecoder
+type PayloadDecoderFunc func(p []byte) (json.RawMessage, error)
+
+func (f PayloadDecoderFunc) Decode(p []byte) (json.RawMessage, error) {
+	return f(p)
+}
+
+// DecodedPayload is a payload as returned by the diagnostics API. Value holds the decoded payload. If the payload
+// could not be decoded, Raw holds the payload bytes and Error the reason. Both are empty for empty payloads.
+type DecodedPayload struct {
+	Value json.RawMessage `json:"value,omitempty"`
+	Raw   []byte          `json:"raw,omitempty"`
+	Error string          `json:"error,omitempty"`
+}
+
+// converterDecoder decodes payloads with the converter used by workflows and activities
+type converterDecoder struct {
+	c converter.Converter
+}
+
+func (d *converterDecoder) Decode(p []byte) (json.RawMessage, error) {
+	var v json.RawMessage
+	if err := d.c.From(p, &v); err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+func decodePayload(d PayloadDecoder, p payload.Payload) *DecodedPayload {
+	if len(p) == 0 {
+		// Empty payloads have neither a value nor raw bytes
+		return &DecodedPayload{}
+	}
+
+	v, err := d.Decode(p)
+	if err == nil && !json.Valid(v) {
+		err = errors.New("decoded payload is not valid JSON")
+	}
+
+	if err != nil {
+		return &DecodedPayload{Raw: p, Error: err.Error()}
+	}
+
+	return &DecodedPayload{Value: v}
+}
+
+// decodeAttributes returns the JSON representation of the attributes of a history event, with payloads replaced by
+// DecodedPayloads. Attributes without payloads are returned unchanged.
+func decodeAttributes(d PayloadDecoder, attributes interface{}) interface{} {
+	v := reflect.ValueOf(attributes)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Struct {
+		return attributes
+	}
+
+	decoded := map[string]interface{}{}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Type != payload.Type && f.Type != payload.SliceType {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+
+		fv := v.Field(i)
+		if fv.Len() == 0 && strings.Contains(opts, "omitempty") {
+			continue
+		}
+
+		if f.Type == payload.Type {
+			decoded[name] = decodePayload(d, fv.Interface().(payload.Payload))
+		} else {
+			payloads := fv.Interface().([]payload.Payload)
+
+			dps := make([]*DecodedPayload, 0, len(payloads))
+			for _, p := range payloads {
+				dps = append(dps, decodePayload(d, p))
+			}
+
+			decoded[name] = dps
+		}
+	}
+
+	if len(decoded) == 0 {
+		return attributes
+	}
+
+	// Keep the other attributes as they are serialized
+	data, err := json.Marshal(attributes)
+	if err != nil {
+		return attributes
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return attributes
+	}
+
+	result := make(map[string]interface{}, len(fields))
+	for name, value := range fields {
+		result[name] = value
+	}
+
+	for name, value := range decoded {
+		result[name] = value
+	}
+
+	return result
+}
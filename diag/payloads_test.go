@@ -0,0 +1,57 @@
+package diag
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/paveliak/go-workflows/internal/converter"
+	"github.com/paveliak/go-workflows/internal/history"
+	"github.com/paveliak/go-workflows/internal/payload"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_DecodeAttributes(t *testing.T) {
+	d := &converterDecoder{converter.DefaultConverter}
+
+	input, err := converter.DefaultConverter.To(map[string]int{"a": 1})
+	require.NoError(t, err)
+
+	a := decodeAttributes(d, &history.ExecutionStartedAttributes{
+		Name:   "wf",
+		Inputs: []payload.Payload{input, []byte("not json")},
+	})
+
+	data, err := json.Marshal(a)
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"name": "wf",
+		"inputs": [
+			{ "value": { "a": 1 } },
+			{ "raw": "bm90IGpzb24=", "error": "invalid character 'o' in literal null (expecting 'u')" }
+		]
+	}`, string(data))
+
+	// Attributes without payloads are unchanged
+	timer := &history.TimerFiredAttributes{}
+	require.Equal(t, timer, decodeAttributes(d, timer))
+
+	// Omitted payloads stay omitted
+	data, err = json.Marshal(decodeAttributes(d, &history.ExecutionCompletedAttributes{Error: "boom"}))
+	require.NoError(t, err)
+	require.JSONEq(t, `{ "error": "boom" }`, string(data))
+}
+
+func Test_DecodePayload_CustomDecoder(t *testing.T) {
+	d := PayloadDecoderFunc(func(p []byte) (json.RawMessage, error) {
+		if string(p) == "secret" {
+			return nil, errors.New("cannot decrypt")
+		}
+
+		return json.RawMessage(`"decrypted"`), nil
+	})
+
+	require.Equal(t, &DecodedPayload{Value: json.RawMessage(`"decrypted"`)}, decodePayload(d, []byte("x")))
+	require.Equal(t, &DecodedPayload{Raw: []byte("secret"), Error: "cannot decrypt"}, decodePayload(d, []byte("secret")))
+	require.Equal(t, &DecodedPayload{}, decodePayload(d, nil))
+}
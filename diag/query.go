@@ -0,0 +1,119 @@
+package diag
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/paveliak/go-workflows/backend"
+	"github.com/paveliak/go-workflows/internal/core"
+)
+
+// WorkflowInstanceQuery selects the workflow instances returned by QueryWorkflowInstances. Instances have to match
+// all set fields, zero values are ignored.
+type WorkflowInstanceQuery struct {
+	// States matches instances in any of the given states
+	States []core.WorkflowInstanceState
+
+	// WorkflowName matches instances of the workflow with the given name
+	WorkflowName string
+
+	// InstanceIDPrefix matches instances whose id starts with the given prefix
+	InstanceIDPrefix string
+
+	// CreatedAfter and CreatedBefore match instances created in the given range, including CreatedAfter
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+
+	// CompletedAfter and CompletedBefore match instances completed in the given range, including CompletedAfter.
+	// Setting either excludes instances that have not completed.
+	CompletedAfter  time.Time
+	CompletedBefore time.Time
+
+	// AfterInstanceID continues a previous query after the instance with the given id
+	AfterInstanceID string
+
+	// Count is the maximum number of instances to return. Defaults to backend.DefaultListCount.
+	Count int
+}
+
+// Limit returns the maximum number of instances to return
+func (q *WorkflowInstanceQuery) Limit() int {
+	if q.Count <= 0 {
+		return backend.DefaultListCount
+	}
+
+	return q.Count
+}
+
+// Matches returns true if the given instance matches the query
+func (q *WorkflowInstanceQuery) Matches(ref *WorkflowInstanceRef) bool {
+	if !strings.HasPrefix(ref.Instance.InstanceID, q.InstanceIDPrefix) {
+		return false
+	}
+
+	filter := &backend.WorkflowInstanceFilter{
+		WorkflowName:    q.WorkflowName,
+		States:          q.States,
+		CreatedAfter:    q.CreatedAfter,
+		CreatedBefore:   q.CreatedBefore,
+		CompletedAfter:  q.CompletedAfter,
+		CompletedBefore: q.CompletedBefore,
+	}
+
+	return filter.Matches(ref.WorkflowName, ref.State, ref.CreatedAt, ref.CompletedAt)
+}
+
+// ParseQuery reads a WorkflowInstanceQuery from the query parameters of an API request. Times are expected in
+// RFC 3339 format.
+func ParseQuery(values url.Values) (*WorkflowInstanceQuery, error) {
+	q := &WorkflowInstanceQuery{
+		WorkflowName:     values.Get("name"),
+		InstanceIDPrefix: values.Get("prefix"),
+		AfterInstanceID:  values.Get("after"),
+	}
+
+	if countStr := values.Get("count"); countStr != "" {
+		count, err := strconv.Atoi(countStr)
+		if err != nil {
+			return nil, fmt.Errorf("parsing count: %w", err)
+		}
+
+		q.Count = count
+	}
+
+	for _, name := range values["state"] {
+		state, ok := parseState(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown state %q", name)
+		}
+
+		q.States = append(q.States, state)
+	}
+
+	for _, p := range []struct {
+		name string
+		t    *time.Time
+	}{
+		{"created_after", &q.CreatedAfter},
+		{"created_before", &q.CreatedBefore},
+		{"completed_after", &q.CompletedAfter},
+		{"completed_before", &q.CompletedBefore},
+	} {
+		value := values.Get(p.name)
+		if value == "" {
+			continue
+		}
+
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %v: %w", p.name, err)
+		}
+
+		*p.t = t
+	}
+
+	return q, nil
+}
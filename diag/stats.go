@@ -0,0 +1,137 @@
+package diag
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/paveliak/go-workflows/internal/core"
+)
+
+// json: serialization in this file needs to be kept in sync with client.ts in the web app
+
+// StatsWindow is the period over which the diagnostics API calculates the failure rate of workflow instances
+const StatsWindow = time.Hour
+
+// StatsBackend is implemented by backends that can aggregate an overview of their workflow instances and tasks. The
+// diagnostics API serves the stats at /api/stats when the backend implements it.
+type StatsBackend interface {
+	// GetStats returns the current stats of the backend. Instances that finished at or after since are counted as
+	// recently finished.
+	GetStats(ctx context.Context, since time.Time) (*Stats, error)
+}
+
+// WorkflowStats are the number of instances of a workflow by their state
+type WorkflowStats struct {
+	WorkflowName string `json:"workflow_name"`
+
+	// Running counts active instances
+	Running int64 `json:"running"`
+
+	Suspended  int64 `json:"suspended"`
+	Completed  int64 `json:"completed"`
+	Failed     int64 `json:"failed"`
+	Canceled   int64 `json:"canceled"`
+	Terminated int64 `json:"terminated"`
+}
+
+// Stats is an overview of the workflow instances and tasks of a backend
+type Stats struct {
+	// Workflows are ordered by the workflow name
+	Workflows []*WorkflowStats `json:"workflows"`
+
+	// ActivityQueueDepth is the number of activity tasks that are waiting to be executed or are being executed
+	ActivityQueueDepth int64 `json:"activity_queue_depth"`
+
+	// OldestPendingTask is the time the oldest workflow or activity task was queued, it's nil if there are no
+	// pending tasks
+	OldestPendingTask *time.Time `json:"oldest_pending_task,omitempty"`
+
+	// RecentlyFinished and RecentlyFailed count the instances that finished since the time given to GetStats
+	RecentlyFinished int64 `json:"recently_finished"`
+	RecentlyFailed   int64 `json:"recently_failed"`
+}
+
+// AddInstances adds count instances of the given workflow in the given state. Backends call it for every
+// combination of workflow name and state.
+func (s *Stats) AddInstances(workflowName string, state core.WorkflowInstanceState, count int64) {
+	var ws *WorkflowStats
+	for _, w := range s.Workflows {
+		if w.WorkflowName == workflowName {
+			ws = w
+			break
+		}
+	}
+
+	if ws == nil {
+		ws = &WorkflowStats{WorkflowName: workflowName}
+		s.Workflows = append(s.Workflows, ws)
+		sort.Slice(s.Workflows, func(i, j int) bool {
+			return s.Workflows[i].WorkflowName < s.Workflows[j].WorkflowName
+		})
+	}
+
+	switch state {
+	case core.WorkflowInstanceStateActive:
+		ws.Running += count
+	case core.WorkflowInstanceStateSuspended:
+		ws.Suspended += count
+	case core.WorkflowInstanceStateCompleted, core.WorkflowInstanceStateFinished:
+		ws.Completed += count
+	case core.WorkflowInstanceStateFailed:
+		ws.Failed += count
+	case core.WorkflowInstanceStateCanceled:
+		ws.Canceled += count
+	case core.WorkflowInstanceStateTerminated:
+		ws.Terminated += count
+	}
+}
+
+// AddRecentlyFinished adds count instances that finished recently in the given state
+func (s *Stats) AddRecentlyFinished(state core.WorkflowInstanceState, count int64) {
+	if !state.Finished() {
+		return
+	}
+
+	s.RecentlyFinished += count
+
+	if state == core.WorkflowInstanceStateFailed {
+		s.RecentlyFailed += count
+	}
+}
+
+// AddPendingTask records a pending task queued at the given time, keeping the oldest one
+func (s *Stats) AddPendingTask(queuedAt time.Time) {
+	if s.OldestPendingTask == nil || queuedAt.Before(*s.OldestPendingTask) {
+		s.OldestPendingTask = &queuedAt
+	}
+}
+
+// FailureRate returns the share of recently finished instances that failed, between 0 and 1
+func (s *Stats) FailureRate() float64 {
+	if s.RecentlyFinished == 0 {
+		return 0
+	}
+
+	return float64(s.RecentlyFailed) / float64(s.RecentlyFinished)
+}
+
+func (s *Stats) MarshalJSON() ([]byte, error) {
+	type stats Stats
+
+	workflows := s.Workflows
+	if workflows == nil {
+		workflows = []*WorkflowStats{}
+	}
+
+	return json.Marshal(&struct {
+		*stats
+		Workflows   []*WorkflowStats `json:"workflows"`
+		FailureRate float64          `json:"failure_rate"`
+	}{
+		stats:       (*stats)(s),
+		Workflows:   workflows,
+		FailureRate: s.FailureRate(),
+	})
+}
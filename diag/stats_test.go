@@ -0,0 +1,55 @@
+package diag_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/paveliak/go-workflows/backend/sqlite"
+	"github.com/paveliak/go-workflows/client"
+	"github.com/paveliak/go-workflows/diag"
+	"github.com/paveliak/go-workflows/internal/core"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Stats(t *testing.T) {
+	s := &diag.Stats{}
+	s.AddInstances("b", core.WorkflowInstanceStateActive, 2)
+	s.AddInstances("a", core.WorkflowInstanceStateFailed, 1)
+	s.AddInstances("b", core.WorkflowInstanceStateCompleted, 3)
+	s.AddRecentlyFinished(core.WorkflowInstanceStateCompleted, 3)
+	s.AddRecentlyFinished(core.WorkflowInstanceStateFailed, 1)
+	s.AddRecentlyFinished(core.WorkflowInstanceStateActive, 5)
+
+	require.Equal(t, []*diag.WorkflowStats{
+		{WorkflowName: "a", Failed: 1},
+		{WorkflowName: "b", Running: 2, Completed: 3},
+	}, s.Workflows)
+	require.Equal(t, int64(4), s.RecentlyFinished)
+	require.Equal(t, 0.25, s.FailureRate())
+}
+
+func Test_Stats_API(t *testing.T) {
+	ctx := context.Background()
+
+	b := sqlite.NewInMemoryBackend()
+	c := client.New(b)
+
+	_, err := c.CreateWorkflowInstance(ctx, client.WorkflowInstanceOptions{
+		InstanceID: uuid.NewString(),
+	}, wf)
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	diag.NewServeMux(b).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/stats", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var stats map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &stats))
+	require.Len(t, stats["workflows"], 1)
+	require.NotEmpty(t, stats["oldest_pending_task"])
+	require.Equal(t, 0.0, stats["failure_rate"])
+}
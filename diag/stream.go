@@ -0,0 +1,159 @@
+package diag
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/paveliak/go-workflows/internal/history"
+)
+
+// streamPollInterval is how often streamInstance checks the backend for changes
+const streamPollInterval = time.Second
+
+// streamInstance sends changes to a workflow instance as server-sent events until the instance is finished or the
+// client disconnects:
+//
+//   - "instance" with the WorkflowInstanceRef, whenever its state changes
+//   - "history" with new history events, its id is the sequence id of the last event
+//   - "finished" once the instance is finished and its complete history has been sent
+//
+// Only history events after the sequence id given in the Last-Event-ID header or the after query parameter are
+// sent.
+func streamInstance(w http.ResponseWriter, r *http.Request, b Backend, d PayloadDecoder, instanceID string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	var lastSequenceID int64
+	after := r.Header.Get("Last-Event-ID")
+	if after == "" {
+		after = r.URL.Query().Get("after")
+	}
+
+	if after != "" {
+		var err error
+		lastSequenceID, err = strconv.ParseInt(after, 10, 64)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+
+	ctx := r.Context()
+
+	instance, err := b.GetWorkflowInstance(ctx, instanceID)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if instance == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	ticker := time.NewTicker(streamPollInterval)
+	defer ticker.Stop()
+
+	var sent *WorkflowInstanceRef
+
+	for {
+		if sent == nil || instanceChanged(sent, instance) {
+			if err := writeStreamEvent(w, "instance", "", instance); err != nil {
+				return
+			}
+
+			if sent != nil && sent.Instance.ExecutionID != instance.Instance.ExecutionID {
+				// The instance has been reset, the history of the new execution needs to be read from the start
+				flusher.Flush()
+				return
+			}
+
+			sent = instance
+		}
+
+		// The instance is read before its history, so that the history is complete once the instance is finished
+		h, err := b.GetWorkflowInstanceHistory(ctx, instance.Instance, &lastSequenceID)
+		if err != nil {
+			return
+		}
+
+		if len(h) > 0 {
+			events := make([]*Event, 0, len(h))
+			for _, event := range h {
+				events = append(events, newEvent(d, event))
+			}
+
+			lastSequenceID = h[len(h)-1].SequenceID
+			if err := writeStreamEvent(w, "history", strconv.FormatInt(lastSequenceID, 10), events); err != nil {
+				return
+			}
+		}
+
+		if instance.State.Finished() {
+			writeStreamEvent(w, "finished", "", struct{}{})
+			flusher.Flush()
+			return
+		}
+
+		flusher.Flush()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		instance, err = b.GetWorkflowInstance(ctx, instanceID)
+		if err != nil || instance == nil {
+			return
+		}
+	}
+}
+
+// instanceChanged returns true if the properties of an instance shown in the web app have changed
+func instanceChanged(previous, current *WorkflowInstanceRef) bool {
+	return previous.Instance.ExecutionID != current.Instance.ExecutionID ||
+		previous.State != current.State ||
+		previous.LastError != current.LastError ||
+		(previous.CompletedAt == nil) != (current.CompletedAt == nil)
+}
+
+func writeStreamEvent(w io.Writer, name, id string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	if id != "" {
+		if _, err := fmt.Fprintf(w, "id: %s\n", id); err != nil {
+			return err
+		}
+	}
+
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, data)
+	return err
+}
+
+// newEvent converts a history event for the diagnostics API, payloads in its attributes are decoded with d
+func newEvent(d PayloadDecoder, event history.Event) *Event {
+	return &Event{
+		ID:              event.ID,
+		SequenceID:      event.SequenceID,
+		Type:            event.Type.String(),
+		Timestamp:       event.Timestamp,
+		ScheduleEventID: event.ScheduleEventID,
+		Attributes:      decodeAttributes(d, event.Attributes),
+		VisibleAt:       event.VisibleAt,
+	}
+}
@@ -0,0 +1,63 @@
+package diag_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/paveliak/go-workflows/backend/sqlite"
+	"github.com/paveliak/go-workflows/client"
+	"github.com/paveliak/go-workflows/diag"
+	"github.com/paveliak/go-workflows/internal/core"
+	"github.com/paveliak/go-workflows/internal/history"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Events_FinishedInstance(t *testing.T) {
+	ctx := context.Background()
+
+	b := sqlite.NewInMemoryBackend()
+	c := client.New(b)
+
+	instance, err := c.CreateWorkflowInstance(ctx, client.WorkflowInstanceOptions{
+		InstanceID: uuid.NewString(),
+	}, wf)
+	require.NoError(t, err)
+
+	task, err := b.GetWorkflowTask(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, task)
+
+	events := []history.Event{
+		history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowTaskStarted, &history.WorkflowTaskStartedAttributes{}),
+		task.NewEvents[0],
+		history.NewHistoryEvent(3, time.Now(), history.EventType_WorkflowExecutionFinished, &history.ExecutionCompletedAttributes{}),
+	}
+	events[1].SequenceID = 2
+
+	err = b.CompleteWorkflowTask(ctx, task, instance, core.WorkflowInstanceStateCompleted, events, []history.Event{}, []history.Event{}, []history.WorkflowEvent{})
+	require.NoError(t, err)
+
+	get := func(url string) string {
+		rec := httptest.NewRecorder()
+		diag.NewServeMux(b).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Equal(t, "text/event-stream", rec.Header().Get("Content-Type"))
+
+		return rec.Body.String()
+	}
+
+	// The stream ends once the complete history of the finished instance has been sent
+	body := get("/api/" + instance.InstanceID + "/events")
+	require.Contains(t, body, "event: instance\n")
+	require.Contains(t, body, "id: 3\nevent: history\n")
+	require.Contains(t, body, "event: finished\n")
+
+	body = get("/api/" + instance.InstanceID + "/events?after=3")
+	require.Contains(t, body, "event: instance\n")
+	require.NotContains(t, body, "event: history\n")
+	require.Contains(t, body, "event: finished\n")
+}
@@ -0,0 +1,181 @@
+package diag
+
+import (
+	"time"
+
+	"github.com/paveliak/go-workflows/internal/history"
+)
+
+// json: serialization in this file needs to be kept in sync with client.ts in the web app
+
+type SpanKind string
+
+const (
+	SpanKindActivity    SpanKind = "activity"
+	SpanKindTimer       SpanKind = "timer"
+	SpanKindSubWorkflow SpanKind = "sub_workflow"
+	SpanKindSignal      SpanKind = "signal"
+	SpanKindSideEffect  SpanKind = "side_effect"
+)
+
+type SpanStatus string
+
+const (
+	SpanStatusPending   SpanStatus = "pending"
+	SpanStatusCompleted SpanStatus = "completed"
+	SpanStatusFailed    SpanStatus = "failed"
+	SpanStatusCanceled  SpanStatus = "canceled"
+)
+
+// Span is an operation of a workflow instance, from the event that scheduled it to the event that finished it.
+// Signals and side effects are recorded by a single event, their spans start and end at the same time.
+type Span struct {
+	Kind   SpanKind   `json:"kind"`
+	Status SpanStatus `json:"status"`
+
+	// Name is the name of the activity, sub-workflow, or signal
+	Name string `json:"name,omitempty"`
+
+	// ScheduleEventID groups the events of activities, timers, sub-workflows and side effects
+	ScheduleEventID int64 `json:"schedule_event_id,omitempty"`
+
+	// Attempt is the number of previous attempts of an activity, it's 0 for the first attempt
+	Attempt int `json:"attempt,omitempty"`
+
+	// SubWorkflowInstanceID is the id of the instance started for a sub-workflow
+	SubWorkflowInstanceID string `json:"sub_workflow_instance_id,omitempty"`
+
+	Start time.Time `json:"start"`
+
+	// End is not set for pending spans
+	End *time.Time `json:"end,omitempty"`
+
+	Error string `json:"error,omitempty"`
+
+	// SequenceIDs are the sequence ids of the history events of the span
+	SequenceIDs []int64 `json:"sequence_ids"`
+}
+
+// Duration returns the duration of a finished span, and the time since the start for pending spans
+func (s *Span) Duration(now time.Time) time.Duration {
+	if s.End != nil {
+		return s.End.Sub(s.Start)
+	}
+
+	return now.Sub(s.Start)
+}
+
+// Timeline is the history of a workflow instance grouped into spans
+type Timeline struct {
+	Start time.Time `json:"start"`
+
+	// End is set once the workflow instance is finished
+	End *time.Time `json:"end,omitempty"`
+
+	// Spans are ordered by the time they were started
+	Spans []*Span `json:"spans"`
+}
+
+// NewTimeline groups the events of a workflow instance history into spans by their schedule event id
+func NewTimeline(h []history.Event) *Timeline {
+	t := &Timeline{
+		Spans: make([]*Span, 0),
+	}
+
+	if len(h) > 0 {
+		t.Start = h[0].Timestamp
+	}
+
+	// Spans by the kind and schedule event id of the events that started them
+	spans := map[SpanKind]map[int64]*Span{}
+
+	start := func(kind SpanKind, event history.Event, name string) *Span {
+		s := &Span{
+			Kind:            kind,
+			Status:          SpanStatusPending,
+			Name:            name,
+			ScheduleEventID: event.ScheduleEventID,
+			Start:           event.Timestamp,
+			SequenceIDs:     []int64{event.SequenceID},
+		}
+
+		t.Spans = append(t.Spans, s)
+
+		if spans[kind] == nil {
+			spans[kind] = map[int64]*Span{}
+		}
+		spans[kind][event.ScheduleEventID] = s
+
+		return s
+	}
+
+	// end finishes the span started for the given event, if there is one
+	end := func(kind SpanKind, event history.Event, status SpanStatus, err string) {
+		s, ok := spans[kind][event.ScheduleEventID]
+		if !ok {
+			return
+		}
+
+		s.SequenceIDs = append(s.SequenceIDs, event.SequenceID)
+
+		if status == SpanStatusPending {
+			return
+		}
+
+		endedAt := event.Timestamp
+		s.End = &endedAt
+		s.Status = status
+		s.Error = err
+	}
+
+	// instant records an operation that is recorded by a single event
+	instant := func(kind SpanKind, event history.Event, name string) {
+		s := start(kind, event, name)
+
+		endedAt := event.Timestamp
+		s.End = &endedAt
+		s.Status = SpanStatusCompleted
+	}
+
+	for _, event := range h {
+		switch a := event.Attributes.(type) {
+		case *history.ActivityScheduledAttributes:
+			start(SpanKindActivity, event, a.Name).Attempt = a.Attempt
+		case *history.ActivityCompletedAttributes:
+			end(SpanKindActivity, event, SpanStatusCompleted, "")
+		case *history.ActivityFailedAttributes:
+			end(SpanKindActivity, event, SpanStatusFailed, a.Reason)
+
+		case *history.TimerScheduledAttributes:
+			start(SpanKindTimer, event, "")
+		case *history.TimerFiredAttributes:
+			end(SpanKindTimer, event, SpanStatusCompleted, "")
+		case *history.TimerCanceledAttributes:
+			end(SpanKindTimer, event, SpanStatusCanceled, "")
+
+		case *history.SubWorkflowScheduledAttributes:
+			s := start(SpanKindSubWorkflow, event, a.Name)
+			if a.SubWorkflowInstance != nil {
+				s.SubWorkflowInstanceID = a.SubWorkflowInstance.InstanceID
+			}
+		case *history.SubWorkflowCancellationRequestedAttributes:
+			end(SpanKindSubWorkflow, event, SpanStatusPending, "")
+		case *history.SubWorkflowCompletedAttributes:
+			end(SpanKindSubWorkflow, event, SpanStatusCompleted, "")
+		case *history.SubWorkflowFailedAttributes:
+			end(SpanKindSubWorkflow, event, SpanStatusFailed, a.Error)
+
+		case *history.SignalReceivedAttributes:
+			instant(SpanKindSignal, event, a.Name)
+
+		case *history.SideEffectResultAttributes:
+			instant(SpanKindSideEffect, event, "")
+
+		case *history.ExecutionCompletedAttributes, *history.ExecutionTerminatedAttributes:
+			endedAt := event.Timestamp
+			t.End = &endedAt
+		}
+	}
+
+	return t
+}
@@ -0,0 +1,71 @@
+package diag
+
+import (
+	"testing"
+	"time"
+
+	"github.com/paveliak/go-workflows/internal/core"
+	"github.com/paveliak/go-workflows/internal/history"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_NewTimeline(t *testing.T) {
+	start := time.Now()
+	at := func(s int) time.Time {
+		return start.Add(time.Duration(s) * time.Second)
+	}
+
+	h := []history.Event{
+		history.NewHistoryEvent(1, at(0), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{Name: "wf"}),
+		history.NewHistoryEvent(2, at(0), history.EventType_ActivityScheduled, &history.ActivityScheduledAttributes{Name: "a"}, history.ScheduleEventID(1)),
+		history.NewHistoryEvent(3, at(0), history.EventType_SubWorkflowScheduled, &history.SubWorkflowScheduledAttributes{
+			Name:                "swf",
+			SubWorkflowInstance: core.NewWorkflowInstance("sub", ""),
+		}, history.ScheduleEventID(2)),
+		history.NewHistoryEvent(4, at(1), history.EventType_ActivityFailed, &history.ActivityFailedAttributes{Reason: "boom"}, history.ScheduleEventID(1)),
+		history.NewHistoryEvent(5, at(1), history.EventType_TimerScheduled, &history.TimerScheduledAttributes{At: at(3)}, history.ScheduleEventID(3)),
+		history.NewHistoryEvent(6, at(2), history.EventType_SignalReceived, &history.SignalReceivedAttributes{Name: "signal"}),
+		history.NewHistoryEvent(7, at(3), history.EventType_TimerFired, &history.TimerFiredAttributes{At: at(3)}, history.ScheduleEventID(3)),
+		history.NewHistoryEvent(8, at(3), history.EventType_ActivityScheduled, &history.ActivityScheduledAttributes{Name: "a", Attempt: 1}, history.ScheduleEventID(4)),
+		history.NewHistoryEvent(9, at(5), history.EventType_ActivityCompleted, &history.ActivityCompletedAttributes{}, history.ScheduleEventID(4)),
+	}
+
+	timeline := NewTimeline(h)
+	require.Equal(t, at(0), timeline.Start)
+	require.Nil(t, timeline.End)
+	require.Len(t, timeline.Spans, 5)
+
+	failed := timeline.Spans[0]
+	require.Equal(t, SpanKindActivity, failed.Kind)
+	require.Equal(t, SpanStatusFailed, failed.Status)
+	require.Equal(t, "boom", failed.Error)
+	require.Equal(t, time.Second, failed.Duration(at(10)))
+	require.Equal(t, []int64{2, 4}, failed.SequenceIDs)
+
+	subWorkflow := timeline.Spans[1]
+	require.Equal(t, SpanKindSubWorkflow, subWorkflow.Kind)
+	require.Equal(t, SpanStatusPending, subWorkflow.Status)
+	require.Equal(t, "sub", subWorkflow.SubWorkflowInstanceID)
+	require.Nil(t, subWorkflow.End)
+	require.Equal(t, 10*time.Second, subWorkflow.Duration(at(10)))
+
+	timer := timeline.Spans[2]
+	require.Equal(t, SpanKindTimer, timer.Kind)
+	require.Equal(t, SpanStatusCompleted, timer.Status)
+	require.Equal(t, 2*time.Second, timer.Duration(at(10)))
+
+	signal := timeline.Spans[3]
+	require.Equal(t, SpanKindSignal, signal.Kind)
+	require.Equal(t, "signal", signal.Name)
+	require.Equal(t, time.Duration(0), signal.Duration(at(10)))
+
+	retry := timeline.Spans[4]
+	require.Equal(t, "a", retry.Name)
+	require.Equal(t, 1, retry.Attempt)
+	require.Equal(t, SpanStatusCompleted, retry.Status)
+	require.Equal(t, 2*time.Second, retry.Duration(at(10)))
+
+	h = append(h, history.NewHistoryEvent(10, at(6), history.EventType_WorkflowExecutionFinished, &history.ExecutionCompletedAttributes{}))
+	timeline = NewTimeline(h)
+	require.Equal(t, at(6), *timeline.End)
+}
@@ -0,0 +1,62 @@
+package diag
+
+import (
+	"context"
+)
+
+// maxTreeInstances limits the number of instances returned for a sub-workflow tree
+const maxTreeInstances = 1000
+
+// getWorkflowInstanceTree returns the tree of sub-workflow instances the given instance is part of, starting at the
+// top-most parent instance that still exists.
+func getWorkflowInstanceTree(ctx context.Context, b Backend, instanceID string) (*WorkflowInstanceTree, error) {
+	instance, err := b.GetWorkflowInstance(ctx, instanceID)
+	if err != nil || instance == nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{instance.Instance.InstanceID: true}
+	for instance.Instance.SubWorkflow() && !seen[instance.Instance.ParentInstanceID] {
+		parent, err := b.GetWorkflowInstance(ctx, instance.Instance.ParentInstanceID)
+		if err != nil {
+			return nil, err
+		}
+
+		if parent == nil {
+			// Parent instance has been removed
+			break
+		}
+
+		seen[parent.Instance.InstanceID] = true
+		instance = parent
+	}
+
+	root := &WorkflowInstanceTree{WorkflowInstanceRef: instance}
+
+	// Add children breadth-first, so large trees are cut off at the deepest levels
+	queue := []*WorkflowInstanceTree{root}
+	count := 1
+
+	for len(queue) > 0 && count < maxTreeInstances {
+		node := queue[0]
+		queue = queue[1:]
+
+		children, err := b.GetWorkflowInstanceChildren(ctx, node.Instance.InstanceID)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, child := range children {
+			if count >= maxTreeInstances {
+				break
+			}
+
+			childNode := &WorkflowInstanceTree{WorkflowInstanceRef: child}
+			node.Children = append(node.Children, childNode)
+			queue = append(queue, childNode)
+			count++
+		}
+	}
+
+	return root, nil
+}
@@ -3,6 +3,7 @@ package activity
 import (
 	"context"
 
+	"github.com/paveliak/go-workflows/internal/core"
 	"github.com/paveliak/go-workflows/log"
 	"github.com/paveliak/go-workflows/workflow"
 )
@@ -10,13 +11,15 @@ import (
 type ActivityState struct {
 	ActivityID string
 	Instance   *workflow.Instance
+	Memo       core.Memo
 	Logger     log.Logger
 }
 
-func NewActivityState(activityID string, instance *workflow.Instance, logger log.Logger) *ActivityState {
+func NewActivityState(activityID string, instance *workflow.Instance, memo core.Memo, logger log.Logger) *ActivityState {
 	return &ActivityState{
 		activityID,
 		instance,
+		memo,
 		logger.With(
 			"activity_id", activityID,
 			"instance_id", instance.InstanceID,
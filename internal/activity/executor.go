@@ -54,6 +54,7 @@ func (e *Executor) ExecuteActivity(ctx context.Context, task *task.Activity) (pa
 	as := NewActivityState(
 		task.Event.ID,
 		task.WorkflowInstance,
+		task.Memo,
 		e.logger)
 	activityCtx := WithActivityState(ctx, as)
 
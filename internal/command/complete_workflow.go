@@ -76,7 +76,8 @@ func (c *CompleteWorkflowCommand) Execute(clock clock.Clock) *CommandResult {
 					clock.Now(),
 					history.EventType_SubWorkflowFailed,
 					&history.SubWorkflowFailedAttributes{
-						Error: c.Error,
+						Error:               c.Error,
+						SubWorkflowInstance: c.Instance,
 					},
 					// Ensure the message gets sent back to the parent workflow with the right schedule event ID
 					history.ScheduleEventID(c.Instance.ParentEventID),
@@ -86,7 +87,8 @@ func (c *CompleteWorkflowCommand) Execute(clock clock.Clock) *CommandResult {
 					clock.Now(),
 					history.EventType_SubWorkflowCompleted,
 					&history.SubWorkflowCompletedAttributes{
-						Result: c.Result,
+						Result:              c.Result,
+						SubWorkflowInstance: c.Instance,
 					},
 					// Ensure the message gets sent back to the parent workflow with the right schedule event ID
 					history.ScheduleEventID(c.Instance.ParentEventID),
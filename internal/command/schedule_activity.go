@@ -2,6 +2,7 @@ package command
 
 import (
 	"github.com/benbjohnson/clock"
+	"github.com/paveliak/go-workflows/internal/core"
 	"github.com/paveliak/go-workflows/internal/history"
 	"github.com/paveliak/go-workflows/internal/payload"
 )
@@ -9,21 +10,25 @@ import (
 type ScheduleActivityCommand struct {
 	command
 
-	Name   string
-	Inputs []payload.Payload
+	Name     string
+	Inputs   []payload.Payload
+	Attempt  int
+	Priority core.Priority
 }
 
 var _ Command = (*ScheduleActivityCommand)(nil)
 
-func NewScheduleActivityCommand(id int64, name string, inputs []payload.Payload) *ScheduleActivityCommand {
+func NewScheduleActivityCommand(id int64, name string, inputs []payload.Payload, attempt int, priority core.Priority) *ScheduleActivityCommand {
 	return &ScheduleActivityCommand{
 		command: command{
 			id:    id,
 			name:  "ScheduleActivity",
 			state: CommandState_Pending,
 		},
-		Name:   name,
-		Inputs: inputs,
+		Name:     name,
+		Inputs:   inputs,
+		Attempt:  attempt,
+		Priority: priority,
 	}
 }
 
@@ -36,8 +41,10 @@ func (c *ScheduleActivityCommand) Execute(clock clock.Clock) *CommandResult {
 			clock.Now(),
 			history.EventType_ActivityScheduled,
 			&history.ActivityScheduledAttributes{
-				Name:   c.Name,
-				Inputs: c.Inputs,
+				Name:     c.Name,
+				Inputs:   c.Inputs,
+				Attempt:  c.Attempt,
+				Priority: c.Priority,
 			},
 			history.ScheduleEventID(c.id))
 
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/benbjohnson/clock"
+	"github.com/paveliak/go-workflows/internal/core"
 	"github.com/paveliak/go-workflows/internal/history"
 	"github.com/paveliak/go-workflows/internal/payload"
 	"github.com/stretchr/testify/require"
@@ -36,7 +37,7 @@ func TestScheduleActivityCommand_StateTransitions(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			clock := clock.NewMock()
-			cmd := NewScheduleActivityCommand(1, "activity", []payload.Payload{})
+			cmd := NewScheduleActivityCommand(1, "activity", []payload.Payload{}, 0, core.PriorityNormal)
 
 			tt.f(t, cmd, clock)
 		})
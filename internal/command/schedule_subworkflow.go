@@ -13,15 +13,18 @@ type ScheduleSubWorkflowCommand struct {
 
 	Instance *core.WorkflowInstance
 	Metadata *core.WorkflowMetadata
+	Memo     core.Memo
 
-	Name   string
-	Inputs []payload.Payload
+	Name     string
+	Inputs   []payload.Payload
+	Priority core.Priority
 }
 
 var _ CancelableCommand = (*ScheduleSubWorkflowCommand)(nil)
 
 func NewScheduleSubWorkflowCommand(
 	id int64, parentInstance *core.WorkflowInstance, subWorkflowInstanceID, name string, inputs []payload.Payload, metadata *core.WorkflowMetadata,
+	memo core.Memo, priority core.Priority,
 ) *ScheduleSubWorkflowCommand {
 	if subWorkflowInstanceID == "" {
 		subWorkflowInstanceID = uuid.New().String()
@@ -38,9 +41,11 @@ func NewScheduleSubWorkflowCommand(
 
 		Instance: core.NewSubWorkflowInstance(subWorkflowInstanceID, uuid.NewString(), parentInstance.InstanceID, id),
 		Metadata: metadata,
+		Memo:     memo,
 
-		Name:   name,
-		Inputs: inputs,
+		Name:     name,
+		Inputs:   inputs,
+		Priority: priority,
 	}
 }
 
@@ -59,6 +64,7 @@ func (c *ScheduleSubWorkflowCommand) Execute(clock clock.Clock) *CommandResult {
 						Metadata:            c.Metadata,
 						Name:                c.Name,
 						Inputs:              c.Inputs,
+						Priority:            c.Priority,
 					},
 					history.ScheduleEventID(c.id),
 				),
@@ -74,6 +80,8 @@ func (c *ScheduleSubWorkflowCommand) Execute(clock clock.Clock) *CommandResult {
 							Name:     c.Name,
 							Inputs:   c.Inputs,
 							Metadata: c.Metadata,
+							Priority: c.Priority,
+							Memo:     c.Memo,
 						},
 						history.ScheduleEventID(0),
 					),
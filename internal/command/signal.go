@@ -47,8 +47,9 @@ func (c *SignalWorkflowCommand) Execute(clock clock.Clock) *CommandResult {
 					clock.Now(),
 					history.EventType_SignalWorkflow,
 					&history.SignalWorkflowAttributes{
-						Name: c.Name,
-						Arg:  c.Arg,
+						InstanceID: c.Instance.InstanceID,
+						Name:       c.Name,
+						Arg:        c.Arg,
 					},
 					history.ScheduleEventID(c.id),
 				),
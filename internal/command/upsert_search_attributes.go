@@ -0,0 +1,59 @@
+package command
+
+import (
+	"github.com/benbjohnson/clock"
+	"github.com/paveliak/go-workflows/internal/core"
+	"github.com/paveliak/go-workflows/internal/history"
+)
+
+type UpsertSearchAttributesCommand struct {
+	command
+
+	SearchAttributes core.SearchAttributes
+}
+
+var _ Command = (*UpsertSearchAttributesCommand)(nil)
+
+func NewUpsertSearchAttributesCommand(id int64, searchAttributes core.SearchAttributes) *UpsertSearchAttributesCommand {
+	return &UpsertSearchAttributesCommand{
+		command: command{
+			id:    id,
+			name:  "UpsertSearchAttributes",
+			state: CommandState_Pending,
+		},
+		SearchAttributes: searchAttributes,
+	}
+}
+
+func (c *UpsertSearchAttributesCommand) Execute(clock clock.Clock) *CommandResult {
+	switch c.state {
+	case CommandState_Pending:
+		// Search attributes are indexed by the backend when the event is added to the history
+		c.state = CommandState_Done
+
+		return &CommandResult{
+			Events: []history.Event{
+				history.NewPendingEvent(
+					clock.Now(),
+					history.EventType_SearchAttributesUpserted,
+					&history.SearchAttributesUpsertedAttributes{
+						SearchAttributes: c.SearchAttributes,
+					},
+					history.ScheduleEventID(c.id),
+				),
+			},
+		}
+	}
+
+	return nil
+}
+
+func (c *UpsertSearchAttributesCommand) Done() {
+	switch c.state {
+	case CommandState_Pending, CommandState_Committed:
+		c.state = CommandState_Done
+
+	default:
+		c.invalidStateTransition(CommandState_Done)
+	}
+}
@@ -0,0 +1,40 @@
+package command
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/benbjohnson/clock"
+	"github.com/paveliak/go-workflows/internal/core"
+	"github.com/paveliak/go-workflows/internal/history"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpsertSearchAttributesCommand_StateTransitions(t *testing.T) {
+	tests := []struct {
+		name string
+		f    func(t *testing.T, c *UpsertSearchAttributesCommand, clock clock.Clock)
+	}{
+		{"Execute records search attributes", func(t *testing.T, c *UpsertSearchAttributesCommand, clock clock.Clock) {
+			r := assertExecuteWithEvent(t, c, CommandState_Done, history.EventType_SearchAttributesUpserted)
+
+			a := r.Events[0].Attributes.(*history.SearchAttributesUpsertedAttributes)
+			require.Equal(t, json.RawMessage("42"), a.SearchAttributes["customer"])
+		}},
+		{"Done", func(t *testing.T, c *UpsertSearchAttributesCommand, _ clock.Clock) {
+			require.Equal(t, CommandState_Pending, c.State())
+
+			c.Done()
+			require.Equal(t, CommandState_Done, c.State())
+
+			assertExecuteNoEvent(t, c, CommandState_Done)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := clock.NewMock()
+			cmd := NewUpsertSearchAttributesCommand(1, core.SearchAttributes{"customer": json.RawMessage("42")})
+			tt.f(t, cmd, clock)
+		})
+	}
+}
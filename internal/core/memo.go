@@ -0,0 +1,37 @@
+package core
+
+// Memo holds immutable key/values set when a workflow instance is created. It's available to the workflow and its
+// activities and is returned with the instance by listing and diagnostics APIs.
+type Memo map[string]string
+
+// Clone returns a copy of the memo that can be modified without affecting the original
+func (m Memo) Clone() Memo {
+	if m == nil {
+		return nil
+	}
+
+	c := make(Memo, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+
+	return c
+}
+
+// Merge returns a copy of the memo with the given values added, overwriting existing keys
+func (m Memo) Merge(values map[string]string) Memo {
+	if len(m) == 0 && len(values) == 0 {
+		return nil
+	}
+
+	c := make(Memo, len(m)+len(values))
+	for k, v := range m {
+		c[k] = v
+	}
+
+	for k, v := range values {
+		c[k] = v
+	}
+
+	return c
+}
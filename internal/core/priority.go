@@ -0,0 +1,80 @@
+package core
+
+import "sync"
+
+// Priority determines the order in which tasks are dequeued. Tasks with a higher priority are dequeued first.
+type Priority int
+
+const (
+	PriorityLowest  Priority = -2
+	PriorityLow     Priority = -1
+	PriorityNormal  Priority = 0
+	PriorityHigh    Priority = 1
+	PriorityHighest Priority = 2
+)
+
+// Priorities returns all supported priority levels, from highest to lowest
+func Priorities() []Priority {
+	return []Priority{PriorityHighest, PriorityHigh, PriorityNormal, PriorityLow, PriorityLowest}
+}
+
+// Clamp limits the priority to the supported levels
+func (p Priority) Clamp() Priority {
+	if p > PriorityHighest {
+		return PriorityHighest
+	}
+
+	if p < PriorityLowest {
+		return PriorityLowest
+	}
+
+	return p
+}
+
+// PriorityScheduler determines the order in which backends check priority levels when dequeueing tasks. Usually
+// that is from highest to lowest, but every interval-th dequeue prefers a lower level, in rotation, so that tasks
+// with a low priority are not starved by a constant stream of tasks with a higher priority.
+type PriorityScheduler struct {
+	interval int
+
+	mu sync.Mutex
+	n  int
+}
+
+// NewPriorityScheduler returns a scheduler that prefers lower priority levels every interval-th dequeue. If
+// interval is 0, priority levels are always checked from highest to lowest.
+func NewPriorityScheduler(interval int) *PriorityScheduler {
+	return &PriorityScheduler{
+		interval: interval,
+	}
+}
+
+// Next returns the priority levels in the order they should be checked for the next dequeue
+func (s *PriorityScheduler) Next() []Priority {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	levels := Priorities()
+
+	if s.interval <= 0 {
+		return levels
+	}
+
+	s.n++
+	if s.n%s.interval != 0 {
+		return levels
+	}
+
+	// Rotate through the lower levels, the highest level is preferred on all other dequeues anyway
+	turn := (s.n/s.interval-1)%(len(levels)-1) + 1
+	preferred := levels[turn]
+
+	order := []Priority{preferred}
+	for _, l := range levels {
+		if l != preferred {
+			order = append(order, l)
+		}
+	}
+
+	return order
+}
@@ -0,0 +1,41 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Priority_Clamp(t *testing.T) {
+	require.Equal(t, PriorityHighest, Priority(10).Clamp())
+	require.Equal(t, PriorityLowest, Priority(-10).Clamp())
+	require.Equal(t, PriorityLow, PriorityLow.Clamp())
+}
+
+func Test_PriorityScheduler_Next(t *testing.T) {
+	s := NewPriorityScheduler(2)
+
+	preferred := []Priority{}
+	for i := 0; i < 10; i++ {
+		order := s.Next()
+		require.Len(t, order, len(Priorities()))
+
+		preferred = append(preferred, order[0])
+	}
+
+	require.Equal(t, []Priority{
+		PriorityHighest, PriorityHigh,
+		PriorityHighest, PriorityNormal,
+		PriorityHighest, PriorityLow,
+		PriorityHighest, PriorityLowest,
+		PriorityHighest, PriorityHigh,
+	}, preferred)
+}
+
+func Test_PriorityScheduler_Disabled(t *testing.T) {
+	s := NewPriorityScheduler(0)
+
+	for i := 0; i < 10; i++ {
+		require.Equal(t, Priorities(), s.Next())
+	}
+}
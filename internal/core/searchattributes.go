@@ -0,0 +1,49 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// SearchAttributes are indexed values of a workflow instance used to find it. Values are stored as JSON, instances
+// match a search attribute filter if the JSON encoding of the filter value is equal to the stored value.
+type SearchAttributes map[string]json.RawMessage
+
+// NewSearchAttributes encodes the given values as search attributes
+func NewSearchAttributes(values map[string]interface{}) (SearchAttributes, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	sa := make(SearchAttributes, len(values))
+	for name, value := range values {
+		v, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("encoding search attribute %v: %w", name, err)
+		}
+
+		sa[name] = v
+	}
+
+	return sa, nil
+}
+
+// Values decodes the search attributes. Numbers are decoded as float64.
+func (sa SearchAttributes) Values() map[string]interface{} {
+	if len(sa) == 0 {
+		return nil
+	}
+
+	values := make(map[string]interface{}, len(sa))
+	for name, v := range sa {
+		var value interface{}
+		if err := json.Unmarshal(v, &value); err != nil {
+			// Keep the encoded value, this should not happen for values encoded by NewSearchAttributes
+			value = string(v)
+		}
+
+		values[name] = value
+	}
+
+	return values
+}
@@ -4,5 +4,73 @@ type WorkflowInstanceState int
 
 const (
 	WorkflowInstanceStateActive WorkflowInstanceState = iota
+
+	// WorkflowInstanceStateFinished is the state of instances that finished before their outcome was recorded. New
+	// instances finish as WorkflowInstanceStateCompleted, WorkflowInstanceStateFailed,
+	// WorkflowInstanceStateCanceled, or WorkflowInstanceStateTerminated.
 	WorkflowInstanceStateFinished
+
+	// WorkflowInstanceStateSuspended instances do not receive workflow tasks until they are resumed. New events are
+	// kept as pending events in the meantime.
+	WorkflowInstanceStateSuspended
+
+	// WorkflowInstanceStateCompleted instances returned without an error
+	WorkflowInstanceStateCompleted
+
+	// WorkflowInstanceStateFailed instances returned an error
+	WorkflowInstanceStateFailed
+
+	// WorkflowInstanceStateCanceled instances were canceled and returned an error
+	WorkflowInstanceStateCanceled
+
+	// WorkflowInstanceStateTerminated instances were stopped without running any more workflow code
+	WorkflowInstanceStateTerminated
 )
+
+// WorkflowInstanceStates returns all workflow instance states
+func WorkflowInstanceStates() []WorkflowInstanceState {
+	return []WorkflowInstanceState{
+		WorkflowInstanceStateActive,
+		WorkflowInstanceStateFinished,
+		WorkflowInstanceStateSuspended,
+		WorkflowInstanceStateCompleted,
+		WorkflowInstanceStateFailed,
+		WorkflowInstanceStateCanceled,
+		WorkflowInstanceStateTerminated,
+	}
+}
+
+// Finished returns true if the instance will not execute again
+func (s WorkflowInstanceState) Finished() bool {
+	switch s {
+	case WorkflowInstanceStateFinished,
+		WorkflowInstanceStateCompleted,
+		WorkflowInstanceStateFailed,
+		WorkflowInstanceStateCanceled,
+		WorkflowInstanceStateTerminated:
+		return true
+	}
+
+	return false
+}
+
+func (s WorkflowInstanceState) String() string {
+	switch s {
+	case WorkflowInstanceStateActive:
+		return "Active"
+	case WorkflowInstanceStateFinished:
+		return "Finished"
+	case WorkflowInstanceStateSuspended:
+		return "Suspended"
+	case WorkflowInstanceStateCompleted:
+		return "Completed"
+	case WorkflowInstanceStateFailed:
+		return "Failed"
+	case WorkflowInstanceStateCanceled:
+		return "Canceled"
+	case WorkflowInstanceStateTerminated:
+		return "Terminated"
+	}
+
+	return "Unknown"
+}
@@ -6,7 +6,13 @@ import (
 	"strings"
 )
 
+// Name returns the name of a function. If i is a string, it's used as the name, which allows referring to workflows
+// and activities by name.
 func Name(i interface{}) string {
+	if name, ok := i.(string); ok {
+		return name
+	}
+
 	// Adapted from https://stackoverflow.com/a/7053871
 	fnName := runtime.FuncForPC(reflect.ValueOf(i).Pointer()).Name()
 
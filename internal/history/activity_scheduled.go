@@ -10,5 +10,10 @@ type ActivityScheduledAttributes struct {
 
 	Inputs []payload.Payload `json:"inputs,omitempty"`
 
+	// Attempt is the number of previous attempts to execute the activity, it's 0 for the first attempt
+	Attempt int `json:"attempt,omitempty"`
+
 	Metadata core.WorkflowMetadata `json:"metadata,omitempty"`
+
+	Priority core.Priority `json:"priority,omitempty"`
 }
@@ -1,6 +1,7 @@
 package history
 
 import (
+	"fmt"
 	"strconv"
 	"time"
 
@@ -16,7 +17,7 @@ const (
 	EventType_WorkflowExecutionStarted
 	// Workflow has finished
 	EventType_WorkflowExecutionFinished
-	// Workflow has been terminated (not yet used)
+	// Workflow has been terminated
 	EventType_WorkflowExecutionTerminated
 	// Workflow has been canceled
 	EventType_WorkflowExecutionCanceled
@@ -56,6 +57,12 @@ const (
 
 	// Signal other workflow
 	EventType_SignalWorkflow
+
+	// Workflow instance has been reset to an earlier point in its history and continues as a new execution
+	EventType_WorkflowExecutionReset
+
+	// Workflow has updated its search attributes
+	EventType_SearchAttributesUpserted
 )
 
 func (et EventType) String() string {
@@ -104,11 +111,28 @@ func (et EventType) String() string {
 	case EventType_SignalWorkflow:
 		return "WorkflowSignalRequested"
 
+	case EventType_WorkflowExecutionReset:
+		return "WorkflowExecutionReset"
+
+	case EventType_SearchAttributesUpserted:
+		return "SearchAttributesUpserted"
+
 	default:
 		return "Unknown"
 	}
 }
 
+// ParseEventType returns the event type for the given name as returned by EventType.String
+func ParseEventType(s string) (EventType, error) {
+	for et := EventType(1); et.String() != "Unknown"; et++ {
+		if et.String() == s {
+			return et, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown event type: %s", s)
+}
+
 type Event struct {
 	// ID is a unique identifier for this event
 	ID string `json:"id,omitempty"`
@@ -173,3 +197,7 @@ func NewPendingEvent(timestamp time.Time, eventType EventType, attributes interf
 func NewWorkflowCancellationEvent(timestamp time.Time) Event {
 	return NewPendingEvent(timestamp, EventType_WorkflowExecutionCanceled, &ExecutionCanceledAttributes{})
 }
+
+func NewWorkflowTerminationEvent(timestamp time.Time, reason string) Event {
+	return NewPendingEvent(timestamp, EventType_WorkflowExecutionTerminated, &ExecutionTerminatedAttributes{Reason: reason})
+}
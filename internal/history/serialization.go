@@ -40,6 +40,10 @@ func DeserializeAttributes(eventType EventType, attributes []byte) (attr interfa
 		attr = &ExecutionCompletedAttributes{}
 	case EventType_WorkflowExecutionCanceled:
 		attr = &ExecutionCanceledAttributes{}
+	case EventType_WorkflowExecutionTerminated:
+		attr = &ExecutionTerminatedAttributes{}
+	case EventType_WorkflowExecutionReset:
+		attr = &ExecutionResetAttributes{}
 
 	case EventType_WorkflowTaskStarted:
 		attr = &WorkflowTaskStartedAttributes{}
@@ -76,6 +80,9 @@ func DeserializeAttributes(eventType EventType, attributes []byte) (attr interfa
 	case EventType_SignalWorkflow:
 		attr = &SignalWorkflowAttributes{}
 
+	case EventType_SearchAttributesUpserted:
+		attr = &SearchAttributesUpsertedAttributes{}
+
 	default:
 		return nil, errors.New("unknown event type when deserializing attributes")
 	}
@@ -3,6 +3,9 @@ package history
 import "github.com/paveliak/go-workflows/internal/payload"
 
 type SignalWorkflowAttributes struct {
+	// InstanceID is the id of the workflow instance that is signaled
+	InstanceID string `json:"instance_id,omitempty"`
+
 	Name string          `json:"name,omitempty"`
 	Arg  payload.Payload `json:"arg,omitempty"`
 }
@@ -1,7 +1,13 @@
 package history
 
-import "github.com/paveliak/go-workflows/internal/payload"
+import (
+	"github.com/paveliak/go-workflows/internal/core"
+	"github.com/paveliak/go-workflows/internal/payload"
+)
 
 type SubWorkflowCompletedAttributes struct {
 	Result payload.Payload `json:"result,omitempty"`
+
+	// SubWorkflowInstance is the sub-workflow instance that sent the result
+	SubWorkflowInstance *core.WorkflowInstance `json:"sub_workflow_instance,omitempty"`
 }
@@ -1,5 +1,10 @@
 package history
 
+import "github.com/paveliak/go-workflows/internal/core"
+
 type SubWorkflowFailedAttributes struct {
 	Error string `json:"error,omitempty"`
+
+	// SubWorkflowInstance is the sub-workflow instance that sent the error
+	SubWorkflowInstance *core.WorkflowInstance `json:"sub_workflow_instance,omitempty"`
 }
@@ -13,4 +13,6 @@ type SubWorkflowScheduledAttributes struct {
 	Inputs []payload.Payload `json:"inputs,omitempty"`
 
 	Metadata *core.WorkflowMetadata `json:"metadata,omitempty"`
+
+	Priority core.Priority `json:"priority,omitempty"`
 }
@@ -0,0 +1,12 @@
+package history
+
+type ExecutionResetAttributes struct {
+	// Reason is the reason given when the instance was reset
+	Reason string `json:"reason,omitempty"`
+
+	// PreviousExecutionID is the execution id of the instance before the reset
+	PreviousExecutionID string `json:"previous_execution_id,omitempty"`
+
+	// ToSequenceID is the sequence id of the WorkflowTaskStarted event the history was truncated at
+	ToSequenceID int64 `json:"to_sequence_id,omitempty"`
+}
@@ -11,4 +11,22 @@ type ExecutionStartedAttributes struct {
 	Metadata *core.WorkflowMetadata `json:"metadata,omitempty"`
 
 	Inputs []payload.Payload `json:"inputs,omitempty"`
+
+	Priority core.Priority `json:"priority,omitempty"`
+
+	SearchAttributes core.SearchAttributes `json:"search_attributes,omitempty"`
+
+	Memo core.Memo `json:"memo,omitempty"`
+}
+
+// StartedAttributes returns the attributes of the WorkflowExecutionStarted event in the history h, or nil if there is
+// none
+func StartedAttributes(h []Event) *ExecutionStartedAttributes {
+	for _, event := range h {
+		if a, ok := event.Attributes.(*ExecutionStartedAttributes); ok {
+			return a
+		}
+	}
+
+	return nil
 }
@@ -7,13 +7,25 @@ const (
 	WorkflowInstanceCreated  = Prefix + "workflow.created"
 	WorkflowInstanceFinished = Prefix + "workflow.finished"
 
+	// Workflow instances suspended after their workflow task exceeded the maximum number of attempts
+	WorkflowInstanceSuspended = Prefix + "workflow.suspended"
+
 	WorkflowTaskScheduled = Prefix + "workflow.task.scheduled"
 	WorkflowTaskProcessed = Prefix + "workflow.task.processed"
 	WorkflowTaskDelay     = Prefix + "workflow.task.time_in_queue"
+	WorkflowTaskDeadlock  = Prefix + "workflow.task.deadlock"
+	WorkflowTaskPanic     = Prefix + "workflow.task.panic"
+
+	// Workflow tasks picked up by a worker with (hit) or without (miss) a cached executor for the instance
+	WorkflowTaskStickyHit  = Prefix + "workflow.task.sticky.hit"
+	WorkflowTaskStickyMiss = Prefix + "workflow.task.sticky.miss"
 
 	WorkflowInstanceCacheSize     = Prefix + "workflow.cache.size"
 	WorkflowInstanceCacheEviction = Prefix + "workflow.cache.eviction"
 
+	// Workers
+	WorkerError = Prefix + "worker.error"
+
 	// Activities
 	ActivityTaskScheduled = Prefix + "activity.task.scheduled"
 	ActivityTaskProcessed = Prefix + "activity.task.processed"
@@ -30,5 +42,11 @@ const (
 
 	SubWorkflow = "subworkflow"
 
+	// Final state of a workflow instance
+	State = "state"
+
 	ActivityName = "activity"
+
+	// Worker operation that failed
+	Operation = "operation"
 )
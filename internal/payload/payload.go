@@ -1,3 +1,11 @@
 package payload
 
+import "reflect"
+
 type Payload []byte
+
+// Type and SliceType are the types of payload fields in the attributes of history events
+var (
+	Type      = reflect.TypeOf(Payload{})
+	SliceType = reflect.TypeOf([]Payload{})
+)
@@ -0,0 +1,39 @@
+package ratelimit
+
+import (
+	"math"
+	"time"
+)
+
+// Bucket is the state of a token bucket. It is refilled with rate tokens per second, up to burst tokens.
+type Bucket struct {
+	Tokens    float64
+	UpdatedAt time.Time
+}
+
+// NewBucket returns a full bucket
+func NewBucket(now time.Time, burst int) Bucket {
+	return Bucket{Tokens: float64(burst), UpdatedAt: now}
+}
+
+// Burst returns the default burst for the given rate, which allows one second worth of tokens
+func Burst(rate float64) int {
+	return int(math.Max(1, math.Ceil(rate)))
+}
+
+// Take refills the bucket for the time passed since it was last updated and takes a token. If the bucket is
+// empty, no token is taken and the time to wait until the next token is available is returned.
+func (b *Bucket) Take(now time.Time, rate float64, burst int) time.Duration {
+	if elapsed := now.Sub(b.UpdatedAt); elapsed > 0 {
+		b.Tokens = math.Min(float64(burst), b.Tokens+elapsed.Seconds()*rate)
+	}
+
+	b.UpdatedAt = now
+
+	if b.Tokens >= 1 {
+		b.Tokens--
+		return 0
+	}
+
+	return time.Duration((1 - b.Tokens) / rate * float64(time.Second))
+}
@@ -0,0 +1,34 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Bucket_Take(t *testing.T) {
+	now := time.Now()
+	b := NewBucket(now, 2)
+
+	require.Zero(t, b.Take(now, 2, 2))
+	require.Zero(t, b.Take(now, 2, 2))
+
+	// Bucket is empty, next token is available after half a second
+	require.Equal(t, 500*time.Millisecond, b.Take(now, 2, 2))
+
+	now = now.Add(500 * time.Millisecond)
+	require.Zero(t, b.Take(now, 2, 2))
+
+	// Refill is capped at burst
+	now = now.Add(time.Hour)
+	require.Zero(t, b.Take(now, 2, 2))
+	require.Zero(t, b.Take(now, 2, 2))
+	require.NotZero(t, b.Take(now, 2, 2))
+}
+
+func Test_Burst(t *testing.T) {
+	require.Equal(t, 1, Burst(0.1))
+	require.Equal(t, 1, Burst(1))
+	require.Equal(t, 3, Burst(2.5))
+}
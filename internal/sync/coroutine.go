@@ -1,10 +1,11 @@
 package sync
 
 import (
-	"fmt"
 	"io"
 	"log"
 	"runtime"
+	"runtime/debug"
+	"strings"
 	"sync/atomic"
 	"time"
 )
@@ -26,6 +27,10 @@ type Coroutine interface {
 	Finished() bool
 	Progress() bool
 
+	// Stack returns the stack of the location the coroutine is blocked at. It returns an empty string if the
+	// coroutine is not blocked.
+	Stack() string
+
 	Error() error
 
 	SetScheduler(s Scheduler)
@@ -46,6 +51,7 @@ type coState struct {
 	finished   atomic.Value // coroutine finished executing
 	shouldExit atomic.Value // coroutine should exit
 	progress   atomic.Value // did the coroutine make progress since last yield?
+	deadlocked atomic.Value // coroutine did not yield within the deadlock detection timeout
 
 	err error
 
@@ -53,18 +59,32 @@ type coState struct {
 
 	deadlockDetection time.Duration
 
+	// goroutineID is the id of the goroutine executing the coroutine, used to retrieve its stack when blocked or
+	// deadlocked
+	goroutineID string
+
 	scheduler Scheduler
 }
 
 func NewCoroutine(ctx Context, fn func(ctx Context) error) Coroutine {
+	return newCoroutine(ctx, fn, DeadlockDetection)
+}
+
+func newCoroutine(ctx Context, fn func(ctx Context) error, deadlockDetection time.Duration) Coroutine {
 	s := newState()
+	s.deadlockDetection = deadlockDetection
 	ctx = withCoState(ctx, s)
 
 	go func() {
+		s.goroutineID = currentGoroutineID()
+
 		defer s.finish() // Ensure we always mark the coroutine as finished
 		defer func() {
 			if r := recover(); r != nil {
-				s.err = fmt.Errorf("panic: %v", r)
+				s.err = &PanicError{
+					Value: r,
+					Stack: string(debug.Stack()),
+				}
 			}
 		}()
 
@@ -127,6 +147,40 @@ func (s *coState) Progress() bool {
 	return ok && v
 }
 
+// Stack is only used for stack trace queries, the stack is retrieved on demand instead of capturing it on every
+// yield.
+func (s *coState) Stack() string {
+	if !s.Blocked() || s.Finished() {
+		return ""
+	}
+
+	stack := goroutineStack(s.goroutineID)
+	if stack == "" {
+		return ""
+	}
+
+	// The first line is the goroutine header, followed by a function and a file line for every frame
+	lines := strings.Split(strings.TrimSpace(stack), "\n")[1:]
+
+	var sb strings.Builder
+	for i := 0; i < len(lines); i += 2 {
+		// Skip goroutine bookkeeping and the coroutine's yield
+		if strings.HasPrefix(lines[i], "runtime.") || strings.Contains(lines[i], "internal/sync.(*coState).") {
+			continue
+		}
+
+		sb.WriteString(lines[i])
+		sb.WriteString("\n")
+
+		if i+1 < len(lines) {
+			sb.WriteString(lines[i+1])
+			sb.WriteString("\n")
+		}
+	}
+
+	return sb.String()
+}
+
 func (s *coState) Yield() {
 	s.yield(true)
 }
@@ -177,7 +231,12 @@ func (s *coState) Execute() {
 	case <-s.blocking:
 		s.logger.Println("execute: blocked")
 	case <-t.C:
-		panic("coroutine timed out")
+		s.deadlocked.Store(true)
+
+		panic(&DeadlockError{
+			Timeout: s.deadlockDetection,
+			Stack:   goroutineStack(s.goroutineID),
+		})
 	}
 }
 
@@ -188,6 +247,11 @@ func (s *coState) Exit() {
 		return
 	}
 
+	if v, ok := s.deadlocked.Load().(bool); ok && v {
+		// The coroutine is stuck outside of our control, there is no way to stop it
+		return
+	}
+
 	s.shouldExit.Store(true)
 	s.Execute()
 }
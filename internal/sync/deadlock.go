@@ -0,0 +1,59 @@
+package sync
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// DeadlockError is returned when a coroutine does not yield within the deadlock timeout. This usually happens
+// when workflow code blocks on a native Go primitive like a mutex or channel, or loops without yielding.
+type DeadlockError struct {
+	// Timeout is the deadlock timeout that was exceeded
+	Timeout time.Duration
+
+	// Stack is the stack of the deadlocked coroutine at the time the deadlock was detected
+	Stack string
+}
+
+func (e *DeadlockError) Error() string {
+	return fmt.Sprintf("potential deadlock detected, coroutine did not yield within %v:\n%s", e.Timeout, e.Stack)
+}
+
+func currentGoroutineID() string {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+
+	// The stack starts with "goroutine <id> [running]:"
+	fields := bytes.Fields(buf)
+	if len(fields) < 2 {
+		return ""
+	}
+
+	return string(fields[1])
+}
+
+// goroutineStack returns the stack of the goroutine with the given id
+func goroutineStack(id string) string {
+	buf := make([]byte, 64*1024)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+
+		buf = make([]byte, len(buf)*2)
+	}
+
+	prefix := "goroutine " + id + " "
+	for _, stack := range strings.Split(string(buf), "\n\n") {
+		if strings.HasPrefix(stack, prefix) {
+			return stack
+		}
+	}
+
+	return ""
+}
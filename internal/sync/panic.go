@@ -0,0 +1,16 @@
+package sync
+
+import "fmt"
+
+// PanicError is returned when a coroutine panics. The panic is recovered so that it does not crash the worker.
+type PanicError struct {
+	// Value is the value the coroutine panicked with
+	Value interface{}
+
+	// Stack is the stack of the coroutine at the time of the panic
+	Stack string
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
@@ -1,5 +1,11 @@
 package sync
 
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
 type Scheduler interface {
 	// Starts a new co-routine and tracks it in this scheduler
 	NewCoroutine(ctx Context, fn func(Context) error)
@@ -9,26 +15,59 @@ type Scheduler interface {
 
 	RunningCoroutines() int
 
+	// StackTrace returns the stacks of all running coroutines
+	StackTrace() string
+
 	Exit()
 }
 
 type scheduler struct {
 	coroutines []Coroutine
+
+	deadlockTimeout time.Duration
+}
+
+type SchedulerOption func(*scheduler)
+
+// WithDeadlockTimeout sets the time a coroutine can run without yielding, before it's considered deadlocked
+func WithDeadlockTimeout(timeout time.Duration) SchedulerOption {
+	return func(s *scheduler) {
+		s.deadlockTimeout = timeout
+	}
 }
 
-func NewScheduler() Scheduler {
-	return &scheduler{
-		coroutines: make([]Coroutine, 0),
+func NewScheduler(opts ...SchedulerOption) Scheduler {
+	s := &scheduler{
+		coroutines:      make([]Coroutine, 0),
+		deadlockTimeout: DeadlockDetection,
+	}
+
+	for _, opt := range opts {
+		opt(s)
 	}
+
+	return s
 }
 
 func (s *scheduler) NewCoroutine(ctx Context, fn func(Context) error) {
-	c := NewCoroutine(ctx, fn)
+	c := newCoroutine(ctx, fn, s.deadlockTimeout)
 	s.coroutines = append(s.coroutines, c)
 	c.SetScheduler(s)
 }
 
-func (s *scheduler) Execute() error {
+func (s *scheduler) Execute() (err error) {
+	// Coroutines that do not yield in time panic with a DeadlockError
+	defer func() {
+		if r := recover(); r != nil {
+			derr, ok := r.(*DeadlockError)
+			if !ok {
+				panic(r)
+			}
+
+			err = derr
+		}
+	}()
+
 	allBlocked := false
 	for !allBlocked {
 		allBlocked = true
@@ -64,6 +103,25 @@ func (s *scheduler) RunningCoroutines() int {
 	return len(s.coroutines)
 }
 
+func (s *scheduler) StackTrace() string {
+	var sb strings.Builder
+
+	for i, c := range s.coroutines {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+
+		state := "running"
+		if c.Blocked() {
+			state = "blocked"
+		}
+
+		fmt.Fprintf(&sb, "coroutine %d [%s]:\n%s", i, state, c.Stack())
+	}
+
+	return sb.String()
+}
+
 func (s *scheduler) Exit() {
 	for _, c := range s.coroutines {
 		c.Exit()
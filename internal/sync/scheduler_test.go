@@ -170,4 +170,62 @@ func Test_Scheduler_Panic(t *testing.T) {
 	require.NotNil(t, err)
 	require.Equal(t, "panic: something went wrong", err.Error())
 	require.Equal(t, 0, s.RunningCoroutines())
+
+	var perr *PanicError
+	require.ErrorAs(t, err, &perr)
+	require.Equal(t, "something went wrong", perr.Value)
+	require.Contains(t, perr.Stack, "internal/sync.Test_Scheduler_Panic")
+}
+
+func Test_Scheduler_StackTrace(t *testing.T) {
+	s := NewScheduler()
+
+	ctx := Background()
+	s.NewCoroutine(ctx, func(ctx Context) error {
+		c := NewChannel[int]()
+
+		Go(ctx, func(ctx Context) {
+			c.Send(ctx, 42)
+		})
+
+		getCoState(ctx).Yield()
+
+		return nil
+	})
+
+	require.NoError(t, s.Execute())
+	require.Equal(t, 2, s.RunningCoroutines())
+
+	st := s.StackTrace()
+	require.Contains(t, st, "coroutine 0 [blocked]:")
+	require.Contains(t, st, "coroutine 1 [blocked]:")
+	require.Contains(t, st, "internal/sync.Test_Scheduler_StackTrace.func1")
+	require.Contains(t, st, "internal/sync.(*channel[...]).Send")
+
+	s.Exit()
+}
+
+func Test_Scheduler_DeadlockError(t *testing.T) {
+	s := NewScheduler(WithDeadlockTimeout(time.Millisecond * 10))
+
+	release := make(chan struct{})
+	defer close(release)
+
+	ctx := Background()
+	s.NewCoroutine(ctx, func(ctx Context) error {
+		// Block outside of the scheduler's control
+		<-release
+
+		return nil
+	})
+
+	err := s.Execute()
+
+	var derr *DeadlockError
+	require.ErrorAs(t, err, &derr)
+	require.Equal(t, time.Millisecond*10, derr.Timeout)
+	require.Contains(t, derr.Stack, "internal/sync.Test_Scheduler_DeadlockError")
+
+	// Exiting does not wait for the deadlocked coroutine
+	s.Exit()
 }
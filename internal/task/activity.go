@@ -12,5 +12,8 @@ type Activity struct {
 
 	Metadata *core.WorkflowMetadata
 
+	// Memo of the workflow instance that scheduled the activity
+	Memo core.Memo
+
 	Event history.Event
 }
@@ -19,6 +19,10 @@ type Workflow struct {
 	// LastSequenceID is the sequence ID of the newest event in the workflow instances's history
 	LastSequenceID int64
 
+	// Attempt is the number of times a workflow task has been handed out for the new events, including this one. It is
+	// reset when a workflow task is completed.
+	Attempt int
+
 	// NewEvents are new events since the last task execution
 	NewEvents []history.Event
 
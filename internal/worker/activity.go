@@ -16,6 +16,10 @@ import (
 	"github.com/paveliak/go-workflows/metrics"
 )
 
+// activityRateLimitMaxWait is how long an activity task waits for the rate limit of its activity, before it's
+// released to be picked up again later
+const activityRateLimitMaxWait = time.Second
+
 type ActivityWorker struct {
 	backend backend.Backend
 
@@ -24,12 +28,44 @@ type ActivityWorker struct {
 	activityTaskQueue    chan *task.Activity
 	activityTaskExecutor activity.Executor
 
+	// wg tracks tasks that have been dequeued but not yet handled
 	wg *sync.WaitGroup
 
+	pollersWg     *sync.WaitGroup
+	pollersCancel context.CancelFunc
+	shutdownOnce  sync.Once
+
+	// tasksCtx is the parent context of all activity executions, it is canceled on a hard stop
+	tasksCtx    context.Context
+	tasksCancel context.CancelFunc
+
+	// inFlight holds the tasks being executed. After a hard stop, the remaining ones are released by Shutdown.
+	inFlightMu sync.Mutex
+	inFlight   map[string]*task.Activity
+	stopped    bool
+
+	// rateLimiter limits the rate of all activity tasks, activityRateLimiters the rate of individual activities
+	rateLimiter          rateLimiter
+	activityRateLimiters map[string]rateLimiter
+
 	clock clock.Clock
 }
 
 func NewActivityWorker(backend backend.Backend, registry *workflow.Registry, clock clock.Clock, options *Options) *ActivityWorker {
+	tasksCtx, tasksCancel := context.WithCancel(context.Background())
+
+	var limiter rateLimiter
+	if options.ActivityTasksPerSecond > 0 {
+		limiter = newRateLimiter(backend, options, clock, "activities", options.ActivityTasksPerSecond)
+	}
+
+	activityLimiters := make(map[string]rateLimiter, len(options.ActivityRateLimits))
+	for name, rate := range options.ActivityRateLimits {
+		if rate > 0 {
+			activityLimiters[name] = newRateLimiter(backend, options, clock, "activity:"+name, rate)
+		}
+	}
+
 	return &ActivityWorker{
 		backend: backend,
 
@@ -40,29 +76,77 @@ func NewActivityWorker(backend backend.Backend, registry *workflow.Registry, clo
 
 		wg: &sync.WaitGroup{},
 
+		pollersWg:     &sync.WaitGroup{},
+		pollersCancel: func() {},
+
+		tasksCtx:    tasksCtx,
+		tasksCancel: tasksCancel,
+
+		inFlight: make(map[string]*task.Activity),
+
+		rateLimiter:          limiter,
+		activityRateLimiters: activityLimiters,
+
 		clock: clock,
 	}
 }
 
 func (aw *ActivityWorker) Start(ctx context.Context) error {
+	ctx, aw.pollersCancel = context.WithCancel(ctx)
+
 	for i := 0; i <= aw.options.ActivityPollers; i++ {
+		aw.pollersWg.Add(1)
 		go aw.runPoll(ctx)
 	}
 
-	go aw.runDispatcher(context.Background())
+	go aw.runDispatcher()
 
 	return nil
 }
 
-func (aw *ActivityWorker) WaitForCompletion() error {
-	close(aw.activityTaskQueue)
+// Shutdown stops polling for new activity tasks and waits for in-flight tasks to finish. If ctx is done before
+// that, running activities are canceled and their tasks are released in the backend so that other workers can
+// pick them up immediately.
+func (aw *ActivityWorker) Shutdown(ctx context.Context) error {
+	aw.shutdownOnce.Do(func() {
+		// Pollers might still be sending on the task queue, wait for them to stop before closing it
+		aw.pollersCancel()
+		aw.pollersWg.Wait()
 
-	aw.wg.Wait()
+		close(aw.activityTaskQueue)
+	})
 
-	return nil
+	done := make(chan struct{})
+	go func() {
+		aw.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	// Take over the in-flight tasks before canceling them, tasks that are settled concurrently are skipped
+	tasks := aw.stopTasks()
+
+	aw.tasksCancel()
+
+	for _, t := range tasks {
+		aw.releaseTask(t, 0)
+	}
+
+	return ctx.Err()
+}
+
+func (aw *ActivityWorker) WaitForCompletion() error {
+	return aw.Shutdown(context.Background())
 }
 
 func (aw *ActivityWorker) runPoll(ctx context.Context) {
+	defer aw.pollersWg.Done()
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -75,32 +159,57 @@ func (aw *ActivityWorker) runPoll(ctx context.Context) {
 			}
 
 			if task != nil {
-				aw.activityTaskQueue <- task
+				aw.wg.Add(1)
+
+				select {
+				case aw.activityTaskQueue <- task:
+				case <-ctx.Done():
+					// Worker is shutting down, let another worker pick up the task
+					aw.releaseTask(task, 0)
+					aw.wg.Done()
+				}
 			}
 		}
 	}
 }
 
-func (aw *ActivityWorker) runDispatcher(ctx context.Context) {
+func (aw *ActivityWorker) runDispatcher() {
 	var sem chan struct{}
 	if aw.options.MaxParallelActivityTasks > 0 {
 		sem = make(chan struct{}, aw.options.MaxParallelActivityTasks)
 	}
 
 	for task := range aw.activityTaskQueue {
+		if aw.rateLimiter != nil {
+			// Pollers block while the dispatcher waits, so no further tasks are locked in the meantime
+			if err := aw.rateLimiter.Wait(aw.tasksCtx); err != nil {
+				aw.releaseTask(task, 0)
+				aw.wg.Done()
+				continue
+			}
+		}
+
 		if sem != nil {
-			sem <- struct{}{}
+			select {
+			case sem <- struct{}{}:
+			case <-aw.tasksCtx.Done():
+				aw.releaseTask(task, 0)
+				aw.wg.Done()
+				continue
+			}
 		}
 
 		task := task
 
-		aw.wg.Add(1)
+		aw.trackTask(task)
+
 		go func() {
 			defer aw.wg.Done()
+			defer aw.claimTask(task)
 
-			// Create new context to allow activities to complete when root context is canceled
-			taskCtx := context.Background()
-			aw.handleTask(taskCtx, task)
+			// Activities keep running when the context passed to Start is canceled, they are only canceled
+			// on a hard stop during Shutdown
+			aw.handleTask(aw.tasksCtx, task)
 
 			if sem != nil {
 				<-sem
@@ -109,6 +218,58 @@ func (aw *ActivityWorker) runDispatcher(ctx context.Context) {
 	}
 }
 
+func (aw *ActivityWorker) trackTask(t *task.Activity) {
+	aw.inFlightMu.Lock()
+	defer aw.inFlightMu.Unlock()
+
+	aw.inFlight[t.ID] = t
+}
+
+// claimTask has to be called before a task is settled in the backend. It returns false if the task has been taken
+// over by a hard stop, Shutdown releases it then.
+func (aw *ActivityWorker) claimTask(t *task.Activity) bool {
+	aw.inFlightMu.Lock()
+	defer aw.inFlightMu.Unlock()
+
+	if _, ok := aw.inFlight[t.ID]; ok && aw.stopped {
+		return false
+	}
+
+	delete(aw.inFlight, t.ID)
+
+	return true
+}
+
+// stopTasks takes over the in-flight tasks on a hard stop. It only returns them on the first call.
+func (aw *ActivityWorker) stopTasks() []*task.Activity {
+	aw.inFlightMu.Lock()
+	defer aw.inFlightMu.Unlock()
+
+	if aw.stopped {
+		return nil
+	}
+
+	aw.stopped = true
+
+	tasks := make([]*task.Activity, 0, len(aw.inFlight))
+	for _, t := range aw.inFlight {
+		tasks = append(tasks, t)
+	}
+
+	return tasks
+}
+
+// releaseTask releases the lock of the task, it can be picked up again after delay
+func (aw *ActivityWorker) releaseTask(t *task.Activity, delay time.Duration) {
+	// The context passed to Shutdown might already be done, use a separate one to release the task
+	ctx, cancel := context.WithTimeout(context.Background(), releaseTimeout)
+	defer cancel()
+
+	if err := aw.backend.ReleaseActivityTask(ctx, t.ID, delay); err != nil {
+		reportError(aw.backend, aw.options, "releasing activity task", err)
+	}
+}
+
 func (aw *ActivityWorker) handleTask(ctx context.Context, task *task.Activity) {
 	a := task.Event.Attributes.(*history.ActivityScheduledAttributes)
 	ametrics := aw.backend.Metrics().WithTags(metrics.Tags{metrickeys.ActivityName: a.Name})
@@ -118,6 +279,24 @@ func (aw *ActivityWorker) handleTask(ctx context.Context, task *task.Activity) {
 	timeInQueue := time.Since(scheduledAt)
 	ametrics.Distribution(metrickeys.ActivityTaskDelay, metrics.Tags{}, float64(timeInQueue/time.Millisecond))
 
+	if limiter, ok := aw.activityRateLimiters[a.Name]; ok {
+		// The activity is only known once the task is locked. Don't hold on to the task while the activity's limit
+		// is exceeded, release it so it can be picked up again once there is budget.
+		wait, err := limiter.WaitFor(ctx, activityRateLimitMaxWait)
+		if err != nil {
+			// Hard stop, the task is released
+			return
+		}
+
+		if wait > 0 {
+			if aw.claimTask(task) {
+				aw.releaseTask(task, wait)
+			}
+
+			return
+		}
+	}
+
 	// Start heartbeat while activity is running
 	heartbeatCtx, cancelHeartbeat := context.WithCancel(ctx)
 	go func(ctx context.Context) {
@@ -129,8 +308,15 @@ func (aw *ActivityWorker) handleTask(ctx context.Context, task *task.Activity) {
 			case <-ctx.Done():
 				return
 			case <-t.C:
-				if err := aw.backend.ExtendActivityTask(ctx, task.ID); err != nil {
-					aw.backend.Logger().Panic("extending activity task", "error", err)
+				if err := retryBackend(ctx, aw.options, func() error {
+					return aw.backend.ExtendActivityTask(ctx, task.ID)
+				}); err != nil {
+					if ctx.Err() == nil {
+						// Stop heartbeating, the task will be returned to the queue once its lock expires
+						reportError(aw.backend, aw.options, "extending activity task", err)
+					}
+
+					return
 				}
 			}
 		}
@@ -143,6 +329,11 @@ func (aw *ActivityWorker) handleTask(ctx context.Context, task *task.Activity) {
 
 	cancelHeartbeat()
 
+	if ctx.Err() != nil {
+		// Hard stop, the task is released instead of completed
+		return
+	}
+
 	var event history.Event
 
 	if err != nil {
@@ -164,8 +355,16 @@ func (aw *ActivityWorker) handleTask(ctx context.Context, task *task.Activity) {
 			history.ScheduleEventID(task.Event.ScheduleEventID))
 	}
 
-	if err := aw.backend.CompleteActivityTask(ctx, task.WorkflowInstance, task.ID, event); err != nil {
-		aw.backend.Logger().Panic("completing activity task", "error", err)
+	if !aw.claimTask(task) {
+		// Hard stop, the task is released instead of completed
+		return
+	}
+
+	if err := retryBackend(ctx, aw.options, func() error {
+		return aw.backend.CompleteActivityTask(ctx, task.WorkflowInstance, task.ID, event)
+	}); err != nil && ctx.Err() == nil {
+		// The activity will be executed again once the lock on the task expires
+		reportError(aw.backend, aw.options, "completing activity task", err)
 	}
 }
 
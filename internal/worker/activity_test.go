@@ -0,0 +1,217 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/paveliak/go-workflows/backend"
+	"github.com/paveliak/go-workflows/internal/core"
+	"github.com/paveliak/go-workflows/internal/fn"
+	"github.com/paveliak/go-workflows/internal/history"
+	"github.com/paveliak/go-workflows/internal/logger"
+	"github.com/paveliak/go-workflows/internal/metrics"
+	"github.com/paveliak/go-workflows/internal/task"
+	"github.com/paveliak/go-workflows/internal/workflow"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func activity1(ctx context.Context) (int, error) {
+	return 42, nil
+}
+
+var blockingActivityStarted = make(chan struct{}, 1)
+
+func blockingActivity(ctx context.Context) error {
+	blockingActivityStarted <- struct{}{}
+
+	<-ctx.Done()
+
+	return ctx.Err()
+}
+
+func newTestActivityWorker(t *testing.T, b *backend.MockBackend, options *Options) (*ActivityWorker, *task.Activity) {
+	b.On("Logger").Return(logger.NewDefaultLogger())
+	b.On("Metrics").Return(metrics.NewNoopMetricsClient())
+	b.On("Tracer").Return(trace.NewNoopTracerProvider().Tracer("test"))
+
+	r := workflow.NewRegistry()
+	require.NoError(t, r.RegisterActivity(activity1))
+	require.NoError(t, r.RegisterActivity(blockingActivity))
+
+	o := DefaultOptions
+	if options != nil {
+		o = *options
+	}
+	o.BackendRetryInterval = time.Millisecond
+
+	aw := NewActivityWorker(b, r, clock.New(), &o)
+
+	return aw, &task.Activity{
+		ID:               uuid.NewString(),
+		WorkflowInstance: core.NewWorkflowInstance(uuid.NewString(), uuid.NewString()),
+		Metadata:         &core.WorkflowMetadata{},
+		Event: history.NewHistoryEvent(1, time.Now(), history.EventType_ActivityScheduled, &history.ActivityScheduledAttributes{
+			Name: fn.Name(activity1),
+		}),
+	}
+}
+
+func Test_ActivityWorker_RetriesCompleteActivityTask(t *testing.T) {
+	b := &backend.MockBackend{}
+	aw, task := newTestActivityWorker(t, b, nil)
+
+	b.On("CompleteActivityTask", mock.Anything, task.WorkflowInstance, task.ID, mock.Anything).Return(errors.New("unavailable")).Twice()
+	b.On("CompleteActivityTask", mock.Anything, task.WorkflowInstance, task.ID, mock.Anything).Return(nil).Once()
+
+	aw.handleTask(context.Background(), task)
+
+	b.AssertNumberOfCalls(t, "CompleteActivityTask", 3)
+}
+
+func Test_ActivityWorker_ReportsErrorAfterRetries(t *testing.T) {
+	var reported []error
+
+	options := DefaultOptions
+	options.BackendRetries = 2
+	options.OnError = func(err error) {
+		reported = append(reported, err)
+	}
+
+	b := &backend.MockBackend{}
+	aw, task := newTestActivityWorker(t, b, &options)
+
+	b.On("CompleteActivityTask", mock.Anything, task.WorkflowInstance, task.ID, mock.Anything).Return(errors.New("unavailable"))
+
+	require.NotPanics(t, func() {
+		aw.handleTask(context.Background(), task)
+	})
+
+	b.AssertNumberOfCalls(t, "CompleteActivityTask", 3)
+	require.Len(t, reported, 1)
+	require.EqualError(t, reported[0], "completing activity task: unavailable")
+}
+
+func Test_ActivityWorker_DoesNotRetryPermanentErrors(t *testing.T) {
+	b := &backend.MockBackend{}
+	aw, task := newTestActivityWorker(t, b, nil)
+
+	b.On("CompleteActivityTask", mock.Anything, task.WorkflowInstance, task.ID, mock.Anything).Return(backend.ErrInstanceNotFound)
+
+	aw.handleTask(context.Background(), task)
+
+	b.AssertNumberOfCalls(t, "CompleteActivityTask", 1)
+}
+
+func Test_ActivityWorker_RetriesDisabled(t *testing.T) {
+	options := DefaultOptions
+	options.BackendRetries = -1
+
+	b := &backend.MockBackend{}
+	aw, task := newTestActivityWorker(t, b, &options)
+
+	b.On("CompleteActivityTask", mock.Anything, task.WorkflowInstance, task.ID, mock.Anything).Return(errors.New("unavailable"))
+
+	aw.handleTask(context.Background(), task)
+
+	b.AssertNumberOfCalls(t, "CompleteActivityTask", 1)
+}
+
+func Test_ActivityWorker_ReleasesRateLimitedTask(t *testing.T) {
+	options := DefaultOptions
+	options.ActivityRateLimits = map[string]float64{
+		fn.Name(activity1): 0.1,
+	}
+
+	b := &backend.MockBackend{}
+	aw, task := newTestActivityWorker(t, b, &options)
+
+	b.On("CompleteActivityTask", mock.Anything, task.WorkflowInstance, task.ID, mock.Anything).Return(nil).Once()
+	// The next task would have to wait ten seconds for the activity's limit, it's released for the rest of that time
+	b.On("ReleaseActivityTask", mock.Anything, task.ID, mock.MatchedBy(func(delay time.Duration) bool {
+		return delay > 8*time.Second && delay <= 9*time.Second
+	})).Return(nil).Once()
+
+	aw.handleTask(context.Background(), task)
+	aw.handleTask(context.Background(), task)
+
+	b.AssertNumberOfCalls(t, "CompleteActivityTask", 1)
+	b.AssertNumberOfCalls(t, "ReleaseActivityTask", 1)
+}
+
+func Test_ActivityWorker_ShutdownWaitsForActivities(t *testing.T) {
+	b := &backend.MockBackend{}
+	aw, task := newTestActivityWorker(t, b, nil)
+
+	b.On("CompleteActivityTask", mock.Anything, task.WorkflowInstance, task.ID, mock.Anything).Return(nil).Once()
+
+	go aw.runDispatcher()
+
+	aw.wg.Add(1)
+	aw.activityTaskQueue <- task
+
+	require.NoError(t, aw.Shutdown(context.Background()))
+
+	b.AssertNumberOfCalls(t, "CompleteActivityTask", 1)
+}
+
+func Test_ActivityWorker_ShutdownCancelsAndReleasesActivities(t *testing.T) {
+	b := &backend.MockBackend{}
+	aw, task := newTestActivityWorker(t, b, nil)
+	task.Event.Attributes.(*history.ActivityScheduledAttributes).Name = fn.Name(blockingActivity)
+
+	b.On("ReleaseActivityTask", mock.Anything, task.ID, time.Duration(0)).Return(nil).Once()
+
+	go aw.runDispatcher()
+
+	aw.wg.Add(1)
+	aw.activityTaskQueue <- task
+
+	<-blockingActivityStarted
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+
+	require.ErrorIs(t, aw.Shutdown(ctx), context.DeadlineExceeded)
+
+	// The activity is canceled and not completed
+	require.NoError(t, aw.Shutdown(context.Background()))
+
+	b.AssertCalled(t, "ReleaseActivityTask", mock.Anything, task.ID, time.Duration(0))
+	b.AssertNotCalled(t, "CompleteActivityTask", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func Test_ActivityWorker_ShutdownReleasesOnlyUnclaimedTasks(t *testing.T) {
+	b := &backend.MockBackend{}
+	aw, task := newTestActivityWorker(t, b, nil)
+
+	claimed := *task
+	claimed.ID = uuid.NewString()
+
+	b.On("ReleaseActivityTask", mock.Anything, task.ID, time.Duration(0)).Return(nil).Once()
+
+	aw.wg.Add(1)
+	aw.trackTask(task)
+	aw.trackTask(&claimed)
+
+	// The claimed task is being completed, it must not be released
+	require.True(t, aw.claimTask(&claimed))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	require.ErrorIs(t, aw.Shutdown(ctx), context.Canceled)
+
+	// The task has been released by Shutdown, it must not be completed anymore
+	require.False(t, aw.claimTask(task))
+
+	aw.wg.Done()
+
+	b.AssertNumberOfCalls(t, "ReleaseActivityTask", 1)
+	b.AssertNotCalled(t, "ReleaseActivityTask", mock.Anything, claimed.ID, mock.Anything)
+}
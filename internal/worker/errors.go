@@ -0,0 +1,85 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/paveliak/go-workflows/backend"
+	"github.com/paveliak/go-workflows/internal/core"
+	"github.com/paveliak/go-workflows/internal/metrickeys"
+	"github.com/paveliak/go-workflows/metrics"
+	"github.com/cenkalti/backoff/v4"
+)
+
+// releaseTimeout bounds releasing tasks during shutdown, after the shutdown context is already done
+const releaseTimeout = 10 * time.Second
+
+// retryBackend calls fn until it succeeds, up to options.BackendRetries additional times with exponential backoff
+// between attempts. Negative options.BackendRetries disable retries. Errors that are returned for the state of the workflow instance are not retried. It returns the
+// error of the last attempt.
+func retryBackend(ctx context.Context, options *Options, fn func() error) error {
+	b := backoff.NewExponentialBackOff()
+	b.InitialInterval = options.BackendRetryInterval
+	b.MaxElapsedTime = 0
+
+	retries := options.BackendRetries
+	if retries < 0 {
+		retries = 0
+	}
+
+	return backoff.Retry(func() error {
+		err := fn()
+		if isPermanentBackendError(err) {
+			return backoff.Permanent(err)
+		}
+
+		return err
+	}, backoff.WithContext(backoff.WithMaxRetries(b, uint64(retries)), ctx))
+}
+
+// permanentBackendErrors are returned by backends for the state of a workflow instance, retrying the call does not
+// change the result
+var permanentBackendErrors = []error{
+	backend.ErrInstanceNotFound,
+	backend.ErrInstanceAlreadyExists,
+	backend.ErrInstanceNotFinished,
+	backend.ErrInstanceNotSuspended,
+	backend.ErrInstanceNotActive,
+	backend.ErrInstanceFinished,
+	backend.ErrWorkflowTaskDiscarded,
+}
+
+func isPermanentBackendError(err error) bool {
+	for _, perr := range permanentBackendErrors {
+		if errors.Is(err, perr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// releaseStickiness releases the affinity of the given workflow instance, or of all instances if nil, to this worker
+func releaseStickiness(b backend.Backend, options *Options, instance *core.WorkflowInstance) {
+	ctx, cancel := context.WithTimeout(context.Background(), releaseTimeout)
+	defer cancel()
+
+	if err := b.ReleaseStickiness(ctx, instance); err != nil {
+		reportError(b, options, "releasing workflow instance stickiness", err)
+	}
+}
+
+// reportError records an error that prevented the worker from processing a task. The task is not completed and
+// will be picked up again once its lock expires.
+func reportError(b backend.Backend, options *Options, operation string, err error) {
+	err = fmt.Errorf("%s: %w", operation, err)
+
+	b.Logger().Error("Worker error", "operation", operation, "error", err)
+	b.Metrics().Counter(metrickeys.WorkerError, metrics.Tags{metrickeys.Operation: operation}, 1)
+
+	if options.OnError != nil {
+		options.OnError(err)
+	}
+}
@@ -3,6 +3,7 @@ package worker
 import (
 	"time"
 
+	"github.com/paveliak/go-workflows/internal/sync"
 	"github.com/paveliak/go-workflows/internal/workflow"
 )
 
@@ -21,6 +22,21 @@ type Options struct {
 	// by the worker. The default is 0 which is no limit.
 	MaxParallelActivityTasks int
 
+	// ActivityTasksPerSecond limits the rate at which the worker starts activity tasks. The default is 0 which is
+	// no limit.
+	ActivityTasksPerSecond float64
+
+	// ActivityRateLimits limits the rate at which the worker starts tasks of individual activities, in tasks per
+	// second keyed by the name the activity is registered with. Tasks waiting for their activity's rate limit
+	// count towards MaxParallelActivityTasks, tasks that would wait for more than a second are released to be
+	// picked up again later.
+	ActivityRateLimits map[string]float64
+
+	// SharedActivityRateLimits shares the budgets of ActivityTasksPerSecond and ActivityRateLimits between all
+	// workers using the same backend, if the backend implements backend.RateLimiter. Otherwise, and while the
+	// backend cannot be reached, the limits apply to each worker individually.
+	SharedActivityRateLimits bool
+
 	// ActivityHeartbeatInterval is the interval between heartbeat attempts for activity tasks. Defaults
 	// to 25 seconds
 	ActivityHeartbeatInterval time.Duration
@@ -40,8 +56,42 @@ type Options struct {
 	WorkflowExecutorCacheTTL time.Duration
 
 	// WorkflowExecutorCache is the cache to use for workflow executors. If nil, a default cache implementation
-	// will be used.
+	// will be used. The default cache releases the stickiness of workflow instances to this worker in the backend
+	// when their executors are evicted, custom implementations do not.
 	WorkflowExecutorCache workflow.ExecutorCache
+
+	// NonDeterminismPolicy determines what happens when a workflow is not deterministic when replaying its history.
+	// By default the workflow instance is failed. With NonDeterminismPolicyBlockWorkflow the workflow task
+	// is not completed and retried, until the workflow code is fixed and redeployed.
+	NonDeterminismPolicy workflow.NonDeterminismPolicy
+
+	// WorkflowPanicPolicy determines what happens when workflow code panics. By default the workflow instance is
+	// failed. With PanicPolicyBlockWorkflow the workflow task is abandoned without changing the history and retried,
+	// until the workflow code is fixed and redeployed.
+	WorkflowPanicPolicy workflow.PanicPolicy
+
+	// WorkflowDeadlockTimeout is the time workflow code can run without yielding, for example while blocked on a
+	// native Go mutex or channel, before the workflow task is abandoned. Defaults to 40 seconds.
+	WorkflowDeadlockTimeout time.Duration
+
+	// MaxTaskAttempts is the number of times a workflow task is handed to a worker, for example because it was
+	// abandoned or the worker crashed while processing it, before its workflow instance is suspended. Suspended
+	// instances are skipped by all workers until they are resumed with Client.ResumeWorkflowInstance. The default
+	// is 0 which is no limit.
+	MaxTaskAttempts int
+
+	// BackendRetries is the number of times calls to the backend that complete or extend tasks are retried before
+	// the task is abandoned. Abandoned tasks are picked up again once their lock expires. Defaults to 3, negative
+	// values disable retries.
+	BackendRetries int
+
+	// BackendRetryInterval is the initial interval between retries of backend calls, it increases exponentially.
+	// Defaults to 100 milliseconds.
+	BackendRetryInterval time.Duration
+
+	// OnError is called for errors that prevent the worker from processing a task, for example when the backend
+	// cannot be reached. The task is abandoned and retried once its lock expires.
+	OnError func(err error)
 }
 
 var DefaultOptions = Options{
@@ -55,4 +105,12 @@ var DefaultOptions = Options{
 	WorkflowExecutorCacheSize: 128,
 	WorkflowExecutorCacheTTL:  time.Second * 10,
 	WorkflowExecutorCache:     nil,
+
+	NonDeterminismPolicy: workflow.NonDeterminismPolicyFailWorkflow,
+	WorkflowPanicPolicy:  workflow.PanicPolicyFailWorkflow,
+
+	WorkflowDeadlockTimeout: sync.DeadlockDetection,
+
+	BackendRetries:       3,
+	BackendRetryInterval: 100 * time.Millisecond,
 }
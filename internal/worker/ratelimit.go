@@ -0,0 +1,160 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/paveliak/go-workflows/backend"
+	"github.com/paveliak/go-workflows/internal/ratelimit"
+)
+
+type rateLimiter interface {
+	// Wait blocks until a task can be started or ctx is done
+	Wait(ctx context.Context) error
+
+	// WaitFor is like Wait, but gives up after max. If no task can be started within max, it returns how much longer
+	// it would have to wait, otherwise zero.
+	WaitFor(ctx context.Context, max time.Duration) (time.Duration, error)
+}
+
+// newRateLimiter returns a limiter allowing rate tasks per second. If enabled in the options and supported by the
+// backend, the budget identified by key is shared with all other workers.
+func newRateLimiter(b backend.Backend, options *Options, clock clock.Clock, key string, rate float64) rateLimiter {
+	local := &localRateLimiter{
+		clock:  clock,
+		rate:   rate,
+		burst:  ratelimit.Burst(rate),
+		bucket: ratelimit.NewBucket(clock.Now(), ratelimit.Burst(rate)),
+	}
+
+	if rl, ok := b.(backend.RateLimiter); ok && options.SharedActivityRateLimits {
+		return &backendRateLimiter{
+			backend:     b,
+			rateLimiter: rl,
+			options:     options,
+			clock:       clock,
+			key:         key,
+			local:       local,
+		}
+	}
+
+	return local
+}
+
+// localRateLimiter is a token bucket limiting the rate of tasks started by this worker
+type localRateLimiter struct {
+	mu     sync.Mutex
+	clock  clock.Clock
+	rate   float64
+	burst  int
+	bucket ratelimit.Bucket
+}
+
+func (l *localRateLimiter) take() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.bucket.Take(l.clock.Now(), l.rate, l.burst)
+}
+
+func (l *localRateLimiter) Wait(ctx context.Context) error {
+	_, err := l.wait(ctx, time.Time{})
+	return err
+}
+
+func (l *localRateLimiter) WaitFor(ctx context.Context, max time.Duration) (time.Duration, error) {
+	return l.wait(ctx, l.clock.Now().Add(max))
+}
+
+// wait takes a token, waiting until the deadline at the latest. A zero deadline waits indefinitely. It returns the
+// time left to wait if the deadline has passed.
+func (l *localRateLimiter) wait(ctx context.Context, deadline time.Time) (time.Duration, error) {
+	for {
+		wait := l.take()
+		if wait == 0 {
+			return 0, nil
+		}
+
+		if remaining, err := sleepUntil(ctx, l.clock, wait, deadline); remaining > 0 || err != nil {
+			return remaining, err
+		}
+	}
+}
+
+// backendRateLimiter takes tokens from a bucket in the backend shared by all workers. If the backend cannot be
+// reached it falls back to the local limit of this worker.
+type backendRateLimiter struct {
+	backend     backend.Backend
+	rateLimiter backend.RateLimiter
+	options     *Options
+	clock       clock.Clock
+	key         string
+	local       *localRateLimiter
+}
+
+func (l *backendRateLimiter) Wait(ctx context.Context) error {
+	_, err := l.wait(ctx, time.Time{})
+	return err
+}
+
+func (l *backendRateLimiter) WaitFor(ctx context.Context, max time.Duration) (time.Duration, error) {
+	return l.wait(ctx, l.clock.Now().Add(max))
+}
+
+func (l *backendRateLimiter) wait(ctx context.Context, deadline time.Time) (time.Duration, error) {
+	for {
+		wait, err := l.rateLimiter.TakeRateLimitToken(ctx, l.key, l.local.rate, l.local.burst)
+		if err != nil {
+			if ctx.Err() != nil {
+				return 0, ctx.Err()
+			}
+
+			reportError(l.backend, l.options, "taking rate limit token", fmt.Errorf("%s: %w", l.key, err))
+
+			return l.local.wait(ctx, deadline)
+		}
+
+		if wait == 0 {
+			return 0, nil
+		}
+
+		if remaining, err := sleepUntil(ctx, l.clock, wait, deadline); remaining > 0 || err != nil {
+			return remaining, err
+		}
+	}
+}
+
+// sleepUntil sleeps for d. If that would pass the deadline, it only sleeps until the deadline and returns the part
+// of d it did not sleep. A zero deadline is ignored.
+func sleepUntil(ctx context.Context, clock clock.Clock, d time.Duration, deadline time.Time) (time.Duration, error) {
+	if !deadline.IsZero() {
+		if remaining := deadline.Sub(clock.Now()); d > remaining {
+			if remaining > 0 {
+				if err := sleep(ctx, clock, remaining); err != nil {
+					return 0, err
+				}
+
+				return d - remaining, nil
+			}
+
+			return d, nil
+		}
+	}
+
+	return 0, sleep(ctx, clock, d)
+}
+
+func sleep(ctx context.Context, clock clock.Clock, d time.Duration) error {
+	t := clock.Timer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
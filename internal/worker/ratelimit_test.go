@@ -0,0 +1,129 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/paveliak/go-workflows/backend"
+	"github.com/paveliak/go-workflows/internal/logger"
+	"github.com/paveliak/go-workflows/internal/metrics"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type rateLimitingBackend struct {
+	*backend.MockBackend
+}
+
+func (b *rateLimitingBackend) TakeRateLimitToken(ctx context.Context, key string, rate float64, burst int) (time.Duration, error) {
+	args := b.Called(ctx, key, rate, burst)
+	return args.Get(0).(time.Duration), args.Error(1)
+}
+
+func Test_LocalRateLimiter_Wait(t *testing.T) {
+	c := clock.NewMock()
+	l := newRateLimiter(&backend.MockBackend{}, &DefaultOptions, c, "test", 2)
+
+	// Burst of one second worth of tasks
+	require.NoError(t, l.Wait(context.Background()))
+	require.NoError(t, l.Wait(context.Background()))
+
+	done := make(chan error)
+	go func() {
+		done <- l.Wait(context.Background())
+	}()
+
+	require.Eventually(t, func() bool {
+		c.Add(100 * time.Millisecond)
+
+		select {
+		case err := <-done:
+			require.NoError(t, err)
+			return true
+		default:
+			return false
+		}
+	}, time.Second, time.Millisecond)
+
+	// The next token is available after half a second
+	require.GreaterOrEqual(t, c.Now().Sub(time.Unix(0, 0)), 500*time.Millisecond)
+}
+
+func Test_LocalRateLimiter_WaitCanceled(t *testing.T) {
+	l := newRateLimiter(&backend.MockBackend{}, &DefaultOptions, clock.NewMock(), "test", 1)
+
+	require.NoError(t, l.Wait(context.Background()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	require.ErrorIs(t, l.Wait(ctx), context.Canceled)
+}
+
+func Test_LocalRateLimiter_WaitFor(t *testing.T) {
+	c := clock.NewMock()
+	l := newRateLimiter(&backend.MockBackend{}, &DefaultOptions, c, "test", 1)
+
+	wait, err := l.WaitFor(context.Background(), time.Second)
+	require.NoError(t, err)
+	require.Zero(t, wait)
+
+	// The next token is available after a second, don't wait that long
+	done := make(chan time.Duration)
+	go func() {
+		wait, err := l.WaitFor(context.Background(), 100*time.Millisecond)
+		require.NoError(t, err)
+		done <- wait
+	}()
+
+	require.Eventually(t, func() bool {
+		c.Add(10 * time.Millisecond)
+
+		select {
+		case wait := <-done:
+			// Time left until the next token after waiting for 100ms
+			require.Greater(t, wait, time.Duration(0))
+			require.LessOrEqual(t, wait, 900*time.Millisecond)
+			return true
+		default:
+			return false
+		}
+	}, time.Second, time.Millisecond)
+}
+
+func Test_BackendRateLimiter_Wait(t *testing.T) {
+	b := &rateLimitingBackend{&backend.MockBackend{}}
+	b.On("TakeRateLimitToken", mock.Anything, "activities", 5.0, 5).Return(time.Duration(0), nil).Once()
+
+	options := DefaultOptions
+	options.SharedActivityRateLimits = true
+
+	l := newRateLimiter(b, &options, clock.NewMock(), "activities", 5)
+	require.NoError(t, l.Wait(context.Background()))
+
+	b.AssertExpectations(t)
+}
+
+func Test_BackendRateLimiter_FallsBackToLocal(t *testing.T) {
+	var reported []error
+
+	b := &rateLimitingBackend{&backend.MockBackend{}}
+	b.On("Logger").Return(logger.NewDefaultLogger())
+	b.On("Metrics").Return(metrics.NewNoopMetricsClient())
+	b.On("TakeRateLimitToken", mock.Anything, "activities", 1.0, 1).Return(time.Duration(0), errors.New("unavailable"))
+
+	options := DefaultOptions
+	options.SharedActivityRateLimits = true
+	options.OnError = func(err error) {
+		reported = append(reported, err)
+	}
+
+	l := newRateLimiter(b, &options, clock.NewMock(), "activities", 1)
+	require.NoError(t, l.Wait(context.Background()))
+
+	require.Len(t, reported, 1)
+	require.EqualError(t, reported[0], "taking rate limit token: activities: unavailable")
+}
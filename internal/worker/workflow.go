@@ -2,6 +2,7 @@ package worker
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -9,7 +10,10 @@ import (
 	"github.com/benbjohnson/clock"
 	"github.com/paveliak/go-workflows/backend"
 	"github.com/paveliak/go-workflows/internal/core"
+	"github.com/paveliak/go-workflows/internal/history"
 	"github.com/paveliak/go-workflows/internal/metrickeys"
+	"github.com/paveliak/go-workflows/internal/payload"
+	internalsync "github.com/paveliak/go-workflows/internal/sync"
 	"github.com/paveliak/go-workflows/internal/task"
 	"github.com/paveliak/go-workflows/internal/workflow"
 	"github.com/paveliak/go-workflows/internal/workflow/cache"
@@ -30,7 +34,21 @@ type WorkflowWorker struct {
 
 	logger log.Logger
 
+	// wg tracks tasks that have been dequeued but not yet handled
 	wg *sync.WaitGroup
+
+	pollersWg     *sync.WaitGroup
+	pollersCancel context.CancelFunc
+	shutdownOnce  sync.Once
+
+	// tasksCtx is the parent context of all workflow task executions, it is canceled on a hard stop
+	tasksCtx    context.Context
+	tasksCancel context.CancelFunc
+
+	// inFlight holds the tasks being executed. After a hard stop, the remaining ones are released by Shutdown.
+	inFlightMu sync.Mutex
+	inFlight   map[string]*task.Workflow
+	stopped    bool
 }
 
 func NewWorkflowWorker(backend backend.Backend, registry *workflow.Registry, options *Options) *WorkflowWorker {
@@ -38,9 +56,15 @@ func NewWorkflowWorker(backend backend.Backend, registry *workflow.Registry, opt
 	if options.WorkflowExecutorCache != nil {
 		c = options.WorkflowExecutorCache
 	} else {
-		c = cache.NewWorkflowExecutorLRUCache(backend.Metrics(), options.WorkflowExecutorCacheSize, options.WorkflowExecutorCacheTTL)
+		c = cache.NewWorkflowExecutorLRUCache(backend.Metrics(), options.WorkflowExecutorCacheSize, options.WorkflowExecutorCacheTTL,
+			cache.WithEvictionCallback(func(ctx context.Context, instance *core.WorkflowInstance) {
+				// Without a cached executor there is no benefit in routing tasks for the instance to this worker
+				releaseStickiness(backend, options, instance)
+			}))
 	}
 
+	tasksCtx, tasksCancel := context.WithCancel(context.Background())
+
 	return &WorkflowWorker{
 		backend: backend,
 
@@ -54,11 +78,22 @@ func NewWorkflowWorker(backend backend.Backend, registry *workflow.Registry, opt
 		logger: backend.Logger(),
 
 		wg: &sync.WaitGroup{},
+
+		pollersWg:     &sync.WaitGroup{},
+		pollersCancel: func() {},
+
+		tasksCtx:    tasksCtx,
+		tasksCancel: tasksCancel,
+
+		inFlight: make(map[string]*task.Workflow),
 	}
 }
 
 func (ww *WorkflowWorker) Start(ctx context.Context) error {
+	ctx, ww.pollersCancel = context.WithCancel(ctx)
+
 	for i := 0; i <= ww.options.WorkflowPollers; i++ {
+		ww.pollersWg.Add(1)
 		go ww.runPoll(ctx)
 	}
 
@@ -67,15 +102,54 @@ func (ww *WorkflowWorker) Start(ctx context.Context) error {
 	return nil
 }
 
-func (ww *WorkflowWorker) WaitForCompletion() error {
-	close(ww.workflowTaskQueue)
+// Shutdown stops polling for new workflow tasks and waits for in-flight tasks to finish. If ctx is done before
+// that, the remaining tasks are abandoned and released in the backend so that other workers can pick them up
+// immediately.
+func (ww *WorkflowWorker) Shutdown(ctx context.Context) error {
+	ww.shutdownOnce.Do(func() {
+		// Pollers might still be sending on the task queue, wait for them to stop before closing it
+		ww.pollersCancel()
+		ww.pollersWg.Wait()
 
-	ww.wg.Wait()
+		close(ww.workflowTaskQueue)
+	})
 
-	return nil
+	done := make(chan struct{})
+	go func() {
+		ww.wg.Wait()
+		close(done)
+	}()
+
+	var err error
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		// Take over the in-flight tasks before canceling them, tasks that are settled concurrently are skipped
+		tasks := ww.stopTasks()
+
+		ww.tasksCancel()
+
+		for _, t := range tasks {
+			ww.releaseTask(t)
+		}
+
+		err = ctx.Err()
+	}
+
+	// Cached executors go away with this worker, let other workers pick up the instances immediately
+	releaseStickiness(ww.backend, ww.options, nil)
+
+	return err
+}
+
+func (ww *WorkflowWorker) WaitForCompletion() error {
+	return ww.Shutdown(context.Background())
 }
 
 func (ww *WorkflowWorker) runPoll(ctx context.Context) {
+	defer ww.pollersWg.Done()
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -90,7 +164,14 @@ func (ww *WorkflowWorker) runPoll(ctx context.Context) {
 
 			if task != nil {
 				ww.wg.Add(1)
-				ww.workflowTaskQueue <- task
+
+				select {
+				case ww.workflowTaskQueue <- task:
+				case <-ctx.Done():
+					// Worker is shutting down, let another worker pick up the task
+					ww.releaseTask(task)
+					ww.wg.Done()
+				}
 			}
 		}
 	}
@@ -105,17 +186,26 @@ func (ww *WorkflowWorker) runDispatcher() {
 
 	for t := range ww.workflowTaskQueue {
 		if sem != nil {
-			sem <- struct{}{}
+			select {
+			case sem <- struct{}{}:
+			case <-ww.tasksCtx.Done():
+				ww.releaseTask(t)
+				ww.wg.Done()
+				continue
+			}
 		}
 
 		t := t
 
+		ww.trackTask(t)
+
 		go func() {
 			defer ww.wg.Done()
+			defer ww.claimTask(t)
 
-			// Create new context to allow workflows to complete when root context is canceled
-			taskCtx := context.Background()
-			ww.handle(taskCtx, t)
+			// Workflow tasks are completed when the context passed to Start is canceled, they are only canceled
+			// on a hard stop during Shutdown
+			ww.handle(ww.tasksCtx, t)
 
 			if sem != nil {
 				<-sem
@@ -124,7 +214,68 @@ func (ww *WorkflowWorker) runDispatcher() {
 	}
 }
 
+func (ww *WorkflowWorker) trackTask(t *task.Workflow) {
+	ww.inFlightMu.Lock()
+	defer ww.inFlightMu.Unlock()
+
+	ww.inFlight[t.ID] = t
+}
+
+// claimTask has to be called before a task is settled in the backend. It returns false if the task has been taken
+// over by a hard stop, Shutdown releases it then.
+func (ww *WorkflowWorker) claimTask(t *task.Workflow) bool {
+	ww.inFlightMu.Lock()
+	defer ww.inFlightMu.Unlock()
+
+	if _, ok := ww.inFlight[t.ID]; ok && ww.stopped {
+		return false
+	}
+
+	delete(ww.inFlight, t.ID)
+
+	return true
+}
+
+// stopTasks takes over the in-flight tasks on a hard stop. It only returns them on the first call.
+func (ww *WorkflowWorker) stopTasks() []*task.Workflow {
+	ww.inFlightMu.Lock()
+	defer ww.inFlightMu.Unlock()
+
+	if ww.stopped {
+		return nil
+	}
+
+	ww.stopped = true
+
+	tasks := make([]*task.Workflow, 0, len(ww.inFlight))
+	for _, t := range ww.inFlight {
+		tasks = append(tasks, t)
+	}
+
+	return tasks
+}
+
+func (ww *WorkflowWorker) releaseTask(t *task.Workflow) {
+	// The context passed to Shutdown might already be done, use a separate one to release the task
+	ctx, cancel := context.WithTimeout(context.Background(), releaseTimeout)
+	defer cancel()
+
+	if err := ww.backend.ReleaseWorkflowTask(ctx, t.ID, t.WorkflowInstance); err != nil {
+		reportError(ww.backend, ww.options, "releasing workflow task", err)
+	}
+}
+
 func (ww *WorkflowWorker) handle(ctx context.Context, t *task.Workflow) {
+	if ww.options.MaxTaskAttempts > 0 && t.Attempt > ww.options.MaxTaskAttempts {
+		if !ww.claimTask(t) {
+			return
+		}
+
+		ww.suspendTask(ctx, t)
+
+		return
+	}
+
 	// Record how long this task was in the queue
 	scheduledAt := t.NewEvents[0].Timestamp // Use the timestamp of the first event as the schedule time
 	timeInQueue := time.Since(scheduledAt)
@@ -134,7 +285,55 @@ func (ww *WorkflowWorker) handle(ctx context.Context, t *task.Workflow) {
 
 	result, err := ww.handleTask(ctx, t)
 	if err != nil {
-		ww.logger.Panic("could not handle workflow task", "error", err)
+		var nerr *workflow.NonDeterminismError
+		if errors.As(err, &nerr) {
+			// Blocked by the non-determinism policy. Don't complete the task, it will be retried once its lock expires.
+			ww.logger.Error("Workflow task blocked due to nondeterminism, retrying after the task lock expires",
+				"instance_id", t.WorkflowInstance.InstanceID, "error", err)
+
+			ww.abandonTask(ctx, t, nerr)
+
+			return
+		}
+
+		var derr *internalsync.DeadlockError
+		if errors.As(err, &derr) {
+			// Free up the poller, the task will be retried once its lock expires.
+			ww.logger.Error("Workflow task deadlocked, abandoning task",
+				"instance_id", t.WorkflowInstance.InstanceID, "error", err)
+
+			ww.backend.Metrics().Counter(metrickeys.WorkflowTaskDeadlock, metrics.Tags{}, 1)
+
+			ww.abandonTask(ctx, t, derr)
+
+			return
+		}
+
+		var perr *internalsync.PanicError
+		if errors.As(err, &perr) {
+			// Blocked by the panic policy. Record the panic, the task will be retried once its lock expires.
+			ww.logger.Error("Workflow task panicked, abandoning task",
+				"instance_id", t.WorkflowInstance.InstanceID, "error", err, "stack", perr.Stack)
+
+			ww.backend.Metrics().Counter(metrickeys.WorkflowTaskPanic, metrics.Tags{}, 1)
+
+			ww.abandonTask(ctx, t, perr)
+
+			return
+		}
+
+		if ctx.Err() != nil {
+			// Hard stop, the task is released instead of completed
+			ww.evictExecutor(ctx, t)
+
+			return
+		}
+
+		reportError(ww.backend, ww.options, "handling workflow task", err)
+
+		ww.evictExecutor(ctx, t)
+
+		return
 	}
 
 	// Only record the time spent in the workflow code
@@ -142,21 +341,43 @@ func (ww *WorkflowWorker) handle(ctx context.Context, t *task.Workflow) {
 
 	state := core.WorkflowInstanceStateActive
 	if result.Completed {
-		state = core.WorkflowInstanceStateFinished
+		state = result.State
+		if !state.Finished() {
+			state = core.WorkflowInstanceStateFinished
+		}
 
-		if t.WorkflowInstanceState != state {
+		if !t.WorkflowInstanceState.Finished() {
 			// If the workflow is now finished, record
 			ww.backend.Metrics().Counter(metrickeys.WorkflowInstanceFinished, metrics.Tags{
 				metrickeys.SubWorkflow: fmt.Sprint(t.WorkflowInstance.SubWorkflow()),
+				metrickeys.State:       state.String(),
 			}, 1)
 		}
 	}
 
 	ww.backend.Metrics().Counter(metrickeys.ActivityTaskScheduled, metrics.Tags{}, int64(len(result.ActivityEvents)))
 
+	if !ww.claimTask(t) {
+		// Hard stop, the task is released instead of completed
+		ww.evictExecutor(ctx, t)
+
+		return
+	}
+
+	// Completing the task is not retried, it's not idempotent and a failed call might still have been applied. The
+	// task is executed again with a new executor once its lock expires.
 	if err := ww.backend.CompleteWorkflowTask(
-		ctx, t, t.WorkflowInstance, state, result.Executed, result.ActivityEvents, result.TimerEvents, result.WorkflowEvents); err != nil {
-		ww.logger.Panic("could not complete workflow task", "error", err)
+		ctx, t, t.WorkflowInstance, state, result.Executed, result.ActivityEvents, result.TimerEvents, result.WorkflowEvents,
+	); err != nil {
+		if errors.Is(err, backend.ErrWorkflowTaskDiscarded) {
+			// The instance has been reset while the task was executed, the executor belongs to the previous execution
+			ww.logger.Debug("Workflow task discarded after reset", "instance_id", t.WorkflowInstance.InstanceID)
+		} else if ctx.Err() == nil {
+			reportError(ww.backend, ww.options, "completing workflow task", err)
+		}
+
+		// The executor state is ahead of the persisted history, the task will be executed again once its lock expires
+		ww.evictExecutor(ctx, t)
 	}
 }
 
@@ -164,11 +385,17 @@ func (ww *WorkflowWorker) handleTask(
 	ctx context.Context,
 	t *task.Workflow,
 ) (*workflow.ExecutionResult, error) {
-	executor, err := ww.getExecutor(ctx, t)
+	executor, cached, err := ww.getExecutor(ctx, t)
 	if err != nil {
 		return nil, err
 	}
 
+	if !cached {
+		// The executor is not used again, close it to stop the workflow's goroutines. Otherwise a deadlocked task
+		// leaks them, there is no eviction that would close the executor.
+		defer executor.Close()
+	}
+
 	if ww.options.HeartbeatWorkflowTasks {
 		// Start heartbeat while processing workflow task
 		heartbeatCtx, cancelHeartbeat := context.WithCancel(ctx)
@@ -184,27 +411,135 @@ func (ww *WorkflowWorker) handleTask(
 	return result, nil
 }
 
-func (ww *WorkflowWorker) getExecutor(ctx context.Context, t *task.Workflow) (workflow.WorkflowExecutor, error) {
+// abandonTask records the error for the workflow task without completing it and evicts the cached executor, which
+// cannot be used anymore.
+func (ww *WorkflowWorker) abandonTask(ctx context.Context, t *task.Workflow, taskErr error) {
+	if ww.claimTask(t) {
+		if err := ww.backend.AbandonWorkflowTask(ctx, t, taskErr.Error()); err != nil {
+			reportError(ww.backend, ww.options, "abandoning workflow task", err)
+		}
+	}
+
+	ww.evictExecutor(ctx, t)
+}
+
+// suspendTask moves the instance of a workflow task that keeps failing out of the way, it's not picked up again
+// until it's resumed.
+func (ww *WorkflowWorker) suspendTask(ctx context.Context, t *task.Workflow) {
+	ww.logger.Error("Workflow task exceeded maximum attempts, suspending workflow instance",
+		"instance_id", t.WorkflowInstance.InstanceID, "attempts", t.Attempt)
+
+	reason := fmt.Sprintf("workflow task exceeded maximum attempts (%d)", ww.options.MaxTaskAttempts)
+	if err := retryBackend(ctx, ww.options, func() error {
+		return ww.backend.SuspendWorkflowTask(ctx, t, reason)
+	}); err != nil {
+		if ctx.Err() == nil {
+			reportError(ww.backend, ww.options, "suspending workflow task", err)
+		}
+	} else {
+		ww.backend.Metrics().Counter(metrickeys.WorkflowInstanceSuspended, metrics.Tags{}, 1)
+	}
+
+	ww.evictExecutor(ctx, t)
+}
+
+func (ww *WorkflowWorker) evictExecutor(ctx context.Context, t *task.Workflow) {
+	if err := ww.cache.Evict(ctx, t.WorkflowInstance); err != nil {
+		ww.logger.Error("could not evict workflow task executor", "error", err)
+	}
+}
+
+// getExecutor returns the executor for the task, and whether it's cached for future tasks of the instance
+func (ww *WorkflowWorker) getExecutor(ctx context.Context, t *task.Workflow) (workflow.WorkflowExecutor, bool, error) {
 	// Try to get a cached executor
 	executor, ok, err := ww.cache.Get(ctx, t.WorkflowInstance)
 	if err != nil {
 		ww.logger.Error("could not get cached workflow task executor", "error", err)
 	}
 
-	if !ok {
+	if ok {
+		ww.backend.Metrics().Counter(metrickeys.WorkflowTaskStickyHit, metrics.Tags{}, 1)
+	} else {
+		if t.LastSequenceID > 0 {
+			// The instance has been executed before, but not with a cached executor on this worker
+			ww.backend.Metrics().Counter(metrickeys.WorkflowTaskStickyMiss, metrics.Tags{}, 1)
+		}
+
 		executor, err = workflow.NewExecutor(
-			ww.backend.Logger(), ww.backend.Tracer(), ww.registry, ww.backend, t.WorkflowInstance, clock.New())
+			ww.backend.Logger(), ww.backend.Tracer(), ww.registry, ww.backend, t.WorkflowInstance, clock.New(),
+			workflow.WithNonDeterminismPolicy(ww.options.NonDeterminismPolicy),
+			workflow.WithPanicPolicy(ww.options.WorkflowPanicPolicy),
+			workflow.WithDeadlockTimeout(ww.options.WorkflowDeadlockTimeout))
 		if err != nil {
-			return nil, fmt.Errorf("creating workflow executor: %w", err)
+			return nil, false, fmt.Errorf("creating workflow executor: %w", err)
 		}
 	}
 
 	// Cache executor instance for future continuation tasks, or refresh last access time
 	if err := ww.cache.Store(ctx, t.WorkflowInstance, executor); err != nil {
 		ww.logger.Error("error while caching workflow task executor:", "error", err)
+
+		// An executor taken from the cache is still owned by it, a new one has to be closed by the caller
+		return executor, ok, nil
+	}
+
+	if ok {
+		return executor, true, nil
 	}
 
-	return executor, nil
+	// Caches are free not to keep an executor, only one the cache returns is closed by its eviction
+	cachedExecutor, cached, err := ww.cache.Get(ctx, t.WorkflowInstance)
+	if err != nil {
+		ww.logger.Error("could not get cached workflow task executor", "error", err)
+	}
+
+	return executor, cached && cachedExecutor == executor, nil
+}
+
+// QueryWorkflowInstance runs the query against the state of the given workflow instance in this worker. If the
+// instance is not cached, its state is rebuilt from the history.
+func (ww *WorkflowWorker) QueryWorkflowInstance(ctx context.Context, instance *core.WorkflowInstance, query string) (payload.Payload, error) {
+	executor, ok, err := ww.cache.Get(ctx, instance)
+	if err != nil {
+		ww.logger.Error("could not get cached workflow task executor", "error", err)
+	}
+
+	if !ok {
+		h, err := ww.backend.GetWorkflowInstanceHistory(ctx, instance, nil)
+		if err != nil {
+			return nil, fmt.Errorf("getting workflow history: %w", err)
+		}
+
+		executor, err = workflow.NewExecutor(
+			ww.backend.Logger(), ww.backend.Tracer(), ww.registry, &staticHistoryProvider{h}, instance, clock.New())
+		if err != nil {
+			return nil, fmt.Errorf("creating workflow executor: %w", err)
+		}
+		defer executor.Close()
+
+		if len(h) > 0 {
+			// Replay the history without completing the task
+			if _, err := executor.ExecuteTask(ctx, &task.Workflow{
+				WorkflowInstance:      instance,
+				WorkflowInstanceState: core.WorkflowInstanceStateActive,
+				Metadata:              &core.WorkflowMetadata{},
+				LastSequenceID:        h[len(h)-1].SequenceID,
+				NewEvents:             []history.Event{},
+			}); err != nil {
+				return nil, fmt.Errorf("replaying workflow history: %w", err)
+			}
+		}
+	}
+
+	return executor.Query(ctx, query)
+}
+
+type staticHistoryProvider struct {
+	history []history.Event
+}
+
+func (p *staticHistoryProvider) GetWorkflowInstanceHistory(ctx context.Context, instance *core.WorkflowInstance, lastSequenceID *int64) ([]history.Event, error) {
+	return p.history, nil
 }
 
 func (ww *WorkflowWorker) heartbeatTask(ctx context.Context, task *task.Workflow) {
@@ -216,8 +551,15 @@ func (ww *WorkflowWorker) heartbeatTask(ctx context.Context, task *task.Workflow
 		case <-ctx.Done():
 			return
 		case <-t.C:
-			if err := ww.backend.ExtendWorkflowTask(ctx, task.ID, task.WorkflowInstance); err != nil {
-				ww.logger.Panic("could not heartbeat workflow task", "error", err)
+			if err := retryBackend(ctx, ww.options, func() error {
+				return ww.backend.ExtendWorkflowTask(ctx, task.ID, task.WorkflowInstance)
+			}); err != nil {
+				if ctx.Err() == nil {
+					// Stop heartbeating, completing the task will fail if another worker picked it up in the meantime
+					reportError(ww.backend, ww.options, "extending workflow task", err)
+				}
+
+				return
 			}
 		}
 	}
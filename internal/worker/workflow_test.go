@@ -0,0 +1,97 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/paveliak/go-workflows/backend"
+	"github.com/paveliak/go-workflows/internal/core"
+	"github.com/paveliak/go-workflows/internal/logger"
+	"github.com/paveliak/go-workflows/internal/metrics"
+	"github.com/paveliak/go-workflows/internal/task"
+	"github.com/paveliak/go-workflows/internal/workflow"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func Test_WorkflowWorker_SuspendsTaskAfterMaxAttempts(t *testing.T) {
+	b := &backend.MockBackend{}
+	b.On("Logger").Return(logger.NewDefaultLogger())
+	b.On("Metrics").Return(metrics.NewNoopMetricsClient())
+	b.On("Tracer").Return(trace.NewNoopTracerProvider().Tracer("test"))
+	b.On("ReleaseStickiness", mock.Anything, mock.Anything).Return(nil).Maybe()
+
+	options := DefaultOptions
+	options.MaxTaskAttempts = 3
+
+	ww := NewWorkflowWorker(b, workflow.NewRegistry(), &options)
+
+	task := &task.Workflow{
+		ID:               uuid.NewString(),
+		WorkflowInstance: core.NewWorkflowInstance(uuid.NewString(), uuid.NewString()),
+		Metadata:         &core.WorkflowMetadata{},
+		Attempt:          4,
+	}
+
+	b.On("SuspendWorkflowTask", mock.Anything, task, "workflow task exceeded maximum attempts (3)").Return(nil).Once()
+
+	ww.handle(context.Background(), task)
+
+	b.AssertNumberOfCalls(t, "SuspendWorkflowTask", 1)
+}
+
+type testExecutorCache struct {
+	storeErr error
+}
+
+func (c *testExecutorCache) Store(ctx context.Context, instance *core.WorkflowInstance, executor workflow.WorkflowExecutor) error {
+	return c.storeErr
+}
+
+func (c *testExecutorCache) Get(ctx context.Context, instance *core.WorkflowInstance) (workflow.WorkflowExecutor, bool, error) {
+	return nil, false, nil
+}
+
+func (c *testExecutorCache) Evict(ctx context.Context, instance *core.WorkflowInstance) error {
+	return nil
+}
+
+func (c *testExecutorCache) StartEviction(ctx context.Context) {}
+
+func Test_WorkflowWorker_GetExecutor_NotCached(t *testing.T) {
+	tests := []struct {
+		name  string
+		cache *testExecutorCache
+	}{
+		{"store fails", &testExecutorCache{storeErr: errors.New("store failed")}},
+		{"cache does not keep executors", &testExecutorCache{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &backend.MockBackend{}
+			b.On("Logger").Return(logger.NewDefaultLogger())
+			b.On("Metrics").Return(metrics.NewNoopMetricsClient())
+			b.On("Tracer").Return(trace.NewNoopTracerProvider().Tracer("test"))
+
+			options := DefaultOptions
+			options.WorkflowExecutorCache = tt.cache
+
+			ww := NewWorkflowWorker(b, workflow.NewRegistry(), &options)
+
+			task := &task.Workflow{
+				ID:               uuid.NewString(),
+				WorkflowInstance: core.NewWorkflowInstance(uuid.NewString(), uuid.NewString()),
+				Metadata:         &core.WorkflowMetadata{},
+			}
+
+			executor, cached, err := ww.getExecutor(context.Background(), task)
+			require.NoError(t, err)
+			require.NotNil(t, executor)
+			require.False(t, cached)
+		})
+	}
+}
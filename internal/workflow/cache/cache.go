@@ -13,18 +13,42 @@ import (
 
 type LruCache struct {
 	mc metrics.Client
-	c  *ttlcache.Cache[string, workflow.WorkflowExecutor]
+	c  *ttlcache.Cache[string, *cacheEntry]
 }
 
-func NewWorkflowExecutorLRUCache(mc metrics.Client, size int, expiration time.Duration) workflow.ExecutorCache {
+type cacheEntry struct {
+	instance *core.WorkflowInstance
+	executor workflow.WorkflowExecutor
+}
+
+type cacheOptions struct {
+	onEvicted func(ctx context.Context, instance *core.WorkflowInstance)
+}
+
+type CacheOption func(*cacheOptions)
+
+// WithEvictionCallback sets a function that is called, asynchronously, for every workflow instance whose executor
+// is evicted from the cache
+func WithEvictionCallback(fn func(ctx context.Context, instance *core.WorkflowInstance)) CacheOption {
+	return func(o *cacheOptions) {
+		o.onEvicted = fn
+	}
+}
+
+func NewWorkflowExecutorLRUCache(mc metrics.Client, size int, expiration time.Duration, opts ...CacheOption) workflow.ExecutorCache {
+	options := &cacheOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
 	c := ttlcache.New(
-		ttlcache.WithCapacity[string, workflow.WorkflowExecutor](uint64(size)),
-		ttlcache.WithTTL[string, workflow.WorkflowExecutor](expiration),
+		ttlcache.WithCapacity[string, *cacheEntry](uint64(size)),
+		ttlcache.WithTTL[string, *cacheEntry](expiration),
 	)
 
-	c.OnEviction(func(ctx context.Context, er ttlcache.EvictionReason, i *ttlcache.Item[string, workflow.WorkflowExecutor]) {
+	c.OnEviction(func(ctx context.Context, er ttlcache.EvictionReason, i *ttlcache.Item[string, *cacheEntry]) {
 		// Close the executor to allow it to clean up resources.
-		i.Value().Close()
+		i.Value().executor.Close()
 
 		reason := ""
 		switch er {
@@ -32,9 +56,15 @@ func NewWorkflowExecutorLRUCache(mc metrics.Client, size int, expiration time.Du
 			reason = "expired"
 		case ttlcache.EvictionReasonCapacityReached:
 			reason = "capacity"
+		case ttlcache.EvictionReasonDeleted:
+			reason = "deleted"
 		}
 
 		mc.Counter(metrickeys.WorkflowInstanceCacheEviction, metrics.Tags{metrickeys.EvictionReason: reason}, 1)
+
+		if options.onEvicted != nil {
+			options.onEvicted(ctx, i.Value().instance)
+		}
 	})
 
 	return &LruCache{
@@ -46,14 +76,22 @@ func NewWorkflowExecutorLRUCache(mc metrics.Client, size int, expiration time.Du
 func (lc *LruCache) Get(ctx context.Context, instance *core.WorkflowInstance) (workflow.WorkflowExecutor, bool, error) {
 	e := lc.c.Get(getKey(instance))
 	if e != nil {
-		return e.Value(), true, nil
+		return e.Value().executor, true, nil
 	}
 
 	return nil, false, nil
 }
 
 func (lc *LruCache) Store(ctx context.Context, instance *core.WorkflowInstance, executor workflow.WorkflowExecutor) error {
-	lc.c.Set(getKey(instance), executor, ttlcache.DefaultTTL)
+	lc.c.Set(getKey(instance), &cacheEntry{instance: instance, executor: executor}, ttlcache.DefaultTTL)
+
+	lc.mc.Gauge(metrickeys.WorkflowInstanceCacheSize, metrics.Tags{}, int64(lc.c.Len()))
+
+	return nil
+}
+
+func (lc *LruCache) Evict(ctx context.Context, instance *core.WorkflowInstance) error {
+	lc.c.Delete(getKey(instance))
 
 	lc.mc.Gauge(metrickeys.WorkflowInstanceCacheSize, metrics.Tags{}, int64(lc.c.Len()))
 
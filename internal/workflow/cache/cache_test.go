@@ -78,6 +78,28 @@ func Test_Cache_Evict(t *testing.T) {
 	require.Nil(t, e2)
 }
 
+func Test_Cache_EvictInstance(t *testing.T) {
+	c := NewWorkflowExecutorLRUCache(metrics.NewNoopMetricsClient(), 128, time.Second*10)
+
+	i := core.NewWorkflowInstance("instanceID", "executionID")
+	r := wf.NewRegistry()
+	r.RegisterWorkflow(workflowWithActivity)
+	e, err := wf.NewExecutor(
+		logger.NewDefaultLogger(), trace.NewNoopTracerProvider().Tracer(backend.TracerName), r, &testHistoryProvider{}, i, clock.New())
+	require.NoError(t, err)
+
+	require.NoError(t, c.Store(context.Background(), i, e))
+	require.NoError(t, c.Evict(context.Background(), i))
+
+	e2, ok, err := c.Get(context.Background(), i)
+	require.NoError(t, err)
+	require.False(t, ok)
+	require.Nil(t, e2)
+
+	// Evicting an instance that is not cached is not an error
+	require.NoError(t, c.Evict(context.Background(), i))
+}
+
 func workflowWithActivity(ctx workflow.Context) (int, error) {
 	r, err := workflow.ExecuteActivity[int](ctx, workflow.ActivityOptions{
 		RetryOptions: workflow.RetryOptions{
@@ -102,3 +124,37 @@ type testHistoryProvider struct {
 func (t *testHistoryProvider) GetWorkflowInstanceHistory(ctx context.Context, instance *core.WorkflowInstance, lastSequenceID *int64) ([]history.Event, error) {
 	return t.history, nil
 }
+
+func Test_Cache_EvictionCallback(t *testing.T) {
+	evicted := make(chan *core.WorkflowInstance, 1)
+
+	c := NewWorkflowExecutorLRUCache(metrics.NewNoopMetricsClient(), 1, time.Second*10,
+		WithEvictionCallback(func(ctx context.Context, instance *core.WorkflowInstance) {
+			evicted <- instance
+		}))
+
+	r := wf.NewRegistry()
+	r.RegisterWorkflow(workflowWithActivity)
+
+	i := core.NewWorkflowInstance("instanceID", "executionID")
+	e, err := wf.NewExecutor(
+		logger.NewDefaultLogger(), trace.NewNoopTracerProvider().Tracer(backend.TracerName), r, &testHistoryProvider{}, i, clock.New())
+	require.NoError(t, err)
+
+	i2 := core.NewWorkflowInstance("instanceID2", "executionID2")
+	e2, err := wf.NewExecutor(
+		logger.NewDefaultLogger(), trace.NewNoopTracerProvider().Tracer(backend.TracerName), r, &testHistoryProvider{}, i2, clock.New())
+	require.NoError(t, err)
+
+	require.NoError(t, c.Store(context.Background(), i, e))
+
+	// Storing another executor evicts the first one
+	require.NoError(t, c.Store(context.Background(), i2, e2))
+
+	select {
+	case instance := <-evicted:
+		require.Equal(t, i, instance)
+	case <-time.After(time.Second):
+		require.Fail(t, "eviction callback not called")
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	gosync "sync"
+	"time"
 
 	"github.com/benbjohnson/clock"
 	"github.com/paveliak/go-workflows/internal/command"
@@ -22,7 +24,11 @@ import (
 )
 
 type ExecutionResult struct {
-	Completed      bool
+	Completed bool
+
+	// State is the state of the workflow instance after the task, one of the finished states if Completed is set
+	State core.WorkflowInstanceState
+
 	Executed       []history.Event
 	ActivityEvents []history.Event
 	TimerEvents    []history.Event
@@ -36,10 +42,16 @@ type WorkflowHistoryProvider interface {
 type WorkflowExecutor interface {
 	ExecuteTask(ctx context.Context, t *task.Workflow) (*ExecutionResult, error)
 
+	// Query runs the given query against the current state of the workflow execution
+	Query(ctx context.Context, query string) (payload.Payload, error)
+
 	Close()
 }
 
 type executor struct {
+	// mu guards the workflow state, queries can be run concurrently with task executions
+	mu gosync.Mutex
+
 	registry           *Registry
 	historyProvider    WorkflowHistoryProvider
 	workflow           *workflow
@@ -52,9 +64,50 @@ type executor struct {
 	tracer             trace.Tracer
 	lastSequenceID     int64
 	wfStartedEventSeen bool
+	options            *executorOptions
+}
+
+type executorOptions struct {
+	nonDeterminismPolicy NonDeterminismPolicy
+	panicPolicy          PanicPolicy
+	deadlockTimeout      time.Duration
+}
+
+type ExecutorOption func(*executorOptions)
+
+// WithNonDeterminismPolicy sets how the executor handles nondeterminism detected while replaying history
+func WithNonDeterminismPolicy(policy NonDeterminismPolicy) ExecutorOption {
+	return func(o *executorOptions) {
+		o.nonDeterminismPolicy = policy
+	}
 }
 
-func NewExecutor(logger log.Logger, tracer trace.Tracer, registry *Registry, historyProvider WorkflowHistoryProvider, instance *core.WorkflowInstance, clock clock.Clock) (WorkflowExecutor, error) {
+// WithPanicPolicy sets how the executor handles panics in workflow code
+func WithPanicPolicy(policy PanicPolicy) ExecutorOption {
+	return func(o *executorOptions) {
+		o.panicPolicy = policy
+	}
+}
+
+// WithDeadlockTimeout sets the time workflow code can run without yielding before the task fails with a
+// sync.DeadlockError
+func WithDeadlockTimeout(timeout time.Duration) ExecutorOption {
+	return func(o *executorOptions) {
+		o.deadlockTimeout = timeout
+	}
+}
+
+func NewExecutor(logger log.Logger, tracer trace.Tracer, registry *Registry, historyProvider WorkflowHistoryProvider, instance *core.WorkflowInstance, clock clock.Clock, opts ...ExecutorOption) (WorkflowExecutor, error) {
+	options := &executorOptions{
+		nonDeterminismPolicy: NonDeterminismPolicyFailWorkflow,
+		panicPolicy:          PanicPolicyFailWorkflow,
+		deadlockTimeout:      sync.DeadlockDetection,
+	}
+
+	for _, opt := range opts {
+		opt(options)
+	}
+
 	s := workflowstate.NewWorkflowState(instance, logger, clock)
 
 	wfTracer := workflowtracer.New(tracer)
@@ -80,10 +133,14 @@ func NewExecutor(logger log.Logger, tracer trace.Tracer, registry *Registry, his
 		logger:             logger,
 		tracer:             tracer,
 		wfStartedEventSeen: false,
+		options:            options,
 	}, nil
 }
 
 func (e *executor) ExecuteTask(ctx context.Context, t *task.Workflow) (*ExecutionResult, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
 	ctx = tracing.UnmarshalSpan(ctx, t.Metadata)
 	ctx, span := e.tracer.Start(ctx, "WorkflowTaskExecution", trace.WithAttributes(
 		attribute.String(tracing.WorkflowInstanceID, t.WorkflowInstance.InstanceID),
@@ -101,7 +158,7 @@ func (e *executor) ExecuteTask(ctx context.Context, t *task.Workflow) (*Executio
 
 	logger.Debug("Executing workflow task", "task_last_sequence_id", t.LastSequenceID)
 
-	if t.WorkflowInstanceState == core.WorkflowInstanceStateFinished {
+	if t.WorkflowInstanceState.Finished() {
 		// This could happen if signals are delivered after the workflow is finished
 		logger.Error("Received workflow task for finished workflow instance, discarding events")
 
@@ -112,9 +169,20 @@ func (e *executor) ExecuteTask(ctx context.Context, t *task.Workflow) (*Executio
 
 		return &ExecutionResult{
 			Completed: true,
+			State:     t.WorkflowInstanceState,
 		}, nil
 	}
 
+	// Terminated instances are stopped without replaying history or running any more workflow code. This
+	// allows stopping instances that cannot make progress, for example because of broken workflow code.
+	for _, event := range t.NewEvents {
+		if event.Type == history.EventType_WorkflowExecutionTerminated {
+			logger.Debug("Terminating workflow instance")
+
+			return e.terminate(t), nil
+		}
+	}
+
 	skipNewEvents := false
 
 	if t.LastSequenceID > e.lastSequenceID {
@@ -128,6 +196,28 @@ func (e *executor) ExecuteTask(ctx context.Context, t *task.Workflow) (*Executio
 		if err := e.replayHistory(h); err != nil {
 			logger.Error("Error while replaying history", "error", err)
 
+			var nerr *NonDeterminismError
+			if errors.As(err, &nerr) && e.options.nonDeterminismPolicy == NonDeterminismPolicyBlockWorkflow {
+				// Leave the task to be retried, the executor cannot be used anymore
+				return nil, err
+			}
+
+			var derr *sync.DeadlockError
+			if errors.As(err, &derr) {
+				// Fail the task, the executor cannot be used anymore
+				return nil, err
+			}
+
+			if errors.Is(err, errOlderHistory) {
+				// The executor state is inconsistent, not the workflow. Fail the task so that it's retried with a
+				// new executor.
+				return nil, err
+			}
+
+			if e.blockOnPanic(err) {
+				return nil, err
+			}
+
 			// Fail workflow with an error. Skip executing new events, but still go through the commands
 			e.workflowCompleted(nil, err)
 			skipNewEvents = true
@@ -186,6 +276,16 @@ func (e *executor) ExecuteTask(ctx context.Context, t *task.Workflow) (*Executio
 		if err != nil {
 			logger.Error("Error while executing new events", "error", err)
 
+			var derr *sync.DeadlockError
+			if errors.As(err, &derr) {
+				// Fail the task, the executor cannot be used anymore
+				return nil, err
+			}
+
+			if e.blockOnPanic(err) {
+				return nil, err
+			}
+
 			e.workflowCompleted(nil, err)
 		}
 	}
@@ -228,8 +328,14 @@ func (e *executor) ExecuteTask(ctx context.Context, t *task.Workflow) (*Executio
 		"completed", completed,
 	)
 
+	state := core.WorkflowInstanceStateActive
+	if completed {
+		state = e.finishedState(newCommandEvents)
+	}
+
 	return &ExecutionResult{
 		Completed:      completed,
+		State:          state,
 		Executed:       executedEvents,
 		ActivityEvents: activityEvents,
 		TimerEvents:    timerEvents,
@@ -237,11 +343,77 @@ func (e *executor) ExecuteTask(ctx context.Context, t *task.Workflow) (*Executio
 	}, nil
 }
 
+// terminate records the new events of the task and finishes the workflow instance as terminated
+func (e *executor) terminate(t *task.Workflow) *ExecutionResult {
+	// History is not replayed, continue with the sequence ids of the task
+	if t.LastSequenceID > e.lastSequenceID {
+		e.lastSequenceID = t.LastSequenceID
+	}
+
+	executedEvents := []history.Event{e.createNewEvent(history.EventType_WorkflowTaskStarted, &history.WorkflowTaskStartedAttributes{})}
+
+	// Record all new events without executing them, keep the termination event last
+	var terminationEvents []history.Event
+	for _, event := range t.NewEvents {
+		if event.Type == history.EventType_WorkflowExecutionTerminated {
+			terminationEvents = append(terminationEvents, event)
+		} else {
+			executedEvents = append(executedEvents, event)
+		}
+	}
+	executedEvents = append(executedEvents, terminationEvents...)
+
+	for i := range executedEvents {
+		executedEvents[i].SequenceID = e.nextSequenceID()
+	}
+
+	workflowEvents := make([]history.WorkflowEvent, 0)
+
+	instance := e.workflowState.Instance()
+	if instance.SubWorkflow() {
+		// Let the parent workflow instance know that the sub-workflow is not going to complete
+		msg := "workflow terminated"
+		if a := terminationEvents[0].Attributes.(*history.ExecutionTerminatedAttributes); a.Reason != "" {
+			msg += ": " + a.Reason
+		}
+
+		workflowEvents = append(workflowEvents, history.WorkflowEvent{
+			WorkflowInstance: core.NewWorkflowInstance(instance.ParentInstanceID, ""),
+			HistoryEvent: history.NewPendingEvent(
+				e.clock.Now(),
+				history.EventType_SubWorkflowFailed,
+				&history.SubWorkflowFailedAttributes{
+					Error:               msg,
+					SubWorkflowInstance: instance,
+				},
+				history.ScheduleEventID(instance.ParentEventID),
+			),
+		})
+	}
+
+	return &ExecutionResult{
+		Completed:      true,
+		State:          core.WorkflowInstanceStateTerminated,
+		Executed:       executedEvents,
+		ActivityEvents: []history.Event{},
+		TimerEvents:    []history.Event{},
+		WorkflowEvents: workflowEvents,
+	}
+}
+
+// blockOnPanic returns true if err was caused by a panic in workflow code and the task should be left to be retried
+func (e *executor) blockOnPanic(err error) bool {
+	var perr *sync.PanicError
+	return errors.As(err, &perr) && e.options.panicPolicy == PanicPolicyBlockWorkflow
+}
+
+var errOlderHistory = errors.New("history has older events than current state")
+
 func (e *executor) replayHistory(h []history.Event) error {
 	e.workflowState.SetReplaying(true)
 	for _, event := range h {
 		if event.SequenceID < e.lastSequenceID {
-			e.logger.Panic("history has older events than current state")
+			return fmt.Errorf("%w: event %d, current state %d", errOlderHistory, event.SequenceID, e.lastSequenceID)
 		}
 
 		if err := e.executeEvent(event); err != nil {
@@ -259,6 +431,13 @@ func (e *executor) replayHistory(h []history.Event) error {
 		e.lastSequenceID = event.SequenceID
 	}
 
+	// Every command produced while replaying has to be matched by an event in the history
+	for _, c := range e.workflowState.Commands() {
+		if c.State() == command.CommandState_Pending {
+			return newUnmatchedCommandError(c)
+		}
+	}
+
 	return nil
 }
 
@@ -284,6 +463,9 @@ func (e *executor) executeNewEvents(newEvents []history.Event) ([]history.Event,
 }
 
 func (e *executor) Close() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
 	if e.workflow != nil {
 		e.logger.Debug("Stopping workflow executor", "instance_id", e.workflowState.Instance().InstanceID)
 
@@ -314,6 +496,9 @@ func (e *executor) executeEvent(event history.Event) error {
 	case history.EventType_WorkflowExecutionCanceled:
 		err = e.handleWorkflowCanceled()
 
+	case history.EventType_WorkflowExecutionReset:
+	// Ignore, only marks the start of a new execution in the history
+
 	case history.EventType_WorkflowTaskStarted:
 		err = e.handleWorkflowTaskStarted(event, event.Attributes.(*history.WorkflowTaskStartedAttributes))
 
@@ -353,6 +538,12 @@ func (e *executor) executeEvent(event history.Event) error {
 	case history.EventType_SignalWorkflow:
 		err = e.handleSignalWorkflow(event, event.Attributes.(*history.SignalWorkflowAttributes))
 
+	case history.EventType_SearchAttributesUpserted:
+		err = e.handleSearchAttributesUpserted(event, event.Attributes.(*history.SearchAttributesUpsertedAttributes))
+
+	case history.EventType_WorkflowExecutionTerminated:
+		// Terminated instances don't run any more workflow code, nothing to do
+
 	default:
 		return fmt.Errorf("unknown event type: %v", event.Type)
 	}
@@ -366,7 +557,9 @@ func (e *executor) handleWorkflowExecutionStarted(a *history.ExecutionStartedAtt
 		return fmt.Errorf("workflow %s not found", a.Name)
 	}
 
-	e.workflow = NewWorkflow(reflect.ValueOf(wfFn))
+	e.workflowState.SetMemo(a.Memo)
+
+	e.workflow = NewWorkflow(reflect.ValueOf(wfFn), sync.WithDeadlockTimeout(e.options.deadlockTimeout))
 
 	return e.workflow.Execute(e.workflowCtx, a.Inputs)
 }
@@ -385,18 +578,11 @@ func (e *executor) handleWorkflowTaskStarted(event history.Event, a *history.Wor
 
 func (e *executor) handleActivityScheduled(event history.Event, a *history.ActivityScheduledAttributes) error {
 	c := e.workflowState.CommandByScheduleEventID(event.ScheduleEventID)
-	if c == nil {
-		return fmt.Errorf("previous workflow execution scheduled an activity which could not be found")
-	}
-
-	sac, ok := c.(*command.ScheduleActivityCommand)
-	if !ok {
-		return fmt.Errorf("previous workflow execution scheduled an activity, not: %v", c.Type())
-	}
 
 	// Ensure the same activity was scheduled again
-	if a.Name != sac.Name {
-		return fmt.Errorf("previous workflow execution scheduled different type of activity: %s, %s", a.Name, sac.Name)
+	sac, ok := c.(*command.ScheduleActivityCommand)
+	if !ok || a.Name != sac.Name {
+		return newNonDeterminismError(event, c)
 	}
 
 	c.Commit()
@@ -461,12 +647,8 @@ func (e *executor) handleActivityFailed(event history.Event, a *history.Activity
 
 func (e *executor) handleTimerScheduled(event history.Event, a *history.TimerScheduledAttributes) error {
 	c := e.workflowState.CommandByScheduleEventID(event.ScheduleEventID)
-	if c == nil {
-		return fmt.Errorf("previous workflow execution scheduled a timer")
-	}
-
 	if _, ok := c.(*command.ScheduleTimerCommand); !ok {
-		return fmt.Errorf("previous workflow execution scheduled a timer, not: %v", c.Type())
+		return newNonDeterminismError(event, c)
 	}
 
 	c.Commit()
@@ -503,13 +685,9 @@ func (e *executor) handleTimerFired(event history.Event, a *history.TimerFiredAt
 
 func (e *executor) handleTimerCanceled(event history.Event, a *history.TimerCanceledAttributes) error {
 	c := e.workflowState.CommandByScheduleEventID(event.ScheduleEventID)
-	if c == nil {
-		return fmt.Errorf("previous workflow execution canceled a timer")
-	}
-
 	stc, ok := c.(*command.ScheduleTimerCommand)
 	if !ok {
-		return fmt.Errorf("previous workflow execution canceled a timer, not: %v", c.Type())
+		return newNonDeterminismError(event, c)
 	}
 
 	stc.HandleCancel()
@@ -532,17 +710,11 @@ func (e *executor) handleTimerCanceled(event history.Event, a *history.TimerCanc
 
 func (e *executor) handleSubWorkflowScheduled(event history.Event, a *history.SubWorkflowScheduledAttributes) error {
 	c := e.workflowState.CommandByScheduleEventID(event.ScheduleEventID)
-	if c == nil {
-		return fmt.Errorf("previous workflow execution scheduled a sub workflow")
-	}
 
+	// Ensure the same sub workflow was scheduled again
 	sswc, ok := c.(*command.ScheduleSubWorkflowCommand)
-	if !ok {
-		return fmt.Errorf("previous workflow execution scheduled a sub workflow, not: %v", c.Type())
-	}
-
-	if a.Name != sswc.Name {
-		return fmt.Errorf("previous workflow execution scheduled different type of sub workflow: %s, %s", a.Name, sswc.Name)
+	if !ok || a.Name != sswc.Name {
+		return newNonDeterminismError(event, c)
 	}
 
 	// If we are replaying this event, the command will have generated a new instance ID. Ensure we use the same one as
@@ -556,13 +728,9 @@ func (e *executor) handleSubWorkflowScheduled(event history.Event, a *history.Su
 
 func (e *executor) handleSubWorkflowCancellationRequest(event history.Event, a *history.SubWorkflowCancellationRequestedAttributes) error {
 	c := e.workflowState.CommandByScheduleEventID(event.ScheduleEventID)
-	if c == nil {
-		return fmt.Errorf("previous workflow execution cancelled a sub-workflow execution")
-	}
-
 	sswc, ok := c.(*command.ScheduleSubWorkflowCommand)
 	if !ok {
-		return fmt.Errorf("previous workflow execution cancelled a sub-workflow execution, not: %v", c.Type())
+		return newNonDeterminismError(event, c)
 	}
 
 	sswc.HandleCancel()
@@ -571,6 +739,10 @@ func (e *executor) handleSubWorkflowCancellationRequest(event history.Event, a *
 }
 
 func (e *executor) handleSubWorkflowFailed(event history.Event, a *history.SubWorkflowFailedAttributes) error {
+	if e.staleSubWorkflowResult(event, a.SubWorkflowInstance) {
+		return nil
+	}
+
 	f, ok := e.workflowState.FutureByScheduleEventID(event.ScheduleEventID)
 	if !ok {
 		return errors.New("no pending future found for sub workflow failed event")
@@ -599,6 +771,10 @@ func (e *executor) handleSubWorkflowFailed(event history.Event, a *history.SubWo
 }
 
 func (e *executor) handleSubWorkflowCompleted(event history.Event, a *history.SubWorkflowCompletedAttributes) error {
+	if e.staleSubWorkflowResult(event, a.SubWorkflowInstance) {
+		return nil
+	}
+
 	f, ok := e.workflowState.FutureByScheduleEventID(event.ScheduleEventID)
 	if !ok {
 		return errors.New("no pending future found for sub workflow completed event")
@@ -626,6 +802,27 @@ func (e *executor) handleSubWorkflowCompleted(event history.Event, a *history.Su
 	return e.workflow.Continue()
 }
 
+// staleSubWorkflowResult returns true if a sub-workflow result was sent by a sub-workflow of a previous execution.
+// After a reset, the schedule event ids of those sub-workflows can be reused by the current execution.
+func (e *executor) staleSubWorkflowResult(event history.Event, subWorkflowInstance *core.WorkflowInstance) bool {
+	// Results sent without their instance cannot be matched
+	if subWorkflowInstance == nil {
+		return false
+	}
+
+	c, ok := e.workflowState.CommandByScheduleEventID(event.ScheduleEventID).(*command.ScheduleSubWorkflowCommand)
+	if ok && c.Instance.InstanceID == subWorkflowInstance.InstanceID && c.Instance.ExecutionID == subWorkflowInstance.ExecutionID {
+		return false
+	}
+
+	e.logger.Debug("Discarding result of sub-workflow of previous execution",
+		"instance_id", e.workflowState.Instance().InstanceID,
+		"sub_workflow_instance_id", subWorkflowInstance.InstanceID,
+		"schedule_event_id", event.ScheduleEventID)
+
+	return true
+}
+
 func (e *executor) handleSignalReceived(event history.Event, a *history.SignalReceivedAttributes) error {
 	// Send signal to workflow channel
 	workflowstate.ReceiveSignal(e.workflowState, a.Name, a.Arg)
@@ -635,13 +832,12 @@ func (e *executor) handleSignalReceived(event history.Event, a *history.SignalRe
 
 func (e *executor) handleSignalWorkflow(event history.Event, a *history.SignalWorkflowAttributes) error {
 	c := e.workflowState.CommandByScheduleEventID(event.ScheduleEventID)
-	if c == nil {
-		return fmt.Errorf("previous workflow execution requested a signal")
-	}
 
+	// Ensure the same workflow instance is signaled again. Histories recorded before the target instance was
+	// stored in the event only allow checking the signal name.
 	sewc, ok := c.(*command.SignalWorkflowCommand)
-	if !ok {
-		return fmt.Errorf("previous workflow execution requested to signal a workflow, not: %v", c.Type())
+	if !ok || a.Name != sewc.Name || (a.InstanceID != "" && a.InstanceID != sewc.Instance.InstanceID) {
+		return newNonDeterminismError(event, c)
 	}
 
 	sewc.Done()
@@ -649,15 +845,23 @@ func (e *executor) handleSignalWorkflow(event history.Event, a *history.SignalWo
 	return e.workflow.Continue()
 }
 
-func (e *executor) handleSideEffectResult(event history.Event, a *history.SideEffectResultAttributes) error {
+func (e *executor) handleSearchAttributesUpserted(event history.Event, a *history.SearchAttributesUpsertedAttributes) error {
 	c := e.workflowState.CommandByScheduleEventID(event.ScheduleEventID)
-	if c == nil {
-		return fmt.Errorf("previous workflow execution scheduled a side effect")
+	uc, ok := c.(*command.UpsertSearchAttributesCommand)
+	if !ok {
+		return newNonDeterminismError(event, c)
 	}
 
+	uc.Done()
+
+	return e.workflow.Continue()
+}
+
+func (e *executor) handleSideEffectResult(event history.Event, a *history.SideEffectResultAttributes) error {
+	c := e.workflowState.CommandByScheduleEventID(event.ScheduleEventID)
 	sec, ok := c.(*command.SideEffectCommand)
 	if !ok {
-		return fmt.Errorf("previous workflow execution scheduled a side effect, not: %v", c.Type())
+		return newNonDeterminismError(event, c)
 	}
 
 	sec.Done()
@@ -683,6 +887,28 @@ func (e *executor) workflowCompleted(result payload.Payload, err error) {
 	e.workflowState.AddCommand(cmd)
 }
 
+// finishedState determines the outcome of a workflow instance from the events of the task that finished it
+func (e *executor) finishedState(events []history.Event) core.WorkflowInstanceState {
+	for _, event := range events {
+		if event.Type != history.EventType_WorkflowExecutionFinished {
+			continue
+		}
+
+		a := event.Attributes.(*history.ExecutionCompletedAttributes)
+		if a.Error == "" {
+			return core.WorkflowInstanceStateCompleted
+		}
+
+		if e.workflowCtx.Err() != nil {
+			return core.WorkflowInstanceStateCanceled
+		}
+
+		return core.WorkflowInstanceStateFailed
+	}
+
+	return core.WorkflowInstanceStateCompleted
+}
+
 func (e *executor) nextSequenceID() int64 {
 	e.lastSequenceID++
 	return e.lastSequenceID
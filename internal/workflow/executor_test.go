@@ -66,11 +66,12 @@ func Test_Executor(t *testing.T) {
 
 				task := startWorkflowTask(i.InstanceID, wf)
 
-				_, err := e.ExecuteTask(context.Background(), task)
+				result, err := e.ExecuteTask(context.Background(), task)
 				require.NoError(t, err)
 
 				require.Equal(t, 1, workflowHits)
 				require.True(t, e.workflow.Completed())
+				require.Equal(t, core.WorkflowInstanceStateCompleted, result.State)
 				require.Len(t, e.workflowState.Commands(), 1)
 				require.IsType(t, &command.CompleteWorkflowCommand{}, e.workflowState.Commands()[0])
 			},
@@ -489,6 +490,90 @@ func Test_Executor(t *testing.T) {
 				require.Len(t, e.workflowState.Commands(), 1)
 				require.Len(t, pendingCommands(e.workflowState.Commands()), 0)
 				require.True(t, r1.Completed)
+				require.Equal(t, core.WorkflowInstanceStateFailed, r1.State)
+			},
+		},
+		{
+			name: "Canceled workflow finishes as canceled",
+			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
+				wf := func(ctx sync.Context) error {
+					ctx.Done().Receive(ctx)
+
+					return ctx.Err()
+				}
+
+				r.RegisterWorkflow(wf)
+
+				result, err := e.ExecuteTask(context.Background(), startWorkflowTask(i.InstanceID, wf))
+				require.NoError(t, err)
+				require.False(t, result.Completed)
+				require.Equal(t, core.WorkflowInstanceStateActive, result.State)
+
+				hp.history = append(hp.history, result.Executed...)
+				result, err = e.ExecuteTask(context.Background(), continueTask(i.InstanceID, []history.Event{
+					history.NewWorkflowCancellationEvent(time.Now()),
+				}, result.Executed[len(result.Executed)-1].SequenceID))
+				require.NoError(t, err)
+				require.True(t, result.Completed)
+				require.Equal(t, core.WorkflowInstanceStateCanceled, result.State)
+			},
+		},
+		{
+			name: "Terminated workflow finishes without running workflow code",
+			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
+				signalReceived := false
+				wf := func(ctx sync.Context) error {
+					wf.NewSignalChannel[string](ctx, "signal").Receive(ctx)
+					signalReceived = true
+
+					return nil
+				}
+
+				r.RegisterWorkflow(wf)
+
+				result, err := e.ExecuteTask(context.Background(), startWorkflowTask(i.InstanceID, wf))
+				require.NoError(t, err)
+				require.False(t, result.Completed)
+
+				hp.history = append(hp.history, result.Executed...)
+				lastSequenceID := result.Executed[len(result.Executed)-1].SequenceID
+				result, err = e.ExecuteTask(context.Background(), continueTask(i.InstanceID, []history.Event{
+					history.NewWorkflowTerminationEvent(time.Now(), "stuck"),
+					history.NewPendingEvent(time.Now(), history.EventType_SignalReceived, &history.SignalReceivedAttributes{
+						Name: "signal",
+					}),
+				}, lastSequenceID))
+				require.NoError(t, err)
+				require.True(t, result.Completed)
+				require.Equal(t, core.WorkflowInstanceStateTerminated, result.State)
+				require.False(t, signalReceived)
+				require.Empty(t, result.WorkflowEvents)
+
+				// New events are recorded, the termination event last
+				require.Len(t, result.Executed, 3)
+				require.Equal(t, history.EventType_WorkflowTaskStarted, result.Executed[0].Type)
+				require.Equal(t, history.EventType_SignalReceived, result.Executed[1].Type)
+				require.Equal(t, history.EventType_WorkflowExecutionTerminated, result.Executed[2].Type)
+				require.Equal(t, lastSequenceID+3, result.Executed[2].SequenceID)
+			},
+		},
+		{
+			name: "Panic blocks workflow task",
+			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
+				e.options.panicPolicy = PanicPolicyBlockWorkflow
+
+				workflowPanic := func(ctx sync.Context) error {
+					panic("wf error")
+				}
+
+				r.RegisterWorkflow(workflowPanic)
+
+				_, err := e.ExecuteTask(context.Background(), startWorkflowTask(i.InstanceID, workflowPanic))
+
+				var perr *sync.PanicError
+				require.ErrorAs(t, err, &perr)
+				require.Equal(t, "wf error", perr.Value)
+				require.Contains(t, perr.Stack, "internal/workflow.Test_Executor")
 			},
 		},
 		{
@@ -519,6 +604,56 @@ func Test_Executor(t *testing.T) {
 				require.Equal(t, history.EventType_WorkflowExecutionStarted, result.WorkflowEvents[0].HistoryEvent.Type)
 			},
 		},
+		{
+			name: "Discards result of subworkflow of previous execution",
+			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
+				subworkflow := func(ctx wf.Context) error {
+					return nil
+				}
+
+				workflow := func(ctx wf.Context) error {
+					_, err := wf.CreateSubWorkflowInstance[any](ctx, wf.SubWorkflowOptions{
+						InstanceID: "subworkflow",
+					}, subworkflow).Get(ctx)
+
+					return err
+				}
+
+				r.RegisterWorkflow(workflow)
+				r.RegisterWorkflow(subworkflow)
+
+				result, err := e.ExecuteTask(context.Background(), startWorkflowTask("instanceID", workflow))
+				require.NoError(t, err)
+				require.Len(t, result.WorkflowEvents, 1)
+
+				subWorkflowInstance := result.WorkflowEvents[0].WorkflowInstance
+
+				// Result of a sub-workflow scheduled with the same schedule event id before the instance was reset
+				swr, _ := converter.DefaultConverter.To(nil)
+				hp.history = append(hp.history, result.Executed...)
+				result, err = e.ExecuteTask(context.Background(), continueTask("instanceID", []history.Event{
+					history.NewPendingEvent(time.Now(), history.EventType_SubWorkflowCompleted, &history.SubWorkflowCompletedAttributes{
+						Result:              swr,
+						SubWorkflowInstance: core.NewWorkflowInstance(subWorkflowInstance.InstanceID, "previousExecutionID"),
+					}, history.ScheduleEventID(1)),
+				}, result.Executed[len(result.Executed)-1].SequenceID))
+
+				require.NoError(t, err)
+				require.False(t, e.workflow.Completed())
+
+				hp.history = append(hp.history, result.Executed...)
+				result, err = e.ExecuteTask(context.Background(), continueTask("instanceID", []history.Event{
+					history.NewPendingEvent(time.Now(), history.EventType_SubWorkflowCompleted, &history.SubWorkflowCompletedAttributes{
+						Result:              swr,
+						SubWorkflowInstance: subWorkflowInstance,
+					}, history.ScheduleEventID(1)),
+				}, result.Executed[len(result.Executed)-1].SequenceID))
+
+				require.NoError(t, err)
+				require.True(t, e.workflow.Completed())
+				require.Equal(t, core.WorkflowInstanceStateCompleted, result.State)
+			},
+		},
 		{
 			name: "Schedule and cancel subworkflow",
 			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
@@ -636,6 +771,165 @@ func Test_Executor(t *testing.T) {
 				require.Equal(t, history.EventType_TimerFired, result.Executed[3].Type)
 			},
 		},
+		{
+			name: "Replay with different activity fails workflow",
+			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
+				workflowWithActivity := func(ctx sync.Context) error {
+					_, err := wf.ExecuteActivity[int](ctx, wf.DefaultActivityOptions, activity1, 42).Get(ctx)
+					return err
+				}
+
+				r.RegisterWorkflow(workflowWithActivity)
+
+				hp.history = historyWithActivity(fn.Name(workflowWithActivity), "activity2")
+
+				result, err := e.ExecuteTask(context.Background(), continueTask(i.InstanceID, []history.Event{}, 2))
+				require.NoError(t, err)
+
+				finished := result.Executed[len(result.Executed)-1]
+				require.Equal(t, history.EventType_WorkflowExecutionFinished, finished.Type)
+				require.Contains(t, finished.Attributes.(*history.ExecutionCompletedAttributes).Error,
+					"history has ActivityScheduled(activity2), workflow code produced ScheduleActivity(activity1)")
+			},
+		},
+		{
+			name: "Replay with different activity blocks workflow",
+			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
+				e.options.nonDeterminismPolicy = NonDeterminismPolicyBlockWorkflow
+
+				workflowWithTimer := func(ctx sync.Context) error {
+					_, err := wf.ScheduleTimer(ctx, time.Second).Get(ctx)
+					return err
+				}
+
+				r.RegisterWorkflow(workflowWithTimer)
+
+				hp.history = historyWithActivity(fn.Name(workflowWithTimer), "activity1")
+
+				_, err := e.ExecuteTask(context.Background(), continueTask(i.InstanceID, []history.Event{}, 2))
+
+				var nerr *NonDeterminismError
+				require.ErrorAs(t, err, &nerr)
+				require.Equal(t, int64(1), nerr.ScheduleEventID)
+				require.Equal(t, "ActivityScheduled(activity1)", nerr.Expected)
+				require.Equal(t, "ScheduleTimer", nerr.Actual)
+			},
+		},
+		{
+			name: "Replay with missing command",
+			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
+				e.options.nonDeterminismPolicy = NonDeterminismPolicyBlockWorkflow
+
+				workflowWithoutActivity := func(ctx sync.Context) error {
+					return nil
+				}
+
+				r.RegisterWorkflow(workflowWithoutActivity)
+
+				hp.history = historyWithActivity(fn.Name(workflowWithoutActivity), "activity1")
+
+				_, err := e.ExecuteTask(context.Background(), continueTask(i.InstanceID, []history.Event{}, 2))
+
+				var nerr *NonDeterminismError
+				require.ErrorAs(t, err, &nerr)
+				require.Equal(t, "no command", nerr.Actual)
+			},
+		},
+		{
+			name: "Replay with extra command",
+			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
+				e.options.nonDeterminismPolicy = NonDeterminismPolicyBlockWorkflow
+
+				workflowWithTwoActivities := func(ctx sync.Context) error {
+					f1 := wf.ExecuteActivity[int](ctx, wf.DefaultActivityOptions, activity1, 42)
+					f2 := wf.ExecuteActivity[int](ctx, wf.DefaultActivityOptions, activity1, 23)
+
+					if _, err := f1.Get(ctx); err != nil {
+						return err
+					}
+
+					_, err := f2.Get(ctx)
+					return err
+				}
+
+				r.RegisterWorkflow(workflowWithTwoActivities)
+
+				hp.history = historyWithActivity(fn.Name(workflowWithTwoActivities), "activity1")
+
+				_, err := e.ExecuteTask(context.Background(), continueTask(i.InstanceID, []history.Event{}, 2))
+
+				var nerr *NonDeterminismError
+				require.ErrorAs(t, err, &nerr)
+				require.Equal(t, int64(2), nerr.ScheduleEventID)
+				require.Equal(t, "no event", nerr.Expected)
+				require.Equal(t, "ScheduleActivity(activity1)", nerr.Actual)
+			},
+		},
+		{
+			name: "Replay of older history fails task",
+			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
+				workflowWithActivity := func(ctx sync.Context) error {
+					_, err := wf.ExecuteActivity[int](ctx, wf.DefaultActivityOptions, activity1, 42).Get(ctx)
+					return err
+				}
+
+				r.RegisterWorkflow(workflowWithActivity)
+
+				hp.history = historyWithActivity(fn.Name(workflowWithActivity), "activity1")
+				e.lastSequenceID = 2
+
+				result, err := e.ExecuteTask(context.Background(), continueTask(i.InstanceID, []history.Event{}, 3))
+				require.ErrorIs(t, err, errOlderHistory)
+				require.Nil(t, result)
+			},
+		},
+		{
+			name: "Stack trace query",
+			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
+				workflowWithActivity := func(ctx sync.Context) error {
+					_, err := wf.ExecuteActivity[int](ctx, wf.DefaultActivityOptions, activity1, 42).Get(ctx)
+					return err
+				}
+
+				r.RegisterWorkflow(workflowWithActivity)
+
+				_, err := e.ExecuteTask(context.Background(), startWorkflowTask(i.InstanceID, workflowWithActivity))
+				require.NoError(t, err)
+
+				p, err := e.Query(context.Background(), StackTraceQuery)
+				require.NoError(t, err)
+
+				var stackTrace string
+				require.NoError(t, converter.DefaultConverter.From(p, &stackTrace))
+				require.Contains(t, stackTrace, "coroutine 0 [blocked]:")
+				require.Contains(t, stackTrace, "internal/workflow.Test_Executor")
+
+				_, err = e.Query(context.Background(), "unknown")
+				require.Error(t, err)
+			},
+		},
+		{
+			name: "Deadlocked workflow fails task",
+			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
+				e.options.deadlockTimeout = time.Millisecond * 10
+
+				release := make(chan struct{})
+				defer close(release)
+
+				workflowBlocked := func(ctx sync.Context) error {
+					<-release
+					return nil
+				}
+
+				r.RegisterWorkflow(workflowBlocked)
+
+				_, err := e.ExecuteTask(context.Background(), startWorkflowTask(i.InstanceID, workflowBlocked))
+
+				var derr *sync.DeadlockError
+				require.ErrorAs(t, err, &derr)
+				require.Contains(t, derr.Stack, "internal/workflow.Test_Executor")
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -673,6 +967,29 @@ func startWorkflowTask(instanceID string, workflow interface{}, workflowArgs ...
 	}
 }
 
+func historyWithActivity(workflowName, activityName string) []history.Event {
+	return []history.Event{
+		history.NewHistoryEvent(
+			1,
+			time.Now(),
+			history.EventType_WorkflowExecutionStarted,
+			&history.ExecutionStartedAttributes{
+				Name:   workflowName,
+				Inputs: []payload.Payload{},
+			},
+		),
+		history.NewHistoryEvent(
+			2,
+			time.Now(),
+			history.EventType_ActivityScheduled,
+			&history.ActivityScheduledAttributes{
+				Name: activityName,
+			},
+			history.ScheduleEventID(1),
+		),
+	}
+}
+
 func continueTask(instanceID string, newEvents []history.Event, lastSequenceID int64) *task.Workflow {
 	return &task.Workflow{
 		ID:               uuid.NewString(),
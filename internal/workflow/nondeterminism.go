@@ -0,0 +1,83 @@
+package workflow
+
+import (
+	"fmt"
+
+	"github.com/paveliak/go-workflows/internal/command"
+	"github.com/paveliak/go-workflows/internal/history"
+)
+
+// NonDeterminismError is returned when the commands produced by the workflow code while replaying the history
+// do not match the events recorded in the history.
+type NonDeterminismError struct {
+	// ScheduleEventID is the schedule event id of the history event that could not be matched
+	ScheduleEventID int64
+
+	// Expected describes the event recorded in the history, or "no event"
+	Expected string
+
+	// Actual describes the command produced by the workflow code, or "no command"
+	Actual string
+}
+
+func (e *NonDeterminismError) Error() string {
+	return fmt.Sprintf("workflow execution is not deterministic at schedule event id %d: history has %s, workflow code produced %s",
+		e.ScheduleEventID, e.Expected, e.Actual)
+}
+
+// NonDeterminismPolicy determines how the executor handles nondeterminism detected while replaying a workflow history
+type NonDeterminismPolicy int
+
+const (
+	// NonDeterminismPolicyFailWorkflow fails the workflow instance with the NonDeterminismError
+	NonDeterminismPolicyFailWorkflow NonDeterminismPolicy = iota
+
+	// NonDeterminismPolicyBlockWorkflow returns the NonDeterminismError from ExecuteTask without completing the task.
+	// The workflow instance is retried once the task becomes available again, which allows fixing the workflow code
+	// and redeploying.
+	NonDeterminismPolicyBlockWorkflow
+)
+
+func newNonDeterminismError(event history.Event, c command.Command) *NonDeterminismError {
+	return &NonDeterminismError{
+		ScheduleEventID: event.ScheduleEventID,
+		Expected:        describeEvent(event),
+		Actual:          describeCommand(c),
+	}
+}
+
+func newUnmatchedCommandError(c command.Command) *NonDeterminismError {
+	return &NonDeterminismError{
+		ScheduleEventID: c.ID(),
+		Expected:        "no event",
+		Actual:          describeCommand(c),
+	}
+}
+
+func describeEvent(event history.Event) string {
+	switch a := event.Attributes.(type) {
+	case *history.ActivityScheduledAttributes:
+		return fmt.Sprintf("%v(%s)", event.Type, a.Name)
+	case *history.SubWorkflowScheduledAttributes:
+		return fmt.Sprintf("%v(%s)", event.Type, a.Name)
+	case *history.SignalWorkflowAttributes:
+		return fmt.Sprintf("%v(%s, %s)", event.Type, a.InstanceID, a.Name)
+	}
+
+	return event.Type.String()
+}
+
+func describeCommand(c command.Command) string {
+	switch c := c.(type) {
+	case nil:
+		return "no command"
+	case *command.ScheduleActivityCommand:
+		return fmt.Sprintf("%s(%s)", c.Type(), c.Name)
+	case *command.ScheduleSubWorkflowCommand:
+		return fmt.Sprintf("%s(%s)", c.Type(), c.Name)
+	case *command.SignalWorkflowCommand:
+		return fmt.Sprintf("%s(%s, %s)", c.Type(), c.Instance.InstanceID, c.Name)
+	}
+
+	return c.Type()
+}
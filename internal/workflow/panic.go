@@ -0,0 +1,14 @@
+package workflow
+
+// PanicPolicy determines how the executor handles panics in workflow code
+type PanicPolicy int
+
+const (
+	// PanicPolicyFailWorkflow fails the workflow instance with the recovered panic
+	PanicPolicyFailWorkflow PanicPolicy = iota
+
+	// PanicPolicyBlockWorkflow returns the sync.PanicError from ExecuteTask without completing the task. The history
+	// is left untouched and the task is retried once it becomes available again, which allows fixing the workflow
+	// code and redeploying.
+	PanicPolicyBlockWorkflow
+)
@@ -0,0 +1,29 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/paveliak/go-workflows/internal/converter"
+	"github.com/paveliak/go-workflows/internal/payload"
+)
+
+// StackTraceQuery is the built-in query returning the locations all coroutines of a workflow are blocked at
+const StackTraceQuery = "__stack_trace"
+
+func (e *executor) Query(ctx context.Context, query string) (payload.Payload, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	switch query {
+	case StackTraceQuery:
+		stackTrace := ""
+		if e.workflow != nil {
+			stackTrace = e.workflow.StackTrace()
+		}
+
+		return converter.DefaultConverter.To(stackTrace)
+	}
+
+	return nil, fmt.Errorf("unknown query: %s", query)
+}
@@ -20,8 +20,8 @@ type workflow struct {
 	err    error
 }
 
-func NewWorkflow(workflowFn reflect.Value) *workflow {
-	s := sync.NewScheduler()
+func NewWorkflow(workflowFn reflect.Value, opts ...sync.SchedulerOption) *workflow {
+	s := sync.NewScheduler(opts...)
 
 	return &workflow{
 		s:  s,
@@ -106,3 +106,8 @@ func (w *workflow) Close() {
 	// End coroutine execution to prevent goroutine leaks
 	w.s.Exit()
 }
+
+// StackTrace returns the locations all coroutines of the workflow are blocked at
+func (w *workflow) StackTrace() string {
+	return w.s.StackTrace()
+}
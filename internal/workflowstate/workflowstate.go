@@ -48,6 +48,7 @@ type signalChannel struct {
 
 type WfState struct {
 	instance        *core.WorkflowInstance
+	memo            core.Memo
 	scheduleEventID int64
 	commands        []command.Command
 	pendingFutures  map[int64]DecodingSettable
@@ -151,6 +152,14 @@ func (wf *WfState) Instance() *core.WorkflowInstance {
 	return wf.instance
 }
 
+func (wf *WfState) Memo() core.Memo {
+	return wf.memo
+}
+
+func (wf *WfState) SetMemo(memo core.Memo) {
+	wf.memo = memo
+}
+
 func (wf *WfState) Logger() log.Logger {
 	return wf.logger
 }
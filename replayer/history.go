@@ -0,0 +1,233 @@
+package replayer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/paveliak/go-workflows/diag"
+	"github.com/paveliak/go-workflows/internal/history"
+	"github.com/paveliak/go-workflows/internal/payload"
+)
+
+// LoadHistory reads a workflow history exported as JSON. The input can either be a JSON array of events or a single
+// event per line, as written by the filesystem archiver.
+func LoadHistory(r io.Reader) ([]history.Event, error) {
+	br := bufio.NewReader(r)
+
+	if isJSONArray(br) {
+		h := make([]history.Event, 0)
+		if err := json.NewDecoder(br).Decode(&h); err != nil {
+			return nil, fmt.Errorf("decoding history: %w", err)
+		}
+
+		return h, nil
+	}
+
+	h := make([]history.Event, 0)
+
+	dec := json.NewDecoder(br)
+	for {
+		var event history.Event
+		if err := dec.Decode(&event); err != nil {
+			if err == io.EOF {
+				break
+			}
+
+			return nil, fmt.Errorf("decoding event: %w", err)
+		}
+
+		h = append(h, event)
+	}
+
+	return h, nil
+}
+
+// diagEvent is the representation of an event returned by the diag API
+type diagEvent struct {
+	ID              string          `json:"id,omitempty"`
+	SequenceID      int64           `json:"sequence_id,omitempty"`
+	Type            string          `json:"type,omitempty"`
+	Timestamp       time.Time       `json:"timestamp,omitempty"`
+	ScheduleEventID int64           `json:"schedule_event_id,omitempty"`
+	Attributes      json.RawMessage `json:"attributes,omitempty"`
+	VisibleAt       *time.Time      `json:"visible_at,omitempty"`
+}
+
+// LoadDiagHistory reads the history from a workflow instance response of the diag API (/api/{instanceID}). It returns
+// an error if the payloads of the response were decoded with a custom diag.PayloadDecoder.
+func LoadDiagHistory(r io.Reader) ([]history.Event, error) {
+	var info struct {
+		History              []*diagEvent `json:"history,omitempty"`
+		CustomPayloadDecoder bool         `json:"custom_payload_decoder,omitempty"`
+	}
+
+	if err := json.NewDecoder(r).Decode(&info); err != nil {
+		return nil, fmt.Errorf("decoding workflow instance: %w", err)
+	}
+
+	if info.CustomPayloadDecoder {
+		return nil, errCustomPayloadDecoder
+	}
+
+	h := make([]history.Event, 0, len(info.History))
+	for _, de := range info.History {
+		et, err := history.ParseEventType(de.Type)
+		if err != nil {
+			return nil, err
+		}
+
+		attributes, err := restorePayloads(et, de.Attributes)
+		if err != nil {
+			return nil, fmt.Errorf("restoring payloads for event %v: %w", de.SequenceID, err)
+		}
+
+		attr, err := history.DeserializeAttributes(et, attributes)
+		if err != nil {
+			return nil, fmt.Errorf("deserializing attributes for event %v: %w", de.SequenceID, err)
+		}
+
+		h = append(h, history.Event{
+			ID:              de.ID,
+			SequenceID:      de.SequenceID,
+			Type:            et,
+			Timestamp:       de.Timestamp,
+			ScheduleEventID: de.ScheduleEventID,
+			Attributes:      attr,
+			VisibleAt:       de.VisibleAt,
+		})
+	}
+
+	return h, nil
+}
+
+// errCustomPayloadDecoder is returned for diag API responses with payloads decoded by a custom PayloadDecoder
+var errCustomPayloadDecoder = errors.New("payloads were decoded with a custom payload decoder and cannot be restored, load the history with LoadHistory instead")
+
+// restorePayloads replaces the payloads the diag API decoded for display with payloads again. Payloads that could
+// not be decoded are restored from their raw bytes, all others from their decoded JSON value. This matches the
+// original payloads since the diag API uses the default JSON converter to decode them. Payloads returned by older
+// versions of the diag API, which did not decode them, are used as they are.
+func restorePayloads(et history.EventType, attributes json.RawMessage) (json.RawMessage, error) {
+	if len(attributes) == 0 {
+		return attributes, nil
+	}
+
+	// Deserialize empty attributes to find the payload fields of the event type
+	attr, err := history.DeserializeAttributes(et, []byte("{}"))
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(attributes, &fields); err != nil {
+		return nil, err
+	}
+
+	t := reflect.TypeOf(attr).Elem()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Type != payload.Type && f.Type != payload.SliceType {
+			continue
+		}
+
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "" {
+			name = f.Name
+		}
+
+		value, ok := fields[name]
+		if !ok {
+			continue
+		}
+
+		var restored interface{}
+		if f.Type == payload.Type {
+			p, err := restorePayload(value)
+			if err != nil {
+				return nil, fmt.Errorf("decoding %v: %w", name, err)
+			}
+
+			restored = p
+		} else {
+			var values []json.RawMessage
+			if err := json.Unmarshal(value, &values); err != nil {
+				return nil, fmt.Errorf("decoding %v: %w", name, err)
+			}
+
+			var payloads []payload.Payload
+			for _, v := range values {
+				p, err := restorePayload(v)
+				if err != nil {
+					return nil, fmt.Errorf("decoding %v: %w", name, err)
+				}
+
+				payloads = append(payloads, p)
+			}
+
+			restored = payloads
+		}
+
+		if fields[name], err = json.Marshal(restored); err != nil {
+			return nil, err
+		}
+	}
+
+	return json.Marshal(fields)
+}
+
+// restorePayload restores a single payload, either a diag.DecodedPayload or a base64 encoded payload
+func restorePayload(value json.RawMessage) (payload.Payload, error) {
+	if v := bytes.TrimSpace(value); len(v) > 0 && v[0] == '"' {
+		var p payload.Payload
+		if err := json.Unmarshal(v, &p); err != nil {
+			return nil, err
+		}
+
+		return p, nil
+	}
+
+	var dp diag.DecodedPayload
+	if err := json.Unmarshal(value, &dp); err != nil {
+		return nil, err
+	}
+
+	if dp.Error != "" {
+		return dp.Raw, nil
+	}
+
+	if len(dp.Value) == 0 {
+		// Empty payload
+		return nil, nil
+	}
+
+	// The default converter encodes payloads without whitespace, responses might have been formatted since
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, dp.Value); err != nil {
+		return nil, err
+	}
+
+	return payload.Payload(buf.Bytes()), nil
+}
+
+func isJSONArray(br *bufio.Reader) bool {
+	for i := 1; ; i++ {
+		b, err := br.Peek(i)
+		if err != nil {
+			return false
+		}
+
+		c := b[i-1]
+		if len(bytes.TrimSpace([]byte{c})) == 0 {
+			continue
+		}
+
+		return c == '['
+	}
+}
@@ -0,0 +1,275 @@
+package replayer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/benbjohnson/clock"
+	"github.com/paveliak/go-workflows/internal/core"
+	"github.com/paveliak/go-workflows/internal/history"
+	"github.com/paveliak/go-workflows/internal/logger"
+	"github.com/paveliak/go-workflows/internal/task"
+	"github.com/paveliak/go-workflows/internal/workflow"
+	"github.com/paveliak/go-workflows/log"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Replayer replays recorded workflow histories against the registered workflow code to detect changes that are not
+// deterministic.
+type Replayer interface {
+	// RegisterWorkflow registers a workflow that recorded histories can be replayed against
+	RegisterWorkflow(workflow interface{}) error
+
+	// ReplayWorkflowHistory replays the given history of a single workflow execution. It returns a
+	// *NonDeterminismError if the registered workflow code does not produce the same commands as recorded in the
+	// history.
+	ReplayWorkflowHistory(ctx context.Context, h []history.Event) error
+}
+
+// NonDeterminismError is returned when replaying a history produces different commands than the ones recorded.
+// It's the same error the worker reports for workflow instances that are not deterministic.
+type NonDeterminismError = workflow.NonDeterminismError
+
+type options struct {
+	Logger log.Logger
+}
+
+type ReplayerOption func(*options)
+
+func WithLogger(logger log.Logger) ReplayerOption {
+	return func(o *options) {
+		o.Logger = logger
+	}
+}
+
+type replayer struct {
+	registry *workflow.Registry
+	logger   log.Logger
+	tracer   trace.Tracer
+}
+
+func New(opts ...ReplayerOption) Replayer {
+	options := &options{}
+
+	for _, o := range opts {
+		o(options)
+	}
+
+	if options.Logger == nil {
+		options.Logger = logger.NewDefaultLogger()
+	}
+
+	return &replayer{
+		registry: workflow.NewRegistry(),
+		logger:   options.Logger,
+		tracer:   trace.NewNoopTracerProvider().Tracer("workflow-replayer"),
+	}
+}
+
+func (r *replayer) RegisterWorkflow(workflow interface{}) error {
+	return r.registry.RegisterWorkflow(workflow)
+}
+
+// noHistoryProvider is used for the executor, the replayer always passes the complete state with each task
+type noHistoryProvider struct{}
+
+func (*noHistoryProvider) GetWorkflowInstanceHistory(ctx context.Context, instance *core.WorkflowInstance, lastSequenceID *int64) ([]history.Event, error) {
+	return nil, errors.New("replayer does not provide workflow history")
+}
+
+func (r *replayer) ReplayWorkflowHistory(ctx context.Context, h []history.Event) (err error) {
+	tasks, err := splitTasks(h)
+	if err != nil {
+		return err
+	}
+
+	instance := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+
+	clock := clock.NewMock()
+
+	e, err := workflow.NewExecutor(r.logger, r.tracer, r.registry, &noHistoryProvider{}, instance, clock)
+	if err != nil {
+		return fmt.Errorf("creating workflow executor: %w", err)
+	}
+	defer e.Close()
+
+	// The executor panics when it detects an inconsistent workflow state
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("replaying workflow history: %v", p)
+		}
+	}()
+
+	lastSequenceID := int64(0)
+
+	for _, t := range tasks {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		// Use the recorded time, timers are scheduled relative to it
+		clock.Set(t.started.Timestamp)
+
+		newEvents := make([]history.Event, len(t.newEvents))
+		for i, event := range t.newEvents {
+			newEvents[i] = event
+			newEvents[i].SequenceID = 0
+		}
+
+		result, err := e.ExecuteTask(ctx, &task.Workflow{
+			ID:                    t.started.ID,
+			WorkflowInstance:      instance,
+			WorkflowInstanceState: core.WorkflowInstanceStateActive,
+			Metadata:              &core.WorkflowMetadata{},
+			LastSequenceID:        lastSequenceID,
+			NewEvents:             newEvents,
+		})
+		if err != nil {
+			return fmt.Errorf("executing workflow task %d: %w", t.started.SequenceID, err)
+		}
+
+		if len(result.Executed) > 0 {
+			lastSequenceID = result.Executed[len(result.Executed)-1].SequenceID
+		}
+
+		commandEvents := make([]history.Event, 0)
+		for _, event := range result.Executed {
+			if isCommandEvent(event.Type) {
+				commandEvents = append(commandEvents, event)
+			}
+		}
+
+		if err := compareCommands(t.started.SequenceID, t.commandEvents, commandEvents); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type workflowTask struct {
+	started       history.Event
+	newEvents     []history.Event
+	commandEvents []history.Event
+}
+
+// splitTasks splits the history into the workflow tasks it was recorded in. Every task starts with a
+// WorkflowTaskStarted event, followed by the new events for the task and the events for the commands
+// the workflow produced.
+func splitTasks(h []history.Event) ([]*workflowTask, error) {
+	tasks := make([]*workflowTask, 0)
+
+	var t *workflowTask
+	for _, event := range h {
+		if event.Type == history.EventType_WorkflowTaskStarted {
+			t = &workflowTask{
+				started:       event,
+				newEvents:     make([]history.Event, 0),
+				commandEvents: make([]history.Event, 0),
+			}
+			tasks = append(tasks, t)
+
+			continue
+		}
+
+		if t == nil {
+			return nil, errors.New("history does not start with a WorkflowTaskStarted event")
+		}
+
+		if isCommandEvent(event.Type) {
+			t.commandEvents = append(t.commandEvents, event)
+		} else {
+			if len(t.commandEvents) > 0 {
+				return nil, fmt.Errorf("unexpected event %v after command events in workflow task %d", event.Type, t.started.SequenceID)
+			}
+
+			t.newEvents = append(t.newEvents, event)
+		}
+	}
+
+	return tasks, nil
+}
+
+// isCommandEvent returns true for the events the executor adds to the history when executing workflow commands
+func isCommandEvent(et history.EventType) bool {
+	switch et {
+	case history.EventType_ActivityScheduled,
+		history.EventType_TimerScheduled,
+		history.EventType_TimerCanceled,
+		history.EventType_SubWorkflowScheduled,
+		history.EventType_SubWorkflowCancellationRequested,
+		history.EventType_SideEffectResult,
+		history.EventType_SearchAttributesUpserted,
+		history.EventType_SignalWorkflow,
+		history.EventType_WorkflowExecutionFinished:
+		return true
+	}
+
+	return false
+}
+
+func compareCommands(sequenceID int64, expected, actual []history.Event) error {
+	for i := 0; i < len(expected) || i < len(actual); i++ {
+		var e, a *history.Event
+		if i < len(expected) {
+			e = &expected[i]
+		}
+
+		if i < len(actual) {
+			a = &actual[i]
+		}
+
+		if e == nil || a == nil || e.Type != a.Type || e.ScheduleEventID != a.ScheduleEventID || commandName(*e) != commandName(*a) {
+			nerr := &NonDeterminismError{
+				Expected: describeEvent(e),
+				Actual:   describeEvent(a),
+			}
+
+			if e != nil {
+				nerr.ScheduleEventID = e.ScheduleEventID
+			} else {
+				nerr.ScheduleEventID = a.ScheduleEventID
+			}
+
+			return fmt.Errorf("workflow task %d: %w", sequenceID, nerr)
+		}
+	}
+
+	return nil
+}
+
+// commandName returns the name of the activity or workflow scheduled by the event
+func commandName(event history.Event) string {
+	switch a := event.Attributes.(type) {
+	case *history.ActivityScheduledAttributes:
+		return a.Name
+	case *history.SubWorkflowScheduledAttributes:
+		return a.Name
+	}
+
+	return ""
+}
+
+func describeEvent(event *history.Event) string {
+	if event == nil {
+		return "no command"
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%v(schedule_event_id=%d", event.Type, event.ScheduleEventID)
+
+	if name := commandName(*event); name != "" {
+		fmt.Fprintf(&sb, ", name=%s", name)
+	}
+
+	if a, ok := event.Attributes.(*history.ExecutionCompletedAttributes); ok && a.Error != "" {
+		fmt.Fprintf(&sb, ", error=%s", a.Error)
+	}
+
+	sb.WriteString(")")
+
+	return sb.String()
+}
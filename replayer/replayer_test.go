@@ -0,0 +1,253 @@
+package replayer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/paveliak/go-workflows/backend/sqlite"
+	"github.com/paveliak/go-workflows/client"
+	"github.com/paveliak/go-workflows/diag"
+	"github.com/paveliak/go-workflows/internal/fn"
+	"github.com/paveliak/go-workflows/internal/history"
+	"github.com/paveliak/go-workflows/internal/payload"
+	"github.com/paveliak/go-workflows/worker"
+	"github.com/paveliak/go-workflows/workflow"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+var useOtherActivity = false
+
+func activity1(ctx context.Context, n int) (int, error) {
+	return n + 1, nil
+}
+
+func activity2(ctx context.Context, n int) (int, error) {
+	return n + 2, nil
+}
+
+func replayWorkflow(ctx workflow.Context, n int) (int, error) {
+	a := activity1
+	if useOtherActivity {
+		a = activity2
+	}
+
+	r, err := workflow.ExecuteActivity[int](ctx, workflow.DefaultActivityOptions, a, n).Get(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := workflow.ScheduleTimer(ctx, time.Millisecond).Get(ctx); err != nil {
+		return 0, err
+	}
+
+	return workflow.ExecuteActivity[int](ctx, workflow.DefaultActivityOptions, activity1, r).Get(ctx)
+}
+
+// recordHistory runs replayWorkflow to completion and returns the recorded history
+func recordHistory(t *testing.T) (diag.Backend, *workflow.Instance) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	b := sqlite.NewInMemoryBackend()
+	c := client.New(b)
+	w := worker.New(b, nil)
+
+	t.Cleanup(func() {
+		cancel()
+		require.NoError(t, w.WaitForCompletion())
+	})
+
+	require.NoError(t, w.RegisterWorkflow(replayWorkflow))
+	require.NoError(t, w.RegisterActivity(activity1))
+	require.NoError(t, w.RegisterActivity(activity2))
+	require.NoError(t, w.Start(ctx))
+
+	instance, err := c.CreateWorkflowInstance(ctx, client.WorkflowInstanceOptions{
+		InstanceID: uuid.NewString(),
+	}, replayWorkflow, 1)
+	require.NoError(t, err)
+
+	r, err := client.GetWorkflowResult[int](ctx, c, instance, time.Second*10)
+	require.NoError(t, err)
+	require.Equal(t, 3, r)
+
+	return b, instance
+}
+
+func getHistory(t *testing.T, b diag.Backend, instance *workflow.Instance) []history.Event {
+	h, err := b.GetWorkflowInstanceHistory(context.Background(), instance, nil)
+	require.NoError(t, err)
+
+	return h
+}
+
+func Test_Replayer_Deterministic(t *testing.T) {
+	b, instance := recordHistory(t)
+	h := getHistory(t, b, instance)
+
+	r := New()
+	require.NoError(t, r.RegisterWorkflow(replayWorkflow))
+
+	require.NoError(t, r.ReplayWorkflowHistory(context.Background(), h))
+}
+
+func Test_Replayer_DetectsDifferentActivity(t *testing.T) {
+	b, instance := recordHistory(t)
+	h := getHistory(t, b, instance)
+
+	useOtherActivity = true
+	t.Cleanup(func() { useOtherActivity = false })
+
+	r := New()
+	require.NoError(t, r.RegisterWorkflow(replayWorkflow))
+
+	err := r.ReplayWorkflowHistory(context.Background(), h)
+
+	var nerr *NonDeterminismError
+	require.ErrorAs(t, err, &nerr)
+	require.Contains(t, nerr.Expected, "activity1")
+	require.Contains(t, nerr.Actual, "activity2")
+}
+
+func Test_Replayer_DetectsMissingCommand(t *testing.T) {
+	b, instance := recordHistory(t)
+	h := getHistory(t, b, instance)
+
+	changedWorkflow := func(ctx workflow.Context, n int) (int, error) {
+		return n, nil
+	}
+
+	r := New()
+	require.NoError(t, r.RegisterWorkflow(changedWorkflow))
+
+	// Replay the recorded history against the changed workflow
+	for i, event := range h {
+		if a, ok := event.Attributes.(*history.ExecutionStartedAttributes); ok {
+			na := *a
+			na.Name = fn.Name(changedWorkflow)
+			h[i].Attributes = &na
+		}
+	}
+
+	err := r.ReplayWorkflowHistory(context.Background(), h)
+
+	var nerr *NonDeterminismError
+	require.ErrorAs(t, err, &nerr)
+	require.Contains(t, nerr.Expected, history.EventType_ActivityScheduled.String())
+	require.Contains(t, nerr.Actual, history.EventType_WorkflowExecutionFinished.String())
+}
+
+func Test_Replayer_WorkflowNotRegistered(t *testing.T) {
+	b, instance := recordHistory(t)
+	h := getHistory(t, b, instance)
+
+	r := New()
+
+	err := r.ReplayWorkflowHistory(context.Background(), h)
+	require.Error(t, err)
+}
+
+func Test_LoadHistory(t *testing.T) {
+	b, instance := recordHistory(t)
+	h := getHistory(t, b, instance)
+
+	r := New()
+	require.NoError(t, r.RegisterWorkflow(replayWorkflow))
+
+	// JSON array
+	data, err := json.Marshal(h)
+	require.NoError(t, err)
+
+	lh, err := LoadHistory(bytes.NewReader(data))
+	require.NoError(t, err)
+	require.Len(t, lh, len(h))
+	require.NoError(t, r.ReplayWorkflowHistory(context.Background(), lh))
+
+	// One event per line
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, event := range h {
+		require.NoError(t, enc.Encode(event))
+	}
+
+	lh, err = LoadHistory(&buf)
+	require.NoError(t, err)
+	require.Len(t, lh, len(h))
+	require.NoError(t, r.ReplayWorkflowHistory(context.Background(), lh))
+}
+
+func Test_LoadDiagHistory(t *testing.T) {
+	b, instance := recordHistory(t)
+
+	srv := httptest.NewServer(diag.NewServeMux(b))
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL + "/api/" + instance.InstanceID)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	h, err := LoadDiagHistory(res.Body)
+	require.NoError(t, err)
+	require.Len(t, h, len(getHistory(t, b, instance)))
+
+	r := New()
+	require.NoError(t, r.RegisterWorkflow(replayWorkflow))
+	require.NoError(t, r.ReplayWorkflowHistory(context.Background(), h))
+}
+
+func Test_LoadDiagHistory_CustomPayloadDecoder(t *testing.T) {
+	_, err := LoadDiagHistory(strings.NewReader(`{"history": [], "custom_payload_decoder": true}`))
+	require.ErrorIs(t, err, errCustomPayloadDecoder)
+}
+
+func Test_RestorePayloads(t *testing.T) {
+	tests := []struct {
+		name       string
+		eventType  history.EventType
+		attributes string
+		expected   interface{}
+	}{
+		{
+			name:       "Decoded value",
+			eventType:  history.EventType_ActivityCompleted,
+			attributes: `{"result": {"value": {"n": 42}}}`,
+			expected:   &history.ActivityCompletedAttributes{Result: []byte(`{"n":42}`)},
+		},
+		{
+			name:       "Raw bytes of payloads that could not be decoded",
+			eventType:  history.EventType_ActivityCompleted,
+			attributes: `{"result": {"raw": "c2VjcmV0", "error": "cannot decode"}}`,
+			expected:   &history.ActivityCompletedAttributes{Result: []byte("secret")},
+		},
+		{
+			name:       "Empty payloads",
+			eventType:  history.EventType_WorkflowExecutionStarted,
+			attributes: `{"name": "wf", "inputs": [{}, {"value": null}]}`,
+			expected:   &history.ExecutionStartedAttributes{Name: "wf", Inputs: []payload.Payload{nil, []byte("null")}},
+		},
+		{
+			name:       "Base64 encoded payloads of older diag API versions",
+			eventType:  history.EventType_WorkflowExecutionStarted,
+			attributes: `{"name": "wf", "inputs": ["NDI="]}`,
+			expected:   &history.ExecutionStartedAttributes{Name: "wf", Inputs: []payload.Payload{[]byte("42")}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restored, err := restorePayloads(tt.eventType, json.RawMessage(tt.attributes))
+			require.NoError(t, err)
+
+			attr, err := history.DeserializeAttributes(tt.eventType, restored)
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, attr)
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package server
+
+import (
+	"errors"
+	"time"
+
+	"github.com/paveliak/go-workflows/backend"
+	"github.com/paveliak/go-workflows/diag"
+	"github.com/paveliak/go-workflows/internal/core"
+	"github.com/paveliak/go-workflows/internal/history"
+	"github.com/paveliak/go-workflows/internal/task"
+)
+
+// The protocol of the server: every backend method is a POST request to /{method name} with a JSON body of the
+// method's request type. Successful calls return 200 with a JSON body of the method's response type, failed calls
+// an ErrorResponse.
+
+// ErrNotImplemented is returned for methods of optional interfaces, like diag.Backend, that the backend of the
+// server does not implement
+var ErrNotImplemented = errors.New("not implemented by the backend")
+
+// ErrBadRequest is returned for requests missing a required field, like the instance or event of a method
+var ErrBadRequest = errors.New("bad request")
+
+// ErrorResponse is the body of failed calls. Code identifies errors defined by the backend package, it's empty for
+// all other errors.
+type ErrorResponse struct {
+	Error string `json:"error"`
+	Code  string `json:"code,omitempty"`
+}
+
+var errorCodes = []struct {
+	code   string
+	err    error
+	status int
+}{
+	{"instance_not_found", backend.ErrInstanceNotFound, 404},
+	{"instance_already_exists", backend.ErrInstanceAlreadyExists, 409},
+	{"instance_not_finished", backend.ErrInstanceNotFinished, 409},
+	{"instance_not_suspended", backend.ErrInstanceNotSuspended, 409},
+	{"instance_not_active", backend.ErrInstanceNotActive, 409},
+	{"instance_finished", backend.ErrInstanceFinished, 409},
+	{"workflow_task_discarded", backend.ErrWorkflowTaskDiscarded, 409},
+	{"bad_request", ErrBadRequest, 400},
+	{"not_implemented", ErrNotImplemented, 501},
+}
+
+// BackendError returns the error identified by the code of an ErrorResponse, or nil for unknown codes
+func BackendError(code string) error {
+	for _, c := range errorCodes {
+		if c.code == code {
+			return c.err
+		}
+	}
+
+	return nil
+}
+
+// Empty is the request or response of methods without parameters or results
+type Empty struct{}
+
+type InstanceRequest struct {
+	Instance *core.WorkflowInstance `json:"instance,omitempty"`
+}
+
+type InstanceIDRequest struct {
+	InstanceID string `json:"instance_id"`
+}
+
+type InstanceEventRequest struct {
+	Instance *core.WorkflowInstance `json:"instance,omitempty"`
+	Event    *history.Event         `json:"event,omitempty"`
+}
+
+type StateResponse struct {
+	State core.WorkflowInstanceState `json:"state"`
+}
+
+type ListWorkflowInstancesRequest struct {
+	Filter *backend.WorkflowInstanceFilter `json:"filter,omitempty"`
+}
+
+type ListWorkflowInstancesResponse struct {
+	Instances []*backend.WorkflowInstanceRef `json:"instances"`
+}
+
+type GetWorkflowInstanceHistoryRequest struct {
+	Instance       *core.WorkflowInstance `json:"instance,omitempty"`
+	LastSequenceID *int64                 `json:"last_sequence_id,omitempty"`
+}
+
+type EventsResponse struct {
+	Events []history.Event `json:"events"`
+}
+
+type ResetWorkflowInstanceRequest struct {
+	Instance       *core.WorkflowInstance `json:"instance,omitempty"`
+	ExecutionID    string                 `json:"execution_id"`
+	ToSequenceID   int64                  `json:"to_sequence_id"`
+	ActivityEvents []history.Event        `json:"activity_events,omitempty"`
+	TimerEvents    []history.Event        `json:"timer_events,omitempty"`
+	PendingEvents  []history.Event        `json:"pending_events,omitempty"`
+}
+
+type ImportWorkflowInstanceRequest struct {
+	Instance       *core.WorkflowInstance     `json:"instance,omitempty"`
+	State          core.WorkflowInstanceState `json:"state"`
+	History        []history.Event            `json:"history,omitempty"`
+	ActivityEvents []history.Event            `json:"activity_events,omitempty"`
+	TimerEvents    []history.Event            `json:"timer_events,omitempty"`
+}
+
+type SignalWorkflowRequest struct {
+	InstanceID string        `json:"instance_id"`
+	Event      history.Event `json:"event"`
+}
+
+// PollRequest is the request of GetWorkflowTask and GetActivityTask. The server waits up to Wait for a task before
+// it returns an empty response.
+type PollRequest struct {
+	Wait time.Duration `json:"wait"`
+}
+
+type WorkflowTaskResponse struct {
+	Task *task.Workflow `json:"task,omitempty"`
+}
+
+type TaskRequest struct {
+	TaskID   string                 `json:"task_id"`
+	Instance *core.WorkflowInstance `json:"instance,omitempty"`
+}
+
+type CompleteWorkflowTaskRequest struct {
+	Task           *task.Workflow             `json:"task,omitempty"`
+	Instance       *core.WorkflowInstance     `json:"instance,omitempty"`
+	State          core.WorkflowInstanceState `json:"state"`
+	ExecutedEvents []history.Event            `json:"executed_events,omitempty"`
+	ActivityEvents []history.Event            `json:"activity_events,omitempty"`
+	TimerEvents    []history.Event            `json:"timer_events,omitempty"`
+	WorkflowEvents []history.WorkflowEvent    `json:"workflow_events,omitempty"`
+}
+
+// WorkflowTaskRequest is the request of AbandonWorkflowTask and SuspendWorkflowTask, Reason is the last error or
+// the suspend reason.
+type WorkflowTaskRequest struct {
+	Task   *task.Workflow `json:"task,omitempty"`
+	Reason string         `json:"reason,omitempty"`
+}
+
+type ActivityTaskResponse struct {
+	Task *task.Activity `json:"task,omitempty"`
+}
+
+type CompleteActivityTaskRequest struct {
+	Instance   *core.WorkflowInstance `json:"instance,omitempty"`
+	ActivityID string                 `json:"activity_id"`
+	Event      history.Event          `json:"event"`
+}
+
+type ActivityRequest struct {
+	ActivityID string `json:"activity_id"`
+}
+
+type ReleaseActivityTaskRequest struct {
+	ActivityID string        `json:"activity_id"`
+	Delay      time.Duration `json:"delay,omitempty"`
+}
+
+type WorkflowInstanceResponse struct {
+	Instance *diag.WorkflowInstanceRef `json:"instance,omitempty"`
+}
+
+type GetWorkflowInstancesRequest struct {
+	AfterInstanceID string                       `json:"after_instance_id,omitempty"`
+	Count           int                          `json:"count"`
+	States          []core.WorkflowInstanceState `json:"states,omitempty"`
+}
+
+type QueryWorkflowInstancesRequest struct {
+	Query *diag.WorkflowInstanceQuery `json:"query,omitempty"`
+}
+
+type WorkflowInstancesResponse struct {
+	Instances []*diag.WorkflowInstanceRef `json:"instances"`
+}
+
+type GetStatsRequest struct {
+	Since time.Time `json:"since"`
+}
+
+type StatsResponse struct {
+	Stats *diag.Stats `json:"stats,omitempty"`
+}
+
+type TakeRateLimitTokenRequest struct {
+	Key   string  `json:"key"`
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst"`
+}
+
+type TakeRateLimitTokenResponse struct {
+	Wait time.Duration `json:"wait"`
+}
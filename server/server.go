@@ -0,0 +1,432 @@
+// Package server exposes a backend over HTTP, so that clients and workers can use it with the backend/remote package
+// instead of connecting to the database directly.
+//
+//	b := sqlite.NewSqliteBackend("workflows.sqlite")
+//	http.ListenAndServe(":8080", server.NewHandler(b))
+//
+// The handler does not authenticate requests, wrap it with middleware that does before exposing it to untrusted
+// networks.
+//
+// Workflow instances are not sticky to workers using the server. The backend of the server tracks stickiness by its
+// own worker name, which all remote workers share, so the server releases the stickiness of an instance after each
+// completed workflow task. Workers with a cached executor for an instance fetch the history they missed.
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/paveliak/go-workflows/backend"
+	"github.com/paveliak/go-workflows/diag"
+)
+
+type options struct {
+	pollInterval   time.Duration
+	maxPollTimeout time.Duration
+}
+
+type Option func(*options)
+
+// WithPollInterval configures how often the backend is asked for a new task while a GetWorkflowTask or
+// GetActivityTask request is waiting for one. The default is 100ms.
+func WithPollInterval(interval time.Duration) Option {
+	return func(o *options) {
+		o.pollInterval = interval
+	}
+}
+
+// WithMaxPollTimeout limits how long GetWorkflowTask and GetActivityTask requests wait for a task, regardless of the
+// wait requested by the client. The default is one minute.
+func WithMaxPollTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.maxPollTimeout = timeout
+	}
+}
+
+type server struct {
+	b backend.Backend
+	o *options
+}
+
+// NewHandler returns an http.Handler serving the given backend. Methods of diag.Backend, diag.StatsBackend,
+// backend.RateLimiter, and backend.Importer are served if the backend implements them, and fail with
+// ErrNotImplemented otherwise.
+func NewHandler(b backend.Backend, opts ...Option) http.Handler {
+	o := &options{
+		pollInterval:   100 * time.Millisecond,
+		maxPollTimeout: time.Minute,
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	s := &server{b: b, o: o}
+
+	mux := http.NewServeMux()
+
+	// backend.Backend
+	handle(mux, "CreateWorkflowInstance", func(ctx context.Context, req *InstanceEventRequest) (*Empty, error) {
+		if req.Instance == nil {
+			return nil, missing("instance")
+		}
+
+		if req.Event == nil {
+			return nil, missing("event")
+		}
+
+		return &Empty{}, b.CreateWorkflowInstance(ctx, req.Instance, *req.Event)
+	})
+	handle(mux, "CancelWorkflowInstance", func(ctx context.Context, req *InstanceEventRequest) (*Empty, error) {
+		if req.Instance == nil {
+			return nil, missing("instance")
+		}
+
+		if req.Event == nil {
+			return nil, missing("event")
+		}
+
+		return &Empty{}, b.CancelWorkflowInstance(ctx, req.Instance, req.Event)
+	})
+	handle(mux, "TerminateWorkflowInstance", func(ctx context.Context, req *InstanceEventRequest) (*Empty, error) {
+		if req.Instance == nil {
+			return nil, missing("instance")
+		}
+
+		if req.Event == nil {
+			return nil, missing("event")
+		}
+
+		return &Empty{}, b.TerminateWorkflowInstance(ctx, req.Instance, req.Event)
+	})
+	handle(mux, "GetWorkflowInstanceState", func(ctx context.Context, req *InstanceRequest) (*StateResponse, error) {
+		if req.Instance == nil {
+			return nil, missing("instance")
+		}
+
+		state, err := b.GetWorkflowInstanceState(ctx, req.Instance)
+		return &StateResponse{State: state}, err
+	})
+	handle(mux, "ListWorkflowInstances", func(ctx context.Context, req *ListWorkflowInstancesRequest) (*ListWorkflowInstancesResponse, error) {
+		if req.Filter == nil {
+			req.Filter = &backend.WorkflowInstanceFilter{}
+		}
+
+		instances, err := b.ListWorkflowInstances(ctx, req.Filter)
+		return &ListWorkflowInstancesResponse{Instances: instances}, err
+	})
+	handle(mux, "GetWorkflowInstanceHistory", func(ctx context.Context, req *GetWorkflowInstanceHistoryRequest) (*EventsResponse, error) {
+		if req.Instance == nil {
+			return nil, missing("instance")
+		}
+
+		events, err := b.GetWorkflowInstanceHistory(ctx, req.Instance, req.LastSequenceID)
+		return &EventsResponse{Events: events}, err
+	})
+	handle(mux, "RemoveWorkflowInstance", func(ctx context.Context, req *InstanceRequest) (*Empty, error) {
+		if req.Instance == nil {
+			return nil, missing("instance")
+		}
+
+		return &Empty{}, b.RemoveWorkflowInstance(ctx, req.Instance)
+	})
+	handle(mux, "ResetWorkflowInstance", func(ctx context.Context, req *ResetWorkflowInstanceRequest) (*Empty, error) {
+		if req.Instance == nil {
+			return nil, missing("instance")
+		}
+
+		return &Empty{}, b.ResetWorkflowInstance(
+			ctx, req.Instance, req.ExecutionID, req.ToSequenceID, req.ActivityEvents, req.TimerEvents, req.PendingEvents)
+	})
+	handle(mux, "SignalWorkflow", func(ctx context.Context, req *SignalWorkflowRequest) (*Empty, error) {
+		return &Empty{}, b.SignalWorkflow(ctx, req.InstanceID, req.Event)
+	})
+	handle(mux, "GetWorkflowTask", s.getWorkflowTask)
+	handle(mux, "ExtendWorkflowTask", func(ctx context.Context, req *TaskRequest) (*Empty, error) {
+		if req.Instance == nil {
+			return nil, missing("instance")
+		}
+
+		return &Empty{}, b.ExtendWorkflowTask(ctx, req.TaskID, req.Instance)
+	})
+	handle(mux, "CompleteWorkflowTask", s.completeWorkflowTask)
+	handle(mux, "AbandonWorkflowTask", func(ctx context.Context, req *WorkflowTaskRequest) (*Empty, error) {
+		if req.Task == nil {
+			return nil, missing("task")
+		}
+
+		return &Empty{}, b.AbandonWorkflowTask(ctx, req.Task, req.Reason)
+	})
+	handle(mux, "ReleaseWorkflowTask", func(ctx context.Context, req *TaskRequest) (*Empty, error) {
+		if req.Instance == nil {
+			return nil, missing("instance")
+		}
+
+		return &Empty{}, b.ReleaseWorkflowTask(ctx, req.TaskID, req.Instance)
+	})
+	handle(mux, "SuspendWorkflowTask", func(ctx context.Context, req *WorkflowTaskRequest) (*Empty, error) {
+		if req.Task == nil {
+			return nil, missing("task")
+		}
+
+		return &Empty{}, b.SuspendWorkflowTask(ctx, req.Task, req.Reason)
+	})
+	handle(mux, "SuspendWorkflowInstance", func(ctx context.Context, req *InstanceRequest) (*Empty, error) {
+		if req.Instance == nil {
+			return nil, missing("instance")
+		}
+
+		return &Empty{}, b.SuspendWorkflowInstance(ctx, req.Instance)
+	})
+	handle(mux, "ResumeWorkflowInstance", func(ctx context.Context, req *InstanceRequest) (*Empty, error) {
+		if req.Instance == nil {
+			return nil, missing("instance")
+		}
+
+		return &Empty{}, b.ResumeWorkflowInstance(ctx, req.Instance)
+	})
+	handle(mux, "ReleaseStickiness", func(ctx context.Context, req *InstanceRequest) (*Empty, error) {
+		return &Empty{}, b.ReleaseStickiness(ctx, req.Instance)
+	})
+	handle(mux, "GetActivityTask", s.getActivityTask)
+	handle(mux, "CompleteActivityTask", func(ctx context.Context, req *CompleteActivityTaskRequest) (*Empty, error) {
+		if req.Instance == nil {
+			return nil, missing("instance")
+		}
+
+		return &Empty{}, b.CompleteActivityTask(ctx, req.Instance, req.ActivityID, req.Event)
+	})
+	handle(mux, "ExtendActivityTask", func(ctx context.Context, req *ActivityRequest) (*Empty, error) {
+		return &Empty{}, b.ExtendActivityTask(ctx, req.ActivityID)
+	})
+	handle(mux, "ReleaseActivityTask", func(ctx context.Context, req *ReleaseActivityTaskRequest) (*Empty, error) {
+		return &Empty{}, b.ReleaseActivityTask(ctx, req.ActivityID, req.Delay)
+	})
+
+	// diag.Backend
+	db, isDiag := b.(diag.Backend)
+	handle(mux, "GetWorkflowInstance", func(ctx context.Context, req *InstanceIDRequest) (*WorkflowInstanceResponse, error) {
+		if !isDiag {
+			return nil, ErrNotImplemented
+		}
+
+		instance, err := db.GetWorkflowInstance(ctx, req.InstanceID)
+		return &WorkflowInstanceResponse{Instance: instance}, err
+	})
+	handle(mux, "GetWorkflowInstances", func(ctx context.Context, req *GetWorkflowInstancesRequest) (*WorkflowInstancesResponse, error) {
+		if !isDiag {
+			return nil, ErrNotImplemented
+		}
+
+		instances, err := db.GetWorkflowInstances(ctx, req.AfterInstanceID, req.Count, req.States...)
+		return &WorkflowInstancesResponse{Instances: instances}, err
+	})
+	handle(mux, "QueryWorkflowInstances", func(ctx context.Context, req *QueryWorkflowInstancesRequest) (*WorkflowInstancesResponse, error) {
+		if !isDiag {
+			return nil, ErrNotImplemented
+		}
+
+		if req.Query == nil {
+			req.Query = &diag.WorkflowInstanceQuery{}
+		}
+
+		instances, err := db.QueryWorkflowInstances(ctx, req.Query)
+		return &WorkflowInstancesResponse{Instances: instances}, err
+	})
+	handle(mux, "GetWorkflowInstanceChildren", func(ctx context.Context, req *InstanceIDRequest) (*WorkflowInstancesResponse, error) {
+		if !isDiag {
+			return nil, ErrNotImplemented
+		}
+
+		instances, err := db.GetWorkflowInstanceChildren(ctx, req.InstanceID)
+		return &WorkflowInstancesResponse{Instances: instances}, err
+	})
+
+	// diag.StatsBackend
+	handle(mux, "GetStats", func(ctx context.Context, req *GetStatsRequest) (*StatsResponse, error) {
+		sb, ok := b.(diag.StatsBackend)
+		if !ok {
+			return nil, ErrNotImplemented
+		}
+
+		stats, err := sb.GetStats(ctx, req.Since)
+		return &StatsResponse{Stats: stats}, err
+	})
+
+	// backend.RateLimiter
+	handle(mux, "TakeRateLimitToken", func(ctx context.Context, req *TakeRateLimitTokenRequest) (*TakeRateLimitTokenResponse, error) {
+		rl, ok := b.(backend.RateLimiter)
+		if !ok {
+			return nil, ErrNotImplemented
+		}
+
+		wait, err := rl.TakeRateLimitToken(ctx, req.Key, req.Rate, req.Burst)
+		return &TakeRateLimitTokenResponse{Wait: wait}, err
+	})
+
+	// backend.Importer
+	handle(mux, "ImportWorkflowInstance", func(ctx context.Context, req *ImportWorkflowInstanceRequest) (*Empty, error) {
+		im, ok := b.(backend.Importer)
+		if !ok {
+			return nil, ErrNotImplemented
+		}
+
+		if req.Instance == nil {
+			return nil, missing("instance")
+		}
+
+		return &Empty{}, im.ImportWorkflowInstance(ctx, req.Instance, req.State, req.History, req.ActivityEvents, req.TimerEvents)
+	})
+
+	return mux
+}
+
+func (s *server) getWorkflowTask(ctx context.Context, req *PollRequest) (*WorkflowTaskResponse, error) {
+	t, err := poll(ctx, s.pollTimeout(req.Wait), s.o.pollInterval, s.b.GetWorkflowTask)
+	if err != nil || t == nil {
+		return &WorkflowTaskResponse{}, err
+	}
+
+	// The client gave up waiting, make the task available to other workers right away instead of after its lock
+	// expires
+	if ctx.Err() != nil {
+		if err := s.b.ReleaseWorkflowTask(context.Background(), t.ID, t.WorkflowInstance); err != nil {
+			s.b.Logger().Error("could not release workflow task", "task_id", t.ID, "error", err)
+		}
+
+		return nil, ctx.Err()
+	}
+
+	return &WorkflowTaskResponse{Task: t}, nil
+}
+
+func (s *server) completeWorkflowTask(ctx context.Context, req *CompleteWorkflowTaskRequest) (*Empty, error) {
+	if req.Task == nil {
+		return nil, missing("task")
+	}
+
+	if req.Instance == nil {
+		return nil, missing("instance")
+	}
+
+	if err := s.b.CompleteWorkflowTask(
+		ctx, req.Task, req.Instance, req.State, req.ExecutedEvents, req.ActivityEvents, req.TimerEvents, req.WorkflowEvents,
+	); err != nil {
+		return nil, err
+	}
+
+	// The instance would be sticky to the worker name of the server's backend, which all remote workers share. The
+	// task has been completed, so only log errors, the stickiness expires after the sticky timeout.
+	if err := s.b.ReleaseStickiness(ctx, req.Instance); err != nil {
+		s.b.Logger().Error("could not release workflow instance stickiness", "instance_id", req.Instance.InstanceID, "error", err)
+	}
+
+	return &Empty{}, nil
+}
+
+func (s *server) getActivityTask(ctx context.Context, req *PollRequest) (*ActivityTaskResponse, error) {
+	t, err := poll(ctx, s.pollTimeout(req.Wait), s.o.pollInterval, s.b.GetActivityTask)
+	if err != nil || t == nil {
+		return &ActivityTaskResponse{}, err
+	}
+
+	if ctx.Err() != nil {
+		if err := s.b.ReleaseActivityTask(context.Background(), t.ID, 0); err != nil {
+			s.b.Logger().Error("could not release activity task", "activity_id", t.ID, "error", err)
+		}
+
+		return nil, ctx.Err()
+	}
+
+	return &ActivityTaskResponse{Task: t}, nil
+}
+
+func (s *server) pollTimeout(wait time.Duration) time.Duration {
+	if wait > s.o.maxPollTimeout {
+		return s.o.maxPollTimeout
+	}
+
+	return wait
+}
+
+// poll asks get for a task every interval until it returns one or timeout has elapsed. Returns nil if there is no
+// task.
+func poll[T any](ctx context.Context, timeout, interval time.Duration, get func(context.Context) (*T, error)) (*T, error) {
+	pollCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for {
+		t, err := get(pollCtx)
+		if t != nil {
+			return t, nil
+		}
+
+		if err != nil {
+			if pollCtx.Err() != nil {
+				// Backends may return the context error when the poll times out while they wait for a task
+				return nil, nil
+			}
+
+			return nil, err
+		}
+
+		select {
+		case <-pollCtx.Done():
+			return nil, nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+// missing returns ErrBadRequest for a request without the given field
+func missing(field string) error {
+	return fmt.Errorf("%w: missing %s", ErrBadRequest, field)
+}
+
+// handle registers the backend method name with the mux. f is called with the decoded request, its response or
+// error is written as JSON.
+func handle[Req, Res any](mux *http.ServeMux, name string, f func(ctx context.Context, req *Req) (*Res, error)) {
+	mux.HandleFunc("/"+name, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		req := new(Req)
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			writeJSON(w, http.StatusBadRequest, &ErrorResponse{Error: "decoding request: " + err.Error()})
+			return
+		}
+
+		res, err := f(r.Context(), req)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, res)
+	})
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	for _, c := range errorCodes {
+		if errors.Is(err, c.err) {
+			writeJSON(w, c.status, &ErrorResponse{Error: err.Error(), Code: c.code})
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusInternalServerError, &ErrorResponse{Error: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	// The status has been sent, the client notices incomplete responses
+	_ = json.NewEncoder(w).Encode(v)
+}
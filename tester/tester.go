@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"reflect"
 	"sort"
+	"strings"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -81,9 +82,21 @@ type testWorkflow struct {
 	pendingEvents []history.Event
 }
 
+// memo returns the memo the workflow was started with
+func (tw *testWorkflow) memo() core.Memo {
+	for _, event := range tw.history {
+		if a, ok := event.Attributes.(*history.ExecutionStartedAttributes); ok {
+			return a.Memo
+		}
+	}
+
+	return nil
+}
+
 type options struct {
 	TestTimeout time.Duration
 	Logger      log.Logger
+	Memo        map[string]string
 }
 
 type workflowTester[TResult any] struct {
@@ -137,6 +150,13 @@ func WithTestTimeout(timeout time.Duration) WorkflowTesterOption {
 	}
 }
 
+// WithMemo sets the memo the workflow under test is started with
+func WithMemo(memo map[string]string) WorkflowTesterOption {
+	return func(o *options) {
+		o.Memo = memo
+	}
+}
+
 func NewWorkflowTester[TResult any](wf interface{}, opts ...WorkflowTesterOption) WorkflowTester[TResult] {
 	// Start with the current wall-clock tiem
 	clock := clock.NewMock()
@@ -284,7 +304,7 @@ func (wt *workflowTester[TResult]) Execute(args ...interface{}) {
 
 			// Schedule activities
 			for _, event := range result.ActivityEvents {
-				wt.scheduleActivity(tw.instance, event)
+				wt.scheduleActivity(tw.instance, tw.memo(), event)
 			}
 
 			for _, workflowEvent := range result.WorkflowEvents {
@@ -346,13 +366,66 @@ func (wt *workflowTester[TResult]) Execute(args ...interface{}) {
 					}
 				case <-t.C:
 					t.Stop()
-					panic("No new events generated during workflow execution and no pending timers, workflow blocked?")
+					panic("No new events generated during workflow execution and no pending timers, workflow blocked?\n\n" + wt.stackTraces())
 				}
 			}
 		}
 	}
 }
 
+// stackTraces returns the locations the coroutines of all unfinished workflows are blocked at. Executors are not
+// kept between tasks, so the workflow state is rebuilt from the history.
+func (wt *workflowTester[TResult]) stackTraces() string {
+	var sb strings.Builder
+
+	for _, tw := range wt.testWorkflows {
+		if len(tw.history) == 0 || tw.history[len(tw.history)-1].Type == history.EventType_WorkflowExecutionFinished {
+			continue
+		}
+
+		fmt.Fprintf(&sb, "workflow instance %s:\n", tw.instance.InstanceID)
+
+		stackTrace, err := wt.stackTrace(tw)
+		if err != nil {
+			fmt.Fprintf(&sb, "could not get stack trace: %v\n", err)
+			continue
+		}
+
+		sb.WriteString(stackTrace)
+	}
+
+	return sb.String()
+}
+
+func (wt *workflowTester[TResult]) stackTrace(tw *testWorkflow) (string, error) {
+	e, err := workflow.NewExecutor(wt.logger, wt.tracer, wt.registry, &testHistoryProvider{tw.history}, tw.instance, wt.clock)
+	if err != nil {
+		return "", err
+	}
+	defer e.Close()
+
+	if _, err := e.ExecuteTask(context.Background(), &task.Workflow{
+		WorkflowInstance:      tw.instance,
+		WorkflowInstanceState: core.WorkflowInstanceStateActive,
+		Metadata:              &core.WorkflowMetadata{},
+		LastSequenceID:        tw.history[len(tw.history)-1].SequenceID,
+	}); err != nil {
+		return "", err
+	}
+
+	p, err := e.Query(context.Background(), workflow.StackTraceQuery)
+	if err != nil {
+		return "", err
+	}
+
+	var stackTrace string
+	if err := converter.DefaultConverter.From(p, &stackTrace); err != nil {
+		return "", err
+	}
+
+	return stackTrace, nil
+}
+
 func (wt *workflowTester[TResult]) sendEvent(wfi *core.WorkflowInstance, event history.Event) {
 	var w *testWorkflow
 	for _, tw := range wt.testWorkflows {
@@ -422,7 +495,7 @@ func (wt *workflowTester[TResult]) AssertExpectations(t *testing.T) {
 	wt.ma.AssertExpectations(t)
 }
 
-func (wt *workflowTester[TResult]) scheduleActivity(wfi *core.WorkflowInstance, event history.Event) {
+func (wt *workflowTester[TResult]) scheduleActivity(wfi *core.WorkflowInstance, memo core.Memo, event history.Event) {
 	e := event.Attributes.(*history.ActivityScheduledAttributes)
 
 	go func() {
@@ -484,6 +557,7 @@ func (wt *workflowTester[TResult]) scheduleActivity(wfi *core.WorkflowInstance,
 			activityResult, activityErr = executor.ExecuteActivity(context.Background(), &task.Activity{
 				ID:               uuid.NewString(),
 				Metadata:         &core.WorkflowMetadata{},
+				Memo:             memo,
 				WorkflowInstance: wfi,
 				Event:            event,
 			})
@@ -621,6 +695,7 @@ func (wt *workflowTester[TResult]) getInitialEvent(wf interface{}, args []interf
 			Name:     name,
 			Metadata: &core.WorkflowMetadata{},
 			Inputs:   inputs,
+			Memo:     core.Memo(wt.options.Memo).Clone(),
 		},
 	)
 }
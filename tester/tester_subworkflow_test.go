@@ -42,6 +42,36 @@ func Test_SubWorkflow(t *testing.T) {
 	tester.AssertExpectations(t)
 }
 
+func Test_SubWorkflow_PropagatesMemo(t *testing.T) {
+	subWorkflow := func(ctx workflow.Context) (map[string]string, error) {
+		return workflow.Memo(ctx), nil
+	}
+
+	workflowWithSub := func(ctx workflow.Context, propagate bool) (map[string]string, error) {
+		return workflow.CreateSubWorkflowInstance[map[string]string](ctx, workflow.SubWorkflowOptions{
+			Memo:          map[string]string{"step": "sub"},
+			PropagateMemo: propagate,
+		}, subWorkflow).Get(ctx)
+	}
+
+	for _, propagate := range []bool{true, false} {
+		tester := NewWorkflowTester[map[string]string](workflowWithSub, WithMemo(map[string]string{"step": "parent", "customer": "c1"}))
+		tester.Registry().RegisterWorkflow(subWorkflow)
+
+		tester.Execute(propagate)
+
+		require.True(t, tester.WorkflowFinished())
+		r, errStr := tester.WorkflowResult()
+		require.Zero(t, errStr)
+
+		if propagate {
+			require.Equal(t, map[string]string{"step": "sub", "customer": "c1"}, r)
+		} else {
+			require.Equal(t, map[string]string{"step": "sub"}, r)
+		}
+	}
+}
+
 func Test_SubWorkflow_Mocked(t *testing.T) {
 	subWorkflow := func(ctx workflow.Context, input string) (string, error) {
 		panic("should not call this")
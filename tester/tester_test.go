@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/paveliak/go-workflows/activity"
 	"github.com/paveliak/go-workflows/internal/sync"
 	"github.com/paveliak/go-workflows/workflow"
 	"github.com/stretchr/testify/mock"
@@ -30,9 +31,15 @@ func Test_Workflow(t *testing.T) {
 func Test_WorkflowBlocked(t *testing.T) {
 	tester := NewWorkflowTester[any](workflowBlocked, WithTestTimeout(time.Second*1))
 
-	require.Panics(t, func() {
-		tester.Execute()
-	})
+	defer func() {
+		r := recover()
+		require.NotNil(t, r)
+
+		// The panic includes where the workflow is blocked
+		require.Contains(t, r, "tester.workflowBlocked")
+	}()
+
+	tester.Execute()
 }
 
 func workflowBlocked(ctx workflow.Context) error {
@@ -126,6 +133,31 @@ func activity1(ctx context.Context) (int, error) {
 	return 23, nil
 }
 
+func Test_Memo(t *testing.T) {
+	memoActivity := func(ctx context.Context) (string, error) {
+		return activity.Memo(ctx)["customer"], nil
+	}
+
+	wf := func(ctx workflow.Context) (string, error) {
+		r, err := workflow.ExecuteActivity[string](ctx, workflow.DefaultActivityOptions, memoActivity).Get(ctx)
+		if err != nil {
+			return "", err
+		}
+
+		return workflow.Memo(ctx)["region"] + "/" + r, nil
+	}
+
+	tester := NewWorkflowTester[string](wf, WithMemo(map[string]string{"region": "eu", "customer": "c1"}))
+	tester.Registry().RegisterActivity(memoActivity)
+
+	tester.Execute()
+
+	require.True(t, tester.WorkflowFinished())
+	r, errStr := tester.WorkflowResult()
+	require.Zero(t, errStr)
+	require.Equal(t, "eu/c1", r)
+}
+
 func Test_Activity_LongRunning(t *testing.T) {
 	tester := NewWorkflowTester[any](workflowLongRunningActivity)
 	tester.Registry().RegisterActivity(activityLongRunning)
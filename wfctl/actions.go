@@ -0,0 +1,158 @@
+package wfctl
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+
+	"github.com/paveliak/go-workflows/client"
+	"github.com/paveliak/go-workflows/workflow"
+	"github.com/google/uuid"
+)
+
+var startCommand = &command{
+	name:  "start",
+	args:  "<workflow name> [JSON argument...]",
+	short: "Start a workflow by name, each argument is passed to the workflow as JSON",
+	run:   runStart,
+}
+
+func runStart(ctx context.Context, t *tool, fs *flag.FlagSet, args []string) error {
+	instanceID := fs.String("id", "", "id of the new instance, defaults to a random id")
+	priority := fs.Int("priority", int(workflow.PriorityNormal), "priority of the workflow tasks of the instance")
+
+	args, err := parseArgs(fs, args, 1, -1)
+	if err != nil {
+		return err
+	}
+
+	wfArgs := make([]interface{}, 0, len(args)-1)
+	for i, arg := range args[1:] {
+		if !json.Valid([]byte(arg)) {
+			return fmt.Errorf("argument %d is not valid JSON: %v", i+1, arg)
+		}
+
+		wfArgs = append(wfArgs, json.RawMessage(arg))
+	}
+
+	if *instanceID == "" {
+		*instanceID = uuid.NewString()
+	}
+
+	b, err := t.backend()
+	if err != nil {
+		return err
+	}
+
+	instance, err := client.New(b).CreateWorkflowInstance(ctx, client.WorkflowInstanceOptions{
+		InstanceID: *instanceID,
+		Priority:   workflow.Priority(*priority),
+	}, args[0], wfArgs...)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(t.o.stdout, instance.InstanceID)
+
+	return nil
+}
+
+var signalCommand = &command{
+	name:  "signal",
+	args:  "<instance id> <signal name> [JSON argument]",
+	short: "Send a signal to a workflow instance",
+	run:   runSignal,
+}
+
+func runSignal(ctx context.Context, t *tool, fs *flag.FlagSet, args []string) error {
+	args, err := parseArgs(fs, args, 2, 3)
+	if err != nil {
+		return err
+	}
+
+	var arg json.RawMessage
+	if len(args) == 3 {
+		if !json.Valid([]byte(args[2])) {
+			return fmt.Errorf("signal argument is not valid JSON: %v", args[2])
+		}
+
+		arg = json.RawMessage(args[2])
+	}
+
+	b, err := t.backend()
+	if err != nil {
+		return err
+	}
+
+	return client.New(b).SignalWorkflow(ctx, args[0], args[1], arg)
+}
+
+var cancelCommand = &command{
+	name:  "cancel",
+	args:  "<instance id>",
+	short: "Cancel a workflow instance, the workflow can react to the cancellation",
+	run:   runCancel,
+}
+
+func runCancel(ctx context.Context, t *tool, fs *flag.FlagSet, args []string) error {
+	args, err := parseArgs(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	ref, err := t.instance(ctx, args[0])
+	if err != nil {
+		return err
+	}
+
+	return client.New(t.b).CancelWorkflowInstance(ctx, ref.Instance)
+}
+
+var terminateCommand = &command{
+	name:  "terminate",
+	args:  "<instance id>",
+	short: "Terminate a workflow instance without running any more workflow code",
+	run:   runTerminate,
+}
+
+func runTerminate(ctx context.Context, t *tool, fs *flag.FlagSet, args []string) error {
+	reason := fs.String("reason", "", "reason recorded in the history of the instance")
+
+	args, err := parseArgs(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	ref, err := t.instance(ctx, args[0])
+	if err != nil {
+		return err
+	}
+
+	return client.New(t.b).TerminateWorkflowInstance(ctx, ref.Instance, *reason)
+}
+
+var deleteCommand = &command{
+	name:  "delete",
+	args:  "<instance id>",
+	short: "Remove a finished workflow instance and its history from the backend",
+	run:   runDelete,
+}
+
+func runDelete(ctx context.Context, t *tool, fs *flag.FlagSet, args []string) error {
+	args, err := parseArgs(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	ref, err := t.instance(ctx, args[0])
+	if err != nil {
+		return err
+	}
+
+	if err := t.b.RemoveWorkflowInstance(ctx, ref.Instance); err != nil {
+		return fmt.Errorf("removing workflow instance: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,366 @@
+package wfctl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/paveliak/go-workflows/backend"
+	"github.com/paveliak/go-workflows/client"
+	"github.com/paveliak/go-workflows/diag"
+	"github.com/paveliak/go-workflows/internal/core"
+	"github.com/paveliak/go-workflows/internal/history"
+	"github.com/paveliak/go-workflows/replayer"
+	"github.com/google/uuid"
+)
+
+var listCommand = &command{
+	name:  "list",
+	short: "List workflow instances, newest first",
+	run:   runList,
+}
+
+func runList(ctx context.Context, t *tool, fs *flag.FlagSet, args []string) error {
+	var states stringsFlag
+	fs.Var(&states, "state", "only list instances in the given state, can be repeated: "+stateNames())
+	name := fs.String("name", "", "only list instances of the given workflow")
+	prefix := fs.String("prefix", "", "only list instances whose id starts with the given prefix")
+	createdAfter := fs.String("created-after", "", "only list instances created at or after the given time (RFC 3339)")
+	createdBefore := fs.String("created-before", "", "only list instances created before the given time (RFC 3339)")
+	completedAfter := fs.String("completed-after", "", "only list instances completed at or after the given time (RFC 3339)")
+	completedBefore := fs.String("completed-before", "", "only list instances completed before the given time (RFC 3339)")
+	after := fs.String("after", "", "list instances created before the instance with the given id, for paging")
+	count := fs.Int("count", backend.DefaultListCount, "maximum number of instances to list")
+	asJSON := fs.Bool("json", false, "print the instances as JSON")
+
+	if _, err := parseArgs(fs, args, 0, 0); err != nil {
+		return err
+	}
+
+	// Build the query the same way as the diagnostics API
+	values := url.Values{
+		"state": states,
+		"count": []string{strconv.Itoa(*count)},
+	}
+
+	for param, value := range map[string]string{
+		"name":             *name,
+		"prefix":           *prefix,
+		"created_after":    *createdAfter,
+		"created_before":   *createdBefore,
+		"completed_after":  *completedAfter,
+		"completed_before": *completedBefore,
+		"after":            *after,
+	} {
+		if value != "" {
+			values.Set(param, value)
+		}
+	}
+
+	query, err := diag.ParseQuery(values)
+	if err != nil {
+		return err
+	}
+
+	b, err := t.backend()
+	if err != nil {
+		return err
+	}
+
+	refs, err := b.QueryWorkflowInstances(ctx, query)
+	if err != nil {
+		return fmt.Errorf("listing workflow instances: %w", err)
+	}
+
+	if *asJSON {
+		if refs == nil {
+			refs = []*diag.WorkflowInstanceRef{}
+		}
+
+		return writeJSON(t.o.stdout, refs)
+	}
+
+	tw := tabwriter.NewWriter(t.o.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "INSTANCE ID\tWORKFLOW\tSTATE\tCREATED\tCOMPLETED")
+	for _, ref := range refs {
+		completedAt := "-"
+		if ref.CompletedAt != nil {
+			completedAt = ref.CompletedAt.Format(time.RFC3339)
+		}
+
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%v\n",
+			ref.Instance.InstanceID, ref.WorkflowName, ref.State, ref.CreatedAt.Format(time.RFC3339), completedAt)
+	}
+
+	return tw.Flush()
+}
+
+var historyCommand = &command{
+	name:  "history",
+	args:  "<instance id>",
+	short: "Show the history of a workflow instance",
+	run:   runHistory,
+}
+
+func runHistory(ctx context.Context, t *tool, fs *flag.FlagSet, args []string) error {
+	asJSON := fs.Bool("json", false, "print the history as a JSON array of events")
+
+	args, err := parseArgs(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	h, err := t.history(ctx, args[0])
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		return writeJSON(t.o.stdout, h)
+	}
+
+	tw := tabwriter.NewWriter(t.o.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SEQ\tTIME\tTYPE\tSCHEDULE ID\tDETAILS")
+	for _, event := range h {
+		scheduleEventID := ""
+		if event.ScheduleEventID != 0 {
+			scheduleEventID = strconv.FormatInt(event.ScheduleEventID, 10)
+		}
+
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%v\n",
+			event.SequenceID, event.Timestamp.Format(time.RFC3339Nano), event.Type, scheduleEventID, eventDetails(event))
+	}
+
+	return tw.Flush()
+}
+
+// eventDetails summarizes the attributes of an event for the history table
+func eventDetails(event history.Event) string {
+	switch a := event.Attributes.(type) {
+	case *history.ExecutionStartedAttributes:
+		return a.Name
+	case *history.ExecutionCompletedAttributes:
+		if a.Error != "" {
+			return "error: " + a.Error
+		}
+	case *history.ExecutionTerminatedAttributes:
+		return a.Reason
+	case *history.ExecutionResetAttributes:
+		return a.Reason
+	case *history.ActivityScheduledAttributes:
+		if a.Attempt > 0 {
+			return fmt.Sprintf("%v (attempt %v)", a.Name, a.Attempt+1)
+		}
+
+		return a.Name
+	case *history.ActivityFailedAttributes:
+		return "error: " + a.Reason
+	case *history.TimerScheduledAttributes:
+		return "at " + a.At.Format(time.RFC3339Nano)
+	case *history.SubWorkflowScheduledAttributes:
+		if a.SubWorkflowInstance != nil {
+			return fmt.Sprintf("%v (%v)", a.Name, a.SubWorkflowInstance.InstanceID)
+		}
+
+		return a.Name
+	case *history.SubWorkflowFailedAttributes:
+		return "error: " + a.Error
+	case *history.SignalReceivedAttributes:
+		return a.Name
+	case *history.SignalWorkflowAttributes:
+		return fmt.Sprintf("%v to %v", a.Name, a.InstanceID)
+	}
+
+	return ""
+}
+
+var exportCommand = &command{
+	name:  "export",
+	args:  "<instance id>",
+	short: "Export the history of a workflow instance as JSON lines, one event per line",
+	run:   runExport,
+}
+
+func runExport(ctx context.Context, t *tool, fs *flag.FlagSet, args []string) error {
+	out := fs.String("o", "", "write the history to the given file instead of stdout")
+
+	args, err := parseArgs(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	h, err := t.history(ctx, args[0])
+	if err != nil {
+		return err
+	}
+
+	if *out == "" {
+		return writeEvents(t.o.stdout, h)
+	}
+
+	f, err := os.Create(*out)
+	if err != nil {
+		return fmt.Errorf("creating export file: %w", err)
+	}
+
+	if err := writeEvents(f, h); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// writeEvents writes events as JSON lines, the format read by replayer.LoadHistory
+func writeEvents(w io.Writer, h []history.Event) error {
+	enc := json.NewEncoder(w)
+	for _, event := range h {
+		if err := enc.Encode(event); err != nil {
+			return fmt.Errorf("encoding event: %w", err)
+		}
+	}
+
+	return nil
+}
+
+var importCommand = &command{
+	name:  "import",
+	args:  "<history file>",
+	short: "Create a workflow instance with an exported history, in the state the history ended in, - reads the history from stdin",
+	run:   runImport,
+}
+
+func runImport(ctx context.Context, t *tool, fs *flag.FlagSet, args []string) error {
+	instanceID := fs.String("id", "", "id of the new instance, defaults to a random id")
+
+	args, err := parseArgs(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	h, err := t.loadHistory(args[0], replayer.LoadHistory)
+	if err != nil {
+		return err
+	}
+
+	if *instanceID == "" {
+		*instanceID = uuid.NewString()
+	}
+
+	b, err := t.backend()
+	if err != nil {
+		return err
+	}
+
+	instance, err := client.New(b).ImportWorkflowInstance(ctx, *instanceID, h)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(t.o.stdout, instance.InstanceID)
+
+	return nil
+}
+
+var restartCommand = &command{
+	name:  "restart",
+	args:  "<history file>",
+	short: "Start a new workflow instance with the workflow and inputs of an exported history, the workflow runs from the beginning, - reads the history from stdin",
+	run:   runRestart,
+}
+
+func runRestart(ctx context.Context, t *tool, fs *flag.FlagSet, args []string) error {
+	instanceID := fs.String("id", "", "id of the new instance, defaults to a random id")
+
+	args, err := parseArgs(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	h, err := t.loadHistory(args[0], replayer.LoadHistory)
+	if err != nil {
+		return err
+	}
+
+	var started *history.ExecutionStartedAttributes
+	for _, event := range h {
+		if a, ok := event.Attributes.(*history.ExecutionStartedAttributes); ok {
+			started = a
+			break
+		}
+	}
+
+	if started == nil {
+		return errors.New("history does not contain a WorkflowExecutionStarted event")
+	}
+
+	if *instanceID == "" {
+		*instanceID = uuid.NewString()
+	}
+
+	b, err := t.backend()
+	if err != nil {
+		return err
+	}
+
+	instance := core.NewWorkflowInstance(*instanceID, uuid.NewString())
+	startedEvent := history.NewPendingEvent(time.Now(), history.EventType_WorkflowExecutionStarted, started)
+	if err := b.CreateWorkflowInstance(ctx, instance, startedEvent); err != nil {
+		return fmt.Errorf("creating workflow instance: %w", err)
+	}
+
+	fmt.Fprintln(t.o.stdout, instance.InstanceID)
+
+	return nil
+}
+
+// history returns the history of the current execution of the given instance
+func (t *tool) history(ctx context.Context, instanceID string) ([]history.Event, error) {
+	ref, err := t.instance(ctx, instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	h, err := t.b.GetWorkflowInstanceHistory(ctx, ref.Instance, nil)
+	if err != nil {
+		return nil, fmt.Errorf("getting workflow instance history: %w", err)
+	}
+
+	return h, nil
+}
+
+// loadHistory reads a history from the given file, or from stdin for -
+func (t *tool) loadHistory(path string, load func(io.Reader) ([]history.Event, error)) ([]history.Event, error) {
+	r := t.o.stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("opening history: %w", err)
+		}
+		defer f.Close()
+
+		r = f
+	}
+
+	h, err := load(r)
+	if err != nil {
+		return nil, fmt.Errorf("loading history from %v: %w", path, err)
+	}
+
+	return h, nil
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(v)
+}
@@ -0,0 +1,89 @@
+package wfctl
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/paveliak/go-workflows/internal/history"
+	"github.com/paveliak/go-workflows/replayer"
+)
+
+var replayCommand = &command{
+	name:  "replay",
+	args:  "<history file...>",
+	short: "Replay histories against the workflows registered with the binary, - reads a history from stdin",
+	run:   runReplay,
+}
+
+func runReplay(ctx context.Context, t *tool, fs *flag.FlagSet, args []string) error {
+	fromDiag := fs.Bool("diag", false, "read responses of the diagnostics API (/api/{instanceID}) instead of exported histories")
+	instanceID := fs.String("instance", "", "replay the history of the given instance from the backend instead of files")
+
+	args, err := parseArgs(fs, args, 0, -1)
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 0 && *instanceID == "" {
+		fs.Usage()
+		return errUsage
+	}
+
+	if len(t.o.workflows) == 0 {
+		return errors.New("no workflows registered, build a binary that registers them with wfctl.WithWorkflows")
+	}
+
+	r := replayer.New(replayer.WithLogger(&cliLogger{w: t.o.stderr, verbose: t.verbose}))
+	for _, wf := range t.o.workflows {
+		if err := r.RegisterWorkflow(wf); err != nil {
+			return fmt.Errorf("registering workflow: %w", err)
+		}
+	}
+
+	load := replayer.LoadHistory
+	if *fromDiag {
+		load = replayer.LoadDiagHistory
+	}
+
+	histories := make(map[string][]history.Event)
+	names := make([]string, 0)
+
+	if *instanceID != "" {
+		h, err := t.history(ctx, *instanceID)
+		if err != nil {
+			return err
+		}
+
+		histories[*instanceID] = h
+		names = append(names, *instanceID)
+	}
+
+	for _, path := range args {
+		h, err := t.loadHistory(path, load)
+		if err != nil {
+			return err
+		}
+
+		histories[path] = h
+		names = append(names, path)
+	}
+
+	failed := 0
+	for _, name := range names {
+		if err := r.ReplayWorkflowHistory(ctx, histories[name]); err != nil {
+			failed++
+			fmt.Fprintf(t.o.stdout, "FAIL %v: %v\n", name, err)
+			continue
+		}
+
+		fmt.Fprintf(t.o.stdout, "ok   %v\n", name)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d histories failed to replay", failed, len(names))
+	}
+
+	return nil
+}
@@ -0,0 +1,365 @@
+// Package wfctl implements wfctl, a command-line tool for operating workflow instances in a backend.
+//
+// cmd/wfctl builds the tool without any workflows. To replay histories against workflow code, build a binary that
+// registers the workflows:
+//
+//	func main() {
+//		os.Exit(wfctl.Main(os.Args[1:], wfctl.WithWorkflows(Workflow1, Workflow2)))
+//	}
+package wfctl
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/paveliak/go-workflows/backend"
+	"github.com/paveliak/go-workflows/backend/mysql"
+	"github.com/paveliak/go-workflows/backend/redis"
+	"github.com/paveliak/go-workflows/backend/sqlite"
+	"github.com/paveliak/go-workflows/diag"
+	"github.com/paveliak/go-workflows/internal/core"
+	"github.com/paveliak/go-workflows/log"
+	"github.com/paveliak/go-workflows/workflow"
+	mysqldriver "github.com/go-sql-driver/mysql"
+	redisv8 "github.com/go-redis/redis/v8"
+)
+
+type options struct {
+	workflows []workflow.Workflow
+
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+type Option func(*options)
+
+// WithWorkflows registers workflows that histories are replayed against
+func WithWorkflows(workflows ...workflow.Workflow) Option {
+	return func(o *options) {
+		o.workflows = append(o.workflows, workflows...)
+	}
+}
+
+// WithIO configures the input and outputs of the tool, the default is to use os.Stdin, os.Stdout and os.Stderr
+func WithIO(stdin io.Reader, stdout, stderr io.Writer) Option {
+	return func(o *options) {
+		o.stdin = stdin
+		o.stdout = stdout
+		o.stderr = stderr
+	}
+}
+
+// errUsage is returned after the usage of a command has been printed because of invalid arguments
+var errUsage = errors.New("invalid arguments")
+
+// command is a wfctl sub-command
+type command struct {
+	name  string
+	args  string
+	short string
+
+	run func(ctx context.Context, t *tool, fs *flag.FlagSet, args []string) error
+}
+
+var commands = []*command{
+	listCommand,
+	historyCommand,
+	startCommand,
+	signalCommand,
+	cancelCommand,
+	terminateCommand,
+	deleteCommand,
+	exportCommand,
+	importCommand,
+	restartCommand,
+	replayCommand,
+}
+
+// tool holds the global flags and the backend of an invocation
+type tool struct {
+	o *options
+
+	sqlitePath    string
+	mysqlDSN      string
+	redisAddr     string
+	redisPassword string
+	verbose       bool
+
+	b diag.Backend
+}
+
+// Main runs wfctl with the given arguments, without the program name, and returns the exit code
+func Main(args []string, opts ...Option) int {
+	o := &options{
+		stdin:  os.Stdin,
+		stdout: os.Stdout,
+		stderr: os.Stderr,
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if err := run(context.Background(), o, args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+
+		if errors.Is(err, errUsage) {
+			return 2
+		}
+
+		fmt.Fprintln(o.stderr, "wfctl:", err)
+		return 1
+	}
+
+	return 0
+}
+
+func run(ctx context.Context, o *options, args []string) error {
+	t := &tool{o: o}
+
+	fs := flag.NewFlagSet("wfctl", flag.ContinueOnError)
+	fs.SetOutput(o.stderr)
+	fs.StringVar(&t.sqlitePath, "sqlite", "", "path of a sqlite database")
+	fs.StringVar(&t.mysqlDSN, "mysql", "", "MySQL data source name, e.g. user:password@tcp(localhost:3306)/workflows")
+	fs.StringVar(&t.redisAddr, "redis", "", "address of a Redis server, e.g. localhost:6379")
+	fs.StringVar(&t.redisPassword, "redis-password", "", "password of the Redis server")
+	fs.BoolVar(&t.verbose, "v", false, "log debug messages of the backend")
+	fs.Usage = func() {
+		w := fs.Output()
+		fmt.Fprintln(w, "Usage: wfctl [flags] <command> [command flags] [arguments]")
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "Commands:")
+		for _, c := range commands {
+			fmt.Fprintf(w, "  %-10s %s\n", c.name, c.short)
+		}
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "Flags:")
+		fs.PrintDefaults()
+	}
+
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errUsage
+	}
+
+	name := fs.Arg(0)
+	for _, c := range commands {
+		if c.name != name {
+			continue
+		}
+
+		cfs := flag.NewFlagSet("wfctl "+c.name, flag.ContinueOnError)
+		cfs.SetOutput(o.stderr)
+		cfs.Usage = func() {
+			w := cfs.Output()
+			fmt.Fprintf(w, "Usage: wfctl [flags] %s [command flags] %s\n\n%s\n", c.name, c.args, c.short)
+
+			hasFlags := false
+			cfs.VisitAll(func(*flag.Flag) { hasFlags = true })
+			if hasFlags {
+				fmt.Fprintln(w)
+				fmt.Fprintln(w, "Command flags:")
+				cfs.PrintDefaults()
+			}
+		}
+
+		err := c.run(ctx, t, cfs, fs.Args()[1:])
+
+		if c, ok := t.b.(io.Closer); ok {
+			if cerr := c.Close(); cerr != nil && err == nil {
+				err = fmt.Errorf("closing backend: %w", cerr)
+			}
+		}
+
+		return err
+	}
+
+	return fmt.Errorf("unknown command %q, run wfctl -h for a list of commands", name)
+}
+
+// backend connects to the backend given by the global flags
+func (t *tool) backend() (diag.Backend, error) {
+	if t.b != nil {
+		return t.b, nil
+	}
+
+	configured := 0
+	for _, v := range []string{t.sqlitePath, t.mysqlDSN, t.redisAddr} {
+		if v != "" {
+			configured++
+		}
+	}
+
+	if configured != 1 {
+		return nil, errors.New("exactly one of -sqlite, -mysql, or -redis is required")
+	}
+
+	opts := []backend.BackendOption{
+		backend.WithLogger(&cliLogger{w: t.o.stderr, verbose: t.verbose}),
+	}
+
+	switch {
+	case t.sqlitePath != "":
+		// Opening a database that does not exist would create an empty one
+		if _, err := os.Stat(t.sqlitePath); err != nil {
+			return nil, fmt.Errorf("opening sqlite database: %w", err)
+		}
+
+		t.b = sqlite.NewSqliteBackend(t.sqlitePath, opts...)
+
+	case t.mysqlDSN != "":
+		cfg, err := mysqldriver.ParseDSN(t.mysqlDSN)
+		if err != nil {
+			return nil, fmt.Errorf("parsing MySQL data source name: %w", err)
+		}
+
+		t.b = mysql.NewMysqlBackendWithConfig(cfg, opts...)
+
+	case t.redisAddr != "":
+		rclient := redisv8.NewUniversalClient(&redisv8.UniversalOptions{
+			Addrs:    []string{t.redisAddr},
+			Password: t.redisPassword,
+		})
+
+		rb, err := redis.NewRedisBackend(rclient, redis.WithBackendOptions(opts...))
+		if err != nil {
+			return nil, fmt.Errorf("connecting to Redis: %w", err)
+		}
+
+		t.b = rb
+	}
+
+	return t.b, nil
+}
+
+// instance returns the workflow instance with the given id
+func (t *tool) instance(ctx context.Context, instanceID string) (*diag.WorkflowInstanceRef, error) {
+	b, err := t.backend()
+	if err != nil {
+		return nil, err
+	}
+
+	ref, err := b.GetWorkflowInstance(ctx, instanceID)
+	if err != nil {
+		return nil, fmt.Errorf("getting workflow instance: %w", err)
+	}
+
+	if ref == nil {
+		return nil, fmt.Errorf("workflow instance %v not found", instanceID)
+	}
+
+	return ref, nil
+}
+
+// parseFlags parses flags, the flag package has already printed the error and usage for invalid flags
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+
+		return errUsage
+	}
+
+	return nil
+}
+
+// parseArgs parses the command flags and checks the number of remaining arguments
+func parseArgs(fs *flag.FlagSet, args []string, min, max int) ([]string, error) {
+	if err := parseFlags(fs, args); err != nil {
+		return nil, err
+	}
+
+	if fs.NArg() < min || (max >= 0 && fs.NArg() > max) {
+		fs.Usage()
+		return nil, errUsage
+	}
+
+	return fs.Args(), nil
+}
+
+// stringsFlag is a flag that can be given multiple times
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+func stateNames() string {
+	names := make([]string, 0)
+	for _, s := range core.WorkflowInstanceStates() {
+		names = append(names, strings.ToLower(s.String()))
+	}
+	sort.Strings(names)
+
+	return strings.Join(names, ", ")
+}
+
+// cliLogger writes log messages of the backend to stderr, debug messages only in verbose mode
+type cliLogger struct {
+	w       io.Writer
+	verbose bool
+	fields  []interface{}
+}
+
+var _ log.Logger = (*cliLogger)(nil)
+
+func (l *cliLogger) Debug(msg string, fields ...interface{}) {
+	if l.verbose {
+		l.log("DEBUG", msg, fields)
+	}
+}
+
+func (l *cliLogger) Warn(msg string, fields ...interface{}) {
+	l.log("WARN", msg, fields)
+}
+
+func (l *cliLogger) Error(msg string, fields ...interface{}) {
+	l.log("ERROR", msg, fields)
+}
+
+func (l *cliLogger) Panic(msg string, fields ...interface{}) {
+	l.log("PANIC", msg, fields)
+	panic(msg)
+}
+
+func (l *cliLogger) With(fields ...interface{}) log.Logger {
+	return &cliLogger{
+		w:       l.w,
+		verbose: l.verbose,
+		fields:  append(append([]interface{}{}, l.fields...), fields...),
+	}
+}
+
+func (l *cliLogger) log(level, msg string, fields []interface{}) {
+	var sb strings.Builder
+	sb.WriteString(level)
+	sb.WriteString(" ")
+	sb.WriteString(msg)
+
+	for _, f := range [][]interface{}{l.fields, fields} {
+		for i := 0; i+1 < len(f); i += 2 {
+			fmt.Fprintf(&sb, " %v=%v", f[i], f[i+1])
+		}
+	}
+
+	fmt.Fprintln(l.w, sb.String())
+}
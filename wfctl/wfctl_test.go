@@ -0,0 +1,159 @@
+package wfctl
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/paveliak/go-workflows/backend/sqlite"
+	"github.com/paveliak/go-workflows/client"
+	"github.com/paveliak/go-workflows/worker"
+	"github.com/paveliak/go-workflows/workflow"
+	"github.com/stretchr/testify/require"
+)
+
+func Greet(ctx workflow.Context, name string) (string, error) {
+	if _, err := workflow.ScheduleTimer(ctx, time.Millisecond).Get(ctx); err != nil {
+		return "", err
+	}
+
+	return "hello " + name, nil
+}
+
+// runWorkflow runs a worker until the given instance has finished. The worker is stopped before wfctl uses the
+// database again.
+func runWorkflow(t *testing.T, path, instanceID string) string {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	b := sqlite.NewSqliteBackend(path)
+	c := client.New(b)
+	w := worker.New(b, nil)
+	require.NoError(t, w.RegisterWorkflow(Greet))
+	require.NoError(t, w.Start(ctx))
+
+	ref, err := b.GetWorkflowInstance(ctx, instanceID)
+	require.NoError(t, err)
+	require.NotNil(t, ref)
+
+	r, err := client.GetWorkflowResult[string](ctx, c, ref.Instance, 10*time.Second)
+	require.NoError(t, err)
+
+	cancel()
+	require.NoError(t, w.WaitForCompletion())
+
+	return r
+}
+
+func Test_Wfctl(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "workflows.sqlite")
+
+	stdin := ""
+	run := func(args []string, opts ...Option) (int, string, string) {
+		var stdout, stderr bytes.Buffer
+		opts = append(opts, WithIO(strings.NewReader(stdin), &stdout, &stderr))
+
+		code := Main(append([]string{"-sqlite", path}, args...), opts...)
+		return code, stdout.String(), stderr.String()
+	}
+
+	code, _, stderr := run([]string{"list"})
+	require.Equal(t, 1, code)
+	require.Contains(t, stderr, "opening sqlite database")
+
+	// Create the database
+	sqlite.NewSqliteBackend(path)
+
+	code, _, stderr = run([]string{"start", "Greet", "not json"})
+	require.Equal(t, 1, code)
+	require.Contains(t, stderr, "not valid JSON")
+
+	code, stdout, _ := run([]string{"start", "-id", "greet-1", "Greet", `"gopher"`})
+	require.Equal(t, 0, code)
+	require.Equal(t, "greet-1\n", stdout)
+	require.Equal(t, "hello gopher", runWorkflow(t, path, "greet-1"))
+
+	code, stdout, _ = run([]string{"list", "-state", "completed", "-name", "Greet"})
+	require.Equal(t, 0, code)
+	require.Contains(t, stdout, "greet-1")
+	require.Contains(t, stdout, "Completed")
+
+	code, stdout, _ = run([]string{"list", "-state", "active"})
+	require.Equal(t, 0, code)
+	require.NotContains(t, stdout, "greet-1")
+
+	code, stdout, _ = run([]string{"history", "greet-1"})
+	require.Equal(t, 0, code)
+	require.Contains(t, stdout, "WorkflowExecutionStarted")
+	require.Contains(t, stdout, "TimerFired")
+
+	code, stdout, _ = run([]string{"history", "-json", "greet-1"})
+	require.Equal(t, 0, code)
+	require.True(t, strings.HasPrefix(stdout, "["))
+
+	// Export, replay, import and restart the history
+	exported := filepath.Join(dir, "greet-1.jsonl")
+	code, _, _ = run([]string{"export", "-o", exported, "greet-1"})
+	require.Equal(t, 0, code)
+
+	code, _, stderr = run([]string{"replay", exported})
+	require.Equal(t, 1, code)
+	require.Contains(t, stderr, "no workflows registered")
+
+	data, err := os.ReadFile(exported)
+	require.NoError(t, err)
+	stdin = string(data)
+
+	code, stdout, _ = run([]string{"replay", exported, "-"}, WithWorkflows(Greet))
+	require.Equal(t, 0, code)
+	require.Contains(t, stdout, "ok   "+exported)
+	require.Contains(t, stdout, "ok   -")
+
+	code, stdout, _ = run([]string{"replay", "-instance", "greet-1"}, WithWorkflows(Greet))
+	require.Equal(t, 0, code)
+	require.Contains(t, stdout, "ok   greet-1")
+
+	code, stdout, _ = run([]string{"import", "-id", "greet-imported", exported})
+	require.Equal(t, 0, code)
+	require.Equal(t, "greet-imported\n", stdout)
+
+	code, stdout, _ = run([]string{"list", "-state", "completed", "-prefix", "greet-imported"})
+	require.Equal(t, 0, code)
+	require.Contains(t, stdout, "greet-imported")
+
+	code, stdout, _ = run([]string{"export", "greet-imported"})
+	require.Equal(t, 0, code)
+	require.Equal(t, string(data), stdout)
+
+	code, _, stderr = run([]string{"import", "-id", "greet-imported", exported})
+	require.Equal(t, 1, code)
+	require.Contains(t, stderr, "already exists")
+
+	code, stdout, _ = run([]string{"restart", "-id", "greet-2", exported})
+	require.Equal(t, 0, code)
+	require.Equal(t, "greet-2\n", stdout)
+	require.Equal(t, "hello gopher", runWorkflow(t, path, "greet-2"))
+
+	// Actions
+	code, _, stderr = run([]string{"terminate", "-reason", "stuck", "greet-1"})
+	require.Equal(t, 1, code)
+	require.Contains(t, stderr, "finished")
+
+	code, _, _ = run([]string{"delete", "greet-1"})
+	require.Equal(t, 0, code)
+
+	code, _, stderr = run([]string{"history", "greet-1"})
+	require.Equal(t, 1, code)
+	require.Contains(t, stderr, "not found")
+
+	code, _, _ = run([]string{"signal", "greet-2"})
+	require.Equal(t, 2, code)
+
+	code, _, stderr = run([]string{"unknown"})
+	require.Equal(t, 1, code)
+	require.Contains(t, stderr, "unknown command")
+}
@@ -0,0 +1,30 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/paveliak/go-workflows/internal/converter"
+	workflowinternal "github.com/paveliak/go-workflows/internal/workflow"
+	"github.com/paveliak/go-workflows/workflow"
+)
+
+// StackTraceQuery is a built-in query returning the locations all coroutines of a workflow instance are blocked at
+const StackTraceQuery = workflowinternal.StackTraceQuery
+
+// QueryWorkflowInstance runs the given query against the workflow instance in the given worker and converts the
+// result to T.
+func QueryWorkflowInstance[T any](ctx context.Context, w Worker, instance *workflow.Instance, query string) (T, error) {
+	var r T
+
+	p, err := w.QueryWorkflowInstance(ctx, instance, query)
+	if err != nil {
+		return r, err
+	}
+
+	if err := converter.DefaultConverter.From(p, &r); err != nil {
+		return r, fmt.Errorf("converting query result: %w", err)
+	}
+
+	return r, nil
+}
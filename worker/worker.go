@@ -7,6 +7,8 @@ import (
 
 	"github.com/benbjohnson/clock"
 	"github.com/paveliak/go-workflows/backend"
+	"github.com/paveliak/go-workflows/internal/payload"
+	internalsync "github.com/paveliak/go-workflows/internal/sync"
 	internal "github.com/paveliak/go-workflows/internal/worker"
 	workflowinternal "github.com/paveliak/go-workflows/internal/workflow"
 	"github.com/paveliak/go-workflows/workflow"
@@ -30,12 +32,21 @@ type Worker interface {
 
 	// Start starts the worker.
 	//
-	// To stop the worker, cancel the context passed to Start. To wait for completion of the active
-	// work items, call `WaitForCompletion`.
+	// To stop the worker, call `Shutdown`. Canceling the context passed to Start stops polling for new
+	// work items, call `WaitForCompletion` to wait for completion of the active work items.
 	Start(ctx context.Context) error
 
-	// WaitForCompletion
+	// WaitForCompletion stops polling for new work items and waits until all active work items are done.
 	WaitForCompletion() error
+
+	// Shutdown stops polling for new work items and waits until all active work items are done. If ctx is done
+	// before that, running activities are canceled and the locks of all unfinished tasks are released in the
+	// backend, so that other workers can pick them up immediately. In that case ctx.Err() is returned.
+	Shutdown(ctx context.Context) error
+
+	// QueryWorkflowInstance runs the given query against the state of the workflow instance in this worker. See
+	// StackTraceQuery for the built-in queries.
+	QueryWorkflowInstance(ctx context.Context, instance *workflow.Instance, query string) (payload.Payload, error)
 }
 
 type worker struct {
@@ -57,6 +68,38 @@ type Options = internal.Options
 
 var DefaultWorkerOptions = internal.DefaultOptions
 
+// NonDeterminismError is returned when the workflow code does not produce the same commands as recorded in the
+// history of a workflow instance.
+type NonDeterminismError = workflowinternal.NonDeterminismError
+
+// DeadlockError is logged when workflow code does not yield within Options.WorkflowDeadlockTimeout.
+type DeadlockError = internalsync.DeadlockError
+
+// PanicError is logged when workflow code panics and Options.WorkflowPanicPolicy is PanicPolicyBlockWorkflow.
+type PanicError = internalsync.PanicError
+
+type NonDeterminismPolicy = workflowinternal.NonDeterminismPolicy
+
+const (
+	// NonDeterminismPolicyFailWorkflow fails workflow instances that are not deterministic when replaying their history
+	NonDeterminismPolicyFailWorkflow = workflowinternal.NonDeterminismPolicyFailWorkflow
+
+	// NonDeterminismPolicyBlockWorkflow leaves workflow tasks of instances that are not deterministic uncompleted,
+	// they are retried until the workflow code is fixed and redeployed
+	NonDeterminismPolicyBlockWorkflow = workflowinternal.NonDeterminismPolicyBlockWorkflow
+)
+
+type PanicPolicy = workflowinternal.PanicPolicy
+
+const (
+	// PanicPolicyFailWorkflow fails workflow instances when the workflow code panics
+	PanicPolicyFailWorkflow = workflowinternal.PanicPolicyFailWorkflow
+
+	// PanicPolicyBlockWorkflow abandons workflow tasks when the workflow code panics without changing the history,
+	// they are retried until the workflow code is fixed and redeployed
+	PanicPolicyBlockWorkflow = workflowinternal.PanicPolicyBlockWorkflow
+)
+
 func New(backend backend.Backend, options *Options) Worker {
 	if options == nil {
 		options = &internal.DefaultOptions
@@ -70,6 +113,18 @@ func New(backend backend.Backend, options *Options) Worker {
 		options.WorkflowExecutorCacheTTL = internal.DefaultOptions.WorkflowExecutorCacheTTL
 	}
 
+	if options.WorkflowDeadlockTimeout == 0 {
+		options.WorkflowDeadlockTimeout = internal.DefaultOptions.WorkflowDeadlockTimeout
+	}
+
+	if options.BackendRetries == 0 {
+		options.BackendRetries = internal.DefaultOptions.BackendRetries
+	}
+
+	if options.BackendRetryInterval == 0 {
+		options.BackendRetryInterval = internal.DefaultOptions.BackendRetryInterval
+	}
+
 	registry := workflowinternal.NewRegistry()
 
 	return &worker{
@@ -98,17 +153,33 @@ func (w *worker) Start(ctx context.Context) error {
 }
 
 func (w *worker) WaitForCompletion() error {
-	if err := w.workflowWorker.WaitForCompletion(); err != nil {
-		return err
+	return w.Shutdown(context.Background())
+}
+
+func (w *worker) Shutdown(ctx context.Context) error {
+	// Shut down both workers concurrently, they share the deadline of ctx
+	workflowErr := make(chan error, 1)
+	go func() {
+		workflowErr <- w.workflowWorker.Shutdown(ctx)
+	}()
+
+	activityErr := w.activityWorker.Shutdown(ctx)
+
+	if err := <-workflowErr; err != nil {
+		return fmt.Errorf("shutting down workflow worker: %w", err)
 	}
 
-	if err := w.activityWorker.WaitForCompletion(); err != nil {
-		return err
+	if activityErr != nil {
+		return fmt.Errorf("shutting down activity worker: %w", activityErr)
 	}
 
 	return nil
 }
 
+func (w *worker) QueryWorkflowInstance(ctx context.Context, instance *workflow.Instance, query string) (payload.Payload, error) {
+	return w.workflowWorker.QueryWorkflowInstance(ctx, instance, query)
+}
+
 func (w *worker) RegisterWorkflow(wf workflow.Workflow) error {
 	return w.registry.RegisterWorkflow(wf)
 }
@@ -17,6 +17,9 @@ import (
 
 type ActivityOptions struct {
 	RetryOptions RetryOptions
+
+	// Priority of the activity task, defaults to PriorityNormal
+	Priority Priority
 }
 
 var DefaultActivityOptions = ActivityOptions{
@@ -48,7 +51,7 @@ func executeActivity[TResult any](ctx Context, options ActivityOptions, attempt
 	scheduleEventID := wfState.GetNextScheduleEventID()
 
 	name := fn.Name(activity)
-	cmd := command.NewScheduleActivityCommand(scheduleEventID, name, inputs)
+	cmd := command.NewScheduleActivityCommand(scheduleEventID, name, inputs, attempt, options.Priority.Clamp())
 	wfState.AddCommand(cmd)
 	wfState.TrackFuture(scheduleEventID, workflowstate.AsDecodingSettable(f))
 
@@ -0,0 +1,13 @@
+package workflow
+
+import (
+	"github.com/paveliak/go-workflows/internal/sync"
+	"github.com/paveliak/go-workflows/internal/workflowstate"
+)
+
+// Memo returns the memo the workflow instance was created with. The memo is immutable, changes to the returned map
+// are not persisted.
+func Memo(ctx sync.Context) map[string]string {
+	wfState := workflowstate.WorkflowState(ctx)
+	return wfState.Memo().Clone()
+}
@@ -0,0 +1,15 @@
+package workflow
+
+import "github.com/paveliak/go-workflows/internal/core"
+
+// Priority determines the order in which workflow and activity tasks are picked up by workers. Tasks with a
+// higher priority are dequeued first, values outside of PriorityLowest and PriorityHighest are clamped.
+type Priority = core.Priority
+
+const (
+	PriorityLowest  = core.PriorityLowest
+	PriorityLow     = core.PriorityLow
+	PriorityNormal  = core.PriorityNormal
+	PriorityHigh    = core.PriorityHigh
+	PriorityHighest = core.PriorityHighest
+)
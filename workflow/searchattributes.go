@@ -0,0 +1,30 @@
+package workflow
+
+import (
+	"fmt"
+
+	"github.com/paveliak/go-workflows/internal/command"
+	"github.com/paveliak/go-workflows/internal/core"
+	"github.com/paveliak/go-workflows/internal/workflowstate"
+	"github.com/paveliak/go-workflows/internal/workflowtracer"
+)
+
+// UpsertSearchAttributes adds or updates search attributes of the workflow instance. Search attributes are indexed by
+// the backend and can be used to find instances with client.ListWorkflowInstances. Values are encoded as JSON.
+func UpsertSearchAttributes(ctx Context, attributes map[string]interface{}) error {
+	ctx, span := workflowtracer.Tracer(ctx).Start(ctx, "UpsertSearchAttributes")
+	defer span.End()
+
+	sa, err := core.NewSearchAttributes(attributes)
+	if err != nil {
+		return fmt.Errorf("converting search attributes: %w", err)
+	}
+
+	wfState := workflowstate.WorkflowState(ctx)
+	scheduleEventID := wfState.GetNextScheduleEventID()
+
+	cmd := command.NewUpsertSearchAttributesCommand(scheduleEventID, sa)
+	wfState.AddCommand(cmd)
+
+	return nil
+}
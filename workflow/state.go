@@ -0,0 +1,15 @@
+package workflow
+
+import "github.com/paveliak/go-workflows/internal/core"
+
+// InstanceState is the state of a workflow instance
+type InstanceState = core.WorkflowInstanceState
+
+const (
+	InstanceStateActive     = core.WorkflowInstanceStateActive
+	InstanceStateSuspended  = core.WorkflowInstanceStateSuspended
+	InstanceStateCompleted  = core.WorkflowInstanceStateCompleted
+	InstanceStateFailed     = core.WorkflowInstanceStateFailed
+	InstanceStateCanceled   = core.WorkflowInstanceStateCanceled
+	InstanceStateTerminated = core.WorkflowInstanceStateTerminated
+)
@@ -20,6 +20,16 @@ type SubWorkflowOptions struct {
 	InstanceID string
 
 	RetryOptions RetryOptions
+
+	// Priority of the sub-workflow's tasks, defaults to PriorityNormal
+	Priority Priority
+
+	// Memo of the sub-workflow instance. If PropagateMemo is set, it's merged into the memo of the parent workflow
+	// instance, overwriting existing keys.
+	Memo map[string]string
+
+	// PropagateMemo passes the memo of the parent workflow instance on to the sub-workflow instance
+	PropagateMemo bool
 }
 
 var (
@@ -71,7 +81,14 @@ func createSubWorkflowInstance[TResult any](ctx sync.Context, options SubWorkflo
 	metadata := &core.WorkflowMetadata{}
 	span.Marshal(metadata)
 
-	cmd := command.NewScheduleSubWorkflowCommand(scheduleEventID, wfState.Instance(), options.InstanceID, name, inputs, metadata)
+	var memo core.Memo
+	if options.PropagateMemo {
+		memo = wfState.Memo().Merge(options.Memo)
+	} else {
+		memo = core.Memo(options.Memo).Clone()
+	}
+
+	cmd := command.NewScheduleSubWorkflowCommand(scheduleEventID, wfState.Instance(), options.InstanceID, name, inputs, metadata, memo, options.Priority.Clamp())
 	wfState.AddCommand(cmd)
 	wfState.TrackFuture(scheduleEventID, workflowstate.AsDecodingSettable(f))
 